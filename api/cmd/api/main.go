@@ -11,9 +11,18 @@ import (
 	"time"
 
 	"github.com/dustinleblanc/go-bespin-api/internal/api"
+	"github.com/dustinleblanc/go-bespin-api/internal/auth"
+	"github.com/dustinleblanc/go-bespin-api/internal/batches"
 	"github.com/dustinleblanc/go-bespin-api/internal/database"
+	"github.com/dustinleblanc/go-bespin-api/internal/eventbus"
+	"github.com/dustinleblanc/go-bespin-api/internal/jobs"
+	"github.com/dustinleblanc/go-bespin-api/internal/jobtypes"
 	"github.com/dustinleblanc/go-bespin-api/internal/queue"
+	"github.com/dustinleblanc/go-bespin-api/internal/schedule"
 	"github.com/dustinleblanc/go-bespin-api/internal/webhook"
+	"github.com/dustinleblanc/go-bespin-api/internal/websocket"
+	"github.com/dustinleblanc/go-bespin-api/internal/workflows"
+	"github.com/dustinleblanc/go-bespin-contract/events"
 )
 
 func main() {
@@ -31,25 +40,150 @@ func main() {
 		redisAddr = "localhost:6379"
 	}
 
+	resultRetention := queue.DefaultResultRetention
+	if retention := os.Getenv("JOB_RESULT_RETENTION"); retention != "" {
+		d, err := time.ParseDuration(retention)
+		if err != nil {
+			logger.Fatalf("Invalid JOB_RESULT_RETENTION: %v", err)
+		}
+		resultRetention = d
+	}
+
 	// Connect to the database
 	db, err := database.NewConnection()
 	if err != nil {
 		logger.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	// Create webhook repository and service
+	sourceRefreshInterval := webhook.DefaultSourceRefreshInterval
+	if interval := os.Getenv("WEBHOOK_SOURCE_REFRESH_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			logger.Fatalf("Invalid WEBHOOK_SOURCE_REFRESH_INTERVAL: %v", err)
+		}
+		sourceRefreshInterval = d
+	}
+
+	// Create webhook repositories and service. Sources configured in the
+	// environment are stored on first start.
 	webhookRepo := webhook.NewGormRepository(db)
-	webhookService := webhook.NewService(webhookRepo)
+	webhookSources := webhook.NewSourceRegistry(webhook.NewGormSourceRepository(db), sourceRefreshInterval)
+	webhookService := webhook.NewService(webhookRepo, webhookSources)
+	if err := webhookService.SeedSources(context.Background(), webhook.SourcesFromEnv()); err != nil {
+		logger.Fatalf("Failed to seed webhook sources: %v", err)
+	}
+
+	// Create job repository and service
+	jobRepo := jobs.NewGormRepository(db)
+	jobService := jobs.NewService(jobRepo)
+
+	// Create schedule repository and service
+	scheduleRepo := schedule.NewGormRepository(db)
+	scheduleService := schedule.NewService(scheduleRepo)
+
+	// Create job event bus
+	bus := eventbus.NewRedisBus(redisAddr)
+	defer bus.Close()
 
 	// Create job queue
-	jobQueue, err := queue.NewAsynqQueue(redisAddr)
+	jobQueue, err := queue.NewAsynqQueue(redisAddr, resultRetention, bus, jobService)
 	if err != nil {
 		logger.Fatalf("Failed to create job queue: %v", err)
 	}
 	defer jobQueue.Close()
 
-	// Create router
-	router := api.NewRouter(jobQueue, webhookService)
+	// Job types accepted by POST /api/jobs, and the workers advertising them
+	jobTypes, err := jobtypes.NewContractRegistry(bus)
+	if err != nil {
+		logger.Fatalf("Failed to load job types: %v", err)
+	}
+
+	// Create workflow repository and service
+	workflowRepo := workflows.NewGormRepository(db)
+	workflowService := workflows.NewService(workflowRepo, jobQueue, jobTypes, jobService)
+
+	// Create batch repository and service
+	batchRepo := batches.NewGormRepository(db)
+	batchService := batches.NewService(batchRepo, jobQueue, jobService)
+
+	// Create WebSocket server
+	wsServer := websocket.NewServer()
+	go wsServer.Start()
+	defer wsServer.Stop()
+
+	// Feed job events published by the worker to WebSocket clients, the job
+	// history, the workflow runs and the batches, in that order so workflows
+	// and batches can read the job history
+	eventsCtx, stopEvents := context.WithCancel(context.Background())
+	defer stopEvents()
+	go func() {
+		err := bus.Subscribe(eventsCtx, func(event *events.JobEvent) {
+			wsServer.HandleJobEvent(event)
+			if err := jobService.HandleJobEvent(eventsCtx, event); err != nil {
+				logger.Printf("Failed to update job %s: %v", event.JobID, err)
+			}
+			if err := workflowService.HandleJobEvent(eventsCtx, event); err != nil {
+				logger.Printf("Failed to advance workflow of job %s: %v", event.JobID, err)
+			}
+			if err := batchService.HandleJobEvent(eventsCtx, event); err != nil {
+				logger.Printf("Failed to update batch of job %s: %v", event.JobID, err)
+			}
+		})
+		if err != nil {
+			logger.Printf("Job event subscription stopped: %v", err)
+		}
+	}()
+
+	// Check running workflow runs and batches against the job history, in
+	// case job events were missed or a batch callback failed to enqueue
+	reconcileInterval := batches.DefaultReconcileInterval
+	if interval := os.Getenv("RECONCILE_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			logger.Fatalf("Invalid RECONCILE_INTERVAL: %v", err)
+		}
+		reconcileInterval = d
+	}
+	go func() {
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-eventsCtx.Done():
+				return
+			case <-ticker.C:
+				if err := workflowService.Reconcile(eventsCtx); err != nil {
+					logger.Printf("Failed to reconcile workflow runs: %v", err)
+				}
+				if err := batchService.Reconcile(eventsCtx); err != nil {
+					logger.Printf("Failed to reconcile batches: %v", err)
+				}
+			}
+		}
+	}()
+
+	// Admin routes are only reachable with one of the configured tokens
+	adminTokens := auth.ParseTokens(os.Getenv("ADMIN_API_TOKENS"), auth.RoleAdmin)
+	if len(adminTokens) == 0 {
+		logger.Printf("ADMIN_API_TOKENS is not set, admin endpoints will reject every request")
+	}
+	authenticator := auth.NewTokenAuthenticator(adminTokens)
+
+	// Create router. Client IPs are only read from X-Forwarded-For on
+	// requests from the configured proxies.
+	router, err := api.NewRouter(api.Dependencies{
+		JobQueue:        jobQueue,
+		JobTypes:        jobTypes,
+		JobService:      jobService,
+		ScheduleService: scheduleService,
+		WebhookService:  webhookService,
+		WorkflowService: workflowService,
+		BatchService:    batchService,
+		WSServer:        wsServer,
+	}, authenticator, api.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES")))
+	if err != nil {
+		logger.Fatalf("Failed to create router: %v", err)
+	}
 
 	// Create server
 	srv := &http.Server{
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/internal/database"
+	"github.com/dustinleblanc/go-bespin-api/internal/queue"
+	"github.com/dustinleblanc/go-bespin-api/internal/schedule"
+	"github.com/hibiken/asynq"
+)
+
+// defaultSyncInterval is how often schedules are reloaded from the database
+const defaultSyncInterval = 30 * time.Second
+
+func main() {
+	// Initialize logger
+	logger := log.New(os.Stdout, "[Scheduler] ", log.LstdFlags)
+
+	// Get environment variables
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" {
+		redisAddr = "localhost:6379"
+	}
+
+	syncInterval := parseDuration(logger, "SCHEDULE_SYNC_INTERVAL", defaultSyncInterval)
+	resultRetention := parseDuration(logger, "JOB_RESULT_RETENTION", queue.DefaultResultRetention)
+
+	// Connect to the database
+	db, err := database.NewConnection()
+	if err != nil {
+		logger.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// Enqueue the enabled schedules, reloading them every sync interval
+	provider := schedule.NewConfigProvider(schedule.NewGormRepository(db), resultRetention)
+	mgr, err := asynq.NewPeriodicTaskManager(asynq.PeriodicTaskManagerOpts{
+		RedisConnOpt:               asynq.RedisClientOpt{Addr: redisAddr},
+		PeriodicTaskConfigProvider: provider,
+		SyncInterval:               syncInterval,
+	})
+	if err != nil {
+		logger.Fatalf("Failed to create periodic task manager: %v", err)
+	}
+
+	logger.Printf("Starting scheduler, syncing schedules every %s", syncInterval)
+
+	// Run blocks until the process receives a termination signal
+	if err := mgr.Run(); err != nil {
+		logger.Fatalf("Scheduler stopped: %v", err)
+	}
+}
+
+// parseDuration reads a duration from an environment variable
+func parseDuration(logger *log.Logger, name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Fatalf("Invalid %s: %v", name, err)
+	}
+	return d
+}
@@ -1,36 +1,214 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dustinleblanc/go-bespin-api/internal/batches"
+	"github.com/dustinleblanc/go-bespin-api/internal/jobs"
+	"github.com/dustinleblanc/go-bespin-api/internal/jobtypes"
+	"github.com/dustinleblanc/go-bespin-api/internal/pagination"
 	"github.com/dustinleblanc/go-bespin-api/internal/queue"
+	"github.com/dustinleblanc/go-bespin-api/internal/schedule"
 	"github.com/dustinleblanc/go-bespin-api/internal/webhook"
 	"github.com/dustinleblanc/go-bespin-api/internal/websocket"
+	"github.com/dustinleblanc/go-bespin-api/internal/workflows"
 	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
 	"github.com/gin-gonic/gin"
 )
 
 // Handlers contains the HTTP handlers for the API
 type Handlers struct {
-	jobQueue       queue.Queue
-	webhookService webhook.WebhookService
-	wsServer       *websocket.Server
+	jobQueue        queue.Queue
+	jobTypes        *jobtypes.Registry
+	jobService      jobs.JobService
+	scheduleService schedule.ScheduleService
+	webhookService  webhook.WebhookService
+	workflowService workflows.WorkflowService
+	batchService    batches.BatchService
+	wsServer        *websocket.Server
+}
+
+// Dependencies are the queue and services the handlers use
+type Dependencies struct {
+	JobQueue        queue.Queue
+	JobTypes        *jobtypes.Registry
+	JobService      jobs.JobService
+	ScheduleService schedule.ScheduleService
+	WebhookService  webhook.WebhookService
+	WorkflowService workflows.WorkflowService
+	BatchService    batches.BatchService
+	WSServer        *websocket.Server
 }
 
 // NewHandlers creates a new Handlers instance
-func NewHandlers(jobQueue queue.Queue, webhookService webhook.WebhookService) *Handlers {
-	return &Handlers{
-		jobQueue:       jobQueue,
-		webhookService: webhookService,
-		wsServer:       websocket.NewServer(),
+func NewHandlers(deps Dependencies) *Handlers {
+	h := &Handlers{
+		jobQueue:        deps.JobQueue,
+		jobTypes:        deps.JobTypes,
+		jobService:      deps.JobService,
+		scheduleService: deps.ScheduleService,
+		webhookService:  deps.WebhookService,
+		workflowService: deps.WorkflowService,
+		batchService:    deps.BatchService,
+		wsServer:        deps.WSServer,
+	}
+
+	// Let WebSocket clients cancel the job they are subscribed to
+	h.wsServer.HandleCancel(h.jobQueue.CancelJob)
+
+	return h
+}
+
+// HandleSubmitJob handles requests to submit a job of any registered type.
+// The job data is validated against the type's JSON Schema and every invalid
+// field is reported with 422. Follow-up jobs are validated the same way,
+// except for data that references the parent job, which is only known once
+// the parent has finished.
+func (h *Handlers) HandleSubmitJob(c *gin.Context) {
+	var req models.JobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	job, fieldErrs, err := h.buildJob(c, &req, "", false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to validate job: %v", err)})
+		return
+	}
+	if job.IdempotencyKey == "" {
+		job.IdempotencyKey = c.GetHeader("Idempotency-Key")
+	}
+
+	if len(fieldErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "validation failed", "errors": fieldErrs})
+		return
+	}
+
+	jobID, err := h.jobQueue.AddJob(c.Request.Context(), job)
+	if err != nil {
+		switch {
+		case errors.Is(err, queue.ErrUnknownQueue), errors.Is(err, queue.ErrInvalidSchedule),
+			errors.Is(err, queue.ErrInvalidRetry), errors.Is(err, queue.ErrInvalidChain):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add job to queue"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": jobID,
+		"status": "queued",
+	})
+}
+
+// buildJob validates a submitted job and its follow-up jobs and converts them
+// to a job. Field errors are named after their path in the request body,
+// starting with prefix. Follow-up jobs may reference their parent in their
+// data and cannot be scheduled or deduplicated.
+func (h *Handlers) buildJob(c *gin.Context, req *models.JobRequest, prefix string, followUp bool) (*models.Job, []models.FieldError, error) {
+	if len(req.Data) == 0 {
+		req.Data = json.RawMessage("{}")
+	}
+
+	var fieldErrs []models.FieldError
+	invalid := func(field, message string) {
+		fieldErrs = append(fieldErrs, models.FieldError{Field: prefix + field, Message: message})
+	}
+
+	switch _, known := h.jobTypes.Lookup(req.Type); {
+	case req.Type == "":
+		invalid("type", "is required")
+	case !known:
+		invalid("type", "is not a registered job type")
+	case followUp && tasks.HasTemplates(req.Data):
+		// Data referencing the parent job is validated by the worker once
+		// rendered, which fails the follow-up job if it does not match the schema
+	default:
+		if err := h.jobTypes.Validate(req.Type, req.Data); err != nil {
+			var validationErr *jobtypes.ValidationError
+			if !errors.As(err, &validationErr) {
+				return nil, nil, err
+			}
+			for _, fieldErr := range validationErr.Errors {
+				invalid(fieldErr.Field, fieldErr.Message)
+			}
+		}
+	}
+
+	job := &models.Job{Type: req.Type, Data: req.Data}
+	for _, fieldErr := range req.Options.Apply(job) {
+		invalid(fieldErr.Field, fieldErr.Message)
+	}
+	if followUp {
+		if job.ProcessAt != nil || job.ProcessIn != 0 {
+			invalid("options", "follow-up jobs cannot be scheduled")
+		}
+		if job.IdempotencyKey != "" || job.UniqueFor != 0 {
+			invalid("options", "follow-up jobs cannot be deduplicated")
+		}
+	}
+
+	// Without a queue, use the one the workers advertise for the job type
+	if job.Queue == "" && len(fieldErrs) == 0 {
+		queueName, err := h.jobTypes.DefaultQueue(c.Request.Context(), job.Type)
+		if err != nil {
+			log.Printf("Failed to look up the default queue of %s jobs: %v", job.Type, err)
+		}
+		job.Queue = queueName
+	}
+
+	followUps := []struct {
+		trigger string
+		req     *models.JobRequest
+		job     **models.Job
+	}{
+		{tasks.TriggerOnSuccess, req.OnSuccess, &job.OnSuccess},
+		{tasks.TriggerOnFailure, req.OnFailure, &job.OnFailure},
+	}
+	for _, next := range followUps {
+		if next.req == nil {
+			continue
+		}
+		child, childErrs, err := h.buildJob(c, next.req, prefix+next.trigger+".", true)
+		if err != nil {
+			return nil, nil, err
+		}
+		fieldErrs = append(fieldErrs, childErrs...)
+		*next.job = child
+	}
+
+	return job, fieldErrs, nil
+}
+
+// HandleListJobTypes handles requests to list the job types that can be
+// submitted, flagging those no live worker can run
+func (h *Handlers) HandleListJobTypes(c *gin.Context) {
+	statuses, err := h.jobTypes.Discover(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to list job types: %v", err)})
+		return
 	}
+
+	c.JSON(http.StatusOK, gin.H{"job_types": statuses})
 }
 
-// HandleRandomText handles requests to generate random text
+// HandleRandomText handles requests to generate random text. It predates
+// POST /api/jobs and is kept for existing clients.
 func (h *Handlers) HandleRandomText(c *gin.Context) {
+	c.Header("Deprecation", "true")
+	c.Header("Link", `</api/jobs>; rel="successor-version"`)
+
 	// Get the length parameter from the query string
 	lengthStr := c.DefaultQuery("length", "100")
 	length, err := strconv.Atoi(lengthStr)
@@ -39,12 +217,39 @@ func (h *Handlers) HandleRandomText(c *gin.Context) {
 		return
 	}
 
+	// Get the optional priority queue
+	queueName := c.Query("queue")
+	if queueName != "" && !tasks.IsKnownQueue(queueName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid queue parameter: must be one of %s", strings.Join(tasks.Queues(), ", "))})
+		return
+	}
+
 	// Create a new job
 	job := &models.Job{
 		Type: models.JobTypeRandomText,
 		Data: models.RandomTextJobData{
 			Length: length,
 		},
+		Queue:          queueName,
+		IdempotencyKey: c.GetHeader("Idempotency-Key"),
+	}
+
+	// Get the optional uniqueness window, schedule and retry options
+	if value := c.Query("unique_for"); value != "" {
+		uniqueFor, err := time.ParseDuration(value)
+		if err != nil || uniqueFor <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid unique_for parameter: must be a positive duration such as 10m"})
+			return
+		}
+		job.UniqueFor = uniqueFor
+	}
+	if err := parseSchedule(c, job); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := parseRetryOptions(c, job); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Add the job to the queue
@@ -60,6 +265,87 @@ func (h *Handlers) HandleRandomText(c *gin.Context) {
 	})
 }
 
+// parseSchedule reads the process_at and process_in query parameters into job
+func parseSchedule(c *gin.Context, job *models.Job) error {
+	processAt := c.Query("process_at")
+	processIn := c.Query("process_in")
+
+	if processAt != "" && processIn != "" {
+		return errors.New("process_at and process_in are mutually exclusive")
+	}
+
+	if processAt != "" {
+		t, err := time.Parse(time.RFC3339, processAt)
+		if err != nil {
+			return errors.New("invalid process_at parameter: must be an RFC 3339 timestamp")
+		}
+		job.ProcessAt = &t
+	}
+
+	if processIn != "" {
+		d, err := time.ParseDuration(processIn)
+		if err != nil || d < 0 {
+			return errors.New("invalid process_in parameter: must be a non-negative duration such as 30s or 5m")
+		}
+		job.ProcessIn = d
+	}
+
+	return nil
+}
+
+// parseRetryOptions reads the max_retries, timeout, deadline, backoff,
+// backoff_delay and backoff_max_delay query parameters into job
+func parseRetryOptions(c *gin.Context, job *models.Job) error {
+	if value := c.Query("max_retries"); value != "" {
+		maxRetries, err := strconv.Atoi(value)
+		if err != nil || maxRetries < 0 {
+			return errors.New("invalid max_retries parameter: must be a non-negative integer")
+		}
+		job.MaxRetries = &maxRetries
+	}
+
+	if value := c.Query("timeout"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return errors.New("invalid timeout parameter: must be a positive duration such as 30s or 5m")
+		}
+		job.Timeout = timeout
+	}
+
+	if value := c.Query("deadline"); value != "" {
+		deadline, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New("invalid deadline parameter: must be an RFC 3339 timestamp")
+		}
+		job.Deadline = &deadline
+	}
+
+	if strategy := c.Query("backoff"); strategy != "" {
+		backoff := &tasks.Backoff{Strategy: tasks.BackoffStrategy(strategy)}
+
+		delay, err := time.ParseDuration(c.Query("backoff_delay"))
+		if err != nil {
+			return errors.New("invalid backoff_delay parameter: must be a duration such as 10s")
+		}
+		backoff.Delay = delay
+
+		if value := c.Query("backoff_max_delay"); value != "" {
+			maxDelay, err := time.ParseDuration(value)
+			if err != nil {
+				return errors.New("invalid backoff_max_delay parameter: must be a duration such as 10m")
+			}
+			backoff.MaxDelay = maxDelay
+		}
+
+		if err := backoff.Validate(); err != nil {
+			return fmt.Errorf("invalid backoff: %w", err)
+		}
+		job.Backoff = backoff
+	}
+
+	return nil
+}
+
 // HandleWebhook handles incoming webhook requests
 func (h *Handlers) HandleWebhook(c *gin.Context) {
 	// Get the source from the URL parameter
@@ -69,20 +355,6 @@ func (h *Handlers) HandleWebhook(c *gin.Context) {
 		return
 	}
 
-	// Get the event from the header
-	event := c.GetHeader("X-Event-Type")
-	if event == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Event-Type header is required"})
-		return
-	}
-
-	// Get the signature from the header
-	signature := c.GetHeader("X-Signature")
-	if signature == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Signature header is required"})
-		return
-	}
-
 	// Read the request body
 	payload, err := io.ReadAll(c.Request.Body)
 	if err != nil {
@@ -90,23 +362,44 @@ func (h *Handlers) HandleWebhook(c *gin.Context) {
 		return
 	}
 
-	// Create a webhook receipt
-	receipt, err := h.webhookService.CreateReceipt(c.Request.Context(), source, event, payload, signature)
-	if err != nil {
-		if err.Error() == fmt.Sprintf("invalid source: %s", source) {
+	// Verify the delivery with the source's signature scheme and store it
+	receipt, err := h.webhookService.CreateReceipt(c.Request.Context(), source, c.Request.Header, payload, c.ClientIP())
+	duplicate := errors.Is(err, webhook.ErrDuplicateDelivery)
+	if err != nil && !duplicate {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidSource):
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
+		case errors.Is(err, webhook.ErrIPNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, webhook.ErrMissingSignature), errors.Is(err, webhook.ErrInvalidSignature):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Create a new job
+	// Redeliveries of a receipt that was processed already are acknowledged
+	// with the original receipt ID
+	if duplicate && !receipt.IsPending() {
+		c.JSON(http.StatusOK, gin.H{
+			"receipt_id": receipt.ID,
+			"status":     "duplicate",
+		})
+		return
+	}
+
+	// Create a new job. Receipts are processed by a single job, so a
+	// redelivery of a pending receipt gets the job of the original delivery,
+	// or queues it if that failed.
 	job := &models.Job{
 		Type: models.JobTypeProcessWebhook,
 		Data: models.WebhookJobData{
 			ReceiptID: receipt.ID,
+			Source:    receipt.Source,
+			Event:     receipt.Event,
 		},
+		IdempotencyKey: "webhook-receipt:" + receipt.ID,
 	}
 
 	// Add the job to the queue
@@ -116,6 +409,15 @@ func (h *Handlers) HandleWebhook(c *gin.Context) {
 		return
 	}
 
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{
+			"job_id":     jobID,
+			"receipt_id": receipt.ID,
+			"status":     "duplicate",
+		})
+		return
+	}
+
 	c.JSON(http.StatusAccepted, gin.H{
 		"job_id":     jobID,
 		"receipt_id": receipt.ID,
@@ -123,6 +425,208 @@ func (h *Handlers) HandleWebhook(c *gin.Context) {
 	})
 }
 
+// HandleListWebhookReceipts handles requests to list and search webhook
+// receipts
+func (h *Handlers) HandleListWebhookReceipts(c *gin.Context) {
+	filter := webhook.ReceiptFilter{
+		Source: c.Query("source"),
+		Event:  c.Query("event"),
+		Status: models.WebhookStatus(c.Query("status")),
+	}
+
+	// Parse the created-at range
+	for param, target := range map[string]**time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+	} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s parameter: must be an RFC 3339 timestamp", param)})
+			return
+		}
+		*target = &t
+	}
+
+	// Parse pagination
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cursor, err := pagination.DecodeCursor(cursorStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor parameter"})
+			return
+		}
+		filter.Cursor = cursor
+	}
+
+	page, err := h.webhookService.ListReceipts(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to list webhook receipts: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// HandleGetWebhookReceipt handles requests to inspect a webhook receipt with
+// its headers, decoded payload and processing history
+func (h *Handlers) HandleGetWebhookReceipt(c *gin.Context) {
+	detail, err := h.webhookService.GetReceiptDetail(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, webhook.ErrReceiptNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook receipt not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get webhook receipt: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// HandleCreateWebhookSource handles requests to create a webhook source
+func (h *Handlers) HandleCreateWebhookSource(c *gin.Context) {
+	var req models.WebhookSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	source, err := h.webhookService.CreateSource(c.Request.Context(), &req)
+	if err != nil {
+		h.writeWebhookSourceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, source)
+}
+
+// HandleListWebhookSources handles requests to list webhook sources
+func (h *Handlers) HandleListWebhookSources(c *gin.Context) {
+	sources, err := h.webhookService.ListSources(c.Request.Context())
+	if err != nil {
+		h.writeWebhookSourceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sources": sources})
+}
+
+// HandleGetWebhookSource handles requests to get a webhook source
+func (h *Handlers) HandleGetWebhookSource(c *gin.Context) {
+	source, err := h.webhookService.GetSource(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		h.writeWebhookSourceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+// HandleUpdateWebhookSource handles requests to update a webhook source.
+// Only the fields present in the request body are changed.
+func (h *Handlers) HandleUpdateWebhookSource(c *gin.Context) {
+	var req models.WebhookSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	source, err := h.webhookService.UpdateSource(c.Request.Context(), c.Param("name"), &req)
+	if err != nil {
+		h.writeWebhookSourceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+// HandleDeleteWebhookSource handles requests to delete a webhook source
+func (h *Handlers) HandleDeleteWebhookSource(c *gin.Context) {
+	if err := h.webhookService.DeleteSource(c.Request.Context(), c.Param("name")); err != nil {
+		h.writeWebhookSourceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleAddWebhookSecret handles requests to add a secret to a webhook source
+func (h *Handlers) HandleAddWebhookSecret(c *gin.Context) {
+	var req models.WebhookSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	source, err := h.webhookService.AddSecret(c.Request.Context(), c.Param("name"), &req)
+	if err != nil {
+		h.writeWebhookSourceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, source)
+}
+
+// HandlePromoteWebhookSecret handles requests to make a secret the primary
+// secret of a webhook source, optionally expiring the previous one
+func (h *Handlers) HandlePromoteWebhookSecret(c *gin.Context) {
+	var req models.WebhookSecretRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	source, err := h.webhookService.PromoteSecret(c.Request.Context(), c.Param("name"), c.Param("id"), &req)
+	if err != nil {
+		h.writeWebhookSourceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+// HandleRetireWebhookSecret handles requests to remove a secondary secret
+// from a webhook source
+func (h *Handlers) HandleRetireWebhookSecret(c *gin.Context) {
+	source, err := h.webhookService.RetireSecret(c.Request.Context(), c.Param("name"), c.Param("id"))
+	if err != nil {
+		h.writeWebhookSourceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+// writeWebhookSourceError maps webhook source errors to HTTP responses
+func (h *Handlers) writeWebhookSourceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrSourceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook source not found"})
+	case errors.Is(err, webhook.ErrSecretNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook secret not found"})
+	case errors.Is(err, webhook.ErrInvalidSourceConfig):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, webhook.ErrDuplicateSource):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to manage webhook source: %v", err)})
+	}
+}
+
 // HandleGetJobResult handles requests to get a job result
 func (h *Handlers) HandleGetJobResult(c *gin.Context) {
 	// Get the job ID from the URL parameter
@@ -139,14 +643,133 @@ func (h *Handlers) HandleGetJobResult(c *gin.Context) {
 		return
 	}
 
+	// Fall back to the job history once the task has expired from Redis
 	if result == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
-		return
+		record, err := h.jobService.GetJob(c.Request.Context(), jobID)
+		if err != nil {
+			if errors.Is(err, jobs.ErrJobNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get job result: %v", err)})
+			return
+		}
+		result = record.ToResult()
+	}
+
+	// Link the job to its parent and follow-up jobs from the job history
+	if err := h.linkJob(c, result); err != nil {
+		log.Printf("Failed to look up related jobs of job %s: %v", jobID, err)
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
+// linkJob sets the parent and follow-up jobs of a job result
+func (h *Handlers) linkJob(c *gin.Context, result *models.JobResult) error {
+	if result.ParentID == "" {
+		record, err := h.jobService.GetJob(c.Request.Context(), result.ID)
+		if err != nil && !errors.Is(err, jobs.ErrJobNotFound) {
+			return err
+		}
+		if record != nil {
+			result.ParentID = record.ParentID
+		}
+	}
+
+	page, err := h.jobService.ListJobs(c.Request.Context(), jobs.ListFilter{ParentID: result.ID, Limit: jobs.MaxListLimit})
+	if err != nil {
+		return err
+	}
+	for _, child := range page.Jobs {
+		result.Children = append(result.Children, models.JobLink{ID: child.ID, Type: child.Type, Status: child.Status})
+	}
+	return nil
+}
+
+// HandleListJobs handles requests to list and search the job history
+func (h *Handlers) HandleListJobs(c *gin.Context) {
+	filter := jobs.ListFilter{
+		Type:     models.JobType(c.Query("type")),
+		Status:   models.JobStatus(c.Query("status")),
+		Queue:    c.Query("queue"),
+		ParentID: c.Query("parent_id"),
+		BatchID:  c.Query("batch_id"),
+	}
+
+	// Parse the created-at range
+	for param, target := range map[string]**time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+	} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s parameter: must be an RFC 3339 timestamp", param)})
+			return
+		}
+		*target = &t
+	}
+
+	// Parse pagination
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cursor, err := pagination.DecodeCursor(cursorStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor parameter"})
+			return
+		}
+		filter.Cursor = cursor
+	}
+
+	page, err := h.jobService.ListJobs(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to list jobs: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// HandleCancelJob handles requests to cancel a job
+func (h *Handlers) HandleCancelJob(c *gin.Context) {
+	// Get the job ID from the URL parameter
+	jobID := c.Param("id")
+	if jobID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "job ID is required"})
+		return
+	}
+
+	// Cancel the job
+	if err := h.jobQueue.CancelJob(c.Request.Context(), jobID); err != nil {
+		switch {
+		case errors.Is(err, queue.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		case errors.Is(err, queue.ErrJobNotCancellable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to cancel job: %v", err)})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job_id": jobID,
+		"status": models.JobStatusCancelled,
+	})
+}
+
 // HandleWebSocket handles WebSocket connections
 func (h *Handlers) HandleWebSocket(c *gin.Context) {
 	// Get the job ID from the query string
@@ -175,3 +798,403 @@ func (h *Handlers) HandleHealthCheck(c *gin.Context) {
 func (h *Handlers) NotifyJobStatus(jobID string, status string, result interface{}) {
 	h.wsServer.NotifyJobStatus(jobID, status, result)
 }
+
+// HandleCreateSchedule handles requests to create a recurring job schedule
+func (h *Handlers) HandleCreateSchedule(c *gin.Context) {
+	var req models.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	s, err := h.scheduleService.CreateSchedule(c.Request.Context(), &req)
+	if err != nil {
+		h.writeScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, s)
+}
+
+// HandleListSchedules handles requests to list recurring job schedules
+func (h *Handlers) HandleListSchedules(c *gin.Context) {
+	schedules, err := h.scheduleService.ListSchedules(c.Request.Context())
+	if err != nil {
+		h.writeScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+// HandleGetSchedule handles requests to get a recurring job schedule
+func (h *Handlers) HandleGetSchedule(c *gin.Context) {
+	s, err := h.scheduleService.GetSchedule(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.writeScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+// HandleUpdateSchedule handles requests to update a recurring job schedule.
+// Only the fields present in the request body are changed.
+func (h *Handlers) HandleUpdateSchedule(c *gin.Context) {
+	var req models.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	s, err := h.scheduleService.UpdateSchedule(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		h.writeScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+// HandleDeleteSchedule handles requests to delete a recurring job schedule
+func (h *Handlers) HandleDeleteSchedule(c *gin.Context) {
+	if err := h.scheduleService.DeleteSchedule(c.Request.Context(), c.Param("id")); err != nil {
+		h.writeScheduleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// writeScheduleError maps schedule service errors to HTTP responses
+func (h *Handlers) writeScheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, schedule.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+	case errors.Is(err, schedule.ErrInvalidSchedule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, schedule.ErrDuplicateName):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to manage schedule: %v", err)})
+	}
+}
+
+// HandleCreateWorkflow handles requests to start a workflow run
+func (h *Handlers) HandleCreateWorkflow(c *gin.Context) {
+	var req models.WorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	run, err := h.workflowService.CreateWorkflow(c.Request.Context(), &req)
+	if err != nil {
+		var validationErr *workflows.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "validation failed", "errors": validationErr.Errors})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create workflow: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, run)
+}
+
+// HandleGetWorkflow handles requests to get a workflow run and the status of its steps
+func (h *Handlers) HandleGetWorkflow(c *gin.Context) {
+	run, err := h.workflowService.GetWorkflow(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, workflows.ErrWorkflowNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "workflow not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get workflow: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// HandleCreateBatch handles requests to submit a batch of jobs. Every job is
+// validated like a job submitted to POST /api/jobs and the callback like a
+// follow-up job, and every invalid field is reported with 422. If a job fails
+// to enqueue, the jobs already enqueued are cancelled.
+func (h *Handlers) HandleCreateBatch(c *gin.Context) {
+	var req models.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	var fieldErrs []models.FieldError
+	switch {
+	case len(req.Jobs) == 0:
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "jobs", Message: "must contain at least one job"})
+	case len(req.Jobs) > batches.MaxJobs:
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "jobs", Message: fmt.Sprintf("must contain at most %d jobs", batches.MaxJobs)})
+	}
+
+	batchJobs := make([]*models.Job, 0, len(req.Jobs))
+	for i := range req.Jobs {
+		prefix := fmt.Sprintf("jobs[%d].", i)
+		job, jobErrs, err := h.buildJob(c, &req.Jobs[i], prefix, false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to validate job: %v", err)})
+			return
+		}
+		fieldErrs = append(fieldErrs, jobErrs...)
+		if job.IdempotencyKey != "" || job.UniqueFor != 0 {
+			fieldErrs = append(fieldErrs, models.FieldError{Field: prefix + "options", Message: "jobs in a batch cannot be deduplicated"})
+		}
+		batchJobs = append(batchJobs, job)
+	}
+
+	var callback *models.Job
+	if req.Callback != nil {
+		var callbackErrs []models.FieldError
+		var err error
+		callback, callbackErrs, err = h.buildJob(c, req.Callback, "callback.", true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to validate job: %v", err)})
+			return
+		}
+		fieldErrs = append(fieldErrs, callbackErrs...)
+	}
+
+	if len(fieldErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "validation failed", "errors": fieldErrs})
+		return
+	}
+
+	batch, err := h.batchService.CreateBatch(c.Request.Context(), req.Name, batchJobs, callback)
+	if err != nil {
+		switch {
+		case errors.Is(err, batches.ErrInvalidBatch), errors.Is(err, queue.ErrInvalidBatch),
+			errors.Is(err, queue.ErrUnknownQueue), errors.Is(err, queue.ErrInvalidSchedule),
+			errors.Is(err, queue.ErrInvalidRetry), errors.Is(err, queue.ErrInvalidChain):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to submit batch: %v", err)})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// HandleGetBatch handles requests to get a batch and the counts of its jobs by status
+func (h *Handlers) HandleGetBatch(c *gin.Context) {
+	batch, err := h.batchService.GetBatch(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, batches.ErrBatchNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "batch not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get batch: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// HandleListDeadLetters handles requests to list jobs archived after exhausting their retries
+func (h *Handlers) HandleListDeadLetters(c *gin.Context) {
+	page, pageSize, err := parsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.jobQueue.ListDeadLetters(c.Request.Context(), c.Query("queue"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to list dead letters: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// HandleGetDeadLetter handles requests to get an archived job
+func (h *Handlers) HandleGetDeadLetter(c *gin.Context) {
+	deadLetter, err := h.jobQueue.GetDeadLetter(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeDeadLetterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deadLetter)
+}
+
+// ReplayRequest represents a request to replay dead letters
+type ReplayRequest struct {
+	IDs   []string `json:"ids"`
+	Queue string   `json:"queue"`
+}
+
+// HandleReplayDeadLetter handles requests to enqueue an archived job again,
+// optionally on another queue
+func (h *Handlers) HandleReplayDeadLetter(c *gin.Context) {
+	var req ReplayRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	id := c.Param("id")
+	jobID, err := h.jobQueue.ReplayDeadLetter(c.Request.Context(), id, req.Queue)
+	if err != nil {
+		writeDeadLetterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.ReplayResult{ID: id, JobID: jobID})
+}
+
+// HandleReplayDeadLetters handles requests to enqueue several archived jobs
+// again. Each job is replayed independently and reported in the response.
+func (h *Handlers) HandleReplayDeadLetters(c *gin.Context) {
+	var req ReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.IDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids are required"})
+		return
+	}
+
+	results := make([]models.ReplayResult, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		result := models.ReplayResult{ID: id}
+		jobID, err := h.jobQueue.ReplayDeadLetter(c.Request.Context(), id, req.Queue)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.JobID = jobID
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// HandleDeleteDeadLetter handles requests to remove an archived job
+func (h *Handlers) HandleDeleteDeadLetter(c *gin.Context) {
+	if err := h.jobQueue.DeleteDeadLetter(c.Request.Context(), c.Param("id")); err != nil {
+		writeDeadLetterError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandlePurgeDeadLetters handles requests to remove every archived job of a queue, or of all queues
+func (h *Handlers) HandlePurgeDeadLetters(c *gin.Context) {
+	deleted, err := h.jobQueue.PurgeDeadLetters(c.Request.Context(), c.Query("queue"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to purge dead letters: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// HandleListQueueStats handles requests to get the stats of every queue
+func (h *Handlers) HandleListQueueStats(c *gin.Context) {
+	stats, err := h.jobQueue.ListQueueStats(c.Request.Context())
+	if err != nil {
+		writeQueueAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"queues": stats})
+}
+
+// HandleGetQueueStats handles requests to get the stats of a queue
+func (h *Handlers) HandleGetQueueStats(c *gin.Context) {
+	stats, err := h.jobQueue.GetQueueStats(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		writeQueueAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// HandlePauseQueue handles requests to stop workers from processing a queue
+func (h *Handlers) HandlePauseQueue(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.jobQueue.PauseQueue(c.Request.Context(), name); err != nil {
+		writeQueueAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"queue": name, "paused": true})
+}
+
+// HandleResumeQueue handles requests to let workers process a paused queue again
+func (h *Handlers) HandleResumeQueue(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.jobQueue.ResumeQueue(c.Request.Context(), name); err != nil {
+		writeQueueAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"queue": name, "paused": false})
+}
+
+// HandleDrainQueue handles requests to remove every job waiting in a queue
+func (h *Handlers) HandleDrainQueue(c *gin.Context) {
+	result, err := h.jobQueue.DrainQueue(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		writeQueueAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// writeQueueAdminError maps queue administration errors to HTTP responses
+func writeQueueAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, queue.ErrUnknownQueue):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to manage queue: %v", err)})
+	}
+}
+
+// parsePage reads the page and page_size query parameters
+func parsePage(c *gin.Context) (int, int, error) {
+	page, pageSize := 1, 0
+
+	if value := c.Query("page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return 0, 0, errors.New("invalid page parameter")
+		}
+		page = n
+	}
+
+	if value := c.Query("page_size"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > 100 {
+			return 0, 0, errors.New("invalid page_size parameter: must be between 1 and 100")
+		}
+		pageSize = n
+	}
+
+	return page, pageSize, nil
+}
+
+// writeDeadLetterError maps dead letter errors to HTTP responses
+func writeDeadLetterError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, queue.ErrDeadLetterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "dead letter not found"})
+	case errors.Is(err, queue.ErrUnknownQueue):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to manage dead letter: %v", err)})
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -14,14 +15,24 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dustinleblanc/go-bespin-api/internal/auth"
+	"github.com/dustinleblanc/go-bespin-api/internal/batches"
+	"github.com/dustinleblanc/go-bespin-api/internal/jobs"
+	"github.com/dustinleblanc/go-bespin-api/internal/jobtypes"
 	"github.com/dustinleblanc/go-bespin-api/internal/queue"
+	"github.com/dustinleblanc/go-bespin-api/internal/schedule"
 	"github.com/dustinleblanc/go-bespin-api/internal/webhook"
 	internalws "github.com/dustinleblanc/go-bespin-api/internal/websocket"
+	"github.com/dustinleblanc/go-bespin-api/internal/workflows"
 	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-contract/workers"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
@@ -30,12 +41,76 @@ func init() {
 	os.Setenv("GITHUB_WEBHOOK_SECRET", "test-secret-for-testing")
 }
 
+// testJobTypes returns a registry holding every contract job type
+func testJobTypes(t *testing.T) *jobtypes.Registry {
+	registry, err := jobtypes.NewContractRegistry(nil)
+	require.NoError(t, err)
+	return registry
+}
+
+// testWorkflows creates a workflow service that enqueues nothing
+func testWorkflows(t *testing.T) *workflows.Service {
+	return workflows.NewService(workflows.NewMockRepository(), &queue.MockQueue{}, testJobTypes(t), jobs.NewService(jobs.NewMockRepository()))
+}
+
+// testWebhooks creates a webhook service without sources
+func testWebhooks() *webhook.Service {
+	return webhook.NewService(webhook.NewMockRepository(), webhook.NewSourceRegistry(webhook.NewMockSourceRepository(), 0))
+}
+
+// testBatches creates a batch service that enqueues nothing
+func testBatches() *batches.Service {
+	return batches.NewService(batches.NewMockRepository(), &queue.MockQueue{}, jobs.NewService(jobs.NewMockRepository()))
+}
+
+// newTestHandlers creates handlers with deps, using a mock queue, the
+// contract job types and in-memory services for the dependencies left out
+func newTestHandlers(t *testing.T, deps Dependencies) *Handlers {
+	return NewHandlers(testDependencies(t, deps))
+}
+
+// newTestRouter creates a router like newTestHandlers creates handlers,
+// trusting no proxies
+func newTestRouter(t *testing.T, deps Dependencies, authenticator *auth.TokenAuthenticator) *gin.Engine {
+	router, err := NewRouter(testDependencies(t, deps), authenticator, nil)
+	require.NoError(t, err)
+	return router
+}
+
+// testDependencies fills in the dependencies left out of deps
+func testDependencies(t *testing.T, deps Dependencies) Dependencies {
+	if deps.JobQueue == nil {
+		deps.JobQueue = &queue.MockQueue{}
+	}
+	if deps.JobTypes == nil {
+		deps.JobTypes = testJobTypes(t)
+	}
+	if deps.JobService == nil {
+		deps.JobService = jobs.NewService(jobs.NewMockRepository())
+	}
+	if deps.ScheduleService == nil {
+		deps.ScheduleService = schedule.NewService(schedule.NewMockRepository())
+	}
+	if deps.WebhookService == nil {
+		deps.WebhookService = testWebhooks()
+	}
+	if deps.WorkflowService == nil {
+		deps.WorkflowService = testWorkflows(t)
+	}
+	if deps.BatchService == nil {
+		deps.BatchService = testBatches()
+	}
+	if deps.WSServer == nil {
+		deps.WSServer = internalws.NewServer()
+	}
+	return deps
+}
+
 func TestHandleRandomText(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockQueue := &queue.MockQueue{}
-	mockRepo := webhook.NewMockRepository()
-	webhookService := webhook.NewService(mockRepo)
-	handlers := NewHandlers(mockQueue, webhookService)
+	webhookService := testWebhooks()
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue, WebhookService: webhookService})
 
 	router := gin.New()
 	router.GET("/random-text", handlers.HandleRandomText)
@@ -43,6 +118,8 @@ func TestHandleRandomText(t *testing.T) {
 	tests := []struct {
 		name       string
 		length     string
+		queue      string
+		options    string
 		wantStatus int
 	}{
 		{
@@ -50,6 +127,84 @@ func TestHandleRandomText(t *testing.T) {
 			length:     "10",
 			wantStatus: http.StatusAccepted,
 		},
+		{
+			name:       "critical queue",
+			length:     "10",
+			queue:      "critical",
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "unknown queue",
+			length:     "10",
+			queue:      "urgent",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "delayed job",
+			length:     "10",
+			options:    "process_in=5m",
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "scheduled job",
+			length:     "10",
+			options:    "process_at=2030-01-01T09:00:00Z",
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "invalid delay",
+			length:     "10",
+			options:    "process_in=soon",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid schedule time",
+			length:     "10",
+			options:    "process_at=tomorrow",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "both delay and schedule time",
+			length:     "10",
+			options:    "process_in=5m&process_at=2030-01-01T09:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "retry options",
+			length:     "10",
+			options:    "max_retries=5&timeout=30s&backoff=exponential&backoff_delay=1s&backoff_max_delay=1m",
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "unique job",
+			length:     "10",
+			options:    "unique_for=10m",
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "invalid unique window",
+			length:     "10",
+			options:    "unique_for=forever",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid max retries",
+			length:     "10",
+			options:    "max_retries=-1",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown backoff strategy",
+			length:     "10",
+			options:    "backoff=linear&backoff_delay=1s",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "backoff without delay",
+			length:     "10",
+			options:    "backoff=fixed",
+			wantStatus: http.StatusBadRequest,
+		},
 		{
 			name:       "invalid length - not a number",
 			length:     "invalid",
@@ -67,14 +222,24 @@ func TestHandleRandomText(t *testing.T) {
 			// Set up mock expectations
 			if tt.wantStatus == http.StatusAccepted {
 				mockQueue.On("AddJob", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
-					return job.Type == models.JobTypeRandomText
+					return job.Type == models.JobTypeRandomText && job.Queue == tt.queue
 				})).Return("test-job-id", nil).Once()
 			}
 
 			// Create request
-			url := "/random-text"
+			params := []string{}
 			if tt.length != "" {
-				url += "?length=" + tt.length
+				params = append(params, "length="+tt.length)
+			}
+			if tt.queue != "" {
+				params = append(params, "queue="+tt.queue)
+			}
+			if tt.options != "" {
+				params = append(params, tt.options)
+			}
+			url := "/random-text"
+			if len(params) > 0 {
+				url += "?" + strings.Join(params, "&")
 			}
 			req := httptest.NewRequest(http.MethodGet, url, nil)
 
@@ -93,10 +258,230 @@ func TestHandleRandomText(t *testing.T) {
 	}
 }
 
+func TestHandleRandomTextIdempotencyKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue})
+
+	router := gin.New()
+	router.GET("/random-text", handlers.HandleRandomText)
+
+	// Both requests are passed to the queue with the key, which returns the original job
+	mockQueue.On("AddJob", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
+		return job.IdempotencyKey == "order-42"
+	})).Return("original-job-id", nil).Twice()
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/random-text?length=10", nil)
+		req.Header.Set("Idempotency-Key", "order-42")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "original-job-id", body["job_id"])
+	}
+
+	mockQueue.AssertExpectations(t)
+}
+
+func TestHandleSubmitJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue})
+
+	router := gin.New()
+	router.POST("/jobs", handlers.HandleSubmitJob)
+
+	tests := []struct {
+		name           string
+		body           string
+		idempotencyKey string
+		setup          func()
+		wantStatus     int
+		wantFields     []string
+	}{
+		{
+			name: "valid job with options",
+			body: `{"type":"random_text","data":{"length":20},"options":{"queue":"critical","process_in":"5m","max_retries":2,"timeout":"30s","backoff":{"strategy":"exponential","delay":"1s","max_delay":"1m"},"unique_for":"10m"}}`,
+			setup: func() {
+				mockQueue.On("AddJob", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
+					return job.Type == models.JobTypeRandomText &&
+						string(job.Data.(json.RawMessage)) == `{"length":20}` &&
+						job.Queue == "critical" &&
+						job.ProcessIn == 5*time.Minute &&
+						*job.MaxRetries == 2 &&
+						job.Timeout == 30*time.Second &&
+						job.Backoff.Strategy == "exponential" && job.Backoff.MaxDelay == time.Minute &&
+						job.UniqueFor == 10*time.Minute
+				})).Return("job-1", nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:           "idempotency key header",
+			body:           `{"type":"process_webhook","data":{"receipt_id":"r-1"}}`,
+			idempotencyKey: "delivery-1",
+			setup: func() {
+				mockQueue.On("AddJob", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
+					return job.Type == models.JobTypeProcessWebhook && job.IdempotencyKey == "delivery-1"
+				})).Return("job-2", nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "follow-up jobs",
+			body: `{"type":"random_text","data":{"length":20},"on_success":{"type":"random_text","data":{"length":"{{ parent.result.length }}"},"options":{"queue":"low"},"on_failure":{"type":"process_webhook","data":{"receipt_id":"r-1"}}},"on_failure":{"type":"random_text","data":{"length":1}}}`,
+			setup: func() {
+				mockQueue.On("AddJob", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
+					return job.OnSuccess != nil && job.OnSuccess.Queue == "low" &&
+						string(job.OnSuccess.Data.(json.RawMessage)) == `{"length":"{{ parent.result.length }}"}` &&
+						job.OnSuccess.OnFailure != nil && job.OnSuccess.OnFailure.Type == models.JobTypeProcessWebhook &&
+						job.OnFailure != nil && job.OnFailure.Type == models.JobTypeRandomText
+				})).Return("job-3", nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "invalid follow-up jobs",
+			body:       `{"type":"random_text","data":{"length":20},"on_success":{"type":"resize_image"},"on_failure":{"type":"random_text","data":{"length":0},"options":{"process_in":"5m"},"on_success":{"type":"process_webhook","data":{}}}}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantFields: []string{"on_success.type", "on_failure.data.length", "on_failure.options", "on_failure.on_success.data"},
+		},
+		{
+			name:       "malformed body",
+			body:       `{"type":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing type",
+			body:       `{"data":{"length":20}}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantFields: []string{"type"},
+		},
+		{
+			name:       "unknown type",
+			body:       `{"type":"resize_image","data":{}}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantFields: []string{"type"},
+		},
+		{
+			name:       "data does not match schema",
+			body:       `{"type":"random_text","data":{"length":"long","colour":"blue"}}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantFields: []string{"data", "data.length"},
+		},
+		{
+			name:       "missing data",
+			body:       `{"type":"process_webhook"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantFields: []string{"data"},
+		},
+		{
+			name:       "invalid options",
+			body:       `{"type":"random_text","data":{"length":20},"options":{"queue":"urgent","timeout":"soon","backoff":{"strategy":"linear","delay":"1s"}}}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantFields: []string{"options.queue", "options.timeout", "options.backoff"},
+		},
+		{
+			name: "queue rejects the job",
+			body: `{"type":"random_text","data":{"length":20},"options":{"deadline":"2000-01-01T00:00:00Z"}}`,
+			setup: func() {
+				mockQueue.On("AddJob", mock.Anything, mock.Anything).Return("", fmt.Errorf("%w: deadline has passed", queue.ErrInvalidRetry)).Once()
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", tt.idempotencyKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantFields != nil {
+				var body struct {
+					Errors []models.FieldError `json:"errors"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				var fields []string
+				for _, fieldErr := range body.Errors {
+					fields = append(fields, fieldErr.Field)
+				}
+				assert.ElementsMatch(t, tt.wantFields, fields)
+			}
+			mockQueue.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleListJobTypes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	registry, err := jobtypes.NewContractRegistry(&jobtypes.MockWorkerSource{Registrations: []*workers.Registration{
+		{
+			WorkerID:    "host:1",
+			HeartbeatAt: time.Now(),
+			Handlers:    []workers.Handler{{Type: tasks.TypeRandomText, Version: tasks.Version, DefaultQueue: tasks.QueueLow}},
+		},
+	}})
+	require.NoError(t, err)
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue, JobTypes: registry})
+
+	router := gin.New()
+	router.GET("/job-types", handlers.HandleListJobTypes)
+	router.POST("/jobs", handlers.HandleSubmitJob)
+
+	req := httptest.NewRequest(http.MethodGet, "/job-types", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		JobTypes []models.JobTypeStatus `json:"job_types"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.JobTypes, 2)
+	assert.Equal(t, models.JobTypeProcessWebhook, body.JobTypes[0].Name)
+	assert.False(t, body.JobTypes[0].Available)
+	assert.NotEmpty(t, body.JobTypes[0].Schema)
+	assert.Equal(t, models.JobTypeRandomText, body.JobTypes[1].Name)
+	assert.True(t, body.JobTypes[1].Available)
+	assert.Equal(t, 1, body.JobTypes[1].Workers)
+
+	// Jobs submitted without a queue go to the queue the workers advertise
+	mockQueue.On("AddJob", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
+		return job.Queue == tasks.QueueLow
+	})).Return("job-1", nil).Once()
+
+	req = httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"type":"random_text","data":{"length":5}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	mockQueue.AssertExpectations(t)
+}
+
 func TestHandleWebhook(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockService := webhook.NewMockService()
 
+	// pushHeader matches the headers of a GitHub push delivery
+	pushHeader := mock.MatchedBy(func(header http.Header) bool {
+		return header.Get("X-GitHub-Event") == "push"
+	})
+
 	testCases := []struct {
 		name           string
 		source         string
@@ -117,9 +502,9 @@ func TestHandleWebhook(t *testing.T) {
 			expectJobQueue: true,
 			setupMocks: func(s *webhook.MockService, q *queue.MockQueue, payload map[string]interface{}) {
 				payloadBytes, _ := json.Marshal(payload)
-				signature := generateSignature(payloadBytes)
+				signature := "sha256=" + generateSignature(payloadBytes)
 
-				s.On("CreateReceipt", mock.Anything, "github", "push", payloadBytes, signature).Return(&models.WebhookReceipt{
+				s.On("CreateReceipt", mock.Anything, "github", pushHeader, payloadBytes, mock.Anything).Return(&models.WebhookReceipt{
 					ID:        "test-receipt-id",
 					Source:    "github",
 					Event:     "push",
@@ -128,10 +513,52 @@ func TestHandleWebhook(t *testing.T) {
 				}, nil).Once()
 
 				q.On("AddJob", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
-					return job.Type == models.JobTypeProcessWebhook && job.Data.(models.WebhookJobData).ReceiptID == "test-receipt-id"
+					return job.Type == models.JobTypeProcessWebhook && job.Data.(models.WebhookJobData).ReceiptID == "test-receipt-id" &&
+						job.IdempotencyKey == "webhook-receipt:test-receipt-id"
 				})).Return("test-job-id", nil).Once()
 			},
 		},
+		{
+			name:   "redelivery of a processed receipt",
+			source: "github",
+			event:  "push",
+			payload: map[string]interface{}{
+				"test": "data",
+			},
+			wantStatus: http.StatusOK,
+			setupMocks: func(s *webhook.MockService, q *queue.MockQueue, payload map[string]interface{}) {
+				payloadBytes, _ := json.Marshal(payload)
+				s.On("CreateReceipt", mock.Anything, "github", pushHeader, payloadBytes, mock.Anything).Return(&models.WebhookReceipt{
+					ID:     "original-receipt-id",
+					Source: "github",
+					Event:  "push",
+					Status: models.WebhookStatusCompleted,
+				}, fmt.Errorf("%w: delivery-1", webhook.ErrDuplicateDelivery)).Once()
+			},
+		},
+		{
+			name:   "redelivery of a pending receipt",
+			source: "github",
+			event:  "push",
+			payload: map[string]interface{}{
+				"test": "data",
+			},
+			wantStatus: http.StatusOK,
+			setupMocks: func(s *webhook.MockService, q *queue.MockQueue, payload map[string]interface{}) {
+				payloadBytes, _ := json.Marshal(payload)
+				s.On("CreateReceipt", mock.Anything, "github", pushHeader, payloadBytes, mock.Anything).Return(&models.WebhookReceipt{
+					ID:     "original-receipt-id",
+					Source: "github",
+					Event:  "push",
+					Status: models.WebhookStatusPending,
+				}, fmt.Errorf("%w: delivery-1", webhook.ErrDuplicateDelivery)).Once()
+
+				// The job of the original delivery is reused
+				q.On("AddJob", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
+					return job.IdempotencyKey == "webhook-receipt:original-receipt-id"
+				})).Return("original-job-id", nil).Once()
+			},
+		},
 		{
 			name:       "missing source",
 			source:     "",
@@ -146,15 +573,21 @@ func TestHandleWebhook(t *testing.T) {
 			event:      "",
 			payload:    map[string]interface{}{},
 			wantStatus: http.StatusBadRequest,
-			setupMocks: func(s *webhook.MockService, q *queue.MockQueue, payload map[string]interface{}) {},
+			setupMocks: func(s *webhook.MockService, q *queue.MockQueue, payload map[string]interface{}) {
+				payloadBytes, _ := json.Marshal(payload)
+				s.On("CreateReceipt", mock.Anything, "github", mock.Anything, payloadBytes, mock.Anything).Return(nil, webhook.ErrMissingEvent).Once()
+			},
 		},
 		{
 			name:       "missing signature",
 			source:     "github",
 			event:      "push",
 			payload:    map[string]interface{}{},
-			wantStatus: http.StatusBadRequest,
-			setupMocks: func(s *webhook.MockService, q *queue.MockQueue, payload map[string]interface{}) {},
+			wantStatus: http.StatusUnauthorized,
+			setupMocks: func(s *webhook.MockService, q *queue.MockQueue, payload map[string]interface{}) {
+				payloadBytes, _ := json.Marshal(payload)
+				s.On("CreateReceipt", mock.Anything, "github", pushHeader, payloadBytes, mock.Anything).Return(nil, webhook.ErrMissingSignature).Once()
+			},
 		},
 		{
 			name:   "invalid signature",
@@ -163,11 +596,10 @@ func TestHandleWebhook(t *testing.T) {
 			payload: map[string]interface{}{
 				"test": "data",
 			},
-			wantStatus: http.StatusBadRequest,
+			wantStatus: http.StatusUnauthorized,
 			setupMocks: func(s *webhook.MockService, q *queue.MockQueue, payload map[string]interface{}) {
 				payloadBytes, _ := json.Marshal(payload)
-				signature := generateSignature(payloadBytes)
-				s.On("CreateReceipt", mock.Anything, "github", "push", payloadBytes, signature).Return(nil, fmt.Errorf("invalid signature")).Once()
+				s.On("CreateReceipt", mock.Anything, "github", pushHeader, payloadBytes, mock.Anything).Return(nil, webhook.ErrInvalidSignature).Once()
 			},
 		},
 		{
@@ -180,8 +612,20 @@ func TestHandleWebhook(t *testing.T) {
 			wantStatus: http.StatusNotFound,
 			setupMocks: func(s *webhook.MockService, q *queue.MockQueue, payload map[string]interface{}) {
 				payloadBytes, _ := json.Marshal(payload)
-				signature := generateSignature(payloadBytes)
-				s.On("CreateReceipt", mock.Anything, "invalid", "push", payloadBytes, signature).Return(nil, fmt.Errorf("invalid source: invalid")).Once()
+				s.On("CreateReceipt", mock.Anything, "invalid", pushHeader, payloadBytes, mock.Anything).Return(nil, fmt.Errorf("%w: invalid", webhook.ErrInvalidSource)).Once()
+			},
+		},
+		{
+			name:   "ip not allowed",
+			source: "github",
+			event:  "push",
+			payload: map[string]interface{}{
+				"test": "data",
+			},
+			wantStatus: http.StatusForbidden,
+			setupMocks: func(s *webhook.MockService, q *queue.MockQueue, payload map[string]interface{}) {
+				payloadBytes, _ := json.Marshal(payload)
+				s.On("CreateReceipt", mock.Anything, "github", pushHeader, payloadBytes, "192.0.2.1").Return(nil, fmt.Errorf("%w: 192.0.2.1", webhook.ErrIPNotAllowed)).Once()
 			},
 		},
 	}
@@ -190,23 +634,22 @@ func TestHandleWebhook(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Create a new router and queue for each test case
 			mockQueue := &queue.MockQueue{}
-			handlers := NewHandlers(mockQueue, mockService)
+			handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue, WebhookService: mockService})
 			router := gin.New()
 			router.POST("/api/webhooks/:source", handlers.HandleWebhook)
 
 			// Setup mock expectations
 			tc.setupMocks(mockService, mockQueue, tc.payload)
 
-			// Create request
+			// Create a request with the headers of a GitHub delivery
 			payloadBytes, _ := json.Marshal(tc.payload)
 			req := httptest.NewRequest(http.MethodPost, "/api/webhooks/"+tc.source, bytes.NewBuffer(payloadBytes))
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("X-Event-Type", tc.event)
+			req.Header.Set("X-GitHub-Event", tc.event)
 
-			// Generate signature for valid requests
-			if tc.name == "valid request" || tc.name == "invalid source" || tc.name == "invalid signature" {
-				signature := generateSignature(payloadBytes)
-				req.Header.Set("X-Signature", signature)
+			// Sign requests that are not missing their signature
+			if tc.name != "missing signature" {
+				req.Header.Set("X-Hub-Signature-256", "sha256="+generateSignature(payloadBytes))
 			}
 
 			// Create response recorder
@@ -217,6 +660,10 @@ func TestHandleWebhook(t *testing.T) {
 
 			// Assert response
 			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantStatus == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"receipt_id":"original-receipt-id"`)
+				assert.Contains(t, w.Body.String(), `"status":"duplicate"`)
+			}
 
 			// Verify mock expectations
 			mockQueue.AssertExpectations(t)
@@ -228,9 +675,8 @@ func TestHandleWebhook(t *testing.T) {
 func TestHandleGetJobResult(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockQueue := &queue.MockQueue{}
-	mockRepo := webhook.NewMockRepository()
-	webhookService := webhook.NewService(mockRepo)
-	handlers := NewHandlers(mockQueue, webhookService)
+	webhookService := testWebhooks()
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue, WebhookService: webhookService})
 
 	router := gin.New()
 	router.GET("/jobs/:id", handlers.HandleGetJobResult)
@@ -241,6 +687,7 @@ func TestHandleGetJobResult(t *testing.T) {
 		result     *models.JobResult
 		err        error
 		wantStatus int
+		wantResult interface{}
 	}{
 		{
 			name:  "existing job",
@@ -251,6 +698,37 @@ func TestHandleGetJobResult(t *testing.T) {
 				Result: "test result",
 			},
 			wantStatus: http.StatusOK,
+			wantResult: "test result",
+		},
+		{
+			name:  "job with structured result",
+			jobID: "structured-job-id",
+			result: &models.JobResult{
+				ID:     "structured-job-id",
+				Status: models.JobStatusCompleted,
+				Result: map[string]interface{}{
+					"text":   "cloud data",
+					"length": float64(2),
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantResult: map[string]interface{}{
+				"text":   "cloud data",
+				"length": float64(2),
+			},
+		},
+		{
+			name:  "running job with progress",
+			jobID: "running-job-id",
+			result: &models.JobResult{
+				ID:     "running-job-id",
+				Status: models.JobStatusProcessing,
+				Progress: &models.JobProgress{
+					Percent: 50,
+					Message: "halfway",
+				},
+			},
+			wantStatus: http.StatusOK,
 		},
 		{
 			name:       "non-existent job",
@@ -276,6 +754,17 @@ func TestHandleGetJobResult(t *testing.T) {
 
 			// Assert response
 			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantResult != nil {
+				var body models.JobResult
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tt.wantResult, body.Result)
+			}
+			if tt.result != nil && tt.result.Progress != nil {
+				var body models.JobResult
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tt.result.Progress.Percent, body.Progress.Percent)
+				assert.Equal(t, tt.result.Progress.Message, body.Progress.Message)
+			}
 
 			// Verify mock expectations
 			mockQueue.AssertExpectations(t)
@@ -283,12 +772,1078 @@ func TestHandleGetJobResult(t *testing.T) {
 	}
 }
 
+func TestHandleGetJobResultFromHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	jobService := jobs.NewService(jobs.NewMockRepository())
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue, JobService: jobService})
+
+	router := gin.New()
+	router.GET("/jobs/:id", handlers.HandleGetJobResult)
+
+	// The task has expired from Redis but is still in the job history
+	assert.NoError(t, jobService.RecordJob(context.Background(), &models.JobRecord{
+		ID:     "expired-job-id",
+		Type:   models.JobTypeRandomText,
+		Status: models.JobStatusCompleted,
+		Result: models.JSON(`{"text":"cloud data","length":2}`),
+	}))
+	mockQueue.On("GetJobResult", mock.Anything, "expired-job-id").Return(nil, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/expired-job-id", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body models.JobResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, models.JobStatusCompleted, body.Status)
+	assert.Equal(t, map[string]interface{}{"text": "cloud data", "length": float64(2)}, body.Result)
+	mockQueue.AssertExpectations(t)
+}
+
+func TestHandleGetJobResultLinks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	jobService := jobs.NewService(jobs.NewMockRepository())
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue, JobService: jobService})
+
+	router := gin.New()
+	router.GET("/jobs/:id", handlers.HandleGetJobResult)
+
+	ctx := context.Background()
+	assert.NoError(t, jobService.RecordJob(ctx, &models.JobRecord{ID: "parent-id", Type: models.JobTypeRandomText, Status: models.JobStatusCompleted}))
+	assert.NoError(t, jobService.RecordJob(ctx, &models.JobRecord{ID: "child-id", Type: models.JobTypeProcessWebhook, Status: models.JobStatusPending, ParentID: "parent-id"}))
+	mockQueue.On("GetJobResult", mock.Anything, "parent-id").Return(&models.JobResult{ID: "parent-id", Status: models.JobStatusCompleted}, nil).Once()
+	mockQueue.On("GetJobResult", mock.Anything, "child-id").Return(&models.JobResult{ID: "child-id", Status: models.JobStatusPending}, nil).Once()
+
+	// The parent lists its follow-up jobs
+	req := httptest.NewRequest(http.MethodGet, "/jobs/parent-id", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body models.JobResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Empty(t, body.ParentID)
+	assert.Equal(t, []models.JobLink{{ID: "child-id", Type: models.JobTypeProcessWebhook, Status: models.JobStatusPending}}, body.Children)
+
+	// The follow-up job points at its parent
+	req = httptest.NewRequest(http.MethodGet, "/jobs/child-id", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body = models.JobResult{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "parent-id", body.ParentID)
+	assert.Empty(t, body.Children)
+	mockQueue.AssertExpectations(t)
+}
+
+func TestHandleListJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jobService := jobs.NewService(jobs.NewMockRepository())
+	handlers := newTestHandlers(t, Dependencies{JobService: jobService})
+
+	router := gin.New()
+	router.GET("/jobs", handlers.HandleListJobs)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, jobType := range []models.JobType{models.JobTypeRandomText, models.JobTypeProcessWebhook, models.JobTypeRandomText} {
+		assert.NoError(t, jobService.RecordJob(context.Background(), &models.JobRecord{
+			ID:        fmt.Sprintf("job-%d", i),
+			Type:      jobType,
+			Queue:     "default",
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []string
+		wantCursor bool
+	}{
+		{
+			name:       "all jobs",
+			query:      "",
+			wantStatus: http.StatusOK,
+			wantIDs:    []string{"job-2", "job-1", "job-0"},
+		},
+		{
+			name:       "filter by type",
+			query:      "?type=random_text",
+			wantStatus: http.StatusOK,
+			wantIDs:    []string{"job-2", "job-0"},
+		},
+		{
+			name:       "filter by created range",
+			query:      "?created_after=2025-01-01T00:00:30Z&created_before=2025-01-01T00:01:30Z",
+			wantStatus: http.StatusOK,
+			wantIDs:    []string{"job-1"},
+		},
+		{
+			name:       "paginated",
+			query:      "?limit=2",
+			wantStatus: http.StatusOK,
+			wantIDs:    []string{"job-2", "job-1"},
+			wantCursor: true,
+		},
+		{
+			name:       "invalid timestamp",
+			query:      "?created_after=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid cursor",
+			query:      "?cursor=not-a-cursor",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid limit",
+			query:      "?limit=-1",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/jobs"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var page jobs.JobPage
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			ids := make([]string, 0, len(page.Jobs))
+			for _, job := range page.Jobs {
+				ids = append(ids, job.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Equal(t, tt.wantCursor, page.NextCursor != "")
+		})
+	}
+}
+
+func TestHandleCancelJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	webhookService := testWebhooks()
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue, WebhookService: webhookService})
+
+	router := gin.New()
+	router.DELETE("/jobs/:id", handlers.HandleCancelJob)
+
+	tests := []struct {
+		name       string
+		jobID      string
+		err        error
+		wantStatus int
+	}{
+		{
+			name:       "pending or running job",
+			jobID:      "test-job-id",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "non-existent job",
+			jobID:      "non-existent",
+			err:        queue.ErrJobNotFound,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "finished job",
+			jobID:      "finished-job-id",
+			err:        queue.ErrJobNotCancellable,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "queue error",
+			jobID:      "broken-job-id",
+			err:        fmt.Errorf("redis unavailable"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set up mock expectations
+			mockQueue.On("CancelJob", mock.Anything, tt.jobID).Return(tt.err).Once()
+
+			// Create request
+			req := httptest.NewRequest(http.MethodDelete, "/jobs/"+tt.jobID, nil)
+
+			// Create response recorder
+			w := httptest.NewRecorder()
+
+			// Serve request
+			router.ServeHTTP(w, req)
+
+			// Assert response
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				var body map[string]interface{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, string(models.JobStatusCancelled), body["status"])
+			}
+
+			// Verify mock expectations
+			mockQueue.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleSchedules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handlers := newTestHandlers(t, Dependencies{})
+
+	router := gin.New()
+	router.POST("/schedules", handlers.HandleCreateSchedule)
+	router.GET("/schedules", handlers.HandleListSchedules)
+	router.GET("/schedules/:id", handlers.HandleGetSchedule)
+	router.PATCH("/schedules/:id", handlers.HandleUpdateSchedule)
+	router.DELETE("/schedules/:id", handlers.HandleDeleteSchedule)
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Create
+	w := serve(http.MethodPost, "/schedules", `{"name":"nightly","cron":"0 3 * * *","job_type":"random_text","payload":{"length":20}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created models.Schedule
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.ID)
+	assert.True(t, created.Enabled)
+
+	w = serve(http.MethodPost, "/schedules", `{"name":"broken","cron":"whenever","job_type":"random_text"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(http.MethodPost, "/schedules", `{"name":"nightly","cron":"0 4 * * *","job_type":"random_text"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Read
+	w = serve(http.MethodGet, "/schedules", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Schedules []models.Schedule `json:"schedules"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Schedules, 1)
+
+	w = serve(http.MethodGet, "/schedules/"+created.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(http.MethodGet, "/schedules/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Update
+	w = serve(http.MethodPatch, "/schedules/"+created.ID, `{"enabled":false}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated models.Schedule
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.False(t, updated.Enabled)
+	assert.Equal(t, "0 3 * * *", updated.Cron)
+
+	// Delete
+	w = serve(http.MethodDelete, "/schedules/"+created.ID, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = serve(http.MethodDelete, "/schedules/"+created.ID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandleSchedulesRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator := auth.NewTokenAuthenticator(map[string]auth.Role{"admin-token": auth.RoleAdmin})
+	router := newTestRouter(t, Dependencies{}, authenticator)
+
+	serve := func(method, url, token, body string) int {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	body := `{"name":"nightly","cron":"0 3 * * *","job_type":"random_text","payload":{"length":20}}`
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/api/schedules", "", body))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/api/schedules", "guess", body))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPatch, "/api/schedules/any", "", `{"enabled":false}`))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, "/api/schedules/any", "", ""))
+
+	// Schedules are listed without a token
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/api/schedules", "admin-token", body))
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/schedules", "", ""))
+}
+
+func TestHandleWorkflows(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	jobService := jobs.NewService(jobs.NewMockRepository())
+	workflowService := workflows.NewService(workflows.NewMockRepository(), mockQueue, testJobTypes(t), jobService)
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue, JobService: jobService, WorkflowService: workflowService})
+
+	router := gin.New()
+	router.POST("/workflows", handlers.HandleCreateWorkflow)
+	router.GET("/workflows/:id", handlers.HandleGetWorkflow)
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Only the step without dependencies is enqueued
+	mockQueue.On("AddJob", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
+		return job.Type == models.JobTypeRandomText && strings.HasSuffix(job.IdempotencyKey, ":generate")
+	})).Return("job-1", nil).Once()
+
+	w := serve(http.MethodPost, "/workflows", `{"name":"report","steps":[{"name":"generate","type":"random_text","data":{"length":20}},{"name":"deliver","type":"process_webhook","data":{"receipt_id":"r-1"},"depends_on":["generate"]}]}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created models.WorkflowRun
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, models.WorkflowStatusRunning, created.Status)
+	require.Len(t, created.Steps, 2)
+	assert.Equal(t, "job-1", created.Steps[0].JobID)
+	assert.Equal(t, models.WorkflowStepStatusPending, created.Steps[0].Status)
+	assert.Equal(t, models.WorkflowStepStatusWaiting, created.Steps[1].Status)
+
+	w = serve(http.MethodGet, "/workflows/"+created.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"depends_on":["generate"]`)
+
+	w = serve(http.MethodGet, "/workflows/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(http.MethodPost, "/workflows", `{"steps":[{"name":"a","type":"random_text","data":{"length":1},"depends_on":["b"]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"steps[0].depends_on"`)
+
+	w = serve(http.MethodPost, "/workflows", `{"steps":`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockQueue.AssertExpectations(t)
+}
+
+func TestHandleBatches(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	jobService := jobs.NewService(jobs.NewMockRepository())
+	batchService := batches.NewService(batches.NewMockRepository(), mockQueue, jobService)
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue, JobService: jobService, BatchService: batchService})
+
+	router := gin.New()
+	router.POST("/batches", handlers.HandleCreateBatch)
+	router.GET("/batches/:id", handlers.HandleGetBatch)
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Both jobs are enqueued tagged with the batch
+	var batchID string
+	mockQueue.On("AddJobs", mock.Anything, mock.MatchedBy(func(batchJobs []*models.Job) bool {
+		return len(batchJobs) == 2 && batchJobs[0].BatchID != "" && batchJobs[1].Queue == "low"
+	})).Run(func(args mock.Arguments) {
+		batchJobs := args.Get(1).([]*models.Job)
+		batchID = batchJobs[0].BatchID
+		for i, job := range batchJobs {
+			require.NoError(t, jobService.RecordJob(context.Background(), &models.JobRecord{ID: fmt.Sprintf("job-%d", i), Status: models.JobStatusPending, BatchID: job.BatchID}))
+		}
+	}).Return([]string{"job-0", "job-1"}, nil).Once()
+
+	w := serve(http.MethodPost, "/batches", `{"name":"nightly","jobs":[{"type":"random_text","data":{"length":10}},{"type":"random_text","data":{"length":20},"options":{"queue":"low"}}],"callback":{"type":"process_webhook","data":{"receipt_id":"{{ parent.id }}"},"options":{"timeout":"2m"}}}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var created models.Batch
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, batchID, created.ID)
+	assert.Equal(t, []string{"job-0", "job-1"}, created.JobIDs)
+	assert.Equal(t, 2, created.Total)
+	assert.Equal(t, models.BatchCounts{Pending: 2}, created.Counts)
+
+	w = serve(http.MethodGet, "/batches/"+created.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"counts":{"pending":2,"processing":0,"completed":0,"failed":0,"cancelled":0}`)
+	assert.Contains(t, w.Body.String(), `"options":{"timeout":"2m0s"}`)
+
+	// The callback is enqueued with its options once both jobs finished
+	mockQueue.On("AddJob", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
+		return job.Type == models.JobTypeProcessWebhook && job.Timeout == 2*time.Minute
+	})).Return("callback-job", nil).Once()
+	for _, id := range created.JobIDs {
+		event := events.NewJobEvent(events.KindCompleted, id, "")
+		require.NoError(t, jobService.HandleJobEvent(context.Background(), event))
+		require.NoError(t, batchService.HandleJobEvent(context.Background(), event))
+	}
+
+	w = serve(http.MethodGet, "/batches/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Every invalid job is reported, and nothing is enqueued
+	w = serve(http.MethodPost, "/batches", `{"jobs":[{"type":"random_text","data":{"length":0}},{"type":"random_text","data":{"length":1},"options":{"idempotency_key":"once"}}],"callback":{"type":"resize_image"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	for _, field := range []string{"jobs[0].data.length", "jobs[1].options", "callback.type"} {
+		assert.Contains(t, w.Body.String(), `"field":"`+field+`"`)
+	}
+
+	w = serve(http.MethodPost, "/batches", `{"jobs":[]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(http.MethodPost, "/batches", `{"jobs":`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockQueue.AssertExpectations(t)
+}
+
+func TestHandleDeadLetters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue})
+
+	router := gin.New()
+	router.GET("/dead-letters", handlers.HandleListDeadLetters)
+	router.DELETE("/dead-letters", handlers.HandlePurgeDeadLetters)
+	router.POST("/dead-letters/replay", handlers.HandleReplayDeadLetters)
+	router.GET("/dead-letters/:id", handlers.HandleGetDeadLetter)
+	router.DELETE("/dead-letters/:id", handlers.HandleDeleteDeadLetter)
+	router.POST("/dead-letters/:id/replay", handlers.HandleReplayDeadLetter)
+
+	deadLetter := &models.DeadLetter{
+		ID:        "archived-id",
+		Queue:     "default",
+		Type:      models.JobTypeRandomText,
+		Payload:   json.RawMessage(`{"length":10}`),
+		LastError: "boom",
+		Retried:   3,
+		MaxRetry:  3,
+		FailedAt:  time.Now(),
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		setup      func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:   "list dead letters",
+			method: http.MethodGet,
+			url:    "/dead-letters?queue=default&page=2&page_size=10",
+			setup: func() {
+				mockQueue.On("ListDeadLetters", mock.Anything, "default", 2, 10).Return(&models.DeadLetterPage{
+					DeadLetters: []*models.DeadLetter{deadLetter},
+					Total:       11,
+					Page:        2,
+					PageSize:    10,
+				}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"last_error":"boom"`,
+		},
+		{
+			name:       "list with invalid page size",
+			method:     http.MethodGet,
+			url:        "/dead-letters?page_size=1000",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "get dead letter",
+			method: http.MethodGet,
+			url:    "/dead-letters/archived-id",
+			setup: func() {
+				mockQueue.On("GetDeadLetter", mock.Anything, "archived-id").Return(deadLetter, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"payload":{"length":10}`,
+		},
+		{
+			name:   "get missing dead letter",
+			method: http.MethodGet,
+			url:    "/dead-letters/missing",
+			setup: func() {
+				mockQueue.On("GetDeadLetter", mock.Anything, "missing").Return(nil, queue.ErrDeadLetterNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "replay to another queue",
+			method: http.MethodPost,
+			url:    "/dead-letters/archived-id/replay",
+			body:   `{"queue":"critical"}`,
+			setup: func() {
+				mockQueue.On("ReplayDeadLetter", mock.Anything, "archived-id", "critical").Return("new-job-id", nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+			wantBody:   `"job_id":"new-job-id"`,
+		},
+		{
+			name:   "replay without body",
+			method: http.MethodPost,
+			url:    "/dead-letters/archived-id/replay",
+			setup: func() {
+				mockQueue.On("ReplayDeadLetter", mock.Anything, "archived-id", "").Return("new-job-id", nil).Once()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:   "replay many",
+			method: http.MethodPost,
+			url:    "/dead-letters/replay",
+			body:   `{"ids":["archived-id","missing"]}`,
+			setup: func() {
+				mockQueue.On("ReplayDeadLetter", mock.Anything, "archived-id", "").Return("new-job-id", nil).Once()
+				mockQueue.On("ReplayDeadLetter", mock.Anything, "missing", "").Return("", queue.ErrDeadLetterNotFound).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"error":"dead letter not found"`,
+		},
+		{
+			name:       "replay many without ids",
+			method:     http.MethodPost,
+			url:        "/dead-letters/replay",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "delete dead letter",
+			method: http.MethodDelete,
+			url:    "/dead-letters/archived-id",
+			setup: func() {
+				mockQueue.On("DeleteDeadLetter", mock.Anything, "archived-id").Return(nil).Once()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:   "purge dead letters",
+			method: http.MethodDelete,
+			url:    "/dead-letters",
+			setup: func() {
+				mockQueue.On("PurgeDeadLetters", mock.Anything, "").Return(7, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"deleted":7`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantBody != "" {
+				assert.Contains(t, w.Body.String(), tt.wantBody)
+			}
+			mockQueue.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleQueueAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	authenticator := auth.NewTokenAuthenticator(map[string]auth.Role{"admin-token": auth.RoleAdmin})
+	router := newTestRouter(t, Dependencies{JobQueue: mockQueue}, authenticator)
+
+	stats := &models.QueueStats{Queue: "default", Size: 5, Pending: 3, Active: 2, LatencyMs: 1500, Processed: 40, Failed: 2}
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		token      string
+		setup      func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "missing token",
+			method:     http.MethodGet,
+			url:        "/api/admin/queues",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid token",
+			method:     http.MethodPost,
+			url:        "/api/admin/queues/default/pause",
+			token:      "guess",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "list queue stats",
+			method: http.MethodGet,
+			url:    "/api/admin/queues",
+			token:  "admin-token",
+			setup: func() {
+				mockQueue.On("ListQueueStats", mock.Anything).Return([]*models.QueueStats{stats}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"latency_ms":1500`,
+		},
+		{
+			name:   "get queue stats",
+			method: http.MethodGet,
+			url:    "/api/admin/queues/default",
+			token:  "admin-token",
+			setup: func() {
+				mockQueue.On("GetQueueStats", mock.Anything, "default").Return(stats, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"processed":40`,
+		},
+		{
+			name:   "get unknown queue",
+			method: http.MethodGet,
+			url:    "/api/admin/queues/urgent",
+			token:  "admin-token",
+			setup: func() {
+				mockQueue.On("GetQueueStats", mock.Anything, "urgent").Return(nil, fmt.Errorf("%w: urgent", queue.ErrUnknownQueue)).Once()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "pause queue",
+			method: http.MethodPost,
+			url:    "/api/admin/queues/low/pause",
+			token:  "admin-token",
+			setup: func() {
+				mockQueue.On("PauseQueue", mock.Anything, "low").Return(nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"paused":true`,
+		},
+		{
+			name:   "resume queue",
+			method: http.MethodPost,
+			url:    "/api/admin/queues/low/resume",
+			token:  "admin-token",
+			setup: func() {
+				mockQueue.On("ResumeQueue", mock.Anything, "low").Return(nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"paused":false`,
+		},
+		{
+			name:   "drain queue",
+			method: http.MethodPost,
+			url:    "/api/admin/queues/default/drain",
+			token:  "admin-token",
+			setup: func() {
+				mockQueue.On("DrainQueue", mock.Anything, "default").Return(&models.DrainResult{Queue: "default", Pending: 3, Scheduled: 1}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"pending":3`,
+		},
+		{
+			name:       "purge dead letters without token",
+			method:     http.MethodDelete,
+			url:        "/api/dead-letters",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "replay dead letter without token",
+			method:     http.MethodPost,
+			url:        "/api/dead-letters/archived-id/replay",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "purge dead letters",
+			method: http.MethodDelete,
+			url:    "/api/dead-letters?queue=low",
+			token:  "admin-token",
+			setup: func() {
+				mockQueue.On("PurgeDeadLetters", mock.Anything, "low").Return(2, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"deleted":2`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantBody != "" {
+				assert.Contains(t, w.Body.String(), tt.wantBody)
+			}
+			mockQueue.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleWebhookReceipts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	webhookService := testWebhooks()
+	authenticator := auth.NewTokenAuthenticator(map[string]auth.Role{"admin-token": auth.RoleAdmin})
+	router := newTestRouter(t, Dependencies{WebhookService: webhookService}, authenticator)
+
+	ctx := context.Background()
+	_, err := webhookService.CreateSource(ctx, &models.WebhookSourceRequest{Name: "billing", VerifierType: models.WebhookVerifierHMAC, Secrets: []string{"billing-secret"}})
+	require.NoError(t, err)
+
+	var receiptIDs []string
+	for i, event := range []string{"invoice.paid", "invoice.paid", "invoice.voided"} {
+		payload := []byte(fmt.Sprintf(`{"event":%q,"delivery":"%d"}`, event, i))
+		mac := hmac.New(sha256.New, []byte("billing-secret"))
+		mac.Write(payload)
+		header := http.Header{}
+		header.Set("X-Event-Type", event)
+		header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+		receipt, err := webhookService.CreateReceipt(ctx, "billing", header, payload, "")
+		require.NoError(t, err)
+		receiptIDs = append(receiptIDs, receipt.ID)
+	}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer admin-token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("admin token required", func(t *testing.T) {
+		for _, url := range []string{"/api/webhooks/receipts", "/api/webhooks/receipts/" + receiptIDs[0]} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+			assert.Equal(t, http.StatusUnauthorized, w.Code, url)
+			assert.NotContains(t, w.Body.String(), "invoice")
+		}
+	})
+
+	t.Run("list with filters and totals", func(t *testing.T) {
+		w := get("/api/webhooks/receipts?source=billing&event=invoice.paid&status=pending&limit=1")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var page struct {
+			Receipts   []*models.WebhookReceipt     `json:"receipts"`
+			NextCursor string                       `json:"next_cursor"`
+			Total      int                          `json:"total"`
+			Counts     map[models.WebhookStatus]int `json:"counts"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Receipts, 1)
+		assert.Equal(t, "invoice.paid", page.Receipts[0].Event)
+		assert.Equal(t, 2, page.Total)
+		assert.Equal(t, 2, page.Counts[models.WebhookStatusPending])
+		require.NotEmpty(t, page.NextCursor)
+
+		w = get("/api/webhooks/receipts?source=billing&event=invoice.paid&limit=1&cursor=" + page.NextCursor)
+		require.Equal(t, http.StatusOK, w.Code)
+		page.NextCursor = ""
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Receipts, 1)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"cursor=invalid", "limit=0", "created_after=yesterday", "created_before=2024-13-01"} {
+			w := get("/api/webhooks/receipts?" + query)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("inspect receipt", func(t *testing.T) {
+		w := get("/api/webhooks/receipts/" + receiptIDs[2])
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var detail struct {
+			ID      string              `json:"id"`
+			Headers map[string][]string `json:"headers"`
+			Payload map[string]string   `json:"payload"`
+			History []struct {
+				Status models.WebhookStatus `json:"status"`
+			} `json:"history"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+		assert.Equal(t, receiptIDs[2], detail.ID)
+		assert.Equal(t, []string{"invoice.voided"}, detail.Headers["X-Event-Type"])
+		assert.Equal(t, "invoice.voided", detail.Payload["event"])
+		require.Len(t, detail.History, 1)
+		assert.Equal(t, models.WebhookStatusPending, detail.History[0].Status)
+
+		assert.Equal(t, http.StatusNotFound, get("/api/webhooks/receipts/missing").Code)
+	})
+}
+
+func TestHandleWebhookSources(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator := auth.NewTokenAuthenticator(map[string]auth.Role{"admin-token": auth.RoleAdmin})
+	mockQueue := &queue.MockQueue{}
+	mockQueue.On("AddJob", mock.Anything, mock.Anything).Return("test-job-id", nil)
+	router := newTestRouter(t, Dependencies{JobQueue: mockQueue}, authenticator)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		token      string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "missing token",
+			method:     http.MethodGet,
+			url:        "/api/admin/webhook-sources",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "deliveries from unknown sources are rejected",
+			method:     http.MethodPost,
+			url:        "/api/webhooks/billing",
+			body:       `{"event":"invoice"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "create source",
+			method:     http.MethodPost,
+			url:        "/api/admin/webhook-sources",
+			token:      "admin-token",
+			body:       `{"name":"billing","verifier_type":"hmac","secrets":["billing-secret"],"event_header":"X-Billing-Event","allowed_ips":["127.0.0.0/8","192.0.2.1"]}`,
+			wantStatus: http.StatusCreated,
+			wantBody:   `"name":"billing"`,
+		},
+		{
+			name:       "duplicate source",
+			method:     http.MethodPost,
+			url:        "/api/admin/webhook-sources",
+			token:      "admin-token",
+			body:       `{"name":"billing","verifier_type":"hmac","secrets":["other"]}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "invalid source",
+			method:     http.MethodPost,
+			url:        "/api/admin/webhook-sources",
+			token:      "admin-token",
+			body:       `{"name":"mail","verifier_type":"sendgrid","secrets":["not a key"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "secrets are not returned",
+			method:     http.MethodGet,
+			url:        "/api/admin/webhook-sources/billing",
+			token:      "admin-token",
+			wantStatus: http.StatusOK,
+			wantBody:   `"allowed_ips":["127.0.0.0/8","192.0.2.1"]`,
+		},
+		{
+			name:       "deliveries from the new source are accepted without a restart",
+			method:     http.MethodPost,
+			url:        "/api/webhooks/billing",
+			body:       `{"event":"invoice"}`,
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "disable source",
+			method:     http.MethodPatch,
+			url:        "/api/admin/webhook-sources/billing",
+			token:      "admin-token",
+			body:       `{"enabled":false}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"enabled":false`,
+		},
+		{
+			name:       "deliveries from disabled sources are rejected",
+			method:     http.MethodPost,
+			url:        "/api/webhooks/billing",
+			body:       `{"event":"invoice"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "list sources",
+			method:     http.MethodGet,
+			url:        "/api/admin/webhook-sources",
+			token:      "admin-token",
+			wantStatus: http.StatusOK,
+			wantBody:   `"sources":[{`,
+		},
+		{
+			name:       "delete source",
+			method:     http.MethodDelete,
+			url:        "/api/admin/webhook-sources/billing",
+			token:      "admin-token",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "get deleted source",
+			method:     http.MethodGet,
+			url:        "/api/admin/webhook-sources/billing",
+			token:      "admin-token",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if strings.HasPrefix(tt.url, "/api/webhooks/") {
+				req.Header.Set("X-Billing-Event", "invoice.paid")
+				mac := hmac.New(sha256.New, []byte("billing-secret"))
+				mac.Write([]byte(tt.body))
+				req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantBody != "" {
+				assert.Contains(t, w.Body.String(), tt.wantBody)
+			}
+			assert.NotContains(t, w.Body.String(), "billing-secret")
+		})
+	}
+}
+
+func TestHandleWebhookForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockQueue := &queue.MockQueue{}
+	mockQueue.On("AddJob", mock.Anything, mock.Anything).Return("test-job-id", nil)
+	webhookService := testWebhooks()
+	_, err := webhookService.CreateSource(context.Background(), &models.WebhookSourceRequest{
+		Name:         "billing",
+		VerifierType: models.WebhookVerifierHMAC,
+		Secrets:      []string{"billing-secret"},
+		AllowedIPs:   []string{"198.51.100.7"},
+	})
+	require.NoError(t, err)
+
+	// Each delivery has its own payload, since replayed payloads are duplicates
+	delivered := 0
+	deliver := func(router *gin.Engine, remoteAddr, forwardedFor string) int {
+		delivered++
+		body := fmt.Sprintf(`{"event":"invoice","sequence":%d}`, delivered)
+		req := httptest.NewRequest(http.MethodPost, "/api/webhooks/billing", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.Header.Set(webhook.EventHeader, "invoice.paid")
+		mac := hmac.New(sha256.New, []byte("billing-secret"))
+		mac.Write([]byte(body))
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Without trusted proxies a forged X-Forwarded-For is ignored
+	router := newTestRouter(t, Dependencies{JobQueue: mockQueue, WebhookService: webhookService}, nil)
+	assert.Equal(t, http.StatusForbidden, deliver(router, "203.0.113.9:4321", "198.51.100.7"))
+	assert.Equal(t, http.StatusAccepted, deliver(router, "198.51.100.7:4321", ""))
+
+	// Behind a trusted proxy the forwarded client IP is checked
+	router, err = NewRouter(testDependencies(t, Dependencies{JobQueue: mockQueue, WebhookService: webhookService}), nil, []string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, deliver(router, "10.0.0.2:4321", "198.51.100.7"))
+	assert.Equal(t, http.StatusForbidden, deliver(router, "203.0.113.9:4321", "198.51.100.7"))
+
+	_, err = NewRouter(testDependencies(t, Dependencies{}), nil, []string{"not-an-ip"})
+	assert.Error(t, err)
+}
+
+func TestHandleWebhookSecrets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator := auth.NewTokenAuthenticator(map[string]auth.Role{"admin-token": auth.RoleAdmin})
+	webhookService := testWebhooks()
+	router := newTestRouter(t, Dependencies{WebhookService: webhookService}, authenticator)
+
+	source, err := webhookService.CreateSource(context.Background(), &models.WebhookSourceRequest{Name: "billing", VerifierType: models.WebhookVerifierHMAC, Secrets: []string{"old-secret"}})
+	require.NoError(t, err)
+	oldID := source.Secrets[0].ID
+
+	do := func(method, url, body string) (int, *models.WebhookSource) {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer admin-token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.NotContains(t, w.Body.String(), "-secret\"")
+
+		var source models.WebhookSource
+		_ = json.Unmarshal(w.Body.Bytes(), &source)
+		return w.Code, &source
+	}
+
+	status, _ := do(http.MethodPost, "/api/admin/webhook-sources/billing/secrets", `{}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = do(http.MethodPost, "/api/admin/webhook-sources/unknown/secrets", `{"value":"new-secret"}`)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, source = do(http.MethodPost, "/api/admin/webhook-sources/billing/secrets", `{"value":"new-secret"}`)
+	require.Equal(t, http.StatusCreated, status)
+	require.Len(t, source.Secrets, 2)
+	newID := source.Secrets[1].ID
+
+	status, _ = do(http.MethodPost, "/api/admin/webhook-sources/billing/secrets/unknown/promote", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = do(http.MethodPost, "/api/admin/webhook-sources/billing/secrets/"+newID+"/promote", `{"expire_previous_after":"later"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, source = do(http.MethodPost, "/api/admin/webhook-sources/billing/secrets/"+newID+"/promote", `{"expire_previous_after":"24h"}`)
+	require.Equal(t, http.StatusOK, status)
+	primary, ok := source.Secrets.Primary()
+	require.True(t, ok)
+	assert.Equal(t, newID, primary.ID)
+	previous, ok := source.Secrets.Get(oldID)
+	require.True(t, ok)
+	assert.NotNil(t, previous.ExpiresAt)
+
+	status, _ = do(http.MethodDelete, "/api/admin/webhook-sources/billing/secrets/"+newID, "")
+	assert.Equal(t, http.StatusBadRequest, status, "the primary secret cannot be retired")
+	status, source = do(http.MethodDelete, "/api/admin/webhook-sources/billing/secrets/"+oldID, "")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, source.Secrets, 1)
+	assert.Equal(t, newID, source.Secrets[0].ID)
+}
+
 func TestHandleWebSocket(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockQueue := &queue.MockQueue{}
-	mockRepo := webhook.NewMockRepository()
-	webhookService := webhook.NewService(mockRepo)
-	handlers := NewHandlers(mockQueue, webhookService)
+	webhookService := testWebhooks()
+	handlers := newTestHandlers(t, Dependencies{JobQueue: mockQueue, WebhookService: webhookService})
 
 	// Start the WebSocket server
 	go handlers.wsServer.Start()
@@ -1,16 +1,26 @@
 package api
 
 import (
-	"github.com/dustinleblanc/go-bespin-api/internal/queue"
-	"github.com/dustinleblanc/go-bespin-api/internal/webhook"
+	"fmt"
+	"strings"
+
+	"github.com/dustinleblanc/go-bespin-api/internal/auth"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// NewRouter creates a new router with all routes configured
-func NewRouter(jobQueue queue.Queue, webhookService *webhook.Service) *gin.Engine {
+// NewRouter creates a new router with all routes configured. Admin routes
+// require a bearer token that authenticator grants the admin role. Client IPs
+// are read from forwarding headers only on requests from trustedProxies.
+func NewRouter(deps Dependencies, authenticator *auth.TokenAuthenticator, trustedProxies []string) (*gin.Engine, error) {
 	router := gin.Default()
 
+	// Trust no forwarding headers by default, so clients cannot spoof the IP
+	// addresses webhook sources are restricted to
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
 	// Configure CORS
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
@@ -21,7 +31,10 @@ func NewRouter(jobQueue queue.Queue, webhookService *webhook.Service) *gin.Engin
 	}))
 
 	// Create handlers
-	handlers := NewHandlers(jobQueue, webhookService)
+	handlers := NewHandlers(deps)
+
+	// Routes that change what the system runs require the admin role
+	requireAdmin := auth.RequireRole(authenticator, auth.RoleAdmin)
 
 	// API routes
 	api := router.Group("/api")
@@ -29,16 +42,75 @@ func NewRouter(jobQueue queue.Queue, webhookService *webhook.Service) *gin.Engin
 		// Health check
 		api.GET("/health", handlers.HandleHealthCheck)
 
-		// Random text generation
+		// Jobs
+		api.POST("/jobs", handlers.HandleSubmitJob)
+		api.GET("/job-types", handlers.HandleListJobTypes)
 		api.GET("/random-text", handlers.HandleRandomText)
+		api.GET("/jobs", handlers.HandleListJobs)
 		api.GET("/jobs/:id", handlers.HandleGetJobResult)
+		api.DELETE("/jobs/:id", handlers.HandleCancelJob)
+
+		// Jobs archived after exhausting their retries, which only admins may see
+		api.GET("/dead-letters", requireAdmin, handlers.HandleListDeadLetters)
+		api.DELETE("/dead-letters", requireAdmin, handlers.HandlePurgeDeadLetters)
+		api.POST("/dead-letters/replay", requireAdmin, handlers.HandleReplayDeadLetters)
+		api.GET("/dead-letters/:id", requireAdmin, handlers.HandleGetDeadLetter)
+		api.DELETE("/dead-letters/:id", requireAdmin, handlers.HandleDeleteDeadLetter)
+		api.POST("/dead-letters/:id/replay", requireAdmin, handlers.HandleReplayDeadLetter)
+
+		// Recurring job schedules, which only admins may change
+		api.POST("/schedules", requireAdmin, handlers.HandleCreateSchedule)
+		api.GET("/schedules", handlers.HandleListSchedules)
+		api.GET("/schedules/:id", handlers.HandleGetSchedule)
+		api.PATCH("/schedules/:id", requireAdmin, handlers.HandleUpdateSchedule)
+		api.DELETE("/schedules/:id", requireAdmin, handlers.HandleDeleteSchedule)
+
+		// Workflows of dependent jobs
+		api.POST("/workflows", handlers.HandleCreateWorkflow)
+		api.GET("/workflows/:id", handlers.HandleGetWorkflow)
 
-		// Webhooks
+		// Batches of jobs submitted together
+		api.POST("/batches", handlers.HandleCreateBatch)
+		api.GET("/batches/:id", handlers.HandleGetBatch)
+
+		// Webhooks, whose receipts hold provider payloads and headers only admins may see
 		api.POST("/webhooks/:source", handlers.HandleWebhook)
+		api.GET("/webhooks/receipts", requireAdmin, handlers.HandleListWebhookReceipts)
+		api.GET("/webhooks/receipts/:id", requireAdmin, handlers.HandleGetWebhookReceipt)
 
 		// WebSocket
 		api.GET("/ws", handlers.HandleWebSocket)
+
+		// Queue administration
+		admin := api.Group("/admin", requireAdmin)
+		admin.GET("/queues", handlers.HandleListQueueStats)
+		admin.GET("/queues/:name", handlers.HandleGetQueueStats)
+		admin.POST("/queues/:name/pause", handlers.HandlePauseQueue)
+		admin.POST("/queues/:name/resume", handlers.HandleResumeQueue)
+		admin.POST("/queues/:name/drain", handlers.HandleDrainQueue)
+
+		// Webhook source administration
+		admin.POST("/webhook-sources", handlers.HandleCreateWebhookSource)
+		admin.GET("/webhook-sources", handlers.HandleListWebhookSources)
+		admin.GET("/webhook-sources/:name", handlers.HandleGetWebhookSource)
+		admin.PATCH("/webhook-sources/:name", handlers.HandleUpdateWebhookSource)
+		admin.DELETE("/webhook-sources/:name", handlers.HandleDeleteWebhookSource)
+		admin.POST("/webhook-sources/:name/secrets", handlers.HandleAddWebhookSecret)
+		admin.POST("/webhook-sources/:name/secrets/:id/promote", handlers.HandlePromoteWebhookSecret)
+		admin.DELETE("/webhook-sources/:name/secrets/:id", handlers.HandleRetireWebhookSecret)
 	}
 
-	return router
+	return router, nil
+}
+
+// ParseTrustedProxies parses a comma-separated list of the IP addresses and
+// CIDR ranges of the proxies in front of the API
+func ParseTrustedProxies(value string) []string {
+	var proxies []string
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
 }
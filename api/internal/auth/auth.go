@@ -0,0 +1,108 @@
+// Package auth authenticates API callers by bearer token and restricts routes
+// to callers holding a role.
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Role is a set of permissions granted to an API token
+type Role string
+
+// Roles
+const (
+	// RoleAdmin may manage queues and other operational settings
+	RoleAdmin Role = "admin"
+)
+
+// roleContextKey is the gin context key the caller's role is stored under
+const roleContextKey = "auth.role"
+
+// TokenAuthenticator maps bearer tokens to roles
+type TokenAuthenticator struct {
+	tokens map[string]Role
+}
+
+// NewTokenAuthenticator creates a new TokenAuthenticator
+func NewTokenAuthenticator(tokens map[string]Role) *TokenAuthenticator {
+	return &TokenAuthenticator{tokens: tokens}
+}
+
+// ParseTokens builds a token map from a comma separated list of tokens, each
+// granted role. Empty entries are ignored.
+func ParseTokens(value string, role Role) map[string]Role {
+	tokens := make(map[string]Role)
+	for _, token := range strings.Split(value, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens[token] = role
+		}
+	}
+	return tokens
+}
+
+// Authenticate returns the role granted to token. Tokens are compared in
+// constant time so their contents cannot be guessed from response times.
+func (a *TokenAuthenticator) Authenticate(token string) (Role, bool) {
+	if token == "" {
+		return "", false
+	}
+
+	var role Role
+	found := false
+	for candidate, r := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			role, found = r, true
+		}
+	}
+	return role, found
+}
+
+// RequireRole returns middleware that rejects requests without a valid
+// bearer token with 401 and requests whose token lacks role with 403
+func RequireRole(authenticator *TokenAuthenticator, role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+			return
+		}
+
+		granted, ok := authenticator.Authenticate(token)
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid bearer token"})
+			return
+		}
+		if granted != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient role"})
+			return
+		}
+
+		c.Set(roleContextKey, granted)
+		c.Next()
+	}
+}
+
+// RoleFromContext returns the role of the authenticated caller
+func RoleFromContext(c *gin.Context) (Role, bool) {
+	value, ok := c.Get(roleContextKey)
+	if !ok {
+		return "", false
+	}
+	role, ok := value.(Role)
+	return role, ok
+}
+
+// bearerToken extracts the token from an Authorization header
+func bearerToken(header string) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
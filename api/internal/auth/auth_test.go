@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTokens(t *testing.T) {
+	tokens := ParseTokens(" first, ,second,", RoleAdmin)
+	assert.Equal(t, map[string]Role{"first": RoleAdmin, "second": RoleAdmin}, tokens)
+	assert.Empty(t, ParseTokens("", RoleAdmin))
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator := NewTokenAuthenticator(map[string]Role{
+		"admin-token": RoleAdmin,
+		"other-token": Role("viewer"),
+	})
+
+	router := gin.New()
+	router.GET("/admin", RequireRole(authenticator, RoleAdmin), func(c *gin.Context) {
+		role, _ := RoleFromContext(c)
+		c.String(http.StatusOK, string(role))
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "admin token", authorization: "Bearer admin-token", wantStatus: http.StatusOK},
+		{name: "lowercase scheme", authorization: "bearer admin-token", wantStatus: http.StatusOK},
+		{name: "missing header", wantStatus: http.StatusUnauthorized},
+		{name: "wrong scheme", authorization: "Basic admin-token", wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", authorization: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "token without role", authorization: "Bearer other-token", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, string(RoleAdmin), w.Body.String())
+			}
+		})
+	}
+}
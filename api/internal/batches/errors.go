@@ -0,0 +1,11 @@
+package batches
+
+import (
+	"errors"
+)
+
+// Error definitions
+var (
+	ErrBatchNotFound = errors.New("batch not found")
+	ErrInvalidBatch  = errors.New("invalid batch")
+)
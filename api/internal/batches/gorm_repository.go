@@ -0,0 +1,121 @@
+package batches
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"gorm.io/gorm"
+)
+
+// GormRepository implements Repository using GORM
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRepository creates a new GORM repository
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{db: db}
+}
+
+// Create creates a new batch
+func (r *GormRepository) Create(ctx context.Context, batch *models.Batch) error {
+	result := r.db.WithContext(ctx).Create(batch)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create batch: %w", result.Error)
+	}
+	return nil
+}
+
+// GetByID retrieves a batch by ID
+func (r *GormRepository) GetByID(ctx context.Context, id string) (*models.Batch, error) {
+	var batch models.Batch
+	result := r.db.WithContext(ctx).First(&batch, "id = ?", id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrBatchNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get batch: %w", result.Error)
+	}
+	return &batch, nil
+}
+
+// Update updates a batch
+func (r *GormRepository) Update(ctx context.Context, batch *models.Batch) error {
+	result := r.db.WithContext(ctx).Save(batch)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update batch: %w", result.Error)
+	}
+	return nil
+}
+
+// ListRunning lists the running batches, oldest first
+func (r *GormRepository) ListRunning(ctx context.Context) ([]*models.Batch, error) {
+	var batches []*models.Batch
+	result := r.db.WithContext(ctx).
+		Where("status = ?", models.BatchStatusRunning).
+		Order("created_at ASC").
+		Find(&batches)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list batches: %w", result.Error)
+	}
+	return batches, nil
+}
+
+// ListSubmitting lists the batches created before the given time that are
+// still submitting, oldest first
+func (r *GormRepository) ListSubmitting(ctx context.Context, before time.Time) ([]*models.Batch, error) {
+	var batches []*models.Batch
+	result := r.db.WithContext(ctx).
+		Where("status = ? AND created_at < ?", models.BatchStatusSubmitting, before).
+		Order("created_at ASC").
+		Find(&batches)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list batches: %w", result.Error)
+	}
+	return batches, nil
+}
+
+// MarkRunning marks a submitting batch as running in a single conditional update
+func (r *GormRepository) MarkRunning(ctx context.Context, id string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.Batch{}).
+		Where("id = ? AND status = ?", id, models.BatchStatusSubmitting).
+		Updates(map[string]interface{}{
+			"status":     models.BatchStatusRunning,
+			"updated_at": at,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to start batch: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteSubmitting deletes a submitting batch in a single conditional delete
+func (r *GormRepository) DeleteSubmitting(ctx context.Context, id string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND status = ?", id, models.BatchStatusSubmitting).
+		Delete(&models.Batch{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete batch: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// MarkCompleted marks a running batch as completed in a single conditional update
+func (r *GormRepository) MarkCompleted(ctx context.Context, id, callbackJobID string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.Batch{}).
+		Where("id = ? AND status = ?", id, models.BatchStatusRunning).
+		Updates(map[string]interface{}{
+			"status":          models.BatchStatusCompleted,
+			"callback_job_id": callbackJobID,
+			"completed_at":    at,
+			"updated_at":      at,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to complete batch: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,151 @@
+package batches
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// MockRepository is an in-memory implementation of the Repository interface
+type MockRepository struct {
+	batches map[string]*models.Batch
+	mu      sync.RWMutex
+}
+
+// NewMockRepository creates a new mock repository
+func NewMockRepository() *MockRepository {
+	return &MockRepository{
+		batches: make(map[string]*models.Batch),
+	}
+}
+
+// Create stores a batch in memory
+func (r *MockRepository) Create(ctx context.Context, batch *models.Batch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.batches[batch.ID]; ok {
+		return fmt.Errorf("batch already exists: %s", batch.ID)
+	}
+
+	copied := *batch
+	r.batches[batch.ID] = &copied
+	return nil
+}
+
+// GetByID retrieves a batch by ID from memory
+func (r *MockRepository) GetByID(ctx context.Context, id string) (*models.Batch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	batch, ok := r.batches[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrBatchNotFound, id)
+	}
+	copied := *batch
+	return &copied, nil
+}
+
+// Update updates a batch in memory
+func (r *MockRepository) Update(ctx context.Context, batch *models.Batch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.batches[batch.ID]; !ok {
+		return fmt.Errorf("%w: %s", ErrBatchNotFound, batch.ID)
+	}
+
+	copied := *batch
+	r.batches[batch.ID] = &copied
+	return nil
+}
+
+// ListRunning lists the running batches in memory, oldest first
+func (r *MockRepository) ListRunning(ctx context.Context) ([]*models.Batch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var batches []*models.Batch
+	for _, batch := range r.batches {
+		if batch.Status == models.BatchStatusRunning {
+			copied := *batch
+			batches = append(batches, &copied)
+		}
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].CreatedAt.Before(batches[j].CreatedAt)
+	})
+	return batches, nil
+}
+
+// ListSubmitting lists the batches in memory created before the given time
+// that are still submitting, oldest first
+func (r *MockRepository) ListSubmitting(ctx context.Context, before time.Time) ([]*models.Batch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var batches []*models.Batch
+	for _, batch := range r.batches {
+		if batch.Status == models.BatchStatusSubmitting && batch.CreatedAt.Before(before) {
+			copied := *batch
+			batches = append(batches, &copied)
+		}
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].CreatedAt.Before(batches[j].CreatedAt)
+	})
+	return batches, nil
+}
+
+// MarkRunning marks a submitting batch in memory as running
+func (r *MockRepository) MarkRunning(ctx context.Context, id string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	batch, ok := r.batches[id]
+	if !ok || batch.Status != models.BatchStatusSubmitting {
+		return false, nil
+	}
+
+	batch.Status = models.BatchStatusRunning
+	batch.UpdatedAt = at
+	return true, nil
+}
+
+// DeleteSubmitting deletes a submitting batch from memory
+func (r *MockRepository) DeleteSubmitting(ctx context.Context, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	batch, ok := r.batches[id]
+	if !ok || batch.Status != models.BatchStatusSubmitting {
+		return false, nil
+	}
+
+	delete(r.batches, id)
+	return true, nil
+}
+
+// MarkCompleted marks a running batch in memory as completed
+func (r *MockRepository) MarkCompleted(ctx context.Context, id, callbackJobID string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	batch, ok := r.batches[id]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrBatchNotFound, id)
+	}
+	if batch.Status != models.BatchStatusRunning {
+		return false, nil
+	}
+
+	batch.Status = models.BatchStatusCompleted
+	batch.CallbackJobID = callbackJobID
+	batch.CompletedAt = &at
+	batch.UpdatedAt = at
+	return true, nil
+}
@@ -0,0 +1,40 @@
+package batches
+
+import (
+	"context"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// Repository defines the interface for batch storage
+type Repository interface {
+	// Create creates a new batch
+	Create(ctx context.Context, batch *models.Batch) error
+
+	// GetByID retrieves a batch by ID
+	GetByID(ctx context.Context, id string) (*models.Batch, error)
+
+	// Update updates a batch
+	Update(ctx context.Context, batch *models.Batch) error
+
+	// ListRunning lists the running batches, oldest first
+	ListRunning(ctx context.Context) ([]*models.Batch, error)
+
+	// ListSubmitting lists the batches created before the given time that
+	// are still submitting their jobs, oldest first
+	ListSubmitting(ctx context.Context, before time.Time) ([]*models.Batch, error)
+
+	// MarkRunning marks a submitting batch as running once its jobs are
+	// enqueued. It returns false if the batch was not submitting anymore.
+	MarkRunning(ctx context.Context, id string, at time.Time) (bool, error)
+
+	// DeleteSubmitting deletes a batch that is still submitting its jobs. It
+	// returns false if the batch was not submitting.
+	DeleteSubmitting(ctx context.Context, id string) (bool, error)
+
+	// MarkCompleted marks a running batch as completed and stores the ID of
+	// its callback job, if any. It returns false if the batch was not
+	// running, so only one caller completes a batch.
+	MarkCompleted(ctx context.Context, id, callbackJobID string, at time.Time) (bool, error)
+}
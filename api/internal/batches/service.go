@@ -0,0 +1,378 @@
+// Package batches submits many jobs at once and tracks them as a group. A
+// batch is stored before its jobs are enqueued, and a submission that fails
+// or is interrupted is rolled back. A batch's counts are read from the job
+// history. Once every job of a batch has finished, the batch is completed
+// and its callback job, if any, is enqueued.
+package batches
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/internal/jobs"
+	"github.com/dustinleblanc/go-bespin-api/internal/pagination"
+	"github.com/dustinleblanc/go-bespin-api/internal/queue"
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+)
+
+// MaxJobs is the maximum number of jobs in a batch
+const MaxJobs = 1000
+
+// DefaultReconcileInterval is how often running batches are checked against
+// the job history by default
+const DefaultReconcileInterval = time.Minute
+
+// SubmitTimeout is how long a batch may take to enqueue its jobs. Reconcile
+// rolls back batches that have been submitting for longer, since their
+// submission was interrupted.
+const SubmitTimeout = 10 * time.Minute
+
+// BatchService defines the interface for batch operations
+type BatchService interface {
+	CreateBatch(ctx context.Context, name string, jobs []*models.Job, callback *models.Job) (*models.Batch, error)
+	GetBatch(ctx context.Context, id string) (*models.Batch, error)
+	HandleJobEvent(ctx context.Context, event *events.JobEvent) error
+}
+
+// Ensure Service implements BatchService
+var _ BatchService = (*Service)(nil)
+
+// Enqueuer enqueues the jobs of batches and their callbacks
+type Enqueuer interface {
+	// AddJob adds a job to the queue
+	AddJob(ctx context.Context, job *models.Job) (string, error)
+	// AddJobs adds many jobs to the queue and returns their IDs. If a job
+	// fails to enqueue, the jobs already enqueued are cancelled.
+	AddJobs(ctx context.Context, jobs []*models.Job) ([]string, error)
+	// CancelJob cancels a pending or running job
+	CancelJob(ctx context.Context, jobID string) error
+}
+
+// JobHistory reads the recorded status of jobs
+type JobHistory interface {
+	// GetJob gets a job record by ID
+	GetJob(ctx context.Context, id string) (*models.JobRecord, error)
+	// ListJobs lists jobs matching the filter, newest first
+	ListJobs(ctx context.Context, filter jobs.ListFilter) (*jobs.JobPage, error)
+	// CountJobs counts the jobs matching the filter by status
+	CountJobs(ctx context.Context, filter jobs.ListFilter) (map[models.JobStatus]int, error)
+	// HandleJobEvent applies a job event to the job's record
+	HandleJobEvent(ctx context.Context, event *events.JobEvent) error
+}
+
+// Service submits batches and completes them as their jobs finish
+type Service struct {
+	repo     Repository
+	enqueuer Enqueuer
+	history  JobHistory
+	logger   *log.Logger
+}
+
+// NewService creates a new batch service. Jobs are enqueued with enqueuer
+// and the counts of batches are read from history.
+func NewService(repo Repository, enqueuer Enqueuer, history JobHistory) *Service {
+	return &Service{
+		repo:     repo,
+		enqueuer: enqueuer,
+		history:  history,
+		logger:   log.New(log.Writer(), "[BatchService] ", log.LstdFlags),
+	}
+}
+
+// CreateBatch stores a batch and enqueues its jobs. The batch is stored as
+// submitting first, so jobs enqueued by a submission that is interrupted
+// always have a batch that Reconcile can roll back. A batch whose jobs could
+// not be enqueued is deleted again, and jobs that finish before the batch is
+// running are caught up with from the job history.
+func (s *Service) CreateBatch(ctx context.Context, name string, batchJobs []*models.Job, callback *models.Job) (*models.Batch, error) {
+	switch {
+	case len(batchJobs) == 0:
+		return nil, fmt.Errorf("%w: at least one job is required", ErrInvalidBatch)
+	case len(batchJobs) > MaxJobs:
+		return nil, fmt.Errorf("%w: at most %d jobs are allowed", ErrInvalidBatch, MaxJobs)
+	}
+
+	batch := models.NewBatch(name, len(batchJobs))
+	if callback != nil {
+		// The callback is a follow-up job of the batch, so validate it like one
+		chain, err := (&models.Job{OnSuccess: callback}).Chain()
+		if err == nil {
+			err = chain.Validate()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: callback: %v", ErrInvalidBatch, err)
+		}
+
+		// Store the callback as a request, which keeps its durations
+		req, err := models.NewJobRequest(callback)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode callback job: %w", err)
+		}
+		data, err := json.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode callback job: %w", err)
+		}
+		batch.Callback = models.JSON(data)
+	}
+
+	batch.Status = models.BatchStatusSubmitting
+	if err := s.repo.Create(ctx, batch); err != nil {
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	for _, job := range batchJobs {
+		job.BatchID = batch.ID
+	}
+	ids, err := s.enqueuer.AddJobs(ctx, batchJobs)
+	if err != nil {
+		// AddJobs has cancelled the jobs it enqueued
+		if _, deleteErr := s.repo.DeleteSubmitting(ctx, batch.ID); deleteErr != nil {
+			s.logger.Printf("Failed to delete batch %s that could not be submitted: %v", batch.ID, deleteErr)
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	started, err := s.repo.MarkRunning(ctx, batch.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, fmt.Errorf("batch %s took longer than %s to submit and was rolled back", batch.ID, SubmitTimeout)
+	}
+	batch.Status = models.BatchStatusRunning
+	batch.UpdatedAt = now
+	s.logger.Printf("Submitted batch %s (%s) with %d jobs", batch.ID, batch.Name, batch.Total)
+
+	if err := s.check(ctx, batch); err != nil {
+		return nil, err
+	}
+	batch.JobIDs = ids
+	return batch, nil
+}
+
+// GetBatch gets a batch by ID along with its counts
+func (s *Service) GetBatch(ctx context.Context, id string) (*models.Batch, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	batch, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+	if err := s.count(ctx, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// HandleJobEvent completes the batch of a job that finished once every job
+// of the batch has finished. It must run after the job history has applied
+// the event.
+func (s *Service) HandleJobEvent(ctx context.Context, event *events.JobEvent) error {
+	switch event.Kind {
+	case events.KindCompleted, events.KindFailed, events.KindCancelled:
+	default:
+		return nil
+	}
+
+	record, err := s.history.GetJob(ctx, event.JobID)
+	if err != nil {
+		if errors.Is(err, jobs.ErrJobNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+	if record.BatchID == "" {
+		return nil
+	}
+
+	batch, err := s.repo.GetByID(ctx, record.BatchID)
+	if err != nil {
+		if errors.Is(err, ErrBatchNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get batch: %w", err)
+	}
+	return s.check(ctx, batch)
+}
+
+// Reconcile rolls back batches whose submission was interrupted and checks
+// every running batch against the job history. It completes the batches
+// whose last job event was missed and enqueues the callbacks that failed to
+// enqueue, since HandleJobEvent only sees the events delivered to this
+// process.
+func (s *Service) Reconcile(ctx context.Context) error {
+	abandoned, err := s.repo.ListSubmitting(ctx, time.Now().Add(-SubmitTimeout))
+	if err != nil {
+		return err
+	}
+	for _, batch := range abandoned {
+		if err := s.rollback(ctx, batch); err != nil {
+			s.logger.Printf("Failed to roll back batch %s: %v", batch.ID, err)
+		}
+	}
+
+	running, err := s.repo.ListRunning(ctx)
+	if err != nil {
+		return err
+	}
+	for _, batch := range running {
+		if err := s.check(ctx, batch); err != nil {
+			s.logger.Printf("Failed to reconcile batch %s: %v", batch.ID, err)
+		}
+	}
+	return nil
+}
+
+// rollback cancels the jobs of a batch whose submission was interrupted and
+// deletes the batch, like a submission that failed. The jobs are cancelled
+// first, so a rollback that is interrupted itself is picked up again by the
+// next Reconcile.
+func (s *Service) rollback(ctx context.Context, batch *models.Batch) error {
+	filter := jobs.ListFilter{BatchID: batch.ID, Limit: jobs.MaxListLimit}
+	for {
+		page, err := s.history.ListJobs(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, record := range page.Jobs {
+			if err := s.cancel(ctx, record); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		last := page.Jobs[len(page.Jobs)-1]
+		filter.Cursor = &pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	deleted, err := s.repo.DeleteSubmitting(ctx, batch.ID)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		s.logger.Printf("Rolled back batch %s, whose submission was interrupted", batch.ID)
+	}
+	return nil
+}
+
+// cancel cancels a job of a batch that is rolled back. Jobs that were
+// recorded but never enqueued are marked as cancelled in the job history.
+func (s *Service) cancel(ctx context.Context, record *models.JobRecord) error {
+	if record.Status.IsFinal() {
+		return nil
+	}
+
+	err := s.enqueuer.CancelJob(ctx, record.ID)
+	switch {
+	case err == nil, errors.Is(err, queue.ErrJobNotCancellable):
+		return nil
+	case errors.Is(err, queue.ErrJobNotFound):
+		event := events.NewJobEvent(events.KindCancelled, record.ID, string(record.Type))
+		event.Error = "job could not be enqueued"
+		return s.history.HandleJobEvent(ctx, event)
+	default:
+		return fmt.Errorf("failed to cancel job %s: %w", record.ID, err)
+	}
+}
+
+// check completes a running batch whose jobs have all finished. Its callback
+// is enqueued before the batch is marked as completed, so a batch whose
+// callback fails to enqueue stays running and is checked again by Reconcile;
+// the callback's idempotency key keeps concurrent or repeated checks
+// from enqueueing it twice.
+func (s *Service) check(ctx context.Context, batch *models.Batch) error {
+	if batch.Status != models.BatchStatusRunning {
+		return nil
+	}
+	if err := s.count(ctx, batch); err != nil {
+		return err
+	}
+	if batch.Counts.Finished() < batch.Total {
+		return nil
+	}
+
+	var callbackJobID string
+	if len(batch.Callback) > 0 {
+		jobID, err := s.enqueueCallback(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("failed to enqueue callback of batch %s: %w", batch.ID, err)
+		}
+		callbackJobID = jobID
+	}
+
+	now := time.Now()
+	completed, err := s.repo.MarkCompleted(ctx, batch.ID, callbackJobID, now)
+	if err != nil {
+		return fmt.Errorf("failed to complete batch: %w", err)
+	}
+	if !completed {
+		return nil
+	}
+	batch.Status = models.BatchStatusCompleted
+	batch.CallbackJobID = callbackJobID
+	batch.CompletedAt = &now
+	batch.UpdatedAt = now
+	s.logger.Printf("Batch %s completed: %d completed, %d failed, %d cancelled",
+		batch.ID, batch.Counts.Completed, batch.Counts.Failed, batch.Counts.Cancelled)
+	if callbackJobID != "" {
+		s.logger.Printf("Enqueued callback job %s of batch %s", callbackJobID, batch.ID)
+	}
+	return nil
+}
+
+// enqueueCallback rebuilds the callback job of a completed batch from its
+// stored request, renders its data with the batch's ID and counts and
+// enqueues it. The callback's idempotency key makes sure it is enqueued only
+// once.
+func (s *Service) enqueueCallback(ctx context.Context, batch *models.Batch) (string, error) {
+	var req models.JobRequest
+	if err := json.Unmarshal(batch.Callback, &req); err != nil {
+		return "", fmt.Errorf("failed to decode callback job: %w", err)
+	}
+	callback, errs := req.Job()
+	if len(errs) > 0 {
+		return "", fmt.Errorf("invalid callback job: %s %s", errs[0].Field, errs[0].Message)
+	}
+
+	counts, err := json.Marshal(batch.Counts)
+	if err != nil {
+		return "", err
+	}
+	parent := &tasks.Parent{ID: batch.ID, Type: "batch", Result: counts}
+	if batch.Counts.Failed > 0 {
+		parent.Error = fmt.Sprintf("%d of %d jobs failed", batch.Counts.Failed, batch.Total)
+	}
+
+	data, err := json.Marshal(callback.Data)
+	if err != nil {
+		return "", err
+	}
+	rendered, err := tasks.RenderPayload(data, parent)
+	if err != nil {
+		return "", err
+	}
+	callback.Data = rendered
+	callback.IdempotencyKey = "batch:" + batch.ID + ":callback"
+
+	return s.enqueuer.AddJob(ctx, callback)
+}
+
+// count reads the counts of a batch from the job history
+func (s *Service) count(ctx context.Context, batch *models.Batch) error {
+	counts, err := s.history.CountJobs(ctx, jobs.ListFilter{BatchID: batch.ID})
+	if err != nil {
+		return fmt.Errorf("failed to count jobs of batch: %w", err)
+	}
+	batch.Counts = models.NewBatchCounts(counts)
+	return nil
+}
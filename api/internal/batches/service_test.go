@@ -0,0 +1,293 @@
+package batches
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/internal/jobs"
+	"github.com/dustinleblanc/go-bespin-api/internal/queue"
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEnqueuer records enqueued jobs in the job history like the queue does
+type fakeEnqueuer struct {
+	history   *jobs.Service
+	jobs      map[string]*models.Job
+	cancelled []string
+	err       error
+	addErr    error
+	// added is called with the IDs of the jobs of a batch once they are enqueued
+	added func(ids []string)
+}
+
+func (e *fakeEnqueuer) AddJob(ctx context.Context, job *models.Job) (string, error) {
+	if e.addErr != nil {
+		return "", e.addErr
+	}
+	id := "job-" + job.IdempotencyKey
+	e.jobs[id] = job
+	return id, nil
+}
+
+func (e *fakeEnqueuer) AddJobs(ctx context.Context, batchJobs []*models.Job) ([]string, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	ids := make([]string, 0, len(batchJobs))
+	for _, job := range batchJobs {
+		id := fmt.Sprintf("job-%d", len(e.jobs))
+		e.jobs[id] = job
+		if err := e.history.RecordJob(ctx, &models.JobRecord{ID: id, Type: job.Type, Status: models.JobStatusPending, BatchID: job.BatchID}); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if e.added != nil {
+		e.added(ids)
+	}
+	return ids, nil
+}
+
+func (e *fakeEnqueuer) CancelJob(ctx context.Context, jobID string) error {
+	job, ok := e.jobs[jobID]
+	if !ok {
+		return queue.ErrJobNotFound
+	}
+	e.cancelled = append(e.cancelled, jobID)
+	return e.history.HandleJobEvent(ctx, events.NewJobEvent(events.KindCancelled, jobID, string(job.Type)))
+}
+
+func newTestService() (*Service, *fakeEnqueuer, *jobs.Service) {
+	history := jobs.NewService(jobs.NewMockRepository())
+	enqueuer := &fakeEnqueuer{history: history, jobs: make(map[string]*models.Job)}
+	return NewService(NewMockRepository(), enqueuer, history), enqueuer, history
+}
+
+func randomText(n int) []*models.Job {
+	batchJobs := make([]*models.Job, n)
+	for i := range batchJobs {
+		batchJobs[i] = &models.Job{Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":10}`)}
+	}
+	return batchJobs
+}
+
+func TestCreateBatchValidation(t *testing.T) {
+	service, enqueuer, _ := newTestService()
+	ctx := context.Background()
+
+	_, err := service.CreateBatch(ctx, "", nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidBatch)
+
+	_, err = service.CreateBatch(ctx, "", randomText(MaxJobs+1), nil)
+	assert.ErrorIs(t, err, ErrInvalidBatch)
+
+	callback := &models.Job{Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":"{{ batch.total }}"}`)}
+	_, err = service.CreateBatch(ctx, "", randomText(1), callback)
+	assert.ErrorIs(t, err, ErrInvalidBatch)
+	assert.Empty(t, enqueuer.jobs)
+
+	// A batch that cannot be enqueued is not stored
+	enqueuer.err = errors.New("redis unavailable")
+	_, err = service.CreateBatch(ctx, "", randomText(2), nil)
+	assert.Error(t, err)
+	left, err := service.repo.ListSubmitting(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, left)
+}
+
+func TestBatchCompletion(t *testing.T) {
+	ctx := context.Background()
+
+	finish := func(t *testing.T, service *Service, history *jobs.Service, kind events.Kind, jobID string) {
+		event := events.NewJobEvent(kind, jobID, "")
+		require.NoError(t, history.HandleJobEvent(ctx, event))
+		require.NoError(t, service.HandleJobEvent(ctx, event))
+	}
+
+	t.Run("callback is enqueued once every job finished", func(t *testing.T) {
+		service, enqueuer, history := newTestService()
+		callback := &models.Job{
+			Type:  models.JobTypeProcessWebhook,
+			Data:  json.RawMessage(`{"receipt_id":"{{ parent.id }}","failed":"{{ parent.result.failed }}"}`),
+			Queue: "low",
+		}
+
+		batch, err := service.CreateBatch(ctx, "nightly", randomText(3), callback)
+		require.NoError(t, err)
+		require.Len(t, batch.JobIDs, 3)
+		assert.Equal(t, models.BatchStatusRunning, batch.Status)
+		assert.Equal(t, models.BatchCounts{Pending: 3}, batch.Counts)
+		ids := batch.JobIDs
+		for _, id := range ids {
+			assert.Equal(t, batch.ID, enqueuer.jobs[id].BatchID)
+		}
+
+		require.NoError(t, history.HandleJobEvent(ctx, events.NewJobEvent(events.KindProcessing, ids[0], "")))
+		finish(t, service, history, events.KindCompleted, ids[1])
+		finish(t, service, history, events.KindFailed, ids[2])
+
+		batch, err = service.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusRunning, batch.Status)
+		assert.Equal(t, models.BatchCounts{Processing: 1, Completed: 1, Failed: 1}, batch.Counts)
+
+		finish(t, service, history, events.KindCompleted, ids[0])
+		batch, err = service.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCompleted, batch.Status)
+		assert.NotNil(t, batch.CompletedAt)
+		assert.Equal(t, models.BatchCounts{Completed: 2, Failed: 1}, batch.Counts)
+
+		require.NotEmpty(t, batch.CallbackJobID)
+		job := enqueuer.jobs[batch.CallbackJobID]
+		assert.Equal(t, models.JobTypeProcessWebhook, job.Type)
+		assert.Equal(t, "low", job.Queue)
+		data, err := json.Marshal(job.Data)
+		require.NoError(t, err)
+		assert.JSONEq(t, fmt.Sprintf(`{"receipt_id":%q,"failed":1}`, batch.ID), string(data))
+
+		// Events after completion do not enqueue the callback again
+		require.NoError(t, service.HandleJobEvent(ctx, events.NewJobEvent(events.KindCompleted, ids[0], "")))
+		assert.Len(t, enqueuer.jobs, 4)
+	})
+
+	t.Run("callback keeps its options and follow-up jobs", func(t *testing.T) {
+		service, enqueuer, history := newTestService()
+		maxRetries := 2
+		callback := &models.Job{
+			Type:       models.JobTypeRandomText,
+			Data:       json.RawMessage(`{"length":5}`),
+			MaxRetries: &maxRetries,
+			Timeout:    time.Minute,
+			Backoff:    &tasks.Backoff{Strategy: tasks.BackoffExponential, Delay: 10 * time.Second, MaxDelay: 5 * time.Minute},
+			OnSuccess:  &models.Job{Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":1}`), Timeout: 30 * time.Second},
+		}
+		batch, err := service.CreateBatch(ctx, "", randomText(1), callback)
+		require.NoError(t, err)
+
+		// The stored callback shows durations as the duration strings requests use
+		stored, err := service.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"type": "random_text",
+			"data": {"length": 5},
+			"options": {
+				"max_retries": 2,
+				"timeout": "1m0s",
+				"backoff": {"strategy": "exponential", "delay": "10s", "max_delay": "5m0s"}
+			},
+			"on_success": {"type": "random_text", "data": {"length": 1}, "options": {"timeout": "30s"}}
+		}`, string(stored.Callback))
+
+		finish(t, service, history, events.KindCompleted, batch.JobIDs[0])
+		stored, err = service.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		job := enqueuer.jobs[stored.CallbackJobID]
+		require.NotNil(t, job)
+		assert.Equal(t, time.Minute, job.Timeout)
+		assert.Equal(t, &maxRetries, job.MaxRetries)
+		assert.Equal(t, callback.Backoff, job.Backoff)
+		require.NotNil(t, job.OnSuccess)
+		assert.Equal(t, 30*time.Second, job.OnSuccess.Timeout)
+	})
+
+	t.Run("callback that fails to enqueue is retried", func(t *testing.T) {
+		service, enqueuer, history := newTestService()
+		callback := &models.Job{Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":5}`)}
+		batch, err := service.CreateBatch(ctx, "", randomText(1), callback)
+		require.NoError(t, err)
+
+		enqueuer.addErr = errors.New("redis unavailable")
+		event := events.NewJobEvent(events.KindCompleted, batch.JobIDs[0], "")
+		require.NoError(t, history.HandleJobEvent(ctx, event))
+		assert.Error(t, service.HandleJobEvent(ctx, event))
+		stored, err := service.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusRunning, stored.Status)
+		assert.Empty(t, stored.CallbackJobID)
+
+		enqueuer.addErr = nil
+		require.NoError(t, service.Reconcile(ctx))
+		stored, err = service.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCompleted, stored.Status)
+		assert.Equal(t, "job-batch:"+batch.ID+":callback", stored.CallbackJobID)
+	})
+
+	t.Run("jobs finished while the batch was submitting", func(t *testing.T) {
+		service, enqueuer, history := newTestService()
+		enqueuer.added = func(ids []string) {
+			// The event arrives before the batch is running, so it is only counted later
+			finish(t, service, history, events.KindCompleted, ids[0])
+			batch, err := service.repo.GetByID(ctx, enqueuer.jobs[ids[0]].BatchID)
+			require.NoError(t, err)
+			assert.Equal(t, models.BatchStatusSubmitting, batch.Status)
+		}
+
+		batch, err := service.CreateBatch(ctx, "", randomText(1), nil)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCompleted, batch.Status)
+		assert.Empty(t, batch.CallbackJobID)
+	})
+
+	t.Run("batches whose submission was interrupted are rolled back", func(t *testing.T) {
+		service, enqueuer, history := newTestService()
+		interrupted := models.NewBatch("", 3)
+		interrupted.Status = models.BatchStatusSubmitting
+		interrupted.CreatedAt = time.Now().Add(-2 * SubmitTimeout)
+		require.NoError(t, service.repo.Create(ctx, interrupted))
+		submitting := models.NewBatch("", 1)
+		submitting.Status = models.BatchStatusSubmitting
+		require.NoError(t, service.repo.Create(ctx, submitting))
+
+		// The first job was enqueued and the second only recorded when the API stopped
+		enqueuer.jobs["job-0"] = &models.Job{Type: models.JobTypeRandomText, BatchID: interrupted.ID}
+		require.NoError(t, history.RecordJob(ctx, &models.JobRecord{ID: "job-0", Type: models.JobTypeRandomText, Status: models.JobStatusPending, BatchID: interrupted.ID}))
+		require.NoError(t, history.RecordJob(ctx, &models.JobRecord{ID: "job-1", Type: models.JobTypeRandomText, Status: models.JobStatusPending, BatchID: interrupted.ID}))
+		require.NoError(t, history.RecordJob(ctx, &models.JobRecord{ID: "job-2", Type: models.JobTypeRandomText, Status: models.JobStatusCompleted, BatchID: interrupted.ID}))
+
+		require.NoError(t, service.Reconcile(ctx))
+		assert.Equal(t, []string{"job-0"}, enqueuer.cancelled)
+		for id, status := range map[string]models.JobStatus{"job-0": models.JobStatusCancelled, "job-1": models.JobStatusCancelled, "job-2": models.JobStatusCompleted} {
+			record, err := history.GetJob(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, status, record.Status, id)
+		}
+		_, err := service.repo.GetByID(ctx, interrupted.ID)
+		assert.ErrorIs(t, err, ErrBatchNotFound)
+
+		// A batch still within its submit timeout is left alone
+		stored, err := service.repo.GetByID(ctx, submitting.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusSubmitting, stored.Status)
+	})
+
+	t.Run("batches whose last event was missed are completed", func(t *testing.T) {
+		service, _, history := newTestService()
+		batch, err := service.CreateBatch(ctx, "", randomText(2), nil)
+		require.NoError(t, err)
+		for _, id := range batch.JobIDs {
+			require.NoError(t, history.HandleJobEvent(ctx, events.NewJobEvent(events.KindCompleted, id, "")))
+		}
+
+		require.NoError(t, service.Reconcile(ctx))
+		batch, err = service.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCompleted, batch.Status)
+	})
+
+	t.Run("events of jobs outside batches are ignored", func(t *testing.T) {
+		service, _, history := newTestService()
+		require.NoError(t, history.RecordJob(ctx, &models.JobRecord{ID: "single", Status: models.JobStatusCompleted}))
+		assert.NoError(t, service.HandleJobEvent(ctx, events.NewJobEvent(events.KindCompleted, "single", "")))
+		assert.NoError(t, service.HandleJobEvent(ctx, events.NewJobEvent(events.KindCompleted, "unknown", "")))
+	})
+}
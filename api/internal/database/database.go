@@ -53,7 +53,7 @@ func NewConnection() (*gorm.DB, error) {
 	}
 
 	// Auto migrate models
-	if err := db.AutoMigrate(&models.WebhookReceipt{}); err != nil {
+	if err := db.AutoMigrate(&models.WebhookReceipt{}, &models.WebhookStatusChange{}, &models.WebhookSource{}, &models.JobRecord{}, &models.Schedule{}, &models.WorkflowRun{}, &models.WorkflowStep{}, &models.Batch{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
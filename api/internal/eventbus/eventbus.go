@@ -0,0 +1,326 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-contract/workers"
+	"github.com/redis/go-redis/v9"
+)
+
+// Publisher publishes job events
+type Publisher interface {
+	// Publish publishes a job event
+	Publish(ctx context.Context, event *events.JobEvent) error
+}
+
+// ProgressStore reads the latest progress reported by running jobs
+type ProgressStore interface {
+	// GetProgress gets the latest progress of a job, or nil if none was reported
+	GetProgress(ctx context.Context, jobID string) (*events.Progress, error)
+}
+
+// CancellationStore records cancelled jobs so the worker can skip them
+type CancellationStore interface {
+	// MarkCancelled marks a job as cancelled for ttl
+	MarkCancelled(ctx context.Context, jobID string, ttl time.Duration) error
+	// IsCancelled checks whether a job has been cancelled
+	IsCancelled(ctx context.Context, jobID string) (bool, error)
+}
+
+// RetryPolicyStore stores the retry policies jobs are enqueued with so the
+// worker can apply them
+type RetryPolicyStore interface {
+	// SaveRetryPolicy stores the retry policy of a job for ttl
+	SaveRetryPolicy(ctx context.Context, jobID string, policy *tasks.RetryPolicy, ttl time.Duration) error
+}
+
+// ChainStore stores the follow-up jobs of jobs so the worker can enqueue them
+type ChainStore interface {
+	// SaveChain stores the follow-up jobs of a job for ttl
+	SaveChain(ctx context.Context, jobID string, chain *tasks.Chain, ttl time.Duration) error
+}
+
+// JobSettings holds the retry policy and follow-up jobs of a job, either of
+// which may be nil, and how long they are kept
+type JobSettings struct {
+	JobID       string
+	RetryPolicy *tasks.RetryPolicy
+	Chain       *tasks.Chain
+	TTL         time.Duration
+}
+
+// BatchStore stores the settings of many jobs at once
+type BatchStore interface {
+	// SaveJobSettings stores the retry policies and follow-up jobs of many
+	// jobs in a single round trip
+	SaveJobSettings(ctx context.Context, settings []*JobSettings) error
+}
+
+// DedupeStore remembers which job was enqueued for a deduplication key
+type DedupeStore interface {
+	// ClaimDedupeKey associates key with jobID for ttl unless another job
+	// already holds it, in which case that job's ID is returned
+	ClaimDedupeKey(ctx context.Context, key, jobID string, ttl time.Duration) (existingID string, claimed bool, err error)
+	// ReleaseDedupeKey removes key if it is still held by jobID
+	ReleaseDedupeKey(ctx context.Context, key, jobID string) error
+}
+
+// WorkerStore reads the registrations of running workers
+type WorkerStore interface {
+	// ListWorkers lists the workers whose registration has not expired
+	ListWorkers(ctx context.Context) ([]*workers.Registration, error)
+}
+
+// Bus publishes job events, reads job progress, records cancelled jobs and
+// stores job retry policies, follow-up jobs and deduplication keys
+type Bus interface {
+	Publisher
+	ProgressStore
+	CancellationStore
+	RetryPolicyStore
+	ChainStore
+	BatchStore
+	DedupeStore
+}
+
+// Handler handles a job event received from the bus
+type Handler func(event *events.JobEvent)
+
+// RedisBus implements Bus and delivers job events to subscribers using Redis pub/sub
+type RedisBus struct {
+	client *redis.Client
+	logger *log.Logger
+}
+
+// NewRedisBus creates a new RedisBus
+func NewRedisBus(redisAddr string) *RedisBus {
+	return &RedisBus{
+		client: redis.NewClient(&redis.Options{Addr: redisAddr}),
+		logger: log.New(log.Writer(), "[EventBus] ", log.LstdFlags),
+	}
+}
+
+// Publish publishes a job event on the job events channel
+func (b *RedisBus) Publish(ctx context.Context, event *events.JobEvent) error {
+	data, err := events.Encode(event)
+	if err != nil {
+		return err
+	}
+
+	if err := b.client.Publish(ctx, events.Channel, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish job event: %w", err)
+	}
+	return nil
+}
+
+// GetProgress gets the latest progress stored by the worker for a job
+func (b *RedisBus) GetProgress(ctx context.Context, jobID string) (*events.Progress, error) {
+	data, err := b.client.Get(ctx, events.ProgressKey(jobID)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get job progress: %w", err)
+	}
+
+	return events.DecodeProgress(data)
+}
+
+// MarkCancelled marks a job as cancelled. The worker checks the marker before
+// running a task and when a running task stops.
+func (b *RedisBus) MarkCancelled(ctx context.Context, jobID string, ttl time.Duration) error {
+	if err := b.client.Set(ctx, events.CancelKey(jobID), time.Now().Format(time.RFC3339), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to mark job as cancelled: %w", err)
+	}
+	return nil
+}
+
+// SaveRetryPolicy stores the retry policy of a job. The worker reads it before
+// running each attempt of the job.
+func (b *RedisBus) SaveRetryPolicy(ctx context.Context, jobID string, policy *tasks.RetryPolicy, ttl time.Duration) error {
+	data, err := tasks.EncodeRetryPolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	if err := b.client.Set(ctx, events.RetryPolicyKey(jobID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save job retry policy: %w", err)
+	}
+	return nil
+}
+
+// SaveChain stores the follow-up jobs of a job. The worker reads them once
+// the job has finished.
+func (b *RedisBus) SaveChain(ctx context.Context, jobID string, chain *tasks.Chain, ttl time.Duration) error {
+	data, err := tasks.EncodeChain(chain)
+	if err != nil {
+		return err
+	}
+
+	if err := b.client.Set(ctx, events.ChainKey(jobID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save job chain: %w", err)
+	}
+	return nil
+}
+
+// SaveJobSettings stores the retry policies and follow-up jobs of many jobs
+// in a single transaction, so either all of them are stored or none are
+func (b *RedisBus) SaveJobSettings(ctx context.Context, settings []*JobSettings) error {
+	pipe := b.client.TxPipeline()
+	for _, s := range settings {
+		if s.RetryPolicy != nil {
+			data, err := tasks.EncodeRetryPolicy(s.RetryPolicy)
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, events.RetryPolicyKey(s.JobID), data, s.TTL)
+		}
+		if !s.Chain.IsZero() {
+			data, err := tasks.EncodeChain(s.Chain)
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, events.ChainKey(s.JobID), data, s.TTL)
+		}
+	}
+
+	if pipe.Len() == 0 {
+		return nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save job settings: %w", err)
+	}
+	return nil
+}
+
+// releaseDedupeKeyScript deletes a deduplication key only if it still holds the given job ID
+var releaseDedupeKeyScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// ClaimDedupeKey atomically associates a deduplication key with a job
+func (b *RedisBus) ClaimDedupeKey(ctx context.Context, key, jobID string, ttl time.Duration) (string, bool, error) {
+	for {
+		claimed, err := b.client.SetNX(ctx, key, jobID, ttl).Result()
+		if err != nil {
+			return "", false, fmt.Errorf("failed to claim dedupe key: %w", err)
+		}
+		if claimed {
+			return jobID, true, nil
+		}
+
+		existingID, err := b.client.Get(ctx, key).Result()
+		if errors.Is(err, redis.Nil) {
+			// The key expired in between; try to claim it again
+			continue
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get dedupe key: %w", err)
+		}
+		return existingID, false, nil
+	}
+}
+
+// ReleaseDedupeKey removes a deduplication key if it is still held by the job
+func (b *RedisBus) ReleaseDedupeKey(ctx context.Context, key, jobID string) error {
+	if err := releaseDedupeKeyScript.Run(ctx, b.client, []string{key}, jobID).Err(); err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to release dedupe key: %w", err)
+	}
+	return nil
+}
+
+// IsCancelled checks whether a job has been marked as cancelled
+func (b *RedisBus) IsCancelled(ctx context.Context, jobID string) (bool, error) {
+	n, err := b.client.Exists(ctx, events.CancelKey(jobID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check job cancellation: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListWorkers lists the registrations workers advertised and refreshed
+// within workers.TTL. Malformed registrations are logged and skipped.
+func (b *RedisBus) ListWorkers(ctx context.Context) ([]*workers.Registration, error) {
+	var keys []string
+	iter := b.client.Scan(ctx, 0, workers.KeyPattern, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list workers: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := b.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worker registrations: %w", err)
+	}
+
+	registrations := make([]*workers.Registration, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// The registration expired between the scan and the read
+			continue
+		}
+		registration, err := workers.Decode([]byte(data))
+		if err != nil {
+			b.logger.Printf("Skipping worker registration %s: %v", keys[i], err)
+			continue
+		}
+		registrations = append(registrations, registration)
+	}
+	return registrations, nil
+}
+
+// Subscribe delivers every job event published on the bus to handler until
+// ctx is cancelled. Malformed events are logged and skipped.
+func (b *RedisBus) Subscribe(ctx context.Context, handler Handler) error {
+	pubsub := b.client.Subscribe(ctx, events.Channel)
+	defer pubsub.Close()
+
+	// Wait for the subscription to be confirmed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to job events: %w", err)
+	}
+
+	b.logger.Printf("Subscribed to job events on %s", events.Channel)
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+
+			event, err := events.Decode([]byte(msg.Payload))
+			if err != nil {
+				b.logger.Printf("Skipping job event: %v", err)
+				continue
+			}
+			handler(event)
+		}
+	}
+}
+
+// Close closes the bus
+func (b *RedisBus) Close() error {
+	if err := b.client.Close(); err != nil {
+		return fmt.Errorf("failed to close Redis client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"gorm.io/gorm"
+)
+
+// GormRepository implements Repository using GORM
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRepository creates a new GORM repository
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{db: db}
+}
+
+// Create creates a new job record
+func (r *GormRepository) Create(ctx context.Context, record *models.JobRecord) error {
+	result := r.db.WithContext(ctx).Create(record)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create job record: %w", result.Error)
+	}
+	return nil
+}
+
+// GetByID retrieves a job record by ID
+func (r *GormRepository) GetByID(ctx context.Context, id string) (*models.JobRecord, error) {
+	var record models.JobRecord
+	result := r.db.WithContext(ctx).First(&record, "id = ?", id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get job record: %w", result.Error)
+	}
+	return &record, nil
+}
+
+// Update updates a job record
+func (r *GormRepository) Update(ctx context.Context, record *models.JobRecord) error {
+	result := r.db.WithContext(ctx).Save(record)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update job record: %w", result.Error)
+	}
+	return nil
+}
+
+// List retrieves job records matching the filter, newest first
+func (r *GormRepository) List(ctx context.Context, filter ListFilter) ([]*models.JobRecord, error) {
+	var records []*models.JobRecord
+	query := applyFilter(r.db.WithContext(ctx), filter)
+
+	if filter.Cursor != nil {
+		query = query.Where("(created_at < ?) OR (created_at = ? AND id < ?)",
+			filter.Cursor.CreatedAt, filter.Cursor.CreatedAt, filter.Cursor.ID)
+	}
+
+	result := query.Order("created_at desc").Order("id desc").Limit(filter.Limit).Find(&records)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list job records: %w", result.Error)
+	}
+	return records, nil
+}
+
+// CountByStatus counts the job records matching the filter by status
+func (r *GormRepository) CountByStatus(ctx context.Context, filter ListFilter) (map[models.JobStatus]int, error) {
+	var rows []struct {
+		Status models.JobStatus
+		Count  int
+	}
+	result := applyFilter(r.db.WithContext(ctx).Model(&models.JobRecord{}), filter).
+		Select("status, count(*) AS count").
+		Group("status").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to count job records: %w", result.Error)
+	}
+
+	counts := make(map[models.JobStatus]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// applyFilter adds the conditions of a filter, except its cursor and limit, to a query
+func applyFilter(query *gorm.DB, filter ListFilter) *gorm.DB {
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Queue != "" {
+		query = query.Where("queue = ?", filter.Queue)
+	}
+	if filter.ParentID != "" {
+		query = query.Where("parent_id = ?", filter.ParentID)
+	}
+	if filter.BatchID != "" {
+		query = query.Where("batch_id = ?", filter.BatchID)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	return query
+}
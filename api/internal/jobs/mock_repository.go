@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// MockRepository is an in-memory implementation of the Repository interface
+type MockRepository struct {
+	jobs map[string]*models.JobRecord
+	mu   sync.RWMutex
+}
+
+// NewMockRepository creates a new mock repository
+func NewMockRepository() *MockRepository {
+	return &MockRepository{
+		jobs: make(map[string]*models.JobRecord),
+	}
+}
+
+// Create stores a job record in memory
+func (r *MockRepository) Create(ctx context.Context, record *models.JobRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.jobs[record.ID]; ok {
+		return fmt.Errorf("job record already exists: %s", record.ID)
+	}
+
+	copied := *record
+	r.jobs[record.ID] = &copied
+	return nil
+}
+
+// GetByID retrieves a job record by ID from memory
+func (r *MockRepository) GetByID(ctx context.Context, id string) (*models.JobRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+
+	copied := *record
+	return &copied, nil
+}
+
+// Update updates a job record in memory
+func (r *MockRepository) Update(ctx context.Context, record *models.JobRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.jobs[record.ID]; !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, record.ID)
+	}
+
+	copied := *record
+	r.jobs[record.ID] = &copied
+	return nil
+}
+
+// List retrieves job records matching the filter from memory, newest first
+func (r *MockRepository) List(ctx context.Context, filter ListFilter) ([]*models.JobRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	records := make([]*models.JobRecord, 0, len(r.jobs))
+	for _, record := range r.jobs {
+		if !matches(record, filter) {
+			continue
+		}
+		if filter.Cursor != nil && !filter.Cursor.Precedes(record.CreatedAt, record.ID) {
+			continue
+		}
+
+		copied := *record
+		records = append(records, &copied)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].ID > records[j].ID
+		}
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+
+	return records, nil
+}
+
+// CountByStatus counts the job records in memory matching the filter by status
+func (r *MockRepository) CountByStatus(ctx context.Context, filter ListFilter) (map[models.JobStatus]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[models.JobStatus]int)
+	for _, record := range r.jobs {
+		if matches(record, filter) {
+			counts[record.Status]++
+		}
+	}
+	return counts, nil
+}
+
+// matches checks if a record matches the filter, except its cursor and limit
+func matches(record *models.JobRecord, filter ListFilter) bool {
+	switch {
+	case filter.Type != "" && record.Type != filter.Type:
+		return false
+	case filter.Status != "" && record.Status != filter.Status:
+		return false
+	case filter.Queue != "" && record.Queue != filter.Queue:
+		return false
+	case filter.ParentID != "" && record.ParentID != filter.ParentID:
+		return false
+	case filter.BatchID != "" && record.BatchID != filter.BatchID:
+		return false
+	case filter.CreatedAfter != nil && record.CreatedAt.Before(*filter.CreatedAfter):
+		return false
+	case filter.CreatedBefore != nil && !record.CreatedAt.Before(*filter.CreatedBefore):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/internal/pagination"
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// ListFilter filters the jobs returned by List
+type ListFilter struct {
+	Type          models.JobType
+	Status        models.JobStatus
+	Queue         string
+	ParentID      string
+	BatchID       string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Cursor returns jobs created before the job the cursor points at
+	Cursor *pagination.Cursor
+	Limit  int
+}
+
+// Repository defines the interface for job storage
+type Repository interface {
+	// Create creates a new job record
+	Create(ctx context.Context, record *models.JobRecord) error
+
+	// GetByID retrieves a job record by ID
+	GetByID(ctx context.Context, id string) (*models.JobRecord, error)
+
+	// Update updates a job record
+	Update(ctx context.Context, record *models.JobRecord) error
+
+	// List retrieves job records matching the filter, newest first
+	List(ctx context.Context, filter ListFilter) ([]*models.JobRecord, error)
+
+	// CountByStatus counts the job records matching the filter by status,
+	// ignoring its cursor and limit
+	CountByStatus(ctx context.Context, filter ListFilter) (map[models.JobStatus]int, error)
+}
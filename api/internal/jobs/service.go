@@ -0,0 +1,211 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/internal/pagination"
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+)
+
+// DefaultListLimit is the number of jobs returned per page when no limit is given
+const DefaultListLimit = 20
+
+// MaxListLimit is the maximum number of jobs returned per page
+const MaxListLimit = 100
+
+// JobService defines the interface for job history operations
+type JobService interface {
+	RecordJob(ctx context.Context, record *models.JobRecord) error
+	GetJob(ctx context.Context, id string) (*models.JobRecord, error)
+	ListJobs(ctx context.Context, filter ListFilter) (*JobPage, error)
+	CountJobs(ctx context.Context, filter ListFilter) (map[models.JobStatus]int, error)
+	HandleJobEvent(ctx context.Context, event *events.JobEvent) error
+}
+
+// Ensure Service implements JobService
+var _ JobService = (*Service)(nil)
+
+// JobPage represents a page of jobs
+type JobPage struct {
+	Jobs       []*models.JobRecord `json:"jobs"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// Service keeps the persistent job history up to date
+type Service struct {
+	repo   Repository
+	logger *log.Logger
+}
+
+// NewService creates a new job service
+func NewService(repo Repository) *Service {
+	return &Service{
+		repo:   repo,
+		logger: log.New(log.Writer(), "[JobService] ", log.LstdFlags),
+	}
+}
+
+// RecordJob records a newly enqueued job
+func (s *Service) RecordJob(ctx context.Context, record *models.JobRecord) error {
+	if record == nil || record.ID == "" {
+		return fmt.Errorf("job ID is required")
+	}
+
+	if record.Status == "" {
+		record.Status = models.JobStatusPending
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	record.UpdatedAt = time.Now()
+
+	if err := s.repo.Create(ctx, record); err != nil {
+		return fmt.Errorf("failed to record job: %w", err)
+	}
+	return nil
+}
+
+// GetJob gets a job record by ID
+func (s *Service) GetJob(ctx context.Context, id string) (*models.JobRecord, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	record, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return record, nil
+}
+
+// ListJobs lists jobs matching the filter, newest first
+func (s *Service) ListJobs(ctx context.Context, filter ListFilter) (*JobPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultListLimit
+	}
+	if filter.Limit > MaxListLimit {
+		filter.Limit = MaxListLimit
+	}
+
+	// Fetch one extra job to find out whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+
+	records, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	page := &JobPage{Jobs: records}
+	if len(records) > limit {
+		page.Jobs = records[:limit]
+		last := page.Jobs[limit-1]
+		page.NextCursor = (&pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}).Encode()
+	}
+
+	return page, nil
+}
+
+// CountJobs counts the jobs matching the filter by status
+func (s *Service) CountJobs(ctx context.Context, filter ListFilter) (map[models.JobStatus]int, error) {
+	counts, err := s.repo.CountByStatus(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	return counts, nil
+}
+
+// HandleJobEvent applies a job event from the event bus to the job's record.
+// Records are created for jobs enqueued by other processes, and events that
+// arrive after a job has finished are ignored.
+func (s *Service) HandleJobEvent(ctx context.Context, event *events.JobEvent) error {
+	record, err := s.repo.GetByID(ctx, event.JobID)
+	if err != nil {
+		if !errors.Is(err, ErrJobNotFound) {
+			return fmt.Errorf("failed to get job: %w", err)
+		}
+
+		record = &models.JobRecord{
+			ID:        event.JobID,
+			Type:      models.JobType(event.TaskType),
+			Status:    models.JobStatusPending,
+			Queue:     event.Queue,
+			ParentID:  event.ParentID,
+			CreatedAt: event.Timestamp,
+		}
+		if err := s.RecordJob(ctx, record); err != nil {
+			return err
+		}
+	} else if record.ParentID == "" && event.ParentID != "" {
+		// Follow-up jobs can start before the pending event linking them to
+		// their parent arrives
+		record.ParentID = event.ParentID
+		if record.Queue == "" {
+			record.Queue = event.Queue
+		}
+		record.UpdatedAt = time.Now()
+		if err := s.repo.Update(ctx, record); err != nil {
+			return fmt.Errorf("failed to update job: %w", err)
+		}
+	}
+
+	if record.Status.IsFinal() {
+		return nil
+	}
+
+	now := event.Timestamp
+	switch event.Kind {
+	case events.KindPending, events.KindScheduled:
+		// The record was created at enqueue time; a late pending event must
+		// not move a job that has already started back to pending
+		return nil
+	case events.KindProcessing:
+		record.Status = models.JobStatusProcessing
+		if record.StartedAt == nil {
+			record.StartedAt = &now
+		}
+		if event.Attempt > record.Attempts {
+			record.Attempts = event.Attempt
+		}
+		record.NextRetryAt = nil
+	case events.KindProgress:
+		record.Status = models.JobStatusProcessing
+		if event.Progress != nil {
+			progress, err := events.EncodeProgress(event.Progress)
+			if err != nil {
+				return err
+			}
+			record.Progress = models.JSON(progress)
+		}
+	case events.KindCompleted:
+		record.Status = models.JobStatusCompleted
+		record.Result = models.JSON(event.Result)
+		record.Error = ""
+		record.CompletedAt = &now
+	case events.KindRetrying:
+		record.Status = models.JobStatusRetrying
+		record.Error = event.Error
+		record.NextRetryAt = event.NextRetryAt
+	case events.KindFailed:
+		record.Status = models.JobStatusFailed
+		record.Error = event.Error
+		record.CompletedAt = &now
+	case events.KindCancelled:
+		record.Status = models.JobStatusCancelled
+		record.CompletedAt = &now
+	default:
+		s.logger.Printf("Ignoring unknown job event kind %q for job %s", event.Kind, event.JobID)
+		return nil
+	}
+
+	record.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, record); err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/internal/pagination"
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceWithMockRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("RecordJob", func(t *testing.T) {
+		service := NewService(NewMockRepository())
+
+		err := service.RecordJob(ctx, &models.JobRecord{ID: "job-1", Type: models.JobTypeRandomText})
+		require.NoError(t, err)
+
+		record, err := service.GetJob(ctx, "job-1")
+		require.NoError(t, err)
+		assert.Equal(t, models.JobStatusPending, record.Status)
+		assert.False(t, record.CreatedAt.IsZero())
+
+		err = service.RecordJob(ctx, &models.JobRecord{})
+		assert.Error(t, err)
+
+		_, err = service.GetJob(ctx, "missing")
+		assert.ErrorIs(t, err, ErrJobNotFound)
+	})
+
+	t.Run("ListJobs", func(t *testing.T) {
+		service := NewService(NewMockRepository())
+		base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		for i := 0; i < 5; i++ {
+			jobType := models.JobTypeRandomText
+			if i%2 == 1 {
+				jobType = models.JobTypeProcessWebhook
+			}
+			require.NoError(t, service.RecordJob(ctx, &models.JobRecord{
+				ID:        fmt.Sprintf("job-%d", i),
+				Type:      jobType,
+				Queue:     "default",
+				CreatedAt: base.Add(time.Duration(i) * time.Minute),
+			}))
+		}
+
+		// Pages are returned newest first
+		page, err := service.ListJobs(ctx, ListFilter{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page.Jobs, 2)
+		assert.Equal(t, "job-4", page.Jobs[0].ID)
+		assert.Equal(t, "job-3", page.Jobs[1].ID)
+		require.NotEmpty(t, page.NextCursor)
+
+		cursor, err := pagination.DecodeCursor(page.NextCursor)
+		require.NoError(t, err)
+		page, err = service.ListJobs(ctx, ListFilter{Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		require.Len(t, page.Jobs, 2)
+		assert.Equal(t, "job-2", page.Jobs[0].ID)
+		assert.Equal(t, "job-1", page.Jobs[1].ID)
+
+		cursor, err = pagination.DecodeCursor(page.NextCursor)
+		require.NoError(t, err)
+		page, err = service.ListJobs(ctx, ListFilter{Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		require.Len(t, page.Jobs, 1)
+		assert.Empty(t, page.NextCursor)
+
+		// Filters
+		page, err = service.ListJobs(ctx, ListFilter{Type: models.JobTypeProcessWebhook})
+		require.NoError(t, err)
+		assert.Len(t, page.Jobs, 2)
+
+		after := base.Add(2 * time.Minute)
+		page, err = service.ListJobs(ctx, ListFilter{CreatedAfter: &after})
+		require.NoError(t, err)
+		assert.Len(t, page.Jobs, 3)
+
+		page, err = service.ListJobs(ctx, ListFilter{Status: models.JobStatusCompleted})
+		require.NoError(t, err)
+		assert.Empty(t, page.Jobs)
+	})
+
+	t.Run("CountJobs", func(t *testing.T) {
+		service := NewService(NewMockRepository())
+		statuses := []models.JobStatus{models.JobStatusPending, models.JobStatusCompleted, models.JobStatusCompleted, models.JobStatusFailed}
+		for i, status := range statuses {
+			require.NoError(t, service.RecordJob(ctx, &models.JobRecord{ID: fmt.Sprintf("job-%d", i), Status: status, BatchID: "batch-1"}))
+		}
+		require.NoError(t, service.RecordJob(ctx, &models.JobRecord{ID: "other", Status: models.JobStatusCompleted}))
+
+		counts, err := service.CountJobs(ctx, ListFilter{BatchID: "batch-1"})
+		require.NoError(t, err)
+		assert.Equal(t, map[models.JobStatus]int{
+			models.JobStatusPending:   1,
+			models.JobStatusCompleted: 2,
+			models.JobStatusFailed:    1,
+		}, counts)
+	})
+
+	t.Run("HandleJobEvent", func(t *testing.T) {
+		service := NewService(NewMockRepository())
+		require.NoError(t, service.RecordJob(ctx, &models.JobRecord{ID: "job-1", Type: models.JobTypeRandomText}))
+
+		apply := func(kind events.Kind, modify func(*events.JobEvent)) *models.JobRecord {
+			event := events.NewJobEvent(kind, "job-1", string(models.JobTypeRandomText))
+			if modify != nil {
+				modify(event)
+			}
+			require.NoError(t, service.HandleJobEvent(ctx, event))
+			record, err := service.GetJob(ctx, "job-1")
+			require.NoError(t, err)
+			return record
+		}
+
+		record := apply(events.KindProcessing, func(e *events.JobEvent) { e.Attempt = 1 })
+		assert.Equal(t, models.JobStatusProcessing, record.Status)
+		assert.NotNil(t, record.StartedAt)
+		assert.Equal(t, 1, record.Attempts)
+
+		nextRetryAt := time.Now().Add(time.Minute)
+		record = apply(events.KindRetrying, func(e *events.JobEvent) {
+			e.Error = "temporary failure"
+			e.NextRetryAt = &nextRetryAt
+		})
+		assert.Equal(t, models.JobStatusRetrying, record.Status)
+		assert.NotNil(t, record.NextRetryAt)
+
+		record = apply(events.KindProcessing, func(e *events.JobEvent) { e.Attempt = 2 })
+		assert.Equal(t, 2, record.Attempts)
+		assert.Nil(t, record.NextRetryAt)
+
+		// A late pending event does not move the job back
+		record = apply(events.KindPending, nil)
+		assert.Equal(t, models.JobStatusProcessing, record.Status)
+
+		record = apply(events.KindProgress, func(e *events.JobEvent) {
+			e.Progress = &events.Progress{Percent: 50, Message: "halfway"}
+		})
+		var progress events.Progress
+		require.NoError(t, json.Unmarshal(record.Progress, &progress))
+		assert.Equal(t, 50, progress.Percent)
+
+		record = apply(events.KindCompleted, func(e *events.JobEvent) {
+			e.Result = json.RawMessage(`{"text":"abc","length":3}`)
+		})
+		assert.Equal(t, models.JobStatusCompleted, record.Status)
+		assert.JSONEq(t, `{"text":"abc","length":3}`, string(record.Result))
+		assert.NotNil(t, record.CompletedAt)
+
+		// Events after the job has finished are ignored
+		record = apply(events.KindFailed, func(e *events.JobEvent) { e.Error = "boom" })
+		assert.Equal(t, models.JobStatusCompleted, record.Status)
+		assert.Empty(t, record.Error)
+	})
+
+	t.Run("HandleJobEvent for unknown job", func(t *testing.T) {
+		service := NewService(NewMockRepository())
+
+		event := events.NewJobEvent(events.KindFailed, "job-2", string(models.JobTypeProcessWebhook))
+		event.Error = "boom"
+		require.NoError(t, service.HandleJobEvent(ctx, event))
+
+		record, err := service.GetJob(ctx, "job-2")
+		require.NoError(t, err)
+		assert.Equal(t, models.JobTypeProcessWebhook, record.Type)
+		assert.Equal(t, models.JobStatusFailed, record.Status)
+		assert.Equal(t, "boom", record.Error)
+	})
+
+	t.Run("HandleJobEvent for follow-up job", func(t *testing.T) {
+		service := NewService(NewMockRepository())
+
+		// The follow-up job starts before its pending event arrives
+		require.NoError(t, service.HandleJobEvent(ctx, events.NewJobEvent(events.KindProcessing, "child-1", string(models.JobTypeRandomText))))
+
+		pending := events.NewJobEvent(events.KindPending, "child-1", string(models.JobTypeRandomText))
+		pending.ParentID = "job-1"
+		pending.Queue = "low"
+		require.NoError(t, service.HandleJobEvent(ctx, pending))
+
+		record, err := service.GetJob(ctx, "child-1")
+		require.NoError(t, err)
+		assert.Equal(t, "job-1", record.ParentID)
+		assert.Equal(t, "low", record.Queue)
+		assert.Equal(t, models.JobStatusProcessing, record.Status)
+
+		page, err := service.ListJobs(ctx, ListFilter{ParentID: "job-1"})
+		require.NoError(t, err)
+		require.Len(t, page.Jobs, 1)
+		assert.Equal(t, "child-1", page.Jobs[0].ID)
+	})
+}
@@ -0,0 +1,27 @@
+package jobtypes
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// Error definitions
+var (
+	ErrUnknownJobType = errors.New("unknown job type")
+)
+
+// ValidationError is returned when job data does not match its job type's schema
+type ValidationError struct {
+	Errors []models.FieldError
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fieldErr.Field+": "+fieldErr.Message)
+	}
+	return "invalid job data: " + strings.Join(messages, "; ")
+}
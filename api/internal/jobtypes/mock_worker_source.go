@@ -0,0 +1,21 @@
+package jobtypes
+
+import (
+	"context"
+
+	"github.com/dustinleblanc/go-bespin-contract/workers"
+)
+
+// MockWorkerSource is a WorkerSource returning a fixed set of workers
+type MockWorkerSource struct {
+	Registrations []*workers.Registration
+	Err           error
+	// Calls counts how often the workers were listed
+	Calls int
+}
+
+// ListWorkers returns the configured registrations
+func (m *MockWorkerSource) ListWorkers(ctx context.Context) ([]*workers.Registration, error) {
+	m.Calls++
+	return m.Registrations, m.Err
+}
@@ -0,0 +1,300 @@
+// Package jobtypes keeps the registry of job types the API accepts and
+// validates submitted job data against each type's JSON Schema.
+package jobtypes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-contract/workers"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// JobType describes a job type that can be submitted through the API
+type JobType struct {
+	Name        models.JobType  `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+
+	compiled *jsonschema.Schema
+}
+
+// WorkerSource lists the workers currently advertising their handlers
+type WorkerSource interface {
+	// ListWorkers lists the workers whose registration has not expired
+	ListWorkers(ctx context.Context) ([]*workers.Registration, error)
+}
+
+// WorkerCacheTTL is how long the registry reuses the live workers it read.
+// Workers refresh their registration every workers.HeartbeatInterval, so a
+// few seconds of staleness is not noticeable, while it saves listing every
+// worker for each job submitted.
+const WorkerCacheTTL = 5 * time.Second
+
+// Registry holds the job types the API accepts
+type Registry struct {
+	mu      sync.RWMutex
+	types   map[models.JobType]*JobType
+	workers WorkerSource
+
+	cacheMu  sync.Mutex
+	cacheTTL time.Duration
+	cached   []*workers.Registration
+	cachedAt time.Time
+}
+
+// NewRegistry creates an empty registry. Live workers are read from
+// workerSource and cached for WorkerCacheTTL; with a nil source no job type
+// has a live worker.
+func NewRegistry(workerSource WorkerSource) *Registry {
+	return &Registry{
+		types:    make(map[models.JobType]*JobType),
+		workers:  workerSource,
+		cacheTTL: WorkerCacheTTL,
+	}
+}
+
+// NewContractRegistry creates a registry holding every task type of the job
+// contract, validated against the schemas shipped with the contract
+func NewContractRegistry(workerSource WorkerSource) (*Registry, error) {
+	r := NewRegistry(workerSource)
+	for _, taskType := range tasks.Types() {
+		schema, err := tasks.Schema(taskType)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.Register(models.JobType(taskType), schema); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds a job type whose data must match schema, replacing any job
+// type registered under the same name. The description is read from the
+// schema's description keyword.
+func (r *Registry) Register(name models.JobType, schema []byte) error {
+	compiled, err := jsonschema.CompileString(string(name)+".json", string(schema))
+	if err != nil {
+		return fmt.Errorf("invalid schema for job type %s: %w", name, err)
+	}
+
+	var meta struct {
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(schema, &meta); err != nil {
+		return fmt.Errorf("invalid schema for job type %s: %w", name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[name] = &JobType{
+		Name:        name,
+		Description: meta.Description,
+		Schema:      json.RawMessage(schema),
+		compiled:    compiled,
+	}
+	return nil
+}
+
+// Lookup gets a registered job type
+func (r *Registry) Lookup(name models.JobType) (*JobType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	jobType, ok := r.types[name]
+	return jobType, ok
+}
+
+// List returns every registered job type, ordered by name
+func (r *Registry) List() []*JobType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]*JobType, 0, len(r.types))
+	for _, jobType := range r.types {
+		types = append(types, jobType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Name < types[j].Name
+	})
+	return types
+}
+
+// Discover lists every registered job type together with the job types
+// advertised by live workers, and reports which of them a live worker can run
+func (r *Registry) Discover(ctx context.Context) ([]*models.JobTypeStatus, error) {
+	registrations, err := r.listWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[models.JobType]*models.JobTypeStatus)
+	for _, jobType := range r.List() {
+		statuses[jobType.Name] = &models.JobTypeStatus{
+			Name:        jobType.Name,
+			Description: jobType.Description,
+			Schema:      jobType.Schema,
+			Versions:    []string{},
+			Accepted:    true,
+		}
+	}
+
+	// Registrations are read newest first so the most recent worker decides
+	// the advertised default queue
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].HeartbeatAt.After(registrations[j].HeartbeatAt)
+	})
+	for _, registration := range registrations {
+		for _, handler := range registration.Handlers {
+			name := models.JobType(handler.Type)
+			status, ok := statuses[name]
+			if !ok {
+				status = &models.JobTypeStatus{Name: name, Schema: handler.Schema, Versions: []string{}}
+				statuses[name] = status
+			}
+
+			status.Workers++
+			status.Available = true
+			if status.DefaultQueue == "" {
+				status.DefaultQueue = handler.DefaultQueue
+			}
+			if !containsString(status.Versions, handler.Version) {
+				status.Versions = append(status.Versions, handler.Version)
+			}
+		}
+	}
+
+	result := make([]*models.JobTypeStatus, 0, len(statuses))
+	for _, status := range statuses {
+		sort.Strings(status.Versions)
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// DefaultQueue returns the queue the most recent live worker advertises for
+// a job type, or an empty string if no live worker advertises one
+func (r *Registry) DefaultQueue(ctx context.Context, name models.JobType) (string, error) {
+	registrations, err := r.listWorkers(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var queue string
+	var heartbeatAt time.Time
+	for _, registration := range registrations {
+		for _, handler := range registration.Handlers {
+			if models.JobType(handler.Type) != name || handler.DefaultQueue == "" {
+				continue
+			}
+			if queue == "" || registration.HeartbeatAt.After(heartbeatAt) {
+				queue = handler.DefaultQueue
+				heartbeatAt = registration.HeartbeatAt
+			}
+		}
+	}
+	return queue, nil
+}
+
+// listWorkers lists the live workers, if the registry has a worker source.
+// The workers are read at most once every cacheTTL; failures are not cached.
+// The returned slice is a copy the caller may reorder.
+func (r *Registry) listWorkers(ctx context.Context) ([]*workers.Registration, error) {
+	if r.workers == nil {
+		return nil, nil
+	}
+
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+
+	if r.cached == nil || time.Since(r.cachedAt) >= r.cacheTTL {
+		registrations, err := r.workers.ListWorkers(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list workers: %w", err)
+		}
+		if registrations == nil {
+			registrations = []*workers.Registration{}
+		}
+		r.cached = registrations
+		r.cachedAt = time.Now()
+	}
+	return append([]*workers.Registration(nil), r.cached...), nil
+}
+
+// Validate checks data against the schema of a job type. It returns
+// ErrUnknownJobType if the type is not registered and a *ValidationError
+// listing every invalid field if the data does not match the schema.
+func (r *Registry) Validate(name models.JobType, data []byte) error {
+	jobType, ok := r.Lookup(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJobType, name)
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("{}")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Errors: []models.FieldError{{Field: "data", Message: "must be valid JSON"}}}
+	}
+
+	if err := jobType.compiled.Validate(value); err != nil {
+		schemaErr, ok := err.(*jsonschema.ValidationError)
+		if !ok {
+			return fmt.Errorf("failed to validate %s data: %w", name, err)
+		}
+		return &ValidationError{Errors: fieldErrors(schemaErr)}
+	}
+	return nil
+}
+
+// fieldErrors flattens a schema validation error into one error per failed
+// keyword, named after the location of the invalid value in the job data
+func fieldErrors(err *jsonschema.ValidationError) []models.FieldError {
+	if len(err.Causes) == 0 {
+		return []models.FieldError{{Field: fieldName(err.InstanceLocation), Message: err.Message}}
+	}
+
+	var errs []models.FieldError
+	for _, cause := range err.Causes {
+		errs = append(errs, fieldErrors(cause)...)
+	}
+	return errs
+}
+
+// fieldName converts a JSON pointer into the job data to a dotted field name
+func fieldName(pointer string) string {
+	field := "data"
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		field += "." + token
+	}
+	return field
+}
+
+// containsString checks if values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
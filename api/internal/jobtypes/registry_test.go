@@ -0,0 +1,164 @@
+package jobtypes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-contract/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContractRegistry(t *testing.T) {
+	registry, err := NewContractRegistry(nil)
+	require.NoError(t, err)
+
+	types := registry.List()
+	require.Len(t, types, len(tasks.Types()))
+	for _, jobType := range types {
+		assert.True(t, tasks.IsKnownType(string(jobType.Name)))
+		assert.NotEmpty(t, jobType.Description)
+		assert.NotEmpty(t, jobType.Schema)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	registry, err := NewContractRegistry(nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		jobType    models.JobType
+		data       string
+		wantErr    error
+		wantFields []string
+	}{
+		{name: "valid random text", jobType: models.JobTypeRandomText, data: `{"length":50}`},
+		{name: "valid webhook", jobType: models.JobTypeProcessWebhook, data: `{"receipt_id":"r-1","source":"github"}`},
+		{name: "unknown type", jobType: "unknown", data: `{}`, wantErr: ErrUnknownJobType},
+		{name: "missing data", jobType: models.JobTypeRandomText, wantFields: []string{"data"}},
+		{name: "malformed data", jobType: models.JobTypeRandomText, data: `{"length":`, wantFields: []string{"data"}},
+		{name: "wrong type", jobType: models.JobTypeRandomText, data: `{"length":"long"}`, wantFields: []string{"data.length"}},
+		{name: "out of range", jobType: models.JobTypeRandomText, data: `{"length":0}`, wantFields: []string{"data.length"}},
+		{
+			name:       "several errors",
+			jobType:    models.JobTypeProcessWebhook,
+			data:       `{"receipt_id":"","extra":true}`,
+			wantFields: []string{"data", "data.receipt_id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := registry.Validate(tt.jobType, []byte(tt.data))
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.wantFields != nil:
+				var validationErr *ValidationError
+				require.True(t, errors.As(err, &validationErr), "expected a validation error, got %v", err)
+				var fields []string
+				for _, fieldErr := range validationErr.Errors {
+					fields = append(fields, fieldErr.Field)
+				}
+				assert.ElementsMatch(t, tt.wantFields, fields)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	registry := NewRegistry(nil)
+
+	err := registry.Register("resize_image", []byte(`{"description":"Resizes an image","type":"object","required":["url"]}`))
+	require.NoError(t, err)
+
+	jobType, ok := registry.Lookup("resize_image")
+	require.True(t, ok)
+	assert.Equal(t, "Resizes an image", jobType.Description)
+	assert.NoError(t, registry.Validate("resize_image", []byte(`{"url":"https://example.com/a.png"}`)))
+
+	assert.Error(t, registry.Register("broken", []byte(`{"type":42}`)))
+	_, ok = registry.Lookup("broken")
+	assert.False(t, ok)
+}
+
+func TestDiscover(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	source := &MockWorkerSource{Registrations: []*workers.Registration{
+		{
+			WorkerID:    "old:1",
+			HeartbeatAt: now.Add(-20 * time.Second),
+			Handlers: []workers.Handler{
+				{Type: tasks.TypeRandomText, Version: "1.9.0", DefaultQueue: tasks.QueueLow},
+			},
+		},
+		{
+			WorkerID:    "new:1",
+			HeartbeatAt: now,
+			Handlers: []workers.Handler{
+				{Type: tasks.TypeRandomText, Version: "1.10.0", DefaultQueue: tasks.QueueDefault},
+				{Type: "resize_image", Version: "0.1.0", DefaultQueue: tasks.QueueLow},
+			},
+		},
+	}}
+
+	registry, err := NewContractRegistry(source)
+	require.NoError(t, err)
+
+	statuses, err := registry.Discover(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+
+	byName := make(map[models.JobType]*models.JobTypeStatus)
+	for _, status := range statuses {
+		byName[status.Name] = status
+	}
+
+	randomText := byName[models.JobTypeRandomText]
+	assert.True(t, randomText.Available)
+	assert.True(t, randomText.Accepted)
+	assert.Equal(t, 2, randomText.Workers)
+	assert.Equal(t, []string{"1.10.0", "1.9.0"}, randomText.Versions)
+	assert.Equal(t, tasks.QueueDefault, randomText.DefaultQueue)
+
+	webhook := byName[models.JobTypeProcessWebhook]
+	assert.False(t, webhook.Available)
+	assert.True(t, webhook.Accepted)
+	assert.Zero(t, webhook.Workers)
+
+	resize := byName["resize_image"]
+	assert.True(t, resize.Available)
+	assert.False(t, resize.Accepted)
+
+	queue, err := registry.DefaultQueue(ctx, models.JobTypeRandomText)
+	require.NoError(t, err)
+	assert.Equal(t, tasks.QueueDefault, queue)
+
+	queue, err = registry.DefaultQueue(ctx, "resize_image")
+	require.NoError(t, err)
+	assert.Equal(t, tasks.QueueLow, queue)
+
+	queue, err = registry.DefaultQueue(ctx, models.JobTypeProcessWebhook)
+	require.NoError(t, err)
+	assert.Empty(t, queue)
+
+	// Workers are listed once while the cache is fresh
+	assert.Equal(t, 1, source.Calls)
+
+	// Failures are returned once the cache expires and are not cached
+	registry.cachedAt = time.Now().Add(-WorkerCacheTTL)
+	source.Err = errors.New("redis unavailable")
+	_, err = registry.Discover(ctx)
+	assert.Error(t, err)
+	_, err = registry.DefaultQueue(ctx, models.JobTypeRandomText)
+	assert.Error(t, err)
+	assert.Equal(t, 3, source.Calls)
+}
@@ -0,0 +1,52 @@
+// Package pagination provides the cursors of listings ordered by creation
+// time, newest first, such as the job history and webhook receipts.
+package pagination
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cursor points at an item in a listing ordered by creation time, newest
+// first, with ties broken by descending ID
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// Encode encodes the cursor as an opaque string
+func (c *Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor decodes a cursor produced by Cursor.Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	return &Cursor{CreatedAt: t, ID: id}, nil
+}
+
+// Precedes checks if the cursor comes before the item created at createdAt
+// with id, so the item belongs to the pages after the cursor
+func (c *Cursor) Precedes(createdAt time.Time, id string) bool {
+	if createdAt.Equal(c.CreatedAt) {
+		return id < c.ID
+	}
+	return createdAt.Before(c.CreatedAt)
+}
@@ -0,0 +1,33 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := &Cursor{CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC), ID: "b"}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	require.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, "b", decoded.ID)
+
+	for _, invalid := range []string{"not base64!", "bm8tc2VwYXJhdG9y", "eWVzdGVyZGF5fGE"} {
+		_, err := DecodeCursor(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCursorPrecedes(t *testing.T) {
+	cursor := &Cursor{CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), ID: "b"}
+
+	assert.True(t, cursor.Precedes(cursor.CreatedAt.Add(-time.Second), "z"))
+	assert.True(t, cursor.Precedes(cursor.CreatedAt, "a"))
+	assert.False(t, cursor.Precedes(cursor.CreatedAt, "b"))
+	assert.False(t, cursor.Precedes(cursor.CreatedAt, "c"))
+	assert.False(t, cursor.Precedes(cursor.CreatedAt.Add(time.Second), "a"))
+}
@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/hibiken/asynq"
+)
+
+// drainPageSize is the number of tasks removed per round while draining a queue
+const drainPageSize = 100
+
+// ListQueueStats gets the stats of every queue the worker consumes
+func (q *AsynqQueue) ListQueueStats(ctx context.Context) ([]*models.QueueStats, error) {
+	stats := make([]*models.QueueStats, 0, len(tasks.Queues()))
+	for _, name := range tasks.Queues() {
+		s, err := q.GetQueueStats(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// GetQueueStats gets the size, latency and throughput of a queue. A queue
+// nothing was ever enqueued on is reported as empty.
+func (q *AsynqQueue) GetQueueStats(ctx context.Context, queueName string) (*models.QueueStats, error) {
+	if !tasks.IsKnownQueue(queueName) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQueue, queueName)
+	}
+
+	info, err := q.inspector.GetQueueInfo(queueName)
+	if err != nil {
+		if errors.Is(err, asynq.ErrQueueNotFound) {
+			return &models.QueueStats{Queue: queueName}, nil
+		}
+		return nil, fmt.Errorf("failed to get queue info: %w", err)
+	}
+
+	return &models.QueueStats{
+		Queue:          info.Queue,
+		Size:           info.Size,
+		Pending:        info.Pending,
+		Active:         info.Active,
+		Scheduled:      info.Scheduled,
+		Retry:          info.Retry,
+		Archived:       info.Archived,
+		Completed:      info.Completed,
+		LatencyMs:      info.Latency.Milliseconds(),
+		Processed:      info.Processed,
+		Failed:         info.Failed,
+		ProcessedTotal: info.ProcessedTotal,
+		FailedTotal:    info.FailedTotal,
+		MemoryUsage:    info.MemoryUsage,
+		Paused:         info.Paused,
+		Timestamp:      info.Timestamp,
+	}, nil
+}
+
+// PauseQueue stops workers from picking up jobs from a queue. Jobs can still
+// be enqueued and running jobs finish normally.
+func (q *AsynqQueue) PauseQueue(ctx context.Context, queueName string) error {
+	if !tasks.IsKnownQueue(queueName) {
+		return fmt.Errorf("%w: %s", ErrUnknownQueue, queueName)
+	}
+
+	if err := q.inspector.PauseQueue(queueName); err != nil {
+		return fmt.Errorf("failed to pause queue: %w", err)
+	}
+	return nil
+}
+
+// ResumeQueue lets workers pick up jobs from a paused queue again
+func (q *AsynqQueue) ResumeQueue(ctx context.Context, queueName string) error {
+	if !tasks.IsKnownQueue(queueName) {
+		return fmt.Errorf("%w: %s", ErrUnknownQueue, queueName)
+	}
+
+	if err := q.inspector.UnpauseQueue(queueName); err != nil {
+		return fmt.Errorf("failed to resume queue: %w", err)
+	}
+	return nil
+}
+
+// DrainQueue removes every pending, scheduled and retrying job from a queue
+// and publishes a cancelled event for each of them. Running jobs are left to
+// finish and archived jobs are kept as dead letters.
+func (q *AsynqQueue) DrainQueue(ctx context.Context, queueName string) (*models.DrainResult, error) {
+	if !tasks.IsKnownQueue(queueName) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQueue, queueName)
+	}
+
+	result := &models.DrainResult{Queue: queueName}
+	var err error
+	if result.Pending, err = q.drain(ctx, queueName, q.inspector.ListPendingTasks); err != nil {
+		return result, err
+	}
+	if result.Scheduled, err = q.drain(ctx, queueName, q.inspector.ListScheduledTasks); err != nil {
+		return result, err
+	}
+	if result.Retry, err = q.drain(ctx, queueName, q.inspector.ListRetryTasks); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// drain deletes the tasks returned by list one page at a time, so that each
+// deleted job can be reported as cancelled, and returns how many were deleted
+func (q *AsynqQueue) drain(ctx context.Context, queueName string, list func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error)) (int, error) {
+	deleted := 0
+	for {
+		infos, err := list(queueName, asynq.PageSize(drainPageSize))
+		if err != nil {
+			if errors.Is(err, asynq.ErrQueueNotFound) {
+				return deleted, nil
+			}
+			return deleted, fmt.Errorf("failed to list tasks: %w", err)
+		}
+		if len(infos) == 0 {
+			return deleted, nil
+		}
+
+		removed := 0
+		for _, info := range infos {
+			if err := q.inspector.DeleteTask(queueName, info.ID); err != nil {
+				if errors.Is(err, asynq.ErrTaskNotFound) {
+					continue
+				}
+				return deleted, fmt.Errorf("failed to delete task: %w", err)
+			}
+			deleted++
+			removed++
+
+			if err := q.bus.Publish(ctx, events.NewJobEvent(events.KindCancelled, info.ID, info.Type)); err != nil {
+				log.Printf("Failed to publish cancelled event for job %s: %v", info.ID, err)
+			}
+		}
+
+		// Stop rather than spin if nothing on the page could be deleted
+		if removed == 0 {
+			return deleted, nil
+		}
+	}
+}
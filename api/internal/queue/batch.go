@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/dustinleblanc/go-bespin-api/internal/eventbus"
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+)
+
+// AddJobs adds many jobs to the queue. Every job is validated before anything
+// is enqueued, the retry policies and follow-up jobs of all jobs are stored in
+// a single pipelined transaction, and every job is recorded before its task is
+// enqueued so the job history knows the whole batch up front. asynq has no
+// batch enqueue, so each task is enqueued with its own request and the jobs
+// are not enqueued atomically: if a task fails to enqueue, the tasks already
+// enqueued are removed again on a best-effort basis, every job is marked as
+// cancelled and an error is returned. A submission interrupted midway is
+// rolled back by the batch service instead. Jobs in a batch cannot use
+// idempotency keys or uniqueness windows, since a duplicate would leave the
+// batch with fewer jobs than were submitted.
+func (q *AsynqQueue) AddJobs(ctx context.Context, jobs []*models.Job) ([]string, error) {
+	prepared := make([]*preparedJob, 0, len(jobs))
+	settings := make([]*eventbus.JobSettings, 0, len(jobs))
+	for i, job := range jobs {
+		if job.IdempotencyKey != "" || job.UniqueFor != 0 {
+			return nil, fmt.Errorf("%w: job %d: idempotency_key and unique_for are not supported in batches", ErrInvalidBatch, i)
+		}
+
+		p, err := q.prepare(job)
+		if err != nil {
+			return nil, fmt.Errorf("job %d: %w", i, err)
+		}
+		prepared = append(prepared, p)
+		if p.retryPolicy != nil || !p.chain.IsZero() {
+			settings = append(settings, &eventbus.JobSettings{JobID: p.taskID, RetryPolicy: p.retryPolicy, Chain: p.chain, TTL: q.settingsTTL(p.processAt)})
+		}
+	}
+
+	if err := q.bus.SaveJobSettings(ctx, settings); err != nil {
+		return nil, err
+	}
+
+	// Record every job first, so the batch's counts never miss a job that
+	// finishes before the rest of the batch is enqueued
+	for _, p := range prepared {
+		if err := q.recorder.RecordJob(ctx, p.record()); err != nil {
+			q.rollback(ctx, prepared, 0)
+			return nil, fmt.Errorf("failed to record job %s: %w", p.taskID, err)
+		}
+	}
+
+	ids := make([]string, 0, len(prepared))
+	for i, p := range prepared {
+		if _, err := q.client.EnqueueContext(ctx, p.task, p.opts...); err != nil {
+			q.rollback(ctx, prepared, i)
+			return nil, fmt.Errorf("failed to enqueue task: %w", err)
+		}
+		ids = append(ids, p.taskID)
+	}
+
+	for _, p := range prepared {
+		kind := events.KindPending
+		if p.isScheduled() {
+			kind = events.KindScheduled
+		}
+		event := events.NewJobEvent(kind, p.taskID, string(p.job.Type))
+		event.Queue = p.queueName
+		if err := q.bus.Publish(ctx, event); err != nil {
+			log.Printf("Failed to publish %s event for job %s: %v", kind, p.taskID, err)
+		}
+	}
+
+	return ids, nil
+}
+
+// rollback removes the first enqueued tasks of jobs that failed to enqueue
+// and marks every one of the recorded jobs as cancelled
+func (q *AsynqQueue) rollback(ctx context.Context, prepared []*preparedJob, enqueued int) {
+	for i, p := range prepared {
+		if i < enqueued {
+			// Mark the job first so the worker skips it even if it is picked up meanwhile
+			if err := q.bus.MarkCancelled(ctx, p.taskID, q.resultRetention); err != nil {
+				log.Printf("Failed to mark job %s as cancelled: %v", p.taskID, err)
+			}
+			if err := q.inspector.DeleteTask(p.queueName, p.taskID); err != nil {
+				log.Printf("Failed to delete task of job %s: %v", p.taskID, err)
+			}
+		}
+
+		event := events.NewJobEvent(events.KindCancelled, p.taskID, string(p.job.Type))
+		event.Error = "job could not be enqueued"
+		if err := q.bus.Publish(ctx, event); err != nil {
+			log.Printf("Failed to publish cancelled event for job %s: %v", p.taskID, err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/hibiken/asynq"
+)
+
+// DefaultDeadLetterPageSize is the number of dead letters returned per page when no size is given
+const DefaultDeadLetterPageSize = 20
+
+// archiveReadSize is the number of archived tasks read from Redis at a time
+const archiveReadSize = 100
+
+// ListDeadLetters lists archived tasks, most recently failed first. An empty
+// queue name lists the archived tasks of every queue. Tasks of cancelled jobs
+// are archived too but left out.
+func (q *AsynqQueue) ListDeadLetters(ctx context.Context, queueName string, page, pageSize int) (*models.DeadLetterPage, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultDeadLetterPageSize
+	}
+
+	queues, err := q.deadLetterQueues(queueName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every queue may hold any part of the requested page, and the tasks of
+	// cancelled jobs are mixed in, so read the whole archive of each and merge.
+	// Asynq caps the size of an archive.
+	var deadLetters []*models.DeadLetter
+	for _, name := range queues {
+		for archivePage := 1; ; archivePage++ {
+			infos, err := q.inspector.ListArchivedTasks(name, asynq.Page(archivePage), asynq.PageSize(archiveReadSize))
+			if err != nil {
+				if errors.Is(err, asynq.ErrQueueNotFound) {
+					break
+				}
+				return nil, fmt.Errorf("failed to list archived tasks: %w", err)
+			}
+			for _, info := range infos {
+				if !events.IsCancelledError(info.LastErr) {
+					deadLetters = append(deadLetters, newDeadLetter(info))
+				}
+			}
+			if len(infos) < archiveReadSize {
+				break
+			}
+		}
+	}
+	total := len(deadLetters)
+
+	sort.Slice(deadLetters, func(i, j int) bool {
+		return deadLetters[i].FailedAt.After(deadLetters[j].FailedAt)
+	})
+
+	start := (page - 1) * pageSize
+	if start > len(deadLetters) {
+		start = len(deadLetters)
+	}
+	end := start + pageSize
+	if end > len(deadLetters) {
+		end = len(deadLetters)
+	}
+
+	return &models.DeadLetterPage{
+		DeadLetters: deadLetters[start:end],
+		Total:       total,
+		Page:        page,
+		PageSize:    pageSize,
+	}, nil
+}
+
+// GetDeadLetter gets an archived task
+func (q *AsynqQueue) GetDeadLetter(ctx context.Context, id string) (*models.DeadLetter, error) {
+	info, err := q.findDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return newDeadLetter(info), nil
+}
+
+// ReplayDeadLetter enqueues an archived task again as a new job on queueName,
+// or on the queue it was archived from if queueName is empty, and removes it
+// from the archive. It returns the ID of the new job.
+func (q *AsynqQueue) ReplayDeadLetter(ctx context.Context, id, queueName string) (string, error) {
+	info, err := q.findDeadLetter(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	if queueName == "" {
+		queueName = info.Queue
+	}
+
+	// Enqueue a new job rather than running the archived task so the replay
+	// gets its own history and is not skipped if the original was cancelled
+	jobID, err := q.AddJob(ctx, &models.Job{
+		Type:  models.JobType(info.Type),
+		Data:  json.RawMessage(info.Payload),
+		Queue: queueName,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := q.inspector.DeleteTask(info.Queue, id); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+		return "", fmt.Errorf("failed to remove replayed task from the archive: %w", err)
+	}
+
+	return jobID, nil
+}
+
+// DeleteDeadLetter removes an archived task
+func (q *AsynqQueue) DeleteDeadLetter(ctx context.Context, id string) error {
+	info, err := q.findDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := q.inspector.DeleteTask(info.Queue, id); err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return ErrDeadLetterNotFound
+		}
+		return fmt.Errorf("failed to delete archived task: %w", err)
+	}
+	return nil
+}
+
+// PurgeDeadLetters removes every archived task from queueName, or from every
+// queue if queueName is empty, and returns how many were removed
+func (q *AsynqQueue) PurgeDeadLetters(ctx context.Context, queueName string) (int, error) {
+	queues, err := q.deadLetterQueues(queueName)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, name := range queues {
+		n, err := q.inspector.DeleteAllArchivedTasks(name)
+		if err != nil {
+			if errors.Is(err, asynq.ErrQueueNotFound) {
+				continue
+			}
+			return deleted, fmt.Errorf("failed to purge archived tasks: %w", err)
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// deadLetterQueues returns the queues to look for archived tasks in
+func (q *AsynqQueue) deadLetterQueues(queueName string) ([]string, error) {
+	if queueName != "" {
+		return []string{queueName}, nil
+	}
+
+	queues, err := q.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+	return queues, nil
+}
+
+// findDeadLetter looks an archived task up in every queue. Tasks of cancelled
+// jobs are not dead letters.
+func (q *AsynqQueue) findDeadLetter(ctx context.Context, id string) (*asynq.TaskInfo, error) {
+	info, err := q.findTask(id)
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return nil, ErrDeadLetterNotFound
+		}
+		return nil, fmt.Errorf("failed to get task info: %w", err)
+	}
+	if info.State != asynq.TaskStateArchived || events.IsCancelledError(info.LastErr) {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	cancelled, err := q.bus.IsCancelled(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if cancelled {
+		return nil, ErrDeadLetterNotFound
+	}
+	return info, nil
+}
+
+// newDeadLetter converts an archived task to a dead letter
+func newDeadLetter(info *asynq.TaskInfo) *models.DeadLetter {
+	return &models.DeadLetter{
+		ID:        info.ID,
+		Queue:     info.Queue,
+		Type:      models.JobType(info.Type),
+		Payload:   json.RawMessage(info.Payload),
+		LastError: info.LastErr,
+		Retried:   info.Retried,
+		MaxRetry:  info.MaxRetry,
+		FailedAt:  info.LastFailedAt,
+	}
+}
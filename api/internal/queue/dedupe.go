@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// dedupeKeyPrefix is the prefix of the Redis keys mapping deduplication keys to job IDs
+const dedupeKeyPrefix = "bespin:job-dedupe:"
+
+// DefaultIdempotencyWindow is how long an idempotency key is remembered when
+// the job does not set a uniqueness window
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// dedupeKey returns the Redis key used to deduplicate a job and how long it is
+// kept, or an empty key if the job is not deduplicated. Jobs with an
+// idempotency key are deduplicated by that key; other unique jobs by their
+// type, queue and payload.
+func dedupeKey(job *models.Job, queueName string, payload []byte) (string, time.Duration) {
+	window := job.UniqueFor
+	if job.IdempotencyKey != "" {
+		if window <= 0 {
+			window = DefaultIdempotencyWindow
+		}
+		return dedupeKeyPrefix + string(job.Type) + ":key:" + job.IdempotencyKey, window
+	}
+
+	if window <= 0 {
+		return "", 0
+	}
+
+	h := sha256.New()
+	h.Write([]byte(job.Type))
+	h.Write([]byte{0})
+	h.Write([]byte(queueName))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return dedupeKeyPrefix + string(job.Type) + ":payload:" + hex.EncodeToString(h.Sum(nil)), window
+}
@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupeKey(t *testing.T) {
+	payload := []byte(`{"length":10}`)
+
+	t.Run("not deduplicated", func(t *testing.T) {
+		key, _ := dedupeKey(&models.Job{Type: models.JobTypeRandomText}, "default", payload)
+		assert.Empty(t, key)
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		job := &models.Job{Type: models.JobTypeRandomText, IdempotencyKey: "abc"}
+		key, window := dedupeKey(job, "default", payload)
+		assert.Equal(t, "bespin:job-dedupe:random_text:key:abc", key)
+		assert.Equal(t, DefaultIdempotencyWindow, window)
+
+		// The key does not depend on the payload
+		other, _ := dedupeKey(job, "default", []byte(`{"length":20}`))
+		assert.Equal(t, key, other)
+
+		job.UniqueFor = time.Minute
+		_, window = dedupeKey(job, "default", payload)
+		assert.Equal(t, time.Minute, window)
+	})
+
+	t.Run("unique payload", func(t *testing.T) {
+		job := &models.Job{Type: models.JobTypeRandomText, UniqueFor: time.Hour}
+		key, window := dedupeKey(job, "default", payload)
+		assert.NotEmpty(t, key)
+		assert.Equal(t, time.Hour, window)
+
+		same, _ := dedupeKey(job, "default", []byte(`{"length":10}`))
+		assert.Equal(t, key, same)
+
+		otherPayload, _ := dedupeKey(job, "default", []byte(`{"length":20}`))
+		assert.NotEqual(t, key, otherPayload)
+
+		otherQueue, _ := dedupeKey(job, "low", payload)
+		assert.NotEqual(t, key, otherQueue)
+	})
+}
@@ -0,0 +1,17 @@
+package queue
+
+import (
+	"errors"
+)
+
+// Error definitions
+var (
+	ErrJobNotFound        = errors.New("job not found")
+	ErrJobNotCancellable  = errors.New("job has already finished")
+	ErrUnknownQueue       = errors.New("unknown queue")
+	ErrInvalidSchedule    = errors.New("invalid schedule")
+	ErrInvalidRetry       = errors.New("invalid retry policy")
+	ErrInvalidChain       = errors.New("invalid follow-up job")
+	ErrInvalidBatch       = errors.New("invalid batch")
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+)
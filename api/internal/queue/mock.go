@@ -18,6 +18,15 @@ func (m *MockQueue) AddJob(ctx context.Context, job *models.Job) (string, error)
 	return args.String(0), args.Error(1)
 }
 
+// AddJobs mocks the AddJobs method
+func (m *MockQueue) AddJobs(ctx context.Context, jobs []*models.Job) ([]string, error) {
+	args := m.Called(ctx, jobs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
 // GetJobResult mocks the GetJobResult method
 func (m *MockQueue) GetJobResult(ctx context.Context, jobID string) (*models.JobResult, error) {
 	args := m.Called(ctx, jobID)
@@ -27,8 +36,89 @@ func (m *MockQueue) GetJobResult(ctx context.Context, jobID string) (*models.Job
 	return args.Get(0).(*models.JobResult), args.Error(1)
 }
 
+// CancelJob mocks the CancelJob method
+func (m *MockQueue) CancelJob(ctx context.Context, jobID string) error {
+	args := m.Called(ctx, jobID)
+	return args.Error(0)
+}
+
+// ListDeadLetters mocks the ListDeadLetters method
+func (m *MockQueue) ListDeadLetters(ctx context.Context, queueName string, page, pageSize int) (*models.DeadLetterPage, error) {
+	args := m.Called(ctx, queueName, page, pageSize)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DeadLetterPage), args.Error(1)
+}
+
+// GetDeadLetter mocks the GetDeadLetter method
+func (m *MockQueue) GetDeadLetter(ctx context.Context, id string) (*models.DeadLetter, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DeadLetter), args.Error(1)
+}
+
+// ReplayDeadLetter mocks the ReplayDeadLetter method
+func (m *MockQueue) ReplayDeadLetter(ctx context.Context, id, queueName string) (string, error) {
+	args := m.Called(ctx, id, queueName)
+	return args.String(0), args.Error(1)
+}
+
+// DeleteDeadLetter mocks the DeleteDeadLetter method
+func (m *MockQueue) DeleteDeadLetter(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// PurgeDeadLetters mocks the PurgeDeadLetters method
+func (m *MockQueue) PurgeDeadLetters(ctx context.Context, queueName string) (int, error) {
+	args := m.Called(ctx, queueName)
+	return args.Int(0), args.Error(1)
+}
+
 // Close mocks the Close method
 func (m *MockQueue) Close() error {
 	args := m.Called()
 	return args.Error(0)
 }
+
+// ListQueueStats mocks the ListQueueStats method
+func (m *MockQueue) ListQueueStats(ctx context.Context) ([]*models.QueueStats, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.QueueStats), args.Error(1)
+}
+
+// GetQueueStats mocks the GetQueueStats method
+func (m *MockQueue) GetQueueStats(ctx context.Context, queueName string) (*models.QueueStats, error) {
+	args := m.Called(ctx, queueName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.QueueStats), args.Error(1)
+}
+
+// PauseQueue mocks the PauseQueue method
+func (m *MockQueue) PauseQueue(ctx context.Context, queueName string) error {
+	args := m.Called(ctx, queueName)
+	return args.Error(0)
+}
+
+// ResumeQueue mocks the ResumeQueue method
+func (m *MockQueue) ResumeQueue(ctx context.Context, queueName string) error {
+	args := m.Called(ctx, queueName)
+	return args.Error(0)
+}
+
+// DrainQueue mocks the DrainQueue method
+func (m *MockQueue) DrainQueue(ctx context.Context, queueName string) (*models.DrainResult, error) {
+	args := m.Called(ctx, queueName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DrainResult), args.Error(1)
+}
@@ -3,10 +3,16 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/dustinleblanc/go-bespin-api/internal/eventbus"
 	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 )
 
@@ -14,53 +20,303 @@ import (
 type Queue interface {
 	// AddJob adds a job to the queue
 	AddJob(ctx context.Context, job *models.Job) (string, error)
+	// AddJobs adds many jobs to the queue and returns their IDs. If a job
+	// fails to enqueue, the jobs already enqueued are cancelled.
+	AddJobs(ctx context.Context, jobs []*models.Job) ([]string, error)
 	// GetJobResult gets a job result
 	GetJobResult(ctx context.Context, jobID string) (*models.JobResult, error)
+	// CancelJob cancels a pending or running job
+	CancelJob(ctx context.Context, jobID string) error
+	// ListDeadLetters lists jobs archived after exhausting their retries
+	ListDeadLetters(ctx context.Context, queueName string, page, pageSize int) (*models.DeadLetterPage, error)
+	// GetDeadLetter gets an archived job
+	GetDeadLetter(ctx context.Context, id string) (*models.DeadLetter, error)
+	// ReplayDeadLetter enqueues an archived job again and returns the new job ID
+	ReplayDeadLetter(ctx context.Context, id, queueName string) (string, error)
+	// DeleteDeadLetter removes an archived job
+	DeleteDeadLetter(ctx context.Context, id string) error
+	// PurgeDeadLetters removes all archived jobs and returns how many were removed
+	PurgeDeadLetters(ctx context.Context, queueName string) (int, error)
+	// ListQueueStats gets the stats of every queue
+	ListQueueStats(ctx context.Context) ([]*models.QueueStats, error)
+	// GetQueueStats gets the size, latency and throughput of a queue
+	GetQueueStats(ctx context.Context, queueName string) (*models.QueueStats, error)
+	// PauseQueue stops workers from processing jobs from a queue
+	PauseQueue(ctx context.Context, queueName string) error
+	// ResumeQueue lets workers process jobs from a paused queue again
+	ResumeQueue(ctx context.Context, queueName string) error
+	// DrainQueue removes every job waiting in a queue
+	DrainQueue(ctx context.Context, queueName string) (*models.DrainResult, error)
 }
 
+// Recorder records enqueued jobs in persistent storage
+type Recorder interface {
+	// RecordJob records a newly enqueued job
+	RecordJob(ctx context.Context, record *models.JobRecord) error
+}
+
+// DefaultResultRetention is how long completed tasks and their results are kept
+const DefaultResultRetention = 24 * time.Hour
+
 // AsynqQueue implements Queue using Asynq
 type AsynqQueue struct {
-	client    *asynq.Client
-	inspector *asynq.Inspector
+	client          *asynq.Client
+	inspector       *asynq.Inspector
+	bus             eventbus.Bus
+	recorder        Recorder
+	resultRetention time.Duration
 }
 
-// NewAsynqQueue creates a new AsynqQueue
-func NewAsynqQueue(redisAddr string) (*AsynqQueue, error) {
+// NewAsynqQueue creates a new AsynqQueue. Completed tasks and the results
+// written by the worker are kept in Redis for resultRetention. A pending event
+// is published on bus for every enqueued job, and job progress is read from it.
+// Every enqueued job is also recorded with recorder.
+func NewAsynqQueue(redisAddr string, resultRetention time.Duration, bus eventbus.Bus, recorder Recorder) (*AsynqQueue, error) {
 	client := asynq.NewClient(asynq.RedisClientOpt{Addr: redisAddr})
 	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: redisAddr})
 
+	if resultRetention <= 0 {
+		resultRetention = DefaultResultRetention
+	}
+
 	return &AsynqQueue{
-		client:    client,
-		inspector: inspector,
+		client:          client,
+		inspector:       inspector,
+		bus:             bus,
+		recorder:        recorder,
+		resultRetention: resultRetention,
 	}, nil
 }
 
-// AddJob adds a job to the queue
+// AddJob adds a job to the queue requested by the job, or the default queue.
+// Jobs with ProcessAt or ProcessIn set are scheduled to run later. Retry
+// options set on the job are stored for the worker, which applies the
+// defaults of the job type to the rest. Jobs with an idempotency key or a
+// uniqueness window are only enqueued once; enqueueing a duplicate returns
+// the ID of the original job. Follow-up jobs are stored for the worker,
+// which enqueues them once the job has finished.
 func (q *AsynqQueue) AddJob(ctx context.Context, job *models.Job) (string, error) {
-	// Serialize the job data
-	payload, err := json.Marshal(job.Data)
+	prepared, err := q.prepare(job)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal job data: %w", err)
+		return "", err
 	}
+	taskID := prepared.taskID
 
-	// Create the task
-	task := asynq.NewTask(string(job.Type), payload)
+	// Return the original job if this one is a duplicate
+	key, window := dedupeKey(job, prepared.queueName, prepared.payload)
+	if key != "" {
+		existingID, claimed, err := q.bus.ClaimDedupeKey(ctx, key, taskID, window)
+		if err != nil {
+			return "", err
+		}
+		if !claimed {
+			log.Printf("Job %s is a duplicate of job %s", job.Type, existingID)
+			return existingID, nil
+		}
+	}
+
+	// Record the job so it can be listed after it expires from Redis. The
+	// record is created before the task is enqueued, so the events of a task
+	// picked up right away update it rather than create a bare record.
+	recorded := true
+	if err := q.recorder.RecordJob(ctx, prepared.record()); err != nil {
+		log.Printf("Failed to record job %s: %v", taskID, err)
+		recorded = false
+	}
 
-	// Enqueue the task
-	info, err := q.client.EnqueueContext(ctx, task)
+	// Enqueue the task, releasing the dedupe key if that fails so the job can
+	// be retried and cancelling the recorded job
+	info, err := q.enqueue(ctx, prepared)
 	if err != nil {
-		return "", fmt.Errorf("failed to enqueue task: %w", err)
+		if key != "" {
+			if releaseErr := q.bus.ReleaseDedupeKey(ctx, key, taskID); releaseErr != nil {
+				log.Printf("Failed to release dedupe key of job %s: %v", taskID, releaseErr)
+			}
+		}
+		if recorded {
+			q.rollback(ctx, []*preparedJob{prepared}, 0)
+		}
+		return "", err
+	}
+
+	kind := events.KindPending
+	if info.State == asynq.TaskStateScheduled {
+		kind = events.KindScheduled
+	}
+
+	// Let subscribers know the job is queued; the job is already queued, so
+	// a failure to publish is not a failure to add the job
+	if err := q.bus.Publish(ctx, events.NewJobEvent(kind, info.ID, info.Type)); err != nil {
+		log.Printf("Failed to publish %s event for job %s: %v", kind, info.ID, err)
 	}
 
 	return info.ID, nil
 }
 
+// preparedJob is a job turned into a task ready to be enqueued
+type preparedJob struct {
+	job         *models.Job
+	task        *asynq.Task
+	taskID      string
+	queueName   string
+	payload     []byte
+	processAt   *time.Time
+	retryPolicy *tasks.RetryPolicy
+	chain       *tasks.Chain
+	opts        []asynq.Option
+}
+
+// record returns the job history record of a job about to be enqueued.
+// Jobs scheduled in the past run right away.
+func (p *preparedJob) record() *models.JobRecord {
+	record := &models.JobRecord{
+		ID:      p.taskID,
+		Type:    p.job.Type,
+		Status:  models.JobStatusPending,
+		Queue:   p.queueName,
+		Payload: models.JSON(p.payload),
+		BatchID: p.job.BatchID,
+	}
+	if p.isScheduled() {
+		record.Status = models.JobStatusScheduled
+		record.ScheduledAt = p.processAt
+	}
+	return record
+}
+
+// isScheduled checks if the job is scheduled to run later
+func (p *preparedJob) isScheduled() bool {
+	return p.processAt != nil && p.processAt.After(time.Now())
+}
+
+// prepare validates a job and builds its task and enqueue options. The task
+// ID is created up front so the dedupe key, retry policy and follow-up jobs
+// can be stored before a worker can pick the task up.
+func (q *AsynqQueue) prepare(job *models.Job) (*preparedJob, error) {
+	queueName := job.Queue
+	if queueName == "" {
+		queueName = tasks.QueueDefault
+	}
+	if !tasks.IsKnownQueue(queueName) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQueue, queueName)
+	}
+
+	// Build the enqueue options, keeping the task around after completion so its result can be read
+	opts := []asynq.Option{asynq.Queue(queueName), asynq.Retention(q.resultRetention)}
+	var processAt *time.Time
+	switch {
+	case job.ProcessAt != nil && job.ProcessIn != 0:
+		return nil, fmt.Errorf("%w: process_at and process_in are mutually exclusive", ErrInvalidSchedule)
+	case job.ProcessAt != nil:
+		opts = append(opts, asynq.ProcessAt(*job.ProcessAt))
+		processAt = job.ProcessAt
+	case job.ProcessIn < 0:
+		return nil, fmt.Errorf("%w: process_in must not be negative", ErrInvalidSchedule)
+	case job.ProcessIn > 0:
+		opts = append(opts, asynq.ProcessIn(job.ProcessIn))
+		at := time.Now().Add(job.ProcessIn)
+		processAt = &at
+	}
+
+	retryPolicy := job.RetryPolicy()
+	if retryPolicy != nil {
+		if err := retryPolicy.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRetry, err)
+		}
+		if retryPolicy.MaxRetries != nil {
+			opts = append(opts, asynq.MaxRetry(*retryPolicy.MaxRetries))
+		}
+		if retryPolicy.Timeout > 0 {
+			opts = append(opts, asynq.Timeout(retryPolicy.Timeout))
+		}
+		if retryPolicy.Deadline != nil {
+			opts = append(opts, asynq.Deadline(*retryPolicy.Deadline))
+		}
+	}
+
+	chain, err := job.Chain()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChain, err)
+	}
+	if err := chain.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChain, err)
+	}
+
+	// Serialize the job data using the shared job contract
+	payload, err := tasks.Encode(string(job.Type), job.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job data: %w", err)
+	}
+
+	taskID := uuid.New().String()
+	opts = append(opts, asynq.TaskID(taskID))
+
+	return &preparedJob{
+		job:         job,
+		task:        asynq.NewTask(string(job.Type), payload),
+		taskID:      taskID,
+		queueName:   queueName,
+		payload:     payload,
+		processAt:   processAt,
+		retryPolicy: retryPolicy,
+		chain:       chain,
+		opts:        opts,
+	}, nil
+}
+
+// enqueue stores the retry policy and follow-up jobs of a task, if any, and enqueues it
+func (q *AsynqQueue) enqueue(ctx context.Context, p *preparedJob) (*asynq.TaskInfo, error) {
+	ttl := q.settingsTTL(p.processAt)
+	if p.retryPolicy != nil {
+		if err := q.bus.SaveRetryPolicy(ctx, p.taskID, p.retryPolicy, ttl); err != nil {
+			return nil, err
+		}
+	}
+	if !p.chain.IsZero() {
+		if err := q.bus.SaveChain(ctx, p.taskID, p.chain, ttl); err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := q.client.EnqueueContext(ctx, p.task, p.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue task: %w", err)
+	}
+	return info, nil
+}
+
+// settingsTTL returns how long the retry policy and follow-up jobs of a task
+// are kept: the result retention, counted from the time the task is scheduled
+// to run if it runs later
+func (q *AsynqQueue) settingsTTL(processAt *time.Time) time.Duration {
+	ttl := q.resultRetention
+	if processAt != nil {
+		if delay := time.Until(*processAt); delay > 0 {
+			ttl += delay
+		}
+	}
+	return ttl
+}
+
 // GetJobResult gets a job result
 func (q *AsynqQueue) GetJobResult(ctx context.Context, jobID string) (*models.JobResult, error) {
+	cancelled, err := q.bus.IsCancelled(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the task info
-	info, err := q.inspector.GetTaskInfo("default", jobID)
+	info, err := q.findTask(jobID)
 	if err != nil {
 		if err == asynq.ErrTaskNotFound {
+			// Pending tasks are deleted when cancelled, so the marker is all that is left
+			if cancelled {
+				return &models.JobResult{
+					ID:        jobID,
+					Status:    models.JobStatusCancelled,
+					CreatedAt: time.Now(),
+				}, nil
+			}
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get task info: %w", err)
@@ -70,28 +326,131 @@ func (q *AsynqQueue) GetJobResult(ctx context.Context, jobID string) (*models.Jo
 	result := &models.JobResult{
 		ID:        jobID,
 		Status:    models.JobStatusPending,
+		Queue:     info.Queue,
+		Attempts:  info.Retried,
 		CreatedAt: time.Now(), // Asynq doesn't expose task creation time
 	}
 
 	// Update the status based on the task state
 	switch info.State.String() {
+	case "scheduled":
+		result.Status = models.JobStatusScheduled
+		result.ScheduledAt = &info.NextProcessAt
 	case "active":
 		result.Status = models.JobStatusProcessing
+		result.Attempts++
 	case "completed":
 		result.Status = models.JobStatusCompleted
 		result.CompletedAt = &info.CompletedAt
-		result.Result = string(info.Result)
-	case "failed":
+		result.Result = decodeResult(info.Result)
+		result.Attempts++
+	case "failed", "archived":
 		result.Status = models.JobStatusFailed
 		result.Error = info.LastErr
+		result.Attempts++
 	case "retry":
 		result.Status = models.JobStatusRetrying
 		result.Error = info.LastErr
+		result.NextRetryAt = &info.NextProcessAt
+	}
+
+	if cancelled {
+		result.Status = models.JobStatusCancelled
+		result.Error = ""
+	}
+
+	// Attach the latest progress reported by the worker, if any
+	progress, err := q.bus.GetProgress(ctx, jobID)
+	if err != nil {
+		return nil, err
 	}
+	result.Progress = progress
 
 	return result, nil
 }
 
+// CancelJob cancels a job. Pending and retrying tasks are removed from the queue
+// and running tasks have their context cancelled. The job is marked as cancelled
+// so the worker never picks it up again, and a cancelled event is published.
+func (q *AsynqQueue) CancelJob(ctx context.Context, jobID string) error {
+	cancelled, err := q.bus.IsCancelled(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		return ErrJobNotCancellable
+	}
+
+	info, err := q.findTask(jobID)
+	if err != nil {
+		if err == asynq.ErrTaskNotFound {
+			return ErrJobNotFound
+		}
+		return fmt.Errorf("failed to get task info: %w", err)
+	}
+
+	switch info.State {
+	case asynq.TaskStateCompleted, asynq.TaskStateArchived:
+		return ErrJobNotCancellable
+	}
+
+	// Mark the job first so the worker skips it even if it is picked up meanwhile
+	if err := q.bus.MarkCancelled(ctx, jobID, q.resultRetention); err != nil {
+		return err
+	}
+
+	if info.State == asynq.TaskStateActive {
+		if err := q.inspector.CancelProcessing(jobID); err != nil {
+			return fmt.Errorf("failed to cancel running task: %w", err)
+		}
+	} else {
+		if err := q.inspector.DeleteTask(info.Queue, jobID); err != nil && err != asynq.ErrTaskNotFound {
+			return fmt.Errorf("failed to delete task: %w", err)
+		}
+	}
+
+	if err := q.bus.Publish(ctx, events.NewJobEvent(events.KindCancelled, jobID, info.Type)); err != nil {
+		log.Printf("Failed to publish cancelled event for job %s: %v", jobID, err)
+	}
+
+	return nil
+}
+
+// findTask looks a task up in every queue, since the job ID alone does not
+// tell which queue the job was enqueued on
+func (q *AsynqQueue) findTask(jobID string) (*asynq.TaskInfo, error) {
+	queues, err := q.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	for _, queueName := range queues {
+		info, err := q.inspector.GetTaskInfo(queueName, jobID)
+		if err == nil {
+			return info, nil
+		}
+		if !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound) {
+			return nil, err
+		}
+	}
+
+	return nil, asynq.ErrTaskNotFound
+}
+
+// decodeResult decodes a result written by the worker. Results that are not
+// JSON are returned as plain strings.
+func decodeResult(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return string(data)
+	}
+	return decoded
+}
+
 // Close closes the queue
 func (q *AsynqQueue) Close() error {
 	if err := q.client.Close(); err != nil {
@@ -0,0 +1,22 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingsTTL(t *testing.T) {
+	q := &AsynqQueue{resultRetention: time.Hour}
+
+	assert.Equal(t, time.Hour, q.settingsTTL(nil))
+
+	past := time.Now().Add(-time.Minute)
+	assert.Equal(t, time.Hour, q.settingsTTL(&past))
+
+	// Settings of a job scheduled beyond the retention outlive its schedule
+	later := time.Now().Add(48 * time.Hour)
+	ttl := q.settingsTTL(&later)
+	assert.InDelta(t, float64(49*time.Hour), float64(ttl), float64(time.Minute))
+}
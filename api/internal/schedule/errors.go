@@ -0,0 +1,12 @@
+package schedule
+
+import (
+	"errors"
+)
+
+// Error definitions
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+	ErrDuplicateName    = errors.New("schedule name already exists")
+)
@@ -0,0 +1,88 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"gorm.io/gorm"
+)
+
+// GormRepository implements Repository using GORM
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRepository creates a new GORM repository
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{db: db}
+}
+
+// Create creates a new schedule
+func (r *GormRepository) Create(ctx context.Context, schedule *models.Schedule) error {
+	result := r.db.WithContext(ctx).Create(schedule)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create schedule: %w", result.Error)
+	}
+	return nil
+}
+
+// GetByID retrieves a schedule by ID
+func (r *GormRepository) GetByID(ctx context.Context, id string) (*models.Schedule, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+// GetByName retrieves a schedule by name
+func (r *GormRepository) GetByName(ctx context.Context, name string) (*models.Schedule, error) {
+	return r.first(ctx, "name = ?", name)
+}
+
+// first retrieves the first schedule matching the condition
+func (r *GormRepository) first(ctx context.Context, query string, arg string) (*models.Schedule, error) {
+	var schedule models.Schedule
+	result := r.db.WithContext(ctx).First(&schedule, query, arg)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrScheduleNotFound, arg)
+		}
+		return nil, fmt.Errorf("failed to get schedule: %w", result.Error)
+	}
+	return &schedule, nil
+}
+
+// Update updates a schedule
+func (r *GormRepository) Update(ctx context.Context, schedule *models.Schedule) error {
+	result := r.db.WithContext(ctx).Save(schedule)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update schedule: %w", result.Error)
+	}
+	return nil
+}
+
+// Delete deletes a schedule
+func (r *GormRepository) Delete(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).Delete(&models.Schedule{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete schedule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+	return nil
+}
+
+// List retrieves all schedules, optionally only the enabled ones
+func (r *GormRepository) List(ctx context.Context, enabledOnly bool) ([]*models.Schedule, error) {
+	var schedules []*models.Schedule
+	query := r.db.WithContext(ctx)
+
+	if enabledOnly {
+		query = query.Where("enabled = ?", true)
+	}
+
+	result := query.Order("name").Find(&schedules)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", result.Error)
+	}
+	return schedules, nil
+}
@@ -0,0 +1,113 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// MockRepository is an in-memory implementation of the Repository interface
+type MockRepository struct {
+	schedules map[string]*models.Schedule
+	mu        sync.RWMutex
+}
+
+// NewMockRepository creates a new mock repository
+func NewMockRepository() *MockRepository {
+	return &MockRepository{
+		schedules: make(map[string]*models.Schedule),
+	}
+}
+
+// Create stores a schedule in memory
+func (r *MockRepository) Create(ctx context.Context, schedule *models.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.schedules[schedule.ID]; ok {
+		return fmt.Errorf("schedule already exists: %s", schedule.ID)
+	}
+
+	copied := *schedule
+	r.schedules[schedule.ID] = &copied
+	return nil
+}
+
+// GetByID retrieves a schedule by ID from memory
+func (r *MockRepository) GetByID(ctx context.Context, id string) (*models.Schedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schedule, ok := r.schedules[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+
+	copied := *schedule
+	return &copied, nil
+}
+
+// GetByName retrieves a schedule by name from memory
+func (r *MockRepository) GetByName(ctx context.Context, name string) (*models.Schedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, schedule := range r.schedules {
+		if schedule.Name == name {
+			copied := *schedule
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+}
+
+// Update updates a schedule in memory
+func (r *MockRepository) Update(ctx context.Context, schedule *models.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.schedules[schedule.ID]; !ok {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, schedule.ID)
+	}
+
+	copied := *schedule
+	r.schedules[schedule.ID] = &copied
+	return nil
+}
+
+// Delete deletes a schedule from memory
+func (r *MockRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.schedules[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+
+	delete(r.schedules, id)
+	return nil
+}
+
+// List retrieves all schedules from memory, optionally only the enabled ones
+func (r *MockRepository) List(ctx context.Context, enabledOnly bool) ([]*models.Schedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schedules := make([]*models.Schedule, 0, len(r.schedules))
+	for _, schedule := range r.schedules {
+		if enabledOnly && !schedule.Enabled {
+			continue
+		}
+		copied := *schedule
+		schedules = append(schedules, &copied)
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+
+	return schedules, nil
+}
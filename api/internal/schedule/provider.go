@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ConfigProvider provides the enabled schedules to asynq's PeriodicTaskManager.
+// The manager calls GetConfigs on every sync, so changes made through the API
+// are picked up without restarting the scheduler.
+type ConfigProvider struct {
+	repo            Repository
+	resultRetention time.Duration
+	logger          *log.Logger
+}
+
+// Ensure ConfigProvider implements asynq.PeriodicTaskConfigProvider
+var _ asynq.PeriodicTaskConfigProvider = (*ConfigProvider)(nil)
+
+// NewConfigProvider creates a new config provider. Tasks enqueued by the
+// scheduler keep their results for resultRetention.
+func NewConfigProvider(repo Repository, resultRetention time.Duration) *ConfigProvider {
+	return &ConfigProvider{
+		repo:            repo,
+		resultRetention: resultRetention,
+		logger:          log.New(log.Writer(), "[Scheduler] ", log.LstdFlags),
+	}
+}
+
+// GetConfigs returns a periodic task config for every enabled schedule
+func (p *ConfigProvider) GetConfigs() ([]*asynq.PeriodicTaskConfig, error) {
+	schedules, err := p.repo.List(context.Background(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]*asynq.PeriodicTaskConfig, 0, len(schedules))
+	for _, schedule := range schedules {
+		// Skip schedules that were stored before a contract change made them invalid
+		if err := validate(schedule); err != nil {
+			p.logger.Printf("Skipping schedule %s (%s): %v", schedule.ID, schedule.Name, err)
+			continue
+		}
+
+		opts := []asynq.Option{asynq.Retention(p.resultRetention)}
+		if schedule.Queue != "" {
+			opts = append(opts, asynq.Queue(schedule.Queue))
+		}
+
+		configs = append(configs, &asynq.PeriodicTaskConfig{
+			Cronspec: schedule.Cron,
+			Task:     asynq.NewTask(string(schedule.JobType), schedule.Payload),
+			Opts:     opts,
+		})
+	}
+
+	return configs, nil
+}
@@ -0,0 +1,28 @@
+package schedule
+
+import (
+	"context"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// Repository defines the interface for schedule storage
+type Repository interface {
+	// Create creates a new schedule
+	Create(ctx context.Context, schedule *models.Schedule) error
+
+	// GetByID retrieves a schedule by ID
+	GetByID(ctx context.Context, id string) (*models.Schedule, error)
+
+	// GetByName retrieves a schedule by name
+	GetByName(ctx context.Context, name string) (*models.Schedule, error)
+
+	// Update updates a schedule
+	Update(ctx context.Context, schedule *models.Schedule) error
+
+	// Delete deletes a schedule
+	Delete(ctx context.Context, id string) error
+
+	// List retrieves all schedules, optionally only the enabled ones
+	List(ctx context.Context, enabledOnly bool) ([]*models.Schedule, error)
+}
@@ -0,0 +1,179 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/robfig/cron/v3"
+)
+
+// ScheduleService defines the interface for schedule operations
+type ScheduleService interface {
+	CreateSchedule(ctx context.Context, req *models.ScheduleRequest) (*models.Schedule, error)
+	GetSchedule(ctx context.Context, id string) (*models.Schedule, error)
+	ListSchedules(ctx context.Context) ([]*models.Schedule, error)
+	UpdateSchedule(ctx context.Context, id string, req *models.ScheduleRequest) (*models.Schedule, error)
+	DeleteSchedule(ctx context.Context, id string) error
+}
+
+// Ensure Service implements ScheduleService
+var _ ScheduleService = (*Service)(nil)
+
+// Service manages recurring job schedules
+type Service struct {
+	repo   Repository
+	logger *log.Logger
+}
+
+// NewService creates a new schedule service
+func NewService(repo Repository) *Service {
+	return &Service{
+		repo:   repo,
+		logger: log.New(log.Writer(), "[ScheduleService] ", log.LstdFlags),
+	}
+}
+
+// CreateSchedule validates and stores a new schedule. Schedules are enabled
+// unless the request says otherwise.
+func (s *Service) CreateSchedule(ctx context.Context, req *models.ScheduleRequest) (*models.Schedule, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidSchedule)
+	}
+
+	payload := []byte(req.Payload)
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+
+	schedule := models.NewSchedule(req.Name, req.Cron, req.JobType, payload, req.Queue)
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+
+	if err := validate(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureUniqueName(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, schedule); err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+
+	s.logger.Printf("Created schedule %s (%s): %s every %q", schedule.ID, schedule.Name, schedule.JobType, schedule.Cron)
+	return schedule, nil
+}
+
+// GetSchedule gets a schedule by ID
+func (s *Service) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	schedule, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// ListSchedules lists all schedules
+func (s *Service) ListSchedules(ctx context.Context) ([]*models.Schedule, error) {
+	schedules, err := s.repo.List(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// UpdateSchedule updates the fields set in the request and validates the result
+func (s *Service) UpdateSchedule(ctx context.Context, id string, req *models.ScheduleRequest) (*models.Schedule, error) {
+	schedule, err := s.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		schedule.Name = req.Name
+	}
+	if req.Cron != "" {
+		schedule.Cron = req.Cron
+	}
+	if req.JobType != "" {
+		schedule.JobType = req.JobType
+	}
+	if len(req.Payload) > 0 {
+		schedule.Payload = models.JSON(req.Payload)
+	}
+	if req.Queue != "" {
+		schedule.Queue = req.Queue
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+
+	if err := validate(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureUniqueName(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	schedule.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, schedule); err != nil {
+		return nil, fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	s.logger.Printf("Updated schedule %s (%s)", schedule.ID, schedule.Name)
+	return schedule, nil
+}
+
+// DeleteSchedule deletes a schedule
+func (s *Service) DeleteSchedule(ctx context.Context, id string) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	s.logger.Printf("Deleted schedule %s", id)
+	return nil
+}
+
+// ensureUniqueName checks that no other schedule uses the schedule's name
+func (s *Service) ensureUniqueName(ctx context.Context, schedule *models.Schedule) error {
+	existing, err := s.repo.GetByName(ctx, schedule.Name)
+	if err != nil {
+		if errors.Is(err, ErrScheduleNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to check schedule name: %w", err)
+	}
+	if existing.ID != schedule.ID {
+		return fmt.Errorf("%w: %s", ErrDuplicateName, schedule.Name)
+	}
+	return nil
+}
+
+// validate checks the cron expression, job type, payload and queue of a schedule
+func validate(schedule *models.Schedule) error {
+	if _, err := cron.ParseStandard(schedule.Cron); err != nil {
+		return fmt.Errorf("%w: invalid cron expression %q: %v", ErrInvalidSchedule, schedule.Cron, err)
+	}
+
+	if _, err := tasks.Decode(string(schedule.JobType), schedule.Payload); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+
+	if schedule.Queue != "" && !tasks.IsKnownQueue(schedule.Queue) {
+		return fmt.Errorf("%w: unknown queue %s", ErrInvalidSchedule, schedule.Queue)
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceWithMockRepository(t *testing.T) {
+	ctx := context.Background()
+	disabled := false
+
+	t.Run("CreateSchedule", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			req     models.ScheduleRequest
+			wantErr error
+		}{
+			{
+				name: "valid schedule",
+				req: models.ScheduleRequest{
+					Name:    "nightly-text",
+					Cron:    "0 3 * * *",
+					JobType: models.JobTypeRandomText,
+					Payload: json.RawMessage(`{"length":50}`),
+					Queue:   "low",
+				},
+			},
+			{
+				name: "descriptor without payload",
+				req: models.ScheduleRequest{
+					Name:    "every-minute",
+					Cron:    "@every 1m",
+					JobType: models.JobTypeRandomText,
+				},
+			},
+			{
+				name:    "missing name",
+				req:     models.ScheduleRequest{Cron: "* * * * *", JobType: models.JobTypeRandomText},
+				wantErr: ErrInvalidSchedule,
+			},
+			{
+				name:    "invalid cron expression",
+				req:     models.ScheduleRequest{Name: "bad-cron", Cron: "every tuesday", JobType: models.JobTypeRandomText},
+				wantErr: ErrInvalidSchedule,
+			},
+			{
+				name:    "unknown job type",
+				req:     models.ScheduleRequest{Name: "bad-type", Cron: "* * * * *", JobType: "unknown"},
+				wantErr: ErrInvalidSchedule,
+			},
+			{
+				name: "invalid payload",
+				req: models.ScheduleRequest{
+					Name:    "bad-payload",
+					Cron:    "* * * * *",
+					JobType: models.JobTypeProcessWebhook,
+					Payload: json.RawMessage(`{}`),
+				},
+				wantErr: ErrInvalidSchedule,
+			},
+			{
+				name:    "unknown queue",
+				req:     models.ScheduleRequest{Name: "bad-queue", Cron: "* * * * *", JobType: models.JobTypeRandomText, Queue: "urgent"},
+				wantErr: ErrInvalidSchedule,
+			},
+			{
+				name:    "duplicate name",
+				req:     models.ScheduleRequest{Name: "nightly-text", Cron: "* * * * *", JobType: models.JobTypeRandomText},
+				wantErr: ErrDuplicateName,
+			},
+		}
+
+		service := NewService(NewMockRepository())
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				s, err := service.CreateSchedule(ctx, &tc.req)
+				if tc.wantErr != nil {
+					assert.ErrorIs(t, err, tc.wantErr)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.NotEmpty(t, s.ID)
+				assert.True(t, s.Enabled)
+			})
+		}
+	})
+
+	t.Run("UpdateSchedule", func(t *testing.T) {
+		service := NewService(NewMockRepository())
+		s, err := service.CreateSchedule(ctx, &models.ScheduleRequest{
+			Name:    "hourly-text",
+			Cron:    "0 * * * *",
+			JobType: models.JobTypeRandomText,
+		})
+		require.NoError(t, err)
+
+		updated, err := service.UpdateSchedule(ctx, s.ID, &models.ScheduleRequest{Cron: "30 * * * *", Enabled: &disabled})
+		require.NoError(t, err)
+		assert.Equal(t, "30 * * * *", updated.Cron)
+		assert.Equal(t, "hourly-text", updated.Name)
+		assert.False(t, updated.Enabled)
+
+		_, err = service.UpdateSchedule(ctx, s.ID, &models.ScheduleRequest{Cron: "not a cron"})
+		assert.ErrorIs(t, err, ErrInvalidSchedule)
+
+		_, err = service.UpdateSchedule(ctx, "missing", &models.ScheduleRequest{})
+		assert.ErrorIs(t, err, ErrScheduleNotFound)
+	})
+
+	t.Run("DeleteSchedule", func(t *testing.T) {
+		service := NewService(NewMockRepository())
+		s, err := service.CreateSchedule(ctx, &models.ScheduleRequest{
+			Name:    "daily-text",
+			Cron:    "@daily",
+			JobType: models.JobTypeRandomText,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, service.DeleteSchedule(ctx, s.ID))
+		_, err = service.GetSchedule(ctx, s.ID)
+		assert.ErrorIs(t, err, ErrScheduleNotFound)
+		assert.ErrorIs(t, service.DeleteSchedule(ctx, s.ID), ErrScheduleNotFound)
+	})
+}
+
+func TestConfigProvider(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockRepository()
+	service := NewService(repo)
+	disabled := false
+
+	_, err := service.CreateSchedule(ctx, &models.ScheduleRequest{
+		Name:    "enabled",
+		Cron:    "*/5 * * * *",
+		JobType: models.JobTypeRandomText,
+		Payload: json.RawMessage(`{"length":10}`),
+		Queue:   "critical",
+	})
+	require.NoError(t, err)
+	_, err = service.CreateSchedule(ctx, &models.ScheduleRequest{
+		Name:    "disabled",
+		Cron:    "@hourly",
+		JobType: models.JobTypeRandomText,
+		Enabled: &disabled,
+	})
+	require.NoError(t, err)
+
+	provider := NewConfigProvider(repo, time.Hour)
+	configs, err := provider.GetConfigs()
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.Equal(t, "*/5 * * * *", configs[0].Cronspec)
+	assert.Equal(t, string(models.JobTypeRandomText), configs[0].Task.Type())
+	assert.JSONEq(t, `{"length":10}`, string(configs[0].Task.Payload()))
+	assert.Len(t, configs[0].Opts, 2)
+
+	// Changes are picked up on the next sync
+	schedules, err := service.ListSchedules(ctx)
+	require.NoError(t, err)
+	for _, s := range schedules {
+		_, err := service.UpdateSchedule(ctx, s.ID, &models.ScheduleRequest{Enabled: &disabled})
+		require.NoError(t, err)
+	}
+	configs, err = provider.GetConfigs()
+	require.NoError(t, err)
+	assert.Empty(t, configs)
+}
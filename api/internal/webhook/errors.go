@@ -0,0 +1,28 @@
+package webhook
+
+import "errors"
+
+var (
+	// ErrInvalidSource is returned for webhooks from a source that is not configured
+	ErrInvalidSource = errors.New("invalid source")
+	// ErrMissingEvent is returned when the event of a webhook cannot be determined
+	ErrMissingEvent = errors.New("event is required")
+	// ErrMissingSignature is returned when a webhook carries no signature
+	ErrMissingSignature = errors.New("signature is required")
+	// ErrInvalidSignature is returned when the signature of a webhook does not match
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrDuplicateDelivery is returned for a delivery that was received before
+	ErrDuplicateDelivery = errors.New("duplicate delivery")
+	// ErrReceiptNotFound is returned when a webhook receipt does not exist
+	ErrReceiptNotFound = errors.New("webhook receipt not found")
+	// ErrSourceNotFound is returned when a webhook source does not exist
+	ErrSourceNotFound = errors.New("webhook source not found")
+	// ErrInvalidSourceConfig is returned when a webhook source is configured incorrectly
+	ErrInvalidSourceConfig = errors.New("invalid webhook source")
+	// ErrDuplicateSource is returned when a webhook source name is already taken
+	ErrDuplicateSource = errors.New("webhook source already exists")
+	// ErrSecretNotFound is returned when a webhook source has no secret with an ID
+	ErrSecretNotFound = errors.New("webhook secret not found")
+	// ErrIPNotAllowed is returned for webhooks sent from outside a source's allowed IPs
+	ErrIPNotAllowed = errors.New("ip address not allowed")
+)
@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"net/http"
+	"strings"
+)
+
+// GitHubVerifier verifies GitHub deliveries, which carry the HMAC-SHA256 of
+// the payload as X-Hub-Signature-256: sha256=<hex> and name their event in
+// X-GitHub-Event
+type GitHubVerifier struct {
+	secret []byte
+}
+
+// NewGitHubVerifier creates a verifier for a GitHub webhook secret
+func NewGitHubVerifier(secret string) *GitHubVerifier {
+	return &GitHubVerifier{secret: []byte(secret)}
+}
+
+// Verify checks the X-Hub-Signature-256 header
+func (v *GitHubVerifier) Verify(header http.Header, payload []byte) error {
+	signature := v.Signature(header)
+	if signature == "" {
+		return ErrMissingSignature
+	}
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(digest), []byte(hexHMAC(v.secret, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Signature returns the X-Hub-Signature-256 header
+func (v *GitHubVerifier) Signature(header http.Header) string {
+	return header.Get("X-Hub-Signature-256")
+}
+
+// Event returns the X-GitHub-Event header
+func (v *GitHubVerifier) Event(header http.Header, payload []byte) string {
+	return header.Get("X-GitHub-Event")
+}
+
+// DeliveryID returns the X-GitHub-Delivery header, which GitHub keeps when a
+// delivery is redelivered
+func (v *GitHubVerifier) DeliveryID(header http.Header, payload []byte) string {
+	return header.Get("X-GitHub-Delivery")
+}
@@ -2,12 +2,17 @@ package webhook
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
+// uniqueViolation is the PostgreSQL error code of unique constraint violations
+const uniqueViolation = "23505"
+
 // GormRepository implements Repository using GORM
 type GormRepository struct {
 	db *gorm.DB
@@ -18,11 +23,20 @@ func NewGormRepository(db *gorm.DB) *GormRepository {
 	return &GormRepository{db: db}
 }
 
-// Create creates a new webhook receipt
+// Create creates a new webhook receipt and records its status in its history
 func (r *GormRepository) Create(ctx context.Context, receipt *models.WebhookReceipt) error {
-	result := r.db.WithContext(ctx).Create(receipt)
-	if result.Error != nil {
-		return fmt.Errorf("failed to create webhook receipt: %w", result.Error)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(receipt).Error; err != nil {
+			return err
+		}
+		return tx.Create(receipt.StatusChange()).Error
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return fmt.Errorf("%w: %s", ErrDuplicateDelivery, pgErr.ConstraintName)
+		}
+		return fmt.Errorf("failed to create webhook receipt: %w", err)
 	}
 	return nil
 }
@@ -33,50 +47,118 @@ func (r *GormRepository) GetByID(ctx context.Context, id string) (*models.Webhoo
 	result := r.db.WithContext(ctx).First(&receipt, "id = ?", id)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("webhook receipt not found: %s", id)
+			return nil, fmt.Errorf("%w: %s", ErrReceiptNotFound, id)
 		}
 		return nil, fmt.Errorf("failed to get webhook receipt: %w", result.Error)
 	}
 	return &receipt, nil
 }
 
-// Update updates a webhook receipt
-func (r *GormRepository) Update(ctx context.Context, receipt *models.WebhookReceipt) error {
-	result := r.db.WithContext(ctx).Save(receipt)
+// GetByDelivery retrieves the webhook receipt of a source's delivery
+func (r *GormRepository) GetByDelivery(ctx context.Context, source, deliveryID string) (*models.WebhookReceipt, error) {
+	var receipt models.WebhookReceipt
+	result := r.db.WithContext(ctx).First(&receipt, "source = ? AND delivery_id = ?", source, deliveryID)
 	if result.Error != nil {
-		return fmt.Errorf("failed to update webhook receipt: %w", result.Error)
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s delivery %s", ErrReceiptNotFound, source, deliveryID)
+		}
+		return nil, fmt.Errorf("failed to get webhook receipt: %w", result.Error)
+	}
+	return &receipt, nil
+}
+
+// GetByDigest retrieves the webhook receipt of a source's delivery by the
+// digest of its payload
+func (r *GormRepository) GetByDigest(ctx context.Context, source, digest string) (*models.WebhookReceipt, error) {
+	var receipt models.WebhookReceipt
+	result := r.db.WithContext(ctx).First(&receipt, "source = ? AND payload_digest = ?", source, digest)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s payload %s", ErrReceiptNotFound, source, digest)
+		}
+		return nil, fmt.Errorf("failed to get webhook receipt: %w", result.Error)
+	}
+	return &receipt, nil
+}
+
+// Update updates a webhook receipt and records its status in its history
+func (r *GormRepository) Update(ctx context.Context, receipt *models.WebhookReceipt) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(receipt).Error; err != nil {
+			return err
+		}
+		return tx.Create(receipt.StatusChange()).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update webhook receipt: %w", err)
 	}
 	return nil
 }
 
-// List retrieves a list of webhook receipts with optional filtering
-func (r *GormRepository) List(ctx context.Context, source string, limit, offset int) ([]*models.WebhookReceipt, error) {
+// History retrieves the status changes of a webhook receipt, oldest first
+func (r *GormRepository) History(ctx context.Context, receiptID string) ([]*models.WebhookStatusChange, error) {
+	var changes []*models.WebhookStatusChange
+	result := r.db.WithContext(ctx).Where("receipt_id = ?", receiptID).Order("created_at").Order("id").Find(&changes)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get webhook receipt history: %w", result.Error)
+	}
+	return changes, nil
+}
+
+// List retrieves webhook receipts matching the filter, newest first
+func (r *GormRepository) List(ctx context.Context, filter ReceiptFilter) ([]*models.WebhookReceipt, error) {
 	var receipts []*models.WebhookReceipt
-	query := r.db.WithContext(ctx)
+	query := applyFilter(r.db.WithContext(ctx), filter)
 
-	if source != "" {
-		query = query.Where("source = ?", source)
+	if filter.Cursor != nil {
+		query = query.Where("(created_at < ?) OR (created_at = ? AND id < ?)",
+			filter.Cursor.CreatedAt, filter.Cursor.CreatedAt, filter.Cursor.ID)
 	}
 
-	result := query.Order("created_at desc").Limit(limit).Offset(offset).Find(&receipts)
+	result := query.Order("created_at desc").Order("id desc").Limit(filter.Limit).Find(&receipts)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to list webhook receipts: %w", result.Error)
 	}
 	return receipts, nil
 }
 
-// Count counts webhook receipts with optional filtering
-func (r *GormRepository) Count(ctx context.Context, source string) (int64, error) {
-	var count int64
-	query := r.db.WithContext(ctx).Model(&models.WebhookReceipt{})
+// CountByStatus counts the webhook receipts matching the filter by status
+func (r *GormRepository) CountByStatus(ctx context.Context, filter ReceiptFilter) (map[models.WebhookStatus]int, error) {
+	var rows []struct {
+		Status models.WebhookStatus
+		Count  int
+	}
+	result := applyFilter(r.db.WithContext(ctx).Model(&models.WebhookReceipt{}), filter).
+		Select("status, count(*) AS count").
+		Group("status").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to count webhook receipts: %w", result.Error)
+	}
 
-	if source != "" {
-		query = query.Where("source = ?", source)
+	counts := make(map[models.WebhookStatus]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
 	}
+	return counts, nil
+}
 
-	result := query.Count(&count)
-	if result.Error != nil {
-		return 0, fmt.Errorf("failed to count webhook receipts: %w", result.Error)
+// applyFilter adds the conditions of a filter, except its cursor and limit, to a query
+func applyFilter(query *gorm.DB, filter ReceiptFilter) *gorm.DB {
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.Event != "" {
+		query = query.Where("event = ?", filter.Event)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
 	}
-	return count, nil
+	return query
 }
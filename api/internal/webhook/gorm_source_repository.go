@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"gorm.io/gorm"
+)
+
+// GormSourceRepository implements SourceRepository using GORM
+type GormSourceRepository struct {
+	db *gorm.DB
+}
+
+// NewGormSourceRepository creates a new GORM webhook source repository
+func NewGormSourceRepository(db *gorm.DB) *GormSourceRepository {
+	return &GormSourceRepository{db: db}
+}
+
+// Create creates a new webhook source
+func (r *GormSourceRepository) Create(ctx context.Context, source *models.WebhookSource) error {
+	result := r.db.WithContext(ctx).Create(source)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create webhook source: %w", result.Error)
+	}
+	return nil
+}
+
+// GetByName retrieves a webhook source by name
+func (r *GormSourceRepository) GetByName(ctx context.Context, name string) (*models.WebhookSource, error) {
+	var source models.WebhookSource
+	result := r.db.WithContext(ctx).First(&source, "name = ?", name)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to get webhook source: %w", result.Error)
+	}
+	return &source, nil
+}
+
+// Update updates a webhook source
+func (r *GormSourceRepository) Update(ctx context.Context, source *models.WebhookSource) error {
+	result := r.db.WithContext(ctx).Save(source)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update webhook source: %w", result.Error)
+	}
+	return nil
+}
+
+// Delete deletes a webhook source by name
+func (r *GormSourceRepository) Delete(ctx context.Context, name string) error {
+	result := r.db.WithContext(ctx).Delete(&models.WebhookSource{}, "name = ?", name)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete webhook source: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrSourceNotFound, name)
+	}
+	return nil
+}
+
+// List retrieves all webhook sources, optionally only the enabled ones
+func (r *GormSourceRepository) List(ctx context.Context, enabledOnly bool) ([]*models.WebhookSource, error) {
+	var sources []*models.WebhookSource
+	query := r.db.WithContext(ctx)
+
+	if enabledOnly {
+		query = query.Where("enabled = ?", true)
+	}
+
+	result := query.Order("name").Find(&sources)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list webhook sources: %w", result.Error)
+	}
+	return sources, nil
+}
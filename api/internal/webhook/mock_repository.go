@@ -3,6 +3,7 @@ package webhook
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/dustinleblanc/go-bespin-api/pkg/models"
@@ -13,7 +14,7 @@ import (
 type MockRepository struct {
 	mock.Mock
 	webhooks map[string]*models.WebhookReceipt
-	sources  map[string][]string
+	history  map[string][]*models.WebhookStatusChange
 	mu       sync.RWMutex
 }
 
@@ -21,29 +22,32 @@ type MockRepository struct {
 func NewMockRepository() *MockRepository {
 	return &MockRepository{
 		webhooks: make(map[string]*models.WebhookReceipt),
-		sources:  make(map[string][]string),
+		history:  make(map[string][]*models.WebhookStatusChange),
 	}
 }
 
-// Create stores a webhook receipt in memory
+// Create stores a webhook receipt in memory and records its status in its
+// history
 func (r *MockRepository) Create(ctx context.Context, receipt *models.WebhookReceipt) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Store webhook
-	r.webhooks[receipt.ID] = receipt
-
-	// Add to source list
-	if _, ok := r.sources[receipt.Source]; !ok {
-		r.sources[receipt.Source] = make([]string, 0)
+	// Enforce the uniqueness of delivery IDs and payload digests per source
+	for _, existing := range r.webhooks {
+		if existing.Source != receipt.Source {
+			continue
+		}
+		if receipt.DeliveryID != "" && existing.DeliveryID == receipt.DeliveryID {
+			return fmt.Errorf("%w: %s", ErrDuplicateDelivery, receipt.DeliveryID)
+		}
+		if receipt.PayloadDigest != "" && existing.PayloadDigest == receipt.PayloadDigest {
+			return fmt.Errorf("%w: %s", ErrDuplicateDelivery, receipt.PayloadDigest)
+		}
 	}
-	r.sources[receipt.Source] = append(r.sources[receipt.Source], receipt.ID)
 
-	// Add to all list
-	if _, ok := r.sources["all"]; !ok {
-		r.sources["all"] = make([]string, 0)
-	}
-	r.sources["all"] = append(r.sources["all"], receipt.ID)
+	// Store webhook
+	r.webhooks[receipt.ID] = receipt
+	r.history[receipt.ID] = append(r.history[receipt.ID], receipt.StatusChange())
 
 	return nil
 }
@@ -55,69 +59,125 @@ func (r *MockRepository) GetByID(ctx context.Context, id string) (*models.Webhoo
 
 	receipt, ok := r.webhooks[id]
 	if !ok {
-		return nil, fmt.Errorf("webhook receipt not found: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrReceiptNotFound, id)
 	}
 
 	return receipt, nil
 }
 
-// Update updates a webhook receipt in memory
+// GetByDelivery retrieves the webhook receipt of a source's delivery from memory
+func (r *MockRepository) GetByDelivery(ctx context.Context, source, deliveryID string) (*models.WebhookReceipt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, receipt := range r.webhooks {
+		if receipt.Source == source && receipt.DeliveryID == deliveryID {
+			return receipt, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s delivery %s", ErrReceiptNotFound, source, deliveryID)
+}
+
+// GetByDigest retrieves the webhook receipt of a source's delivery by the
+// digest of its payload from memory
+func (r *MockRepository) GetByDigest(ctx context.Context, source, digest string) (*models.WebhookReceipt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, receipt := range r.webhooks {
+		if receipt.Source == source && receipt.PayloadDigest == digest {
+			return receipt, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s payload %s", ErrReceiptNotFound, source, digest)
+}
+
+// Update updates a webhook receipt in memory and records its status in its
+// history
 func (r *MockRepository) Update(ctx context.Context, receipt *models.WebhookReceipt) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	if _, ok := r.webhooks[receipt.ID]; !ok {
-		return fmt.Errorf("webhook receipt not found: %s", receipt.ID)
+		return fmt.Errorf("%w: %s", ErrReceiptNotFound, receipt.ID)
 	}
 
 	r.webhooks[receipt.ID] = receipt
+	r.history[receipt.ID] = append(r.history[receipt.ID], receipt.StatusChange())
 	return nil
 }
 
-// List lists webhook receipts by source from memory
-func (r *MockRepository) List(ctx context.Context, source string, limit, offset int) ([]*models.WebhookReceipt, error) {
+// History retrieves the status changes of a webhook receipt from memory,
+// oldest first
+func (r *MockRepository) History(ctx context.Context, receiptID string) ([]*models.WebhookStatusChange, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	// Get IDs
-	var ids []string
-	if source == "" {
-		ids = r.sources["all"]
-	} else {
-		ids = r.sources[source]
-	}
+	changes := make([]*models.WebhookStatusChange, len(r.history[receiptID]))
+	copy(changes, r.history[receiptID])
+	return changes, nil
+}
 
-	// Apply pagination
-	if offset >= len(ids) {
-		return []*models.WebhookReceipt{}, nil
-	}
+// List retrieves webhook receipts matching the filter from memory, newest first
+func (r *MockRepository) List(ctx context.Context, filter ReceiptFilter) ([]*models.WebhookReceipt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	end := offset + limit
-	if end > len(ids) {
-		end = len(ids)
+	receipts := make([]*models.WebhookReceipt, 0, len(r.webhooks))
+	for _, receipt := range r.webhooks {
+		if !matches(receipt, filter) {
+			continue
+		}
+		if filter.Cursor != nil && !filter.Cursor.Precedes(receipt.CreatedAt, receipt.ID) {
+			continue
+		}
+		receipts = append(receipts, receipt)
 	}
-	ids = ids[offset:end]
-
-	// Get webhooks
-	receipts := make([]*models.WebhookReceipt, 0, len(ids))
-	for _, id := range ids {
-		receipt, ok := r.webhooks[id]
-		if ok {
-			receipts = append(receipts, receipt)
+
+	sort.Slice(receipts, func(i, j int) bool {
+		if receipts[i].CreatedAt.Equal(receipts[j].CreatedAt) {
+			return receipts[i].ID > receipts[j].ID
 		}
+		return receipts[i].CreatedAt.After(receipts[j].CreatedAt)
+	})
+
+	if filter.Limit > 0 && len(receipts) > filter.Limit {
+		receipts = receipts[:filter.Limit]
 	}
 
 	return receipts, nil
 }
 
-// Count counts webhook receipts by source from memory
-func (r *MockRepository) Count(ctx context.Context, source string) (int64, error) {
+// CountByStatus counts the webhook receipts in memory matching the filter by
+// status
+func (r *MockRepository) CountByStatus(ctx context.Context, filter ReceiptFilter) (map[models.WebhookStatus]int, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	if source == "" {
-		return int64(len(r.sources["all"])), nil
+	counts := make(map[models.WebhookStatus]int)
+	for _, receipt := range r.webhooks {
+		if matches(receipt, filter) {
+			counts[receipt.Status]++
+		}
 	}
+	return counts, nil
+}
 
-	return int64(len(r.sources[source])), nil
+// matches checks if a receipt matches the filter, except its cursor and limit
+func matches(receipt *models.WebhookReceipt, filter ReceiptFilter) bool {
+	switch {
+	case filter.Source != "" && receipt.Source != filter.Source:
+		return false
+	case filter.Event != "" && receipt.Event != filter.Event:
+		return false
+	case filter.Status != "" && receipt.Status != filter.Status:
+		return false
+	case filter.CreatedAfter != nil && receipt.CreatedAt.Before(*filter.CreatedAfter):
+		return false
+	case filter.CreatedBefore != nil && !receipt.CreatedAt.Before(*filter.CreatedBefore):
+		return false
+	}
+	return true
 }
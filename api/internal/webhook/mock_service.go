@@ -2,6 +2,7 @@ package webhook
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/dustinleblanc/go-bespin-api/pkg/models"
 	"github.com/stretchr/testify/mock"
@@ -9,13 +10,23 @@ import (
 
 // WebhookService defines the interface for webhook operations
 type WebhookService interface {
-	VerifySignature(source string, payload []byte, signature string) bool
-	CreateReceipt(ctx context.Context, source, event string, payload []byte, signature string) (*models.WebhookReceipt, error)
+	VerifySignature(source string, header http.Header, payload []byte) error
+	EventType(source string, header http.Header, payload []byte) string
+	CreateReceipt(ctx context.Context, source string, header http.Header, payload []byte, remoteIP string) (*models.WebhookReceipt, error)
 	GetReceipt(ctx context.Context, id string) (*models.WebhookReceipt, error)
 	UpdateReceipt(ctx context.Context, receipt *models.WebhookReceipt) error
-	ListReceipts(ctx context.Context, source string, limit, offset int) ([]*models.WebhookReceipt, error)
-	CountReceipts(ctx context.Context, source string) (int64, error)
+	GetReceiptDetail(ctx context.Context, id string) (*models.WebhookReceiptDetail, error)
+	ListReceipts(ctx context.Context, filter ReceiptFilter) (*ReceiptPage, error)
+	CountReceipts(ctx context.Context, filter ReceiptFilter) (map[models.WebhookStatus]int, error)
 	IsValidSource(source string) bool
+	CreateSource(ctx context.Context, req *models.WebhookSourceRequest) (*models.WebhookSource, error)
+	GetSource(ctx context.Context, name string) (*models.WebhookSource, error)
+	ListSources(ctx context.Context) ([]*models.WebhookSource, error)
+	UpdateSource(ctx context.Context, name string, req *models.WebhookSourceRequest) (*models.WebhookSource, error)
+	DeleteSource(ctx context.Context, name string) error
+	AddSecret(ctx context.Context, name string, req *models.WebhookSecretRequest) (*models.WebhookSource, error)
+	PromoteSecret(ctx context.Context, name, id string, req *models.WebhookSecretRequest) (*models.WebhookSource, error)
+	RetireSecret(ctx context.Context, name, id string) (*models.WebhookSource, error)
 }
 
 // Ensure MockService implements WebhookService
@@ -32,14 +43,20 @@ func NewMockService() *MockService {
 }
 
 // VerifySignature verifies the webhook signature
-func (s *MockService) VerifySignature(source string, payload []byte, signature string) bool {
-	args := s.Called(source, payload, signature)
-	return args.Bool(0)
+func (s *MockService) VerifySignature(source string, header http.Header, payload []byte) error {
+	args := s.Called(source, header, payload)
+	return args.Error(0)
+}
+
+// EventType determines the event of a delivery
+func (s *MockService) EventType(source string, header http.Header, payload []byte) string {
+	args := s.Called(source, header, payload)
+	return args.String(0)
 }
 
 // CreateReceipt creates a new webhook receipt
-func (s *MockService) CreateReceipt(ctx context.Context, source, event string, payload []byte, signature string) (*models.WebhookReceipt, error) {
-	args := s.Called(ctx, source, event, payload, signature)
+func (s *MockService) CreateReceipt(ctx context.Context, source string, header http.Header, payload []byte, remoteIP string) (*models.WebhookReceipt, error) {
+	args := s.Called(ctx, source, header, payload, remoteIP)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -61,19 +78,31 @@ func (s *MockService) UpdateReceipt(ctx context.Context, receipt *models.Webhook
 	return args.Error(0)
 }
 
-// ListReceipts lists webhook receipts for a source
-func (s *MockService) ListReceipts(ctx context.Context, source string, limit, offset int) ([]*models.WebhookReceipt, error) {
-	args := s.Called(ctx, source, limit, offset)
+// GetReceiptDetail gets a webhook receipt with its headers, payload and history
+func (s *MockService) GetReceiptDetail(ctx context.Context, id string) (*models.WebhookReceiptDetail, error) {
+	args := s.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*models.WebhookReceipt), args.Error(1)
+	return args.Get(0).(*models.WebhookReceiptDetail), args.Error(1)
 }
 
-// CountReceipts counts webhook receipts for a source
-func (s *MockService) CountReceipts(ctx context.Context, source string) (int64, error) {
-	args := s.Called(ctx, source)
-	return args.Get(0).(int64), args.Error(1)
+// ListReceipts lists the webhook receipts matching the filter
+func (s *MockService) ListReceipts(ctx context.Context, filter ReceiptFilter) (*ReceiptPage, error) {
+	args := s.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ReceiptPage), args.Error(1)
+}
+
+// CountReceipts counts the webhook receipts matching the filter by status
+func (s *MockService) CountReceipts(ctx context.Context, filter ReceiptFilter) (map[models.WebhookStatus]int, error) {
+	args := s.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[models.WebhookStatus]int), args.Error(1)
 }
 
 // IsValidSource checks if a source is valid
@@ -81,3 +110,72 @@ func (s *MockService) IsValidSource(source string) bool {
 	args := s.Called(source)
 	return args.Bool(0)
 }
+
+// CreateSource creates a new webhook source
+func (s *MockService) CreateSource(ctx context.Context, req *models.WebhookSourceRequest) (*models.WebhookSource, error) {
+	args := s.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSource), args.Error(1)
+}
+
+// GetSource gets a webhook source by name
+func (s *MockService) GetSource(ctx context.Context, name string) (*models.WebhookSource, error) {
+	args := s.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSource), args.Error(1)
+}
+
+// ListSources lists all webhook sources
+func (s *MockService) ListSources(ctx context.Context) ([]*models.WebhookSource, error) {
+	args := s.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.WebhookSource), args.Error(1)
+}
+
+// UpdateSource updates a webhook source
+func (s *MockService) UpdateSource(ctx context.Context, name string, req *models.WebhookSourceRequest) (*models.WebhookSource, error) {
+	args := s.Called(ctx, name, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSource), args.Error(1)
+}
+
+// DeleteSource deletes a webhook source
+func (s *MockService) DeleteSource(ctx context.Context, name string) error {
+	args := s.Called(ctx, name)
+	return args.Error(0)
+}
+
+// AddSecret adds a secret to a webhook source
+func (s *MockService) AddSecret(ctx context.Context, name string, req *models.WebhookSecretRequest) (*models.WebhookSource, error) {
+	args := s.Called(ctx, name, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSource), args.Error(1)
+}
+
+// PromoteSecret makes a secret of a webhook source the primary secret
+func (s *MockService) PromoteSecret(ctx context.Context, name, id string, req *models.WebhookSecretRequest) (*models.WebhookSource, error) {
+	args := s.Called(ctx, name, id, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSource), args.Error(1)
+}
+
+// RetireSecret removes a secondary secret from a webhook source
+func (s *MockService) RetireSecret(ctx context.Context, name, id string) (*models.WebhookSource, error) {
+	args := s.Called(ctx, name, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSource), args.Error(1)
+}
@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// MockSourceRepository is an in-memory implementation of the SourceRepository interface
+type MockSourceRepository struct {
+	sources map[string]*models.WebhookSource
+	mu      sync.RWMutex
+}
+
+// NewMockSourceRepository creates a new mock webhook source repository
+// holding sources
+func NewMockSourceRepository(sources ...*models.WebhookSource) *MockSourceRepository {
+	r := &MockSourceRepository{
+		sources: make(map[string]*models.WebhookSource),
+	}
+	for _, source := range sources {
+		r.sources[source.Name] = copySource(source)
+	}
+	return r
+}
+
+// Create stores a webhook source in memory
+func (r *MockSourceRepository) Create(ctx context.Context, source *models.WebhookSource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sources[source.Name]; ok {
+		return fmt.Errorf("webhook source already exists: %s", source.Name)
+	}
+
+	r.sources[source.Name] = copySource(source)
+	return nil
+}
+
+// GetByName retrieves a webhook source by name from memory
+func (r *MockSourceRepository) GetByName(ctx context.Context, name string) (*models.WebhookSource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	source, ok := r.sources[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, name)
+	}
+
+	return copySource(source), nil
+}
+
+// Update updates a webhook source in memory
+func (r *MockSourceRepository) Update(ctx context.Context, source *models.WebhookSource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sources[source.Name]; !ok {
+		return fmt.Errorf("%w: %s", ErrSourceNotFound, source.Name)
+	}
+
+	r.sources[source.Name] = copySource(source)
+	return nil
+}
+
+// Delete deletes a webhook source from memory
+func (r *MockSourceRepository) Delete(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sources[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrSourceNotFound, name)
+	}
+
+	delete(r.sources, name)
+	return nil
+}
+
+// List retrieves webhook sources from memory, sorted by name
+func (r *MockSourceRepository) List(ctx context.Context, enabledOnly bool) ([]*models.WebhookSource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sources := make([]*models.WebhookSource, 0, len(r.sources))
+	for _, source := range r.sources {
+		if enabledOnly && !source.Enabled {
+			continue
+		}
+		sources = append(sources, copySource(source))
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Name < sources[j].Name
+	})
+	return sources, nil
+}
+
+// copySource copies a webhook source along with its secrets, like a source
+// read from the database
+func copySource(source *models.WebhookSource) *models.WebhookSource {
+	copied := *source
+	copied.Secrets = make(models.WebhookSecrets, len(source.Secrets))
+	for i, secret := range source.Secrets {
+		s := *secret
+		copied.Secrets[i] = &s
+	}
+	return &copied
+}
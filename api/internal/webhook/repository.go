@@ -2,24 +2,51 @@ package webhook
 
 import (
 	"context"
+	"time"
 
+	"github.com/dustinleblanc/go-bespin-api/internal/pagination"
 	"github.com/dustinleblanc/go-bespin-api/pkg/models"
 )
 
+// ReceiptFilter filters the receipts returned by List
+type ReceiptFilter struct {
+	Source        string
+	Event         string
+	Status        models.WebhookStatus
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Cursor returns receipts created before the receipt the cursor points at
+	Cursor *pagination.Cursor
+	Limit  int
+}
+
 // Repository defines the interface for webhook storage
 type Repository interface {
-	// Create creates a new webhook receipt
+	// Create creates a new webhook receipt and records its status in its
+	// history. It returns an error wrapping ErrDuplicateDelivery if the
+	// source's delivery ID or payload digest is taken.
 	Create(ctx context.Context, receipt *models.WebhookReceipt) error
 
 	// GetByID retrieves a webhook receipt by ID
 	GetByID(ctx context.Context, id string) (*models.WebhookReceipt, error)
 
-	// Update updates a webhook receipt
+	// GetByDelivery retrieves the webhook receipt of a source's delivery
+	GetByDelivery(ctx context.Context, source, deliveryID string) (*models.WebhookReceipt, error)
+
+	// GetByDigest retrieves the webhook receipt of a source's delivery by
+	// the digest of its payload
+	GetByDigest(ctx context.Context, source, digest string) (*models.WebhookReceipt, error)
+
+	// Update updates a webhook receipt and records its status in its history
 	Update(ctx context.Context, receipt *models.WebhookReceipt) error
 
-	// List retrieves a list of webhook receipts with optional filtering
-	List(ctx context.Context, source string, limit, offset int) ([]*models.WebhookReceipt, error)
+	// History retrieves the status changes of a webhook receipt, oldest first
+	History(ctx context.Context, receiptID string) ([]*models.WebhookStatusChange, error)
+
+	// List retrieves webhook receipts matching the filter, newest first
+	List(ctx context.Context, filter ReceiptFilter) ([]*models.WebhookReceipt, error)
 
-	// Count counts webhook receipts with optional filtering
-	Count(ctx context.Context, source string) (int64, error)
+	// CountByStatus counts the webhook receipts matching the filter by
+	// status, ignoring its cursor and limit
+	CountByStatus(ctx context.Context, filter ReceiptFilter) (map[models.WebhookStatus]int, error)
 }
@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	sendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// SendGridVerifier verifies signed SendGrid Event Webhook deliveries. They are
+// signed with ECDSA: the signature header holds the base64 encoded ASN.1
+// signature of the SHA-256 of the timestamp header followed by the payload.
+// Deliveries signed more than the tolerance ago or ahead are rejected. A
+// delivery is a JSON array of events, so its event is the name those events
+// share, or "mixed" if they differ, and its ID is derived from their
+// sg_event_id values.
+type SendGridVerifier struct {
+	key       *ecdsa.PublicKey
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+// NewSendGridVerifier creates a verifier for the verification key SendGrid
+// shows for a signed Event Webhook, either base64 encoded as shown or PEM
+// encoded. A tolerance of zero uses DefaultTimestampTolerance.
+func NewSendGridVerifier(publicKey string, tolerance time.Duration) (*SendGridVerifier, error) {
+	publicKey = strings.TrimSpace(publicKey)
+
+	var der []byte
+	if block, _ := pem.Decode([]byte(publicKey)); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode SendGrid public key: %w", err)
+		}
+		der = decoded
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SendGrid public key: %w", err)
+	}
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("SendGrid public key is not an ECDSA key")
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTimestampTolerance
+	}
+	return &SendGridVerifier{key: ecdsaKey, tolerance: tolerance, now: time.Now}, nil
+}
+
+// Verify checks the signature and timestamp headers. The timestamp must be
+// within the tolerance.
+func (v *SendGridVerifier) Verify(header http.Header, payload []byte) error {
+	signature := v.Signature(header)
+	if signature == "" {
+		return ErrMissingSignature
+	}
+	timestamp := header.Get(sendGridTimestampHeader)
+	if timestamp == "" {
+		return fmt.Errorf("%w: missing %s header", ErrInvalidSignature, sendGridTimestampHeader)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+	digest := sha256.Sum256(append([]byte(timestamp), payload...))
+	if !ecdsa.VerifyASN1(v.key, digest[:], decoded) {
+		return ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp", ErrInvalidSignature)
+	}
+	return checkTimestamp(time.Unix(unix, 0), v.now(), v.tolerance)
+}
+
+// Tolerance returns how far the signed timestamp may be from the current time
+func (v *SendGridVerifier) Tolerance() time.Duration {
+	return v.tolerance
+}
+
+// Signature returns the signature header
+func (v *SendGridVerifier) Signature(header http.Header) string {
+	return header.Get(sendGridSignatureHeader)
+}
+
+// Event returns the event shared by the events of the delivery
+func (v *SendGridVerifier) Event(header http.Header, payload []byte) string {
+	var events []struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(payload, &events); err != nil || len(events) == 0 {
+		return ""
+	}
+	for _, e := range events[1:] {
+		if e.Event != events[0].Event {
+			return "mixed"
+		}
+	}
+	return events[0].Event
+}
+
+// DeliveryID returns the sg_event_id of the event of a delivery, or the digest
+// of the sorted sg_event_id values of its events if it holds several. SendGrid
+// keeps these IDs when it retries a delivery, and as they are part of the
+// signed payload a replay cannot change them. Deliveries with an event without
+// an ID have none.
+func (v *SendGridVerifier) DeliveryID(header http.Header, payload []byte) string {
+	var events []struct {
+		ID string `json:"sg_event_id"`
+	}
+	if err := json.Unmarshal(payload, &events); err != nil || len(events) == 0 {
+		return ""
+	}
+
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		if e.ID == "" {
+			return ""
+		}
+		ids = append(ids, e.ID)
+	}
+	if len(ids) == 1 {
+		return ids[0]
+	}
+	sort.Strings(ids)
+	return payloadDigest([]byte(strings.Join(ids, "\n")))
+}
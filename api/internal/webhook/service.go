@@ -2,100 +2,202 @@ package webhook
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
+	"time"
 
+	"github.com/dustinleblanc/go-bespin-api/internal/pagination"
 	"github.com/dustinleblanc/go-bespin-api/pkg/models"
 )
 
+// DefaultReceiptLimit is the number of receipts returned per page when no
+// limit is given
+const DefaultReceiptLimit = 20
+
+// MaxReceiptLimit is the maximum number of receipts returned per page
+const MaxReceiptLimit = 100
+
 // Ensure Service implements WebhookService
 var _ WebhookService = (*Service)(nil)
 
+// ReceiptPage represents a page of webhook receipts. Total and Counts cover
+// every receipt matching the filter, not only those on the page.
+type ReceiptPage struct {
+	Receipts   []*models.WebhookReceipt     `json:"receipts"`
+	NextCursor string                       `json:"next_cursor,omitempty"`
+	Total      int                          `json:"total"`
+	Counts     map[models.WebhookStatus]int `json:"counts"`
+}
+
 // Service handles webhook operations
 type Service struct {
 	repo    Repository
+	sources *SourceRegistry
 	logger  *log.Logger
-	secrets map[string]string
 }
 
-// NewService creates a new webhook service
-func NewService(repo Repository) *Service {
-	// Load secrets from environment variables
-	secrets := make(map[string]string)
-
-	// Load webhook secrets from environment variables
-	if secret := os.Getenv("GITHUB_WEBHOOK_SECRET"); secret != "" {
-		secrets["github"] = secret
-	}
-	if secret := os.Getenv("STRIPE_WEBHOOK_SECRET"); secret != "" {
-		secrets["stripe"] = secret
-	}
-	if secret := os.Getenv("SENDGRID_WEBHOOK_SECRET"); secret != "" {
-		secrets["sendgrid"] = secret
-	}
-
+// NewService creates a new webhook service that accepts deliveries from the
+// enabled sources of the registry
+func NewService(repo Repository, sources *SourceRegistry) *Service {
 	return &Service{
 		repo:    repo,
+		sources: sources,
 		logger:  log.New(log.Writer(), "[WebhookService] ", log.LstdFlags),
-		secrets: secrets,
 	}
 }
 
-// VerifySignature verifies the webhook signature
-func (s *Service) VerifySignature(source string, payload []byte, signature string) bool {
-	secret, ok := s.secrets[source]
+// VerifySignature verifies the signature of a delivery from a source. Any
+// active secret of the source may match.
+func (s *Service) VerifySignature(source string, header http.Header, payload []byte) error {
+	active, ok := s.sources.lookup(context.Background(), source)
 	if !ok {
-		s.logger.Printf("No secret found for source: %s", source)
-		return false
+		return fmt.Errorf("%w: %s", ErrInvalidSource, source)
+	}
+	_, err := active.verify(header, payload, time.Now())
+	return err
+}
+
+// EventType determines the event of a delivery from a source. The event is
+// read from the source's event header if it has one, then the way the
+// source's provider sends it, with the X-Event-Type header as a fallback.
+func (s *Service) EventType(source string, header http.Header, payload []byte) string {
+	active, ok := s.sources.lookup(context.Background(), source)
+	if !ok {
+		return header.Get(EventHeader)
+	}
+	return eventType(active, header, payload)
+}
+
+// eventType determines the event of a delivery from an active source
+func eventType(active *activeSource, header http.Header, payload []byte) string {
+	if active.source.EventHeader != "" {
+		return header.Get(active.source.EventHeader)
+	}
+	if reader, ok := active.scheme().(EventReader); ok {
+		if event := reader.Event(header, payload); event != "" {
+			return event
+		}
 	}
+	return header.Get(EventHeader)
+}
 
-	// Create HMAC
-	mac := hmac.New(sha256.New, []byte(secret))
-	mac.Write(payload)
-	expectedMAC := mac.Sum(nil)
-	expectedSignature := hex.EncodeToString(expectedMAC)
+// deliveryID determines the ID of a delivery from an active source: the way
+// the source's provider sends it, with the Idempotency-Key header as a
+// fallback
+func deliveryID(active *activeSource, header http.Header, payload []byte) string {
+	if reader, ok := active.scheme().(DeliveryReader); ok {
+		if id := reader.DeliveryID(header, payload); id != "" {
+			return id
+		}
+	}
+	return header.Get(DeliveryHeader)
+}
 
-	return hmac.Equal([]byte(signature), []byte(expectedSignature))
+// signsDeliveryID checks whether the signature of a delivery from an active
+// source covers both a timestamp and the delivery's ID. Replays of other
+// deliveries could carry a fresh ID, so they are also recognized by the digest
+// of their payload.
+func signsDeliveryID(active *activeSource, header http.Header, payload []byte) bool {
+	if _, ok := active.scheme().(TimestampVerifier); !ok {
+		return false
+	}
+	reader, ok := active.scheme().(DeliveryReader)
+	return ok && reader.DeliveryID(header, payload) != ""
 }
 
-// CreateReceipt creates a new webhook receipt
-func (s *Service) CreateReceipt(ctx context.Context, source, event string, payload []byte, signature string) (*models.WebhookReceipt, error) {
-	if !s.IsValidSource(source) {
-		return nil, fmt.Errorf("invalid source: %s", source)
+// CreateReceipt verifies a delivery sent from remoteIP by a source and
+// stores it as a new webhook receipt along with the ID of the secret its
+// signature matched. If the source delivered it before, the existing receipt
+// is returned with an error wrapping ErrDuplicateDelivery. Deliveries are
+// recognized by their delivery ID and, unless their signature covers both a
+// timestamp and the delivery ID, by the digest of their payload.
+func (s *Service) CreateReceipt(ctx context.Context, source string, header http.Header, payload []byte, remoteIP string) (*models.WebhookReceipt, error) {
+	active, ok := s.sources.lookup(ctx, source)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSource, source)
+	}
+
+	if !active.allows(remoteIP) {
+		s.logger.Printf("Rejected webhook from %s sent from %s", source, remoteIP)
+		return nil, fmt.Errorf("%w: %s", ErrIPNotAllowed, remoteIP)
 	}
 
+	event := eventType(active, header, payload)
 	if event == "" {
-		return nil, fmt.Errorf("event is required")
+		return nil, ErrMissingEvent
 	}
 
 	if len(payload) == 0 {
 		return nil, fmt.Errorf("payload is required")
 	}
 
-	if signature == "" {
-		return nil, fmt.Errorf("signature is required")
+	// Verify signature
+	secret, err := active.verify(header, payload, time.Now())
+	if err != nil {
+		if !errors.Is(err, ErrMissingSignature) {
+			s.logger.Printf("Rejected %s webhook from %s: %v", event, source, err)
+		}
+		return nil, err
 	}
 
-	// Verify signature
-	if !s.VerifySignature(source, payload, signature) {
-		return nil, fmt.Errorf("invalid signature")
+	// Create receipt, recording which secret the signature matched
+	receipt := models.NewWebhookReceipt(source, event, payload, active.scheme().Signature(header))
+	receipt.SecretID = secret.ID
+	receipt.DeliveryID = deliveryID(active, header, payload)
+	if !signsDeliveryID(active, header, payload) {
+		receipt.PayloadDigest = payloadDigest(payload)
 	}
+	receipt.SetHeaders(header)
 
-	// Create receipt
-	receipt := models.NewWebhookReceipt(source, event, payload, signature)
+	// Return the existing receipt of a redelivery
+	if existing, err := s.duplicateReceipt(ctx, receipt); existing != nil || err != nil {
+		return existing, err
+	}
 
-	// Save receipt
+	// Save receipt. A concurrent redelivery may have been saved since.
 	if err := s.repo.Create(ctx, receipt); err != nil {
+		if errors.Is(err, ErrDuplicateDelivery) {
+			if existing, err := s.duplicateReceipt(ctx, receipt); existing != nil || err != nil {
+				return existing, err
+			}
+		}
 		return nil, fmt.Errorf("failed to save receipt: %w", err)
 	}
 
 	return receipt, nil
 }
 
+// duplicateReceipt returns the receipt of an earlier delivery from the same
+// source with the same delivery ID or payload digest along with an error
+// wrapping ErrDuplicateDelivery, or nil if there is none
+func (s *Service) duplicateReceipt(ctx context.Context, receipt *models.WebhookReceipt) (*models.WebhookReceipt, error) {
+	lookups := []struct {
+		key    string
+		lookup func(ctx context.Context, source, key string) (*models.WebhookReceipt, error)
+	}{
+		{receipt.DeliveryID, s.repo.GetByDelivery},
+		{receipt.PayloadDigest, s.repo.GetByDigest},
+	}
+	for _, l := range lookups {
+		if l.key == "" {
+			continue
+		}
+		existing, err := l.lookup(ctx, receipt.Source, l.key)
+		if errors.Is(err, ErrReceiptNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for duplicate delivery: %w", err)
+		}
+
+		s.logger.Printf("Received duplicate %s delivery %s, receipt %s", receipt.Source, l.key, existing.ID)
+		return existing, fmt.Errorf("%w: %s", ErrDuplicateDelivery, l.key)
+	}
+	return nil, nil
+}
+
 // GetReceipt gets a webhook receipt by ID
 func (s *Service) GetReceipt(ctx context.Context, id string) (*models.WebhookReceipt, error) {
 	if id == "" {
@@ -123,36 +225,77 @@ func (s *Service) UpdateReceipt(ctx context.Context, receipt *models.WebhookRece
 	return nil
 }
 
-// ListReceipts lists webhook receipts for a source
-func (s *Service) ListReceipts(ctx context.Context, source string, limit, offset int) ([]*models.WebhookReceipt, error) {
-	if source != "" && !s.IsValidSource(source) {
-		return nil, fmt.Errorf("invalid source: %s", source)
+// ListReceipts lists the webhook receipts matching the filter, newest first,
+// along with the number of matching receipts by status. Receipts of disabled
+// and deleted sources are included.
+func (s *Service) ListReceipts(ctx context.Context, filter ReceiptFilter) (*ReceiptPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultReceiptLimit
+	}
+	if filter.Limit > MaxReceiptLimit {
+		filter.Limit = MaxReceiptLimit
 	}
 
-	receipts, err := s.repo.List(ctx, source, limit, offset)
+	// Fetch one extra receipt to find out whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+
+	receipts, err := s.repo.List(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list receipts: %w", err)
 	}
 
-	return receipts, nil
+	page := &ReceiptPage{Receipts: receipts}
+	if len(receipts) > limit {
+		page.Receipts = receipts[:limit]
+		last := page.Receipts[limit-1]
+		page.NextCursor = (&pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}).Encode()
+	}
+
+	page.Counts, err = s.CountReceipts(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range page.Counts {
+		page.Total += count
+	}
+
+	return page, nil
+}
+
+// CountReceipts counts the webhook receipts matching the filter by status,
+// ignoring its cursor and limit
+func (s *Service) CountReceipts(ctx context.Context, filter ReceiptFilter) (map[models.WebhookStatus]int, error) {
+	counts, err := s.repo.CountByStatus(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count receipts: %w", err)
+	}
+	return counts, nil
 }
 
-// CountReceipts counts webhook receipts for a source
-func (s *Service) CountReceipts(ctx context.Context, source string) (int64, error) {
-	if source != "" && !s.IsValidSource(source) {
-		return 0, fmt.Errorf("invalid source: %s", source)
+// GetReceiptDetail gets a webhook receipt with its headers, its decoded
+// payload and its processing history
+func (s *Service) GetReceiptDetail(ctx context.Context, id string) (*models.WebhookReceiptDetail, error) {
+	receipt, err := s.GetReceipt(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	count, err := s.repo.Count(ctx, source)
+	history, err := s.repo.History(ctx, id)
 	if err != nil {
-		return 0, fmt.Errorf("failed to count receipts: %w", err)
+		return nil, fmt.Errorf("failed to get receipt history: %w", err)
 	}
 
-	return count, nil
+	return &models.WebhookReceiptDetail{
+		WebhookReceipt: receipt,
+		Headers:        receipt.HeaderMap(),
+		Payload:        receipt.DecodedPayload(),
+		History:        history,
+	}, nil
 }
 
-// IsValidSource checks if a source is valid
+// IsValidSource checks if a source is enabled
 func (s *Service) IsValidSource(source string) bool {
-	_, ok := s.secrets[source]
+	_, ok := s.sources.lookup(context.Background(), source)
 	return ok
 }
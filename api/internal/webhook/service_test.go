@@ -2,13 +2,24 @@ package webhook
 
 import (
 	"context"
-	"crypto/hmac"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
-	"encoding/hex"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"os"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/dustinleblanc/go-bespin-api/internal/pagination"
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
@@ -17,15 +28,16 @@ func init() {
 
 func TestServiceWithMockRepository(t *testing.T) {
 	repo := NewMockRepository()
-	service := NewService(repo)
+	github := models.NewWebhookSource("github", models.WebhookVerifierGitHub, []string{os.Getenv("GITHUB_WEBHOOK_SECRET")})
+	service := NewService(repo, NewSourceRegistry(NewMockSourceRepository(github), 0))
 	ctx := context.Background()
 
-	// Helper function to generate valid signature
-	generateSignature := func(payload []byte) string {
-		secret := os.Getenv("GITHUB_WEBHOOK_SECRET")
-		h := hmac.New(sha256.New, []byte(secret))
-		h.Write(payload)
-		return hex.EncodeToString(h.Sum(nil))
+	// Helper function to generate the headers of a signed GitHub delivery
+	githubHeader := func(event string, payload []byte) http.Header {
+		header := http.Header{}
+		header.Set("X-GitHub-Event", event)
+		header.Set("X-Hub-Signature-256", "sha256="+hexHMAC([]byte(os.Getenv("GITHUB_WEBHOOK_SECRET")), payload))
+		return header
 	}
 
 	t.Run("CreateReceipt", func(t *testing.T) {
@@ -34,7 +46,6 @@ func TestServiceWithMockRepository(t *testing.T) {
 			source     string
 			event      string
 			payload    []byte
-			signature  string
 			wantErr    bool
 			errMessage string
 		}{
@@ -65,13 +76,8 @@ func TestServiceWithMockRepository(t *testing.T) {
 
 		for _, tc := range testCases {
 			t.Run(tc.name, func(t *testing.T) {
-				// Generate signature for valid cases
-				signature := ""
-				if tc.source == "github" && tc.event != "" {
-					signature = generateSignature(tc.payload)
-				}
-
-				receipt, err := service.CreateReceipt(ctx, tc.source, tc.event, tc.payload, signature)
+				header := githubHeader(tc.event, tc.payload)
+				receipt, err := service.CreateReceipt(ctx, tc.source, header, tc.payload, "")
 
 				if tc.wantErr {
 					assert.Error(t, err)
@@ -85,7 +91,7 @@ func TestServiceWithMockRepository(t *testing.T) {
 					assert.Equal(t, tc.source, receipt.Source)
 					assert.Equal(t, tc.event, receipt.Event)
 					assert.Equal(t, tc.payload, receipt.Payload)
-					assert.Equal(t, signature, receipt.Signature)
+					assert.Equal(t, header.Get("X-Hub-Signature-256"), receipt.Signature)
 				}
 			})
 		}
@@ -93,9 +99,8 @@ func TestServiceWithMockRepository(t *testing.T) {
 
 	t.Run("GetReceipt", func(t *testing.T) {
 		// Create a test receipt first
-		payload := []byte(`{"test": "data"}`)
-		signature := generateSignature(payload)
-		receipt, err := service.CreateReceipt(ctx, "github", "push", payload, signature)
+		payload := []byte(`{"test": "get"}`)
+		receipt, err := service.CreateReceipt(ctx, "github", githubHeader("push", payload), payload, "")
 		assert.NoError(t, err)
 
 		// Test getting the receipt
@@ -112,33 +117,237 @@ func TestServiceWithMockRepository(t *testing.T) {
 
 	t.Run("ListReceipts", func(t *testing.T) {
 		// Create test receipts
-		payload := []byte(`{"test": "data"}`)
-		signature := generateSignature(payload)
-		_, err := service.CreateReceipt(ctx, "github", "push", payload, signature)
+		push := []byte(`{"test": "push"}`)
+		_, err := service.CreateReceipt(ctx, "github", githubHeader("push", push), push, "")
 		assert.NoError(t, err)
-		_, err = service.CreateReceipt(ctx, "github", "pull_request", payload, signature)
+		pullRequest := []byte(`{"test": "pull_request"}`)
+		_, err = service.CreateReceipt(ctx, "github", githubHeader("pull_request", pullRequest), pullRequest, "")
 		assert.NoError(t, err)
 
 		// Test listing all receipts
-		receipts, err := service.ListReceipts(ctx, "github", 10, 0)
+		page, err := service.ListReceipts(ctx, ReceiptFilter{Source: "github"})
 		assert.NoError(t, err)
-		assert.NotEmpty(t, receipts)
+		assert.NotEmpty(t, page.Receipts)
+		assert.Equal(t, len(page.Receipts), page.Total)
 
-		// Test listing with invalid source
-		receipts, err = service.ListReceipts(ctx, "invalid", 10, 0)
-		assert.Error(t, err)
-		assert.Empty(t, receipts)
+		// Test listing receipts of an unknown source
+		page, err = service.ListReceipts(ctx, ReceiptFilter{Source: "invalid"})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Receipts)
+		assert.Zero(t, page.Total)
 	})
 
 	t.Run("CountReceipts", func(t *testing.T) {
 		// Test counting receipts for valid source
-		count, err := service.CountReceipts(ctx, "github")
+		counts, err := service.CountReceipts(ctx, ReceiptFilter{Source: "github"})
 		assert.NoError(t, err)
-		assert.Greater(t, count, int64(0))
+		assert.Greater(t, counts[models.WebhookStatusPending], 0)
 
-		// Test counting receipts for invalid source
-		count, err = service.CountReceipts(ctx, "invalid")
-		assert.Error(t, err)
-		assert.Equal(t, int64(0), count)
+		// Test counting receipts for unknown source
+		counts, err = service.CountReceipts(ctx, ReceiptFilter{Source: "invalid"})
+		assert.NoError(t, err)
+		assert.Empty(t, counts)
+	})
+}
+
+func TestDuplicateDeliveries(t *testing.T) {
+	repo := NewMockRepository()
+	github := models.NewWebhookSource("github", models.WebhookVerifierGitHub, []string{"github-secret"})
+	test := models.NewWebhookSource("test", models.WebhookVerifierHMAC, []string{"test-secret"})
+	service := NewService(repo, NewSourceRegistry(NewMockSourceRepository(github, test), 0))
+	ctx := context.Background()
+	payload := []byte(`{"ref":"refs/heads/main"}`)
+
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	header.Set("X-GitHub-Delivery", "delivery-1")
+	header.Set("X-Hub-Signature-256", "sha256="+hexHMAC([]byte("github-secret"), payload))
+
+	receipt, err := service.CreateReceipt(ctx, "github", header, payload, "")
+	require.NoError(t, err)
+	assert.Equal(t, "delivery-1", receipt.DeliveryID)
+
+	// A redelivery returns the original receipt
+	duplicate, err := service.CreateReceipt(ctx, "github", header, payload, "")
+	assert.ErrorIs(t, err, ErrDuplicateDelivery)
+	require.NotNil(t, duplicate)
+	assert.Equal(t, receipt.ID, duplicate.ID)
+
+	// GitHub does not sign its delivery ID or a timestamp, so a replay with
+	// a new delivery ID is recognized by its payload
+	header.Set("X-GitHub-Delivery", "delivery-2")
+	duplicate, err = service.CreateReceipt(ctx, "github", header, payload, "")
+	assert.ErrorIs(t, err, ErrDuplicateDelivery)
+	require.NotNil(t, duplicate)
+	assert.Equal(t, receipt.ID, duplicate.ID)
+
+	// Delivery IDs are unique per source, and sources without a provider
+	// delivery ID fall back to the Idempotency-Key header
+	header = http.Header{}
+	header.Set(EventHeader, "ping")
+	header.Set(DeliveryHeader, "delivery-1")
+	header.Set("X-Signature", hexHMAC([]byte("test-secret"), payload))
+	other, err := service.CreateReceipt(ctx, "test", header, payload, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, receipt.ID, other.ID)
+	duplicate, err = service.CreateReceipt(ctx, "test", header, payload, "")
+	assert.ErrorIs(t, err, ErrDuplicateDelivery)
+	assert.Equal(t, other.ID, duplicate.ID)
+
+	// HMAC signatures do not cover a timestamp either, so a replay without
+	// or with a changed Idempotency-Key is recognized by its payload
+	for _, key := range []string{"", "delivery-2"} {
+		header.Set(DeliveryHeader, key)
+		duplicate, err = service.CreateReceipt(ctx, "test", header, payload, "")
+		assert.ErrorIs(t, err, ErrDuplicateDelivery)
+		assert.Equal(t, other.ID, duplicate.ID)
+	}
+
+	// Deliveries with a different payload are new
+	header.Del(DeliveryHeader)
+	next := []byte(`{"ref":"refs/heads/next"}`)
+	header.Set("X-Signature", hexHMAC([]byte("test-secret"), next))
+	_, err = service.CreateReceipt(ctx, "test", header, next, "")
+	require.NoError(t, err)
+
+	counts, err := repo.CountByStatus(ctx, ReceiptFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, counts[models.WebhookStatusPending])
+
+	// The repository rejects concurrent redeliveries that pass the check
+	concurrent := models.NewWebhookReceipt("github", "push", payload, "")
+	concurrent.DeliveryID = "delivery-1"
+	assert.ErrorIs(t, repo.Create(ctx, concurrent), ErrDuplicateDelivery)
+	concurrent.DeliveryID = "delivery-3"
+	concurrent.PayloadDigest = payloadDigest(payload)
+	assert.ErrorIs(t, repo.Create(ctx, concurrent), ErrDuplicateDelivery)
+}
+
+func TestSignedDeliveryIDs(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	sendgrid := models.NewWebhookSource("sendgrid", models.WebhookVerifierSendGrid, []string{base64.StdEncoding.EncodeToString(der)})
+	service := NewService(NewMockRepository(), NewSourceRegistry(NewMockSourceRepository(sendgrid), 0))
+	ctx := context.Background()
+
+	signed := func(payload []byte, idempotencyKey string) http.Header {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		digest := sha256.Sum256(append([]byte(timestamp), payload...))
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+
+		header := http.Header{}
+		header.Set("X-Twilio-Email-Event-Webhook-Timestamp", timestamp)
+		header.Set("X-Twilio-Email-Event-Webhook-Signature", base64.StdEncoding.EncodeToString(signature))
+		header.Set(DeliveryHeader, idempotencyKey)
+		return header
+	}
+
+	// SendGrid signs the event IDs its delivery ID is derived from, so a
+	// replay within the tolerance is recognized by it whatever its
+	// Idempotency-Key
+	payload := []byte(`[{"event":"open","sg_event_id":"ev-1"}]`)
+	receipt, err := service.CreateReceipt(ctx, "sendgrid", signed(payload, "key-1"), payload, "")
+	require.NoError(t, err)
+	assert.Equal(t, "ev-1", receipt.DeliveryID)
+	assert.Empty(t, receipt.PayloadDigest)
+
+	duplicate, err := service.CreateReceipt(ctx, "sendgrid", signed(payload, "key-2"), payload, "")
+	assert.ErrorIs(t, err, ErrDuplicateDelivery)
+	require.NotNil(t, duplicate)
+	assert.Equal(t, receipt.ID, duplicate.ID)
+
+	// Deliveries without signed event IDs fall back to the Idempotency-Key,
+	// which is not signed, so they are recognized by their payload as well
+	payload = []byte(`[{"event":"open"}]`)
+	receipt, err = service.CreateReceipt(ctx, "sendgrid", signed(payload, "key-3"), payload, "")
+	require.NoError(t, err)
+	assert.Equal(t, "key-3", receipt.DeliveryID)
+	assert.Equal(t, payloadDigest(payload), receipt.PayloadDigest)
+
+	duplicate, err = service.CreateReceipt(ctx, "sendgrid", signed(payload, "key-4"), payload, "")
+	assert.ErrorIs(t, err, ErrDuplicateDelivery)
+	require.NotNil(t, duplicate)
+	assert.Equal(t, receipt.ID, duplicate.ID)
+}
+
+func TestReceiptQueries(t *testing.T) {
+	test := models.NewWebhookSource("test", models.WebhookVerifierHMAC, []string{"test-secret"})
+	service := NewService(NewMockRepository(), NewSourceRegistry(NewMockSourceRepository(test), 0))
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+
+	// Five receipts a minute apart, the oldest first
+	var receipts []*models.WebhookReceipt
+	for i, event := range []string{"ping", "push", "push", "push", "ping"} {
+		payload := []byte(fmt.Sprintf(`{"n":%d}`, i))
+		header := http.Header{}
+		header.Set(EventHeader, event)
+		header.Set("X-Signature", hexHMAC([]byte("test-secret"), payload))
+		header.Set("Authorization", "Bearer token")
+		receipt, err := service.CreateReceipt(ctx, "test", header, payload, "")
+		require.NoError(t, err)
+		receipt.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		receipts = append(receipts, receipt)
+	}
+	receipts[1].SetStatus(models.WebhookStatusProcessing, nil)
+	require.NoError(t, service.UpdateReceipt(ctx, receipts[1]))
+	receipts[1].SetStatus(models.WebhookStatusFailed, fmt.Errorf("downstream unavailable"))
+	require.NoError(t, service.UpdateReceipt(ctx, receipts[1]))
+
+	t.Run("cursor pagination", func(t *testing.T) {
+		page, err := service.ListReceipts(ctx, ReceiptFilter{Event: "push", Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page.Receipts, 2)
+		assert.Equal(t, receipts[3].ID, page.Receipts[0].ID)
+		assert.Equal(t, receipts[2].ID, page.Receipts[1].ID)
+		assert.Equal(t, 3, page.Total)
+		assert.Equal(t, map[models.WebhookStatus]int{models.WebhookStatusPending: 2, models.WebhookStatusFailed: 1}, page.Counts)
+		require.NotEmpty(t, page.NextCursor)
+
+		cursor, err := pagination.DecodeCursor(page.NextCursor)
+		require.NoError(t, err)
+		page, err = service.ListReceipts(ctx, ReceiptFilter{Event: "push", Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		require.Len(t, page.Receipts, 1)
+		assert.Equal(t, receipts[1].ID, page.Receipts[0].ID)
+		assert.Empty(t, page.NextCursor)
+		assert.Equal(t, 3, page.Total, "totals ignore the cursor")
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		after, before := start.Add(time.Minute), start.Add(4*time.Minute)
+		page, err := service.ListReceipts(ctx, ReceiptFilter{CreatedAfter: &after, CreatedBefore: &before})
+		require.NoError(t, err)
+		assert.Len(t, page.Receipts, 3)
+
+		page, err = service.ListReceipts(ctx, ReceiptFilter{Status: models.WebhookStatusFailed})
+		require.NoError(t, err)
+		require.Len(t, page.Receipts, 1)
+		assert.Equal(t, receipts[1].ID, page.Receipts[0].ID)
+
+		page, err = service.ListReceipts(ctx, ReceiptFilter{Source: "github"})
+		require.NoError(t, err)
+		assert.Empty(t, page.Receipts)
+	})
+
+	t.Run("detail", func(t *testing.T) {
+		detail, err := service.GetReceiptDetail(ctx, receipts[1].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "push", detail.Headers.Get(EventHeader))
+		assert.Empty(t, detail.Headers.Get("Authorization"), "credentials are not stored")
+		assert.Equal(t, json.RawMessage(`{"n":1}`), detail.Payload)
+
+		require.Len(t, detail.History, 3)
+		assert.Equal(t, models.WebhookStatusPending, detail.History[0].Status)
+		assert.Equal(t, models.WebhookStatusProcessing, detail.History[1].Status)
+		assert.Equal(t, models.WebhookStatusFailed, detail.History[2].Status)
+		assert.Equal(t, "downstream unavailable", detail.History[2].Error)
+
+		_, err = service.GetReceiptDetail(ctx, "missing")
+		assert.ErrorIs(t, err, ErrReceiptNotFound)
 	})
 }
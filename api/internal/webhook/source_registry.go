@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// DefaultSourceRefreshInterval is how long the source registry serves
+// webhook sources from its cache before reloading them
+const DefaultSourceRefreshInterval = 30 * time.Second
+
+// sourceSecret is a secret of a source with the verifier checking it
+type sourceSecret struct {
+	secret   *models.WebhookSecret
+	verifier SignatureVerifier
+}
+
+// activeSource is an enabled webhook source ready to verify deliveries
+type activeSource struct {
+	source *models.WebhookSource
+	// secrets holds the primary secret first
+	secrets  []sourceSecret
+	networks []*net.IPNet
+}
+
+// verify checks a delivery against the secrets that are active at now, the
+// primary one first, and returns the secret that matched. If none matches,
+// the error of the first active secret is returned.
+func (a *activeSource) verify(header http.Header, payload []byte, now time.Time) (*models.WebhookSecret, error) {
+	var first error
+	for _, s := range a.secrets {
+		if !s.secret.IsActive(now) {
+			continue
+		}
+		err := s.verifier.Verify(header, payload)
+		if err == nil {
+			return s.secret, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("%w: every secret of the source has expired", ErrInvalidSignature)
+	}
+	return nil, first
+}
+
+// scheme returns a verifier of the source's signature scheme, to read the
+// signatures and events of deliveries
+func (a *activeSource) scheme() SignatureVerifier {
+	return a.secrets[0].verifier
+}
+
+// allows checks if a delivery from ip is accepted
+func (a *activeSource) allows(ip string) bool {
+	if len(a.networks) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range a.networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceRegistry caches the enabled webhook sources stored in the database.
+// The cache is reloaded once it is older than the refresh interval, so
+// sources changed by another API instance take effect without a restart.
+type SourceRegistry struct {
+	repo     SourceRepository
+	interval time.Duration
+	logger   *log.Logger
+
+	mu       sync.RWMutex
+	sources  map[string]*activeSource
+	loadedAt time.Time
+}
+
+// NewSourceRegistry creates a registry of the sources stored in repo that is
+// reloaded every interval, DefaultSourceRefreshInterval if zero
+func NewSourceRegistry(repo SourceRepository, interval time.Duration) *SourceRegistry {
+	if interval <= 0 {
+		interval = DefaultSourceRefreshInterval
+	}
+	return &SourceRegistry{
+		repo:     repo,
+		interval: interval,
+		logger:   log.New(log.Writer(), "[WebhookSources] ", log.LstdFlags),
+	}
+}
+
+// Refresh reloads the enabled sources. Sources that cannot be loaded, for
+// example because a verification key no longer parses, are left out.
+func (r *SourceRegistry) Refresh(ctx context.Context) error {
+	sources, err := r.repo.List(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to load webhook sources: %w", err)
+	}
+
+	active := make(map[string]*activeSource, len(sources))
+	for _, source := range sources {
+		a, err := newActiveSource(source)
+		if err != nil {
+			r.logger.Printf("Skipping webhook source %s: %v", source.Name, err)
+			continue
+		}
+		active[source.Name] = a
+	}
+
+	r.mu.Lock()
+	r.sources = active
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// Invalidate makes the next lookup reload the sources
+func (r *SourceRegistry) Invalidate() {
+	r.mu.Lock()
+	r.loadedAt = time.Time{}
+	r.mu.Unlock()
+}
+
+// lookup gets an enabled source by name, reloading the sources first if the
+// cache is stale. If they cannot be reloaded the stale cache is used.
+func (r *SourceRegistry) lookup(ctx context.Context, name string) (*activeSource, bool) {
+	r.mu.RLock()
+	stale := time.Since(r.loadedAt) > r.interval
+	r.mu.RUnlock()
+
+	if stale {
+		if err := r.Refresh(ctx); err != nil {
+			r.logger.Printf("Using cached webhook sources: %v", err)
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	source, ok := r.sources[name]
+	return source, ok
+}
+
+// newActiveSource creates the verifiers and allowed networks of a source
+func newActiveSource(source *models.WebhookSource) (*activeSource, error) {
+	secrets, err := newSourceSecrets(source)
+	if err != nil {
+		return nil, err
+	}
+	networks, err := parseNetworks(source.AllowedIPList())
+	if err != nil {
+		return nil, err
+	}
+	return &activeSource{source: source, secrets: secrets, networks: networks}, nil
+}
+
+// newSourceSecrets creates a verifier for every secret of a source, the
+// primary secret first
+func newSourceSecrets(source *models.WebhookSource) ([]sourceSecret, error) {
+	if len(source.Secrets) == 0 {
+		return nil, fmt.Errorf("%w: at least one secret is required", ErrInvalidSourceConfig)
+	}
+
+	var secrets []sourceSecret
+	for _, secret := range source.Secrets {
+		if secret.Value == "" {
+			return nil, fmt.Errorf("%w: secrets cannot be empty", ErrInvalidSourceConfig)
+		}
+		verifier, err := newVerifier(source.VerifierType, secret.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		if secret.Primary {
+			if len(secrets) > 0 && secrets[0].secret.Primary {
+				return nil, fmt.Errorf("%w: only one secret can be the primary secret", ErrInvalidSourceConfig)
+			}
+			secrets = append([]sourceSecret{{secret: secret, verifier: verifier}}, secrets...)
+		} else {
+			secrets = append(secrets, sourceSecret{secret: secret, verifier: verifier})
+		}
+	}
+
+	if !secrets[0].secret.Primary {
+		return nil, fmt.Errorf("%w: a primary secret is required", ErrInvalidSourceConfig)
+	}
+	return secrets, nil
+}
+
+// newVerifier creates a verifier of a signature scheme for a secret
+func newVerifier(verifierType models.WebhookVerifierType, secret string) (SignatureVerifier, error) {
+	switch verifierType {
+	case models.WebhookVerifierHMAC:
+		return NewHMACVerifier("", secret), nil
+	case models.WebhookVerifierGitHub:
+		return NewGitHubVerifier(secret), nil
+	case models.WebhookVerifierStripe:
+		return NewStripeVerifier(secret, DefaultTimestampTolerance), nil
+	case models.WebhookVerifierSendGrid:
+		verifier, err := NewSendGridVerifier(secret, DefaultTimestampTolerance)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSourceConfig, err)
+		}
+		return verifier, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown verifier type %q", ErrInvalidSourceConfig, verifierType)
+	}
+}
+
+// parseNetworks parses IP addresses and CIDR ranges
+func parseNetworks(ips []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(ips))
+	for _, ip := range ips {
+		if !strings.Contains(ip, "/") {
+			parsed := net.ParseIP(ip)
+			if parsed == nil {
+				return nil, fmt.Errorf("%w: invalid IP address %q", ErrInvalidSourceConfig, ip)
+			}
+			bits := 8 * net.IPv6len
+			if parsed.To4() != nil {
+				parsed, bits = parsed.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: parsed, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(ip)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid CIDR range %q", ErrInvalidSourceConfig, ip)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,25 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// SourceRepository defines the interface for webhook source storage
+type SourceRepository interface {
+	// Create creates a new webhook source
+	Create(ctx context.Context, source *models.WebhookSource) error
+
+	// GetByName retrieves a webhook source by name
+	GetByName(ctx context.Context, name string) (*models.WebhookSource, error)
+
+	// Update updates a webhook source
+	Update(ctx context.Context, source *models.WebhookSource) error
+
+	// Delete deletes a webhook source by name
+	Delete(ctx context.Context, name string) error
+
+	// List retrieves all webhook sources, optionally only the enabled ones
+	List(ctx context.Context, enabledOnly bool) ([]*models.WebhookSource, error)
+}
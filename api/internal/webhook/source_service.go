@@ -0,0 +1,281 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// sourceName matches the names of webhook sources, which are path segments
+// of POST /api/webhooks/:source
+var sourceName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// CreateSource validates and stores a new webhook source. Sources are enabled
+// unless the request says otherwise.
+func (s *Service) CreateSource(ctx context.Context, req *models.WebhookSourceRequest) (*models.WebhookSource, error) {
+	if !sourceName.MatchString(req.Name) {
+		return nil, fmt.Errorf("%w: name must consist of lowercase letters, digits, - and _", ErrInvalidSourceConfig)
+	}
+
+	source := models.NewWebhookSource(req.Name, req.VerifierType, req.Secrets)
+	if req.EventHeader != nil {
+		source.EventHeader = *req.EventHeader
+	}
+	if req.Enabled != nil {
+		source.Enabled = *req.Enabled
+	}
+	source.SetAllowedIPs(req.AllowedIPs)
+
+	if _, err := newActiveSource(source); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.sources.repo.GetByName(ctx, source.Name); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateSource, source.Name)
+	} else if !errors.Is(err, ErrSourceNotFound) {
+		return nil, fmt.Errorf("failed to check webhook source name: %w", err)
+	}
+
+	if err := s.sources.repo.Create(ctx, source); err != nil {
+		return nil, fmt.Errorf("failed to create webhook source: %w", err)
+	}
+	s.sources.Invalidate()
+
+	s.logger.Printf("Created webhook source %s (%s)", source.Name, source.VerifierType)
+	return source, nil
+}
+
+// GetSource gets a webhook source by name
+func (s *Service) GetSource(ctx context.Context, name string) (*models.WebhookSource, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	source, err := s.sources.repo.GetByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook source: %w", err)
+	}
+	return source, nil
+}
+
+// ListSources lists all webhook sources
+func (s *Service) ListSources(ctx context.Context) ([]*models.WebhookSource, error) {
+	sources, err := s.sources.repo.List(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook sources: %w", err)
+	}
+	return sources, nil
+}
+
+// UpdateSource updates the fields set in the request and validates the
+// result. The name of a source cannot be changed.
+func (s *Service) UpdateSource(ctx context.Context, name string, req *models.WebhookSourceRequest) (*models.WebhookSource, error) {
+	source, err := s.GetSource(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" && req.Name != source.Name {
+		return nil, fmt.Errorf("%w: name cannot be changed", ErrInvalidSourceConfig)
+	}
+	if req.VerifierType != "" {
+		source.VerifierType = req.VerifierType
+	}
+	if req.Secrets != nil {
+		source.SetSecrets(req.Secrets)
+	}
+	if req.EventHeader != nil {
+		source.EventHeader = *req.EventHeader
+	}
+	if req.Enabled != nil {
+		source.Enabled = *req.Enabled
+	}
+	if req.AllowedIPs != nil {
+		source.SetAllowedIPs(req.AllowedIPs)
+	}
+
+	if err := s.saveSource(ctx, source); err != nil {
+		return nil, err
+	}
+
+	s.logger.Printf("Updated webhook source %s", source.Name)
+	return source, nil
+}
+
+// AddSecret adds a secret to a webhook source. A secondary secret is accepted
+// until it expires; a primary secret replaces the current primary secret,
+// which becomes a secondary one expiring after req.ExpirePreviousAfter.
+func (s *Service) AddSecret(ctx context.Context, name string, req *models.WebhookSecretRequest) (*models.WebhookSource, error) {
+	source, err := s.GetSource(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Value == "" {
+		return nil, fmt.Errorf("%w: value is required", ErrInvalidSourceConfig)
+	}
+	if req.ExpiresAt != nil && !req.Primary && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidSourceConfig)
+	}
+
+	secret := source.Secrets.Add(req.Value, req.ExpiresAt)
+	if req.Primary {
+		previousExpiresAt, err := expireAfter(req.ExpirePreviousAfter)
+		if err != nil {
+			return nil, err
+		}
+		source.Secrets.Promote(secret.ID, previousExpiresAt)
+	}
+
+	if err := s.saveSource(ctx, source); err != nil {
+		return nil, err
+	}
+
+	s.logger.Printf("Added secret %s to webhook source %s (primary: %v)", secret.ID, source.Name, secret.Primary)
+	return source, nil
+}
+
+// PromoteSecret makes a secret of a webhook source the primary secret. The
+// previous primary secret becomes a secondary one expiring after
+// req.ExpirePreviousAfter, or kept until retired if it is empty.
+func (s *Service) PromoteSecret(ctx context.Context, name, id string, req *models.WebhookSecretRequest) (*models.WebhookSource, error) {
+	source, err := s.GetSource(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, ok := source.Secrets.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, id)
+	}
+	if !secret.IsActive(time.Now()) {
+		return nil, fmt.Errorf("%w: secret %s has expired", ErrInvalidSourceConfig, id)
+	}
+
+	previousExpiresAt, err := expireAfter(req.ExpirePreviousAfter)
+	if err != nil {
+		return nil, err
+	}
+	source.Secrets.Promote(id, previousExpiresAt)
+
+	if err := s.saveSource(ctx, source); err != nil {
+		return nil, err
+	}
+
+	s.logger.Printf("Promoted secret %s of webhook source %s", id, source.Name)
+	return source, nil
+}
+
+// RetireSecret removes a secondary secret from a webhook source. The primary
+// secret cannot be retired.
+func (s *Service) RetireSecret(ctx context.Context, name, id string) (*models.WebhookSource, error) {
+	source, err := s.GetSource(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, ok := source.Secrets.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, id)
+	}
+	if secret.Primary {
+		return nil, fmt.Errorf("%w: the primary secret cannot be retired, promote another secret first", ErrInvalidSourceConfig)
+	}
+	source.Secrets.Remove(id)
+
+	if err := s.saveSource(ctx, source); err != nil {
+		return nil, err
+	}
+
+	s.logger.Printf("Retired secret %s of webhook source %s", id, source.Name)
+	return source, nil
+}
+
+// saveSource validates and stores a changed webhook source
+func (s *Service) saveSource(ctx context.Context, source *models.WebhookSource) error {
+	if _, err := newActiveSource(source); err != nil {
+		return err
+	}
+
+	source.UpdatedAt = time.Now()
+	if err := s.sources.repo.Update(ctx, source); err != nil {
+		return fmt.Errorf("failed to update webhook source: %w", err)
+	}
+	s.sources.Invalidate()
+	return nil
+}
+
+// expireAfter parses the time after which a previous primary secret expires.
+// An empty duration means it does not expire.
+func expireAfter(duration string) (*time.Time, error) {
+	if duration == "" {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil || d < 0 {
+		return nil, fmt.Errorf("%w: expire_previous_after must be a positive duration such as 24h", ErrInvalidSourceConfig)
+	}
+	expiresAt := time.Now().Add(d)
+	return &expiresAt, nil
+}
+
+// DeleteSource deletes a webhook source. Its receipts are kept.
+func (s *Service) DeleteSource(ctx context.Context, name string) error {
+	if err := s.sources.repo.Delete(ctx, name); err != nil {
+		return fmt.Errorf("failed to delete webhook source: %w", err)
+	}
+	s.sources.Invalidate()
+
+	s.logger.Printf("Deleted webhook source %s", name)
+	return nil
+}
+
+// SeedSources stores the sources whose names are not taken yet, so sources
+// configured in the environment are created on first start and managed
+// through the API afterwards
+func (s *Service) SeedSources(ctx context.Context, sources []*models.WebhookSource) error {
+	for _, source := range sources {
+		if _, err := s.sources.repo.GetByName(ctx, source.Name); err == nil {
+			continue
+		} else if !errors.Is(err, ErrSourceNotFound) {
+			return fmt.Errorf("failed to check webhook source %s: %w", source.Name, err)
+		}
+
+		if err := s.sources.repo.Create(ctx, source); err != nil {
+			return fmt.Errorf("failed to create webhook source %s: %w", source.Name, err)
+		}
+		s.logger.Printf("Created webhook source %s from the environment", source.Name)
+	}
+	s.sources.Invalidate()
+	return nil
+}
+
+// SourcesFromEnv returns the webhook sources configured through the
+// GITHUB_WEBHOOK_SECRET, STRIPE_WEBHOOK_SECRET, SENDGRID_WEBHOOK_PUBLIC_KEY
+// and TEST_WEBHOOK_SECRET environment variables
+func SourcesFromEnv() []*models.WebhookSource {
+	var sources []*models.WebhookSource
+
+	if secret := os.Getenv("GITHUB_WEBHOOK_SECRET"); secret != "" {
+		sources = append(sources, models.NewWebhookSource("github", models.WebhookVerifierGitHub, []string{secret}))
+	}
+	if secret := os.Getenv("STRIPE_WEBHOOK_SECRET"); secret != "" {
+		sources = append(sources, models.NewWebhookSource("stripe", models.WebhookVerifierStripe, []string{secret}))
+	}
+	if publicKey := os.Getenv("SENDGRID_WEBHOOK_PUBLIC_KEY"); publicKey != "" {
+		sources = append(sources, models.NewWebhookSource("sendgrid", models.WebhookVerifierSendGrid, []string{publicKey}))
+	} else if os.Getenv("SENDGRID_WEBHOOK_SECRET") != "" {
+		log.Printf("SendGrid signs webhooks with a public key, set SENDGRID_WEBHOOK_PUBLIC_KEY instead of SENDGRID_WEBHOOK_SECRET")
+	}
+	if secret := os.Getenv("TEST_WEBHOOK_SECRET"); secret != "" {
+		sources = append(sources, models.NewWebhookSource("test", models.WebhookVerifierHMAC, []string{secret}))
+	}
+
+	return sources
+}
@@ -0,0 +1,226 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceCRUD(t *testing.T) {
+	service := NewService(NewMockRepository(), NewSourceRegistry(NewMockSourceRepository(), time.Hour))
+	ctx := context.Background()
+
+	invalid := []*models.WebhookSourceRequest{
+		{Name: "", VerifierType: models.WebhookVerifierHMAC, Secrets: []string{"secret"}},
+		{Name: "Bad Name", VerifierType: models.WebhookVerifierHMAC, Secrets: []string{"secret"}},
+		{Name: "billing", VerifierType: "unknown", Secrets: []string{"secret"}},
+		{Name: "billing", VerifierType: models.WebhookVerifierHMAC},
+		{Name: "billing", VerifierType: models.WebhookVerifierSendGrid, Secrets: []string{"not a key"}},
+		{Name: "billing", VerifierType: models.WebhookVerifierHMAC, Secrets: []string{"secret"}, AllowedIPs: []string{"10.0.0.0/33"}},
+	}
+	for _, req := range invalid {
+		_, err := service.CreateSource(ctx, req)
+		assert.ErrorIs(t, err, ErrInvalidSourceConfig, "%+v", req)
+	}
+
+	source, err := service.CreateSource(ctx, &models.WebhookSourceRequest{Name: "billing", VerifierType: models.WebhookVerifierHMAC, Secrets: []string{"secret"}})
+	require.NoError(t, err)
+	assert.True(t, source.Enabled)
+	assert.True(t, service.IsValidSource("billing"))
+
+	_, err = service.CreateSource(ctx, &models.WebhookSourceRequest{Name: "billing", VerifierType: models.WebhookVerifierHMAC, Secrets: []string{"secret"}})
+	assert.ErrorIs(t, err, ErrDuplicateSource)
+
+	disabled := false
+	_, err = service.UpdateSource(ctx, "billing", &models.WebhookSourceRequest{Name: "renamed"})
+	assert.ErrorIs(t, err, ErrInvalidSourceConfig)
+	source, err = service.UpdateSource(ctx, "billing", &models.WebhookSourceRequest{Enabled: &disabled})
+	require.NoError(t, err)
+	require.Len(t, source.Secrets, 1)
+	assert.Equal(t, "secret", source.Secrets[0].Value)
+	assert.False(t, service.IsValidSource("billing"))
+
+	require.NoError(t, service.DeleteSource(ctx, "billing"))
+	_, err = service.GetSource(ctx, "billing")
+	assert.ErrorIs(t, err, ErrSourceNotFound)
+	assert.ErrorIs(t, service.DeleteSource(ctx, "billing"), ErrSourceNotFound)
+}
+
+func TestSourceRegistryRefresh(t *testing.T) {
+	repo := NewMockSourceRepository()
+	registry := NewSourceRegistry(repo, time.Hour)
+	service := NewService(NewMockRepository(), registry)
+	ctx := context.Background()
+
+	assert.False(t, service.IsValidSource("billing"))
+
+	// Sources created by another API instance appear once the cache is stale
+	require.NoError(t, repo.Create(ctx, models.NewWebhookSource("billing", models.WebhookVerifierHMAC, []string{"secret"})))
+	assert.False(t, service.IsValidSource("billing"))
+	registry.mu.Lock()
+	registry.loadedAt = time.Now().Add(-2 * time.Hour)
+	registry.mu.Unlock()
+	assert.True(t, service.IsValidSource("billing"))
+}
+
+func TestSourceDeliveries(t *testing.T) {
+	service := NewService(NewMockRepository(), NewSourceRegistry(NewMockSourceRepository(), 0))
+	ctx := context.Background()
+	payload := []byte(`{"id":"inv_1"}`)
+
+	eventHeader := "X-Billing-Event"
+	source, err := service.CreateSource(ctx, &models.WebhookSourceRequest{
+		Name:         "billing",
+		VerifierType: models.WebhookVerifierHMAC,
+		Secrets:      []string{"old-secret", "new-secret"},
+		EventHeader:  &eventHeader,
+		AllowedIPs:   []string{"10.1.0.0/16", "2001:db8::1"},
+	})
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set("X-Billing-Event", "invoice.paid")
+	header.Set(EventHeader, "ignored")
+
+	// Either secret is accepted and the receipt records which one matched
+	for i, secret := range []string{"old-secret", "new-secret"} {
+		payload := []byte(fmt.Sprintf(`{"id":"inv_%d"}`, i+2))
+		header.Set("X-Signature", hexHMAC([]byte(secret), payload))
+		receipt, err := service.CreateReceipt(ctx, "billing", header, payload, "10.1.2.3")
+		require.NoError(t, err)
+		assert.Equal(t, "invoice.paid", receipt.Event)
+		assert.Equal(t, source.Secrets[i].ID, receipt.SecretID)
+	}
+
+	header.Set("X-Signature", hexHMAC([]byte("new-secret"), payload))
+	_, err = service.CreateReceipt(ctx, "billing", header, payload, "2001:db8::1")
+	assert.NoError(t, err)
+	_, err = service.CreateReceipt(ctx, "billing", header, payload, "10.2.0.1")
+	assert.ErrorIs(t, err, ErrIPNotAllowed)
+
+	header.Set("X-Signature", hexHMAC([]byte("other-secret"), payload))
+	_, err = service.CreateReceipt(ctx, "billing", header, payload, "10.1.2.3")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestSeedSources(t *testing.T) {
+	repo := NewMockSourceRepository(models.NewWebhookSource("github", models.WebhookVerifierGitHub, []string{"stored-secret"}))
+	service := NewService(NewMockRepository(), NewSourceRegistry(repo, 0))
+	ctx := context.Background()
+
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "env-secret")
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "whsec_env")
+	t.Setenv("SENDGRID_WEBHOOK_PUBLIC_KEY", "")
+	t.Setenv("TEST_WEBHOOK_SECRET", "")
+	require.NoError(t, service.SeedSources(ctx, SourcesFromEnv()))
+
+	sources, err := service.ListSources(ctx)
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+
+	// Stored sources are not overwritten by the environment
+	assert.Equal(t, "github", sources[0].Name)
+	require.Len(t, sources[0].Secrets, 1)
+	assert.Equal(t, "stored-secret", sources[0].Secrets[0].Value)
+	assert.Equal(t, "stripe", sources[1].Name)
+	assert.Equal(t, models.WebhookVerifierStripe, sources[1].VerifierType)
+	assert.True(t, service.IsValidSource("stripe"))
+}
+
+func TestSecretRotation(t *testing.T) {
+	service := NewService(NewMockRepository(), NewSourceRegistry(NewMockSourceRepository(), 0))
+	ctx := context.Background()
+
+	source, err := service.CreateSource(ctx, &models.WebhookSourceRequest{Name: "billing", VerifierType: models.WebhookVerifierHMAC, Secrets: []string{"old-secret"}})
+	require.NoError(t, err)
+	old := source.Secrets[0]
+	assert.True(t, old.Primary)
+
+	delivered := 0
+	deliver := func(secret string) (*models.WebhookReceipt, error) {
+		// Every delivery has its own payload so none is taken for a replay
+		delivered++
+		payload := []byte(fmt.Sprintf(`{"id":"inv_%d"}`, delivered))
+		header := http.Header{}
+		header.Set(EventHeader, "invoice.paid")
+		header.Set("X-Signature", hexHMAC([]byte(secret), payload))
+		return service.CreateReceipt(ctx, "billing", header, payload, "")
+	}
+
+	_, err = service.AddSecret(ctx, "billing", &models.WebhookSecretRequest{})
+	assert.ErrorIs(t, err, ErrInvalidSourceConfig)
+	past := time.Now().Add(-time.Hour)
+	_, err = service.AddSecret(ctx, "billing", &models.WebhookSecretRequest{Value: "new-secret", ExpiresAt: &past})
+	assert.ErrorIs(t, err, ErrInvalidSourceConfig)
+	_, err = service.AddSecret(ctx, "billing", &models.WebhookSecretRequest{Value: "new-secret", Primary: true, ExpirePreviousAfter: "soon"})
+	assert.ErrorIs(t, err, ErrInvalidSourceConfig)
+	_, err = service.AddSecret(ctx, "unknown", &models.WebhookSecretRequest{Value: "new-secret"})
+	assert.ErrorIs(t, err, ErrSourceNotFound)
+
+	// A secondary secret is accepted alongside the primary one
+	source, err = service.AddSecret(ctx, "billing", &models.WebhookSecretRequest{Value: "new-secret"})
+	require.NoError(t, err)
+	require.Len(t, source.Secrets, 2)
+	added := source.Secrets[1]
+	assert.False(t, added.Primary)
+
+	receipt, err := deliver("new-secret")
+	require.NoError(t, err)
+	assert.Equal(t, added.ID, receipt.SecretID)
+
+	// The primary secret cannot be retired
+	_, err = service.RetireSecret(ctx, "billing", old.ID)
+	assert.ErrorIs(t, err, ErrInvalidSourceConfig)
+	_, err = service.RetireSecret(ctx, "billing", "unknown")
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+	_, err = service.PromoteSecret(ctx, "billing", "unknown", &models.WebhookSecretRequest{})
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+
+	// Promoting the new secret keeps the old one until it expires
+	source, err = service.PromoteSecret(ctx, "billing", added.ID, &models.WebhookSecretRequest{ExpirePreviousAfter: "1h"})
+	require.NoError(t, err)
+	primary, ok := source.Secrets.Primary()
+	require.True(t, ok)
+	assert.Equal(t, added.ID, primary.ID)
+	previous, ok := source.Secrets.Get(old.ID)
+	require.True(t, ok)
+	require.NotNil(t, previous.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *previous.ExpiresAt, time.Minute)
+
+	receipt, err = deliver("old-secret")
+	require.NoError(t, err)
+	assert.Equal(t, old.ID, receipt.SecretID)
+
+	// Expired secrets are no longer accepted and cannot be promoted again
+	stored, err := service.sources.repo.GetByName(ctx, "billing")
+	require.NoError(t, err)
+	expired, _ := stored.Secrets.Get(old.ID)
+	expired.ExpiresAt = &past
+	require.NoError(t, service.sources.repo.Update(ctx, stored))
+	service.sources.Invalidate()
+
+	_, err = deliver("old-secret")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = service.PromoteSecret(ctx, "billing", old.ID, &models.WebhookSecretRequest{})
+	assert.ErrorIs(t, err, ErrInvalidSourceConfig)
+
+	source, err = service.RetireSecret(ctx, "billing", old.ID)
+	require.NoError(t, err)
+	require.Len(t, source.Secrets, 1)
+	assert.Equal(t, added.ID, source.Secrets[0].ID)
+
+	// Adding a primary secret promotes it right away
+	source, err = service.AddSecret(ctx, "billing", &models.WebhookSecretRequest{Value: "next-secret", Primary: true})
+	require.NoError(t, err)
+	primary, _ = source.Secrets.Primary()
+	assert.Equal(t, "next-secret", primary.Value)
+	previous, _ = source.Secrets.Get(added.ID)
+	assert.False(t, previous.Primary)
+	assert.Nil(t, previous.ExpiresAt)
+}
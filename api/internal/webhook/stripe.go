@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StripeVerifier verifies Stripe deliveries. Their Stripe-Signature header
+// holds a timestamp and one or more signatures, t=<unix>,v1=<hex>, where each
+// signature is the HMAC-SHA256 of "<timestamp>.<payload>". The event is the
+// type of the event object in the payload and its delivery ID the ID of the
+// event object.
+type StripeVerifier struct {
+	secret    []byte
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+// NewStripeVerifier creates a verifier for a Stripe endpoint secret that
+// rejects deliveries signed more than tolerance ago or ahead. A tolerance of
+// zero uses DefaultTimestampTolerance.
+func NewStripeVerifier(secret string, tolerance time.Duration) *StripeVerifier {
+	if tolerance <= 0 {
+		tolerance = DefaultTimestampTolerance
+	}
+	return &StripeVerifier{secret: []byte(secret), tolerance: tolerance, now: time.Now}
+}
+
+// Verify checks the Stripe-Signature header. The delivery is valid if any of
+// its v1 signatures matches and its timestamp is within the tolerance.
+func (v *StripeVerifier) Verify(header http.Header, payload []byte) error {
+	signature := v.Signature(header)
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing or malformed timestamp", ErrInvalidSignature)
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("%w: no v1 signature", ErrInvalidSignature)
+	}
+
+	expected := []byte(hexHMAC(v.secret, append([]byte(timestamp+"."), payload...)))
+	matched := false
+	for _, s := range signatures {
+		if hmac.Equal([]byte(s), expected) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return ErrInvalidSignature
+	}
+
+	return checkTimestamp(time.Unix(unix, 0), v.now(), v.tolerance)
+}
+
+// Tolerance returns how far the signed timestamp may be from the current time
+func (v *StripeVerifier) Tolerance() time.Duration {
+	return v.tolerance
+}
+
+// Signature returns the Stripe-Signature header
+func (v *StripeVerifier) Signature(header http.Header) string {
+	return header.Get("Stripe-Signature")
+}
+
+// Event returns the type of the event object in the payload
+func (v *StripeVerifier) Event(header http.Header, payload []byte) string {
+	var event struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return ""
+	}
+	return event.Type
+}
+
+// DeliveryID returns the ID of the event object in the payload, which Stripe
+// keeps when it retries the event
+func (v *StripeVerifier) DeliveryID(header http.Header, payload []byte) string {
+	var event struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return ""
+	}
+	return event.ID
+}
@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// EventHeader is the header a webhook names its event in when its source's
+// verifier cannot read the event from the delivery itself
+const EventHeader = "X-Event-Type"
+
+// DeliveryHeader is the header a webhook names its delivery ID in when its
+// source's verifier cannot read the ID from the delivery itself
+const DeliveryHeader = "Idempotency-Key"
+
+// DefaultTimestampTolerance is how far the signed timestamp of a delivery may
+// be from the current time, as in Stripe's own libraries
+const DefaultTimestampTolerance = 5 * time.Minute
+
+// SignatureVerifier verifies the signatures of a webhook source's deliveries
+type SignatureVerifier interface {
+	// Verify checks the signature carried in the headers of a delivery
+	// against its payload. It returns ErrMissingSignature or an error
+	// wrapping ErrInvalidSignature if the delivery cannot be trusted.
+	Verify(header http.Header, payload []byte) error
+	// Signature returns the signature of a delivery as it was received
+	Signature(header http.Header) string
+}
+
+// EventReader reads the event of a delivery the way its provider sends it.
+// Verifiers of sources that do not implement it fall back to EventHeader.
+type EventReader interface {
+	// Event returns the event of a delivery or an empty string
+	Event(header http.Header, payload []byte) string
+}
+
+// TimestampVerifier is implemented by verifiers whose signature covers the
+// time a delivery was signed, so a captured delivery is rejected once it is
+// older than the tolerance. Within the tolerance a replay is only recognized by
+// its delivery ID if the signature covers that as well, so deliveries of other
+// sources, or without such an ID, are also deduplicated by the digest of their
+// payload.
+type TimestampVerifier interface {
+	// Tolerance returns how far the signed timestamp of a delivery may be
+	// from the current time
+	Tolerance() time.Duration
+}
+
+// DeliveryReader reads the ID a provider gives a delivery, which stays the
+// same when the delivery is redelivered. Verifiers of sources that do not
+// implement it fall back to DeliveryHeader. Verifiers that also implement
+// TimestampVerifier must read the ID from the signed payload.
+type DeliveryReader interface {
+	// DeliveryID returns the ID of a delivery or an empty string
+	DeliveryID(header http.Header, payload []byte) string
+}
+
+// checkTimestamp checks that a signed timestamp is within tolerance of now
+func checkTimestamp(timestamp, now time.Time, tolerance time.Duration) error {
+	if age := now.Sub(timestamp); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside the tolerance of %s", ErrInvalidSignature, tolerance)
+	}
+	return nil
+}
+
+// HMACVerifier verifies a hex encoded HMAC-SHA256 of the payload sent in a
+// header. It is used for sources without a provider-specific scheme.
+type HMACVerifier struct {
+	header string
+	secret []byte
+}
+
+// NewHMACVerifier creates a verifier for HMAC signatures sent in header,
+// X-Signature if empty
+func NewHMACVerifier(header, secret string) *HMACVerifier {
+	if header == "" {
+		header = "X-Signature"
+	}
+	return &HMACVerifier{header: header, secret: []byte(secret)}
+}
+
+// Verify checks the HMAC of the payload
+func (v *HMACVerifier) Verify(header http.Header, payload []byte) error {
+	signature := v.Signature(header)
+	if signature == "" {
+		return ErrMissingSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(hexHMAC(v.secret, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Signature returns the signature header
+func (v *HMACVerifier) Signature(header http.Header) string {
+	return header.Get(v.header)
+}
+
+// payloadDigest returns the hex encoded SHA-256 digest of a payload
+func payloadDigest(payload []byte) string {
+	digest := sha256.Sum256(payload)
+	return hex.EncodeToString(digest[:])
+}
+
+// hexHMAC returns the hex encoded HMAC-SHA256 of message
+func hexHMAC(secret, message []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(message)
+	return hex.EncodeToString(mac.Sum(nil))
+}
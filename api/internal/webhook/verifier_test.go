@@ -0,0 +1,195 @@
+package webhook
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubVerifier(t *testing.T) {
+	verifier := NewGitHubVerifier("github-secret")
+	payload := []byte(`{"zen":"Keep it logically awesome."}`)
+
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "ping")
+	assert.ErrorIs(t, verifier.Verify(header, payload), ErrMissingSignature)
+
+	header.Set("X-Hub-Signature-256", "sha256="+hexHMAC([]byte("github-secret"), payload))
+	assert.NoError(t, verifier.Verify(header, payload))
+	assert.Equal(t, "ping", verifier.Event(header, payload))
+	header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	assert.Equal(t, "72d3162e-cc78-11e3-81ab-4c9367dc0958", verifier.DeliveryID(header, payload))
+
+	// The bare digest of the old X-Signature scheme is rejected
+	header.Set("X-Hub-Signature-256", hexHMAC([]byte("github-secret"), payload))
+	assert.ErrorIs(t, verifier.Verify(header, payload), ErrInvalidSignature)
+
+	header.Set("X-Hub-Signature-256", "sha256="+hexHMAC([]byte("other-secret"), payload))
+	assert.ErrorIs(t, verifier.Verify(header, payload), ErrInvalidSignature)
+}
+
+func TestStripeVerifier(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	verifier := NewStripeVerifier("whsec_test", 0)
+	verifier.now = func() time.Time { return now }
+	payload := []byte(`{"id":"evt_1","type":"invoice.paid"}`)
+
+	sign := func(secret string, at time.Time) string {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		return hexHMAC([]byte(secret), []byte(timestamp+"."+string(payload)))
+	}
+	header := func(value string) http.Header {
+		h := http.Header{}
+		h.Set("Stripe-Signature", value)
+		return h
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr error
+	}{
+		{
+			name:   "valid signature",
+			header: header(fmt.Sprintf("t=%d,v1=%s,v0=ignored", now.Unix(), sign("whsec_test", now))),
+		},
+		{
+			name:   "any v1 signature may match while the secret is rolled",
+			header: header(fmt.Sprintf("t=%d,v1=%s,v1=%s", now.Unix(), sign("whsec_old", now), sign("whsec_test", now))),
+		},
+		{
+			name:   "timestamp within the tolerance",
+			header: header(fmt.Sprintf("t=%d,v1=%s", now.Add(-4*time.Minute).Unix(), sign("whsec_test", now.Add(-4*time.Minute)))),
+		},
+		{
+			name:    "missing header",
+			header:  http.Header{},
+			wantErr: ErrMissingSignature,
+		},
+		{
+			name:    "missing timestamp",
+			header:  header("v1=" + sign("whsec_test", now)),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "wrong secret",
+			header:  header(fmt.Sprintf("t=%d,v1=%s", now.Unix(), sign("whsec_other", now))),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "replayed delivery",
+			header:  header(fmt.Sprintf("t=%d,v1=%s", now.Add(-10*time.Minute).Unix(), sign("whsec_test", now.Add(-10*time.Minute)))),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "timestamp of another signature",
+			header:  header(fmt.Sprintf("t=%d,v1=%s", now.Unix(), sign("whsec_test", now.Add(-time.Second)))),
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifier.Verify(tt.header, payload)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	assert.Equal(t, "invoice.paid", verifier.Event(http.Header{}, payload))
+	assert.Equal(t, "evt_1", verifier.DeliveryID(http.Header{}, payload))
+}
+
+func TestSendGridVerifier(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	verifier, err := NewSendGridVerifier(base64.StdEncoding.EncodeToString(der), 0)
+	require.NoError(t, err)
+	verifier.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	_, err = NewSendGridVerifier("not a key", 0)
+	assert.Error(t, err)
+
+	payload := []byte(`[{"email":"a@example.com","event":"delivered"},{"email":"b@example.com","event":"delivered"}]`)
+	sign := func(timestamp string, body []byte) string {
+		digest := sha256.Sum256(append([]byte(timestamp), body...))
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(signature)
+	}
+
+	header := http.Header{}
+	assert.ErrorIs(t, verifier.Verify(header, payload), ErrMissingSignature)
+
+	header.Set("X-Twilio-Email-Event-Webhook-Timestamp", "1700000000")
+	header.Set("X-Twilio-Email-Event-Webhook-Signature", sign("1700000000", payload))
+	assert.NoError(t, verifier.Verify(header, payload))
+	assert.Equal(t, "delivered", verifier.Event(header, payload))
+
+	// The signature covers the timestamp and the payload
+	header.Set("X-Twilio-Email-Event-Webhook-Timestamp", "1700000001")
+	assert.ErrorIs(t, verifier.Verify(header, payload), ErrInvalidSignature)
+	header.Set("X-Twilio-Email-Event-Webhook-Timestamp", "1700000000")
+	assert.ErrorIs(t, verifier.Verify(header, []byte(`[]`)), ErrInvalidSignature)
+
+	// Replayed deliveries are rejected once their timestamp is out of tolerance
+	header.Set("X-Twilio-Email-Event-Webhook-Timestamp", "1699999000")
+	header.Set("X-Twilio-Email-Event-Webhook-Signature", sign("1699999000", payload))
+	assert.ErrorIs(t, verifier.Verify(header, payload), ErrInvalidSignature)
+
+	assert.Equal(t, "mixed", verifier.Event(header, []byte(`[{"event":"open"},{"event":"click"}]`)))
+	assert.Empty(t, verifier.Event(header, []byte(`{"event":"open"}`)))
+
+	// The delivery ID is derived from the event IDs in the signed payload
+	assert.Equal(t, "ev-1", verifier.DeliveryID(header, []byte(`[{"event":"open","sg_event_id":"ev-1"}]`)))
+	both := verifier.DeliveryID(header, []byte(`[{"sg_event_id":"ev-1"},{"sg_event_id":"ev-2"}]`))
+	assert.Len(t, both, 64)
+	assert.Equal(t, both, verifier.DeliveryID(header, []byte(`[{"sg_event_id":"ev-2"},{"sg_event_id":"ev-1"}]`)))
+	assert.NotEqual(t, both, verifier.DeliveryID(header, []byte(`[{"sg_event_id":"ev-1"},{"sg_event_id":"ev-3"}]`)))
+	assert.Empty(t, verifier.DeliveryID(header, []byte(`[{"sg_event_id":"ev-1"},{"event":"open"}]`)))
+	assert.Empty(t, verifier.DeliveryID(header, payload))
+}
+
+func TestServiceVerifiers(t *testing.T) {
+	test := models.NewWebhookSource("test", models.WebhookVerifierHMAC, []string{"test-secret"})
+	service := NewService(NewMockRepository(), NewSourceRegistry(NewMockSourceRepository(test), 0))
+	ctx := context.Background()
+	payload := []byte(`{"test": "data"}`)
+
+	// Sources without an event scheme name their event in X-Event-Type
+	header := http.Header{}
+	header.Set("X-Signature", hexHMAC([]byte("test-secret"), payload))
+	_, err := service.CreateReceipt(ctx, "test", header, payload, "")
+	assert.ErrorIs(t, err, ErrMissingEvent)
+
+	header.Set(EventHeader, "ping")
+	receipt, err := service.CreateReceipt(ctx, "test", header, payload, "")
+	require.NoError(t, err)
+	assert.Equal(t, "ping", receipt.Event)
+	assert.Equal(t, header.Get("X-Signature"), receipt.Signature)
+
+	header.Set("X-Signature", hexHMAC([]byte("wrong-secret"), payload))
+	_, err = service.CreateReceipt(ctx, "test", header, payload, "")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	assert.ErrorIs(t, service.VerifySignature("unknown", header, payload), ErrInvalidSource)
+}
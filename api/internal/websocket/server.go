@@ -9,10 +9,12 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"sync"
 
+	"github.com/dustinleblanc/go-bespin-contract/events"
 	"github.com/olahol/melody"
 )
 
@@ -24,8 +26,27 @@ type Server struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 	mu     sync.RWMutex
-	// Track latest status for each job
+	// Track latest status and progress for each job
 	jobStatuses map[string]JobStatus
+	jobProgress map[string]JobProgress
+	// Called when a client asks to cancel its job
+	cancelHandler CancelHandler
+}
+
+// CancelHandler cancels a job on behalf of a WebSocket client
+type CancelHandler func(ctx context.Context, jobID string) error
+
+// ClientMessage represents a message sent by a client.
+// Supported types: "cancel" cancels the job the client is subscribed to.
+type ClientMessage struct {
+	Type string `json:"type"`
+}
+
+// ErrorMessage represents an error sent back to a client in response to one of its messages
+type ErrorMessage struct {
+	Type  string `json:"type"`   // Message type, always "error"
+	JobID string `json:"job_id"` // ID of the job the client is subscribed to
+	Error string `json:"error"`  // Error message
 }
 
 // JobStatus represents a job status update message.
@@ -37,6 +58,14 @@ type JobStatus struct {
 	Result interface{} `json:"result,omitempty"` // Optional result data
 }
 
+// JobProgress represents a job progress update message.
+// It includes the job ID and the latest progress reported by the job.
+type JobProgress struct {
+	Type     string           `json:"type"`     // Message type, always "job_progress"
+	JobID    string           `json:"job_id"`   // ID of the job this progress is for
+	Progress *events.Progress `json:"progress"` // Latest progress (percent, message, metadata)
+}
+
 // NewServer creates a new WebSocket server with default configuration.
 // The server allows all origins and uses standard logging.
 func NewServer() *Server {
@@ -57,6 +86,7 @@ func NewServer() *Server {
 		ctx:         ctx,
 		cancel:      cancel,
 		jobStatuses: make(map[string]JobStatus),
+		jobProgress: make(map[string]JobProgress),
 	}
 
 	// Set up melody handlers
@@ -91,6 +121,14 @@ func (s *Server) HandleConnection(w http.ResponseWriter, r *http.Request, jobID
 	s.melody.HandleRequest(w, r)
 }
 
+// HandleCancel sets the handler used to cancel jobs when a client sends a
+// "cancel" message.
+func (s *Server) HandleCancel(handler CancelHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelHandler = handler
+}
+
 // NotifyJobStatus notifies all clients subscribed to a specific job about a status change.
 // The status update is also stored for new clients that connect later.
 func (s *Server) NotifyJobStatus(jobID string, status string, result interface{}) {
@@ -114,13 +152,62 @@ func (s *Server) NotifyJobStatus(jobID string, status string, result interface{}
 	s.jobStatuses[jobID] = message
 	s.mu.Unlock()
 
-	// Broadcast only to clients subscribed to this job
+	s.broadcastToJob(jobID, data)
+}
+
+// NotifyJobProgress notifies all clients subscribed to a specific job about its progress.
+// The progress update is also stored for new clients that connect later.
+func (s *Server) NotifyJobProgress(jobID string, progress *events.Progress) {
+	message := JobProgress{
+		Type:     "job_progress",
+		JobID:    jobID,
+		Progress: progress,
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		s.logger.Printf("Failed to marshal job progress message: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	// Store the latest progress
+	s.jobProgress[jobID] = message
+	s.mu.Unlock()
+
+	s.broadcastToJob(jobID, data)
+}
+
+// broadcastToJob sends a message only to clients subscribed to the given job
+func (s *Server) broadcastToJob(jobID string, data []byte) {
 	s.melody.BroadcastFilter(data, func(session *melody.Session) bool {
 		sessionJobID, ok := session.Request.Context().Value("job_id").(string)
 		return ok && sessionJobID == jobID
 	})
 }
 
+// HandleJobEvent forwards a job event received from the event bus to the clients
+// subscribed to the job. Progress events are sent as job_progress messages,
+// completed events carry the job result and failures carry the error message.
+func (s *Server) HandleJobEvent(event *events.JobEvent) {
+	if event.Kind == events.KindProgress {
+		if event.Progress != nil {
+			s.NotifyJobProgress(event.JobID, event.Progress)
+		}
+		return
+	}
+
+	var result interface{}
+	switch {
+	case event.Error != "":
+		result = event.Error
+	case len(event.Result) > 0:
+		result = event.Result
+	}
+
+	s.NotifyJobStatus(event.JobID, string(event.Kind), result)
+}
+
 // handleConnect is called when a new WebSocket connection is established.
 func (s *Server) handleConnect(session *melody.Session) {
 	// Get job ID from context
@@ -132,7 +219,7 @@ func (s *Server) handleConnect(session *melody.Session) {
 
 	s.logger.Printf("Client connected: %p, Job ID: %s", session, jobID)
 
-	// Send latest status if available
+	// Send latest status and progress if available
 	s.mu.RLock()
 	if status, ok := s.jobStatuses[jobID]; ok {
 		data, err := json.Marshal(status)
@@ -140,6 +227,12 @@ func (s *Server) handleConnect(session *melody.Session) {
 			session.Write(data)
 		}
 	}
+	if progress, ok := s.jobProgress[jobID]; ok {
+		data, err := json.Marshal(progress)
+		if err == nil {
+			session.Write(data)
+		}
+	}
 	s.mu.RUnlock()
 }
 
@@ -154,13 +247,45 @@ func (s *Server) handleDisconnect(session *melody.Session) {
 }
 
 // handleMessage is called when a message is received from a client.
-// Currently, it only logs received messages. Future implementations may handle
-// client-to-server messages for features like job cancellation or progress updates.
+// A "cancel" message cancels the job the client is subscribed to; the resulting
+// cancelled status reaches clients through the job event bus. Other messages are only logged.
 func (s *Server) handleMessage(session *melody.Session, msg []byte) {
 	jobID, ok := session.Request.Context().Value("job_id").(string)
-	if ok {
-		s.logger.Printf("Received message from client %p (Job ID: %s): %s", session, jobID, msg)
-	} else {
+	if !ok {
 		s.logger.Printf("Received message from client: %s", msg)
+		return
+	}
+
+	s.logger.Printf("Received message from client %p (Job ID: %s): %s", session, jobID, msg)
+
+	var message ClientMessage
+	if err := json.Unmarshal(msg, &message); err != nil || message.Type != "cancel" {
+		return
+	}
+
+	s.mu.RLock()
+	cancel := s.cancelHandler
+	s.mu.RUnlock()
+
+	if cancel == nil {
+		s.writeError(session, jobID, "cancellation is not supported")
+		return
+	}
+	if err := cancel(s.ctx, jobID); err != nil {
+		s.writeError(session, jobID, fmt.Sprintf("failed to cancel job: %v", err))
+	}
+}
+
+// writeError sends an error message to a single client
+func (s *Server) writeError(session *melody.Session, jobID, errMsg string) {
+	data, err := json.Marshal(ErrorMessage{
+		Type:  "error",
+		JobID: jobID,
+		Error: errMsg,
+	})
+	if err != nil {
+		s.logger.Printf("Failed to marshal error message: %v", err)
+		return
 	}
+	session.Write(data)
 }
@@ -1,11 +1,15 @@
 package websocket
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/dustinleblanc/go-bespin-contract/events"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
@@ -168,3 +172,199 @@ func TestWebSocketServerMultipleJobs(t *testing.T) {
 		t.Fatal("Timeout waiting for message from job2")
 	}
 }
+
+func TestWebSocketServerHandleJobEvent(t *testing.T) {
+	// Create a new WebSocket server
+	server := NewServer()
+	go server.Start()
+	defer server.Stop()
+
+	// Create a test HTTP server
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		server.HandleConnection(c.Writer, c.Request, "event-job-id")
+	})
+
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Connect to WebSocket
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect to WebSocket: %v", err)
+	}
+	defer ws.Close()
+
+	messages := make(chan JobStatus)
+	go func() {
+		for {
+			var message JobStatus
+			err := ws.ReadJSON(&message)
+			if err != nil {
+				close(messages)
+				return
+			}
+			messages <- message
+		}
+	}()
+
+	// Events as they arrive from the event bus
+	jobEvents := []struct {
+		event      *events.JobEvent
+		wantResult interface{}
+	}{
+		{
+			event: events.NewJobEvent(events.KindProcessing, "event-job-id", "random_text"),
+		},
+		{
+			event: &events.JobEvent{
+				Kind:   events.KindCompleted,
+				JobID:  "event-job-id",
+				Result: json.RawMessage(`{"text":"cloud"}`),
+			},
+			wantResult: map[string]interface{}{"text": "cloud"},
+		},
+		{
+			event: &events.JobEvent{
+				Kind:  events.KindFailed,
+				JobID: "event-job-id",
+				Error: "boom",
+			},
+			wantResult: "boom",
+		},
+	}
+
+	for _, tc := range jobEvents {
+		server.HandleJobEvent(tc.event)
+
+		select {
+		case msg := <-messages:
+			assert.Equal(t, "job_status", msg.Type)
+			assert.Equal(t, "event-job-id", msg.JobID)
+			assert.Equal(t, string(tc.event.Kind), msg.Status)
+			assert.Equal(t, tc.wantResult, msg.Result)
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for event: %s", tc.event.Kind)
+		}
+	}
+}
+
+func TestWebSocketServerJobProgress(t *testing.T) {
+	// Create a new WebSocket server
+	server := NewServer()
+	go server.Start()
+	defer server.Stop()
+
+	// Create a test HTTP server
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		server.HandleConnection(c.Writer, c.Request, "progress-job-id")
+	})
+
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Connect to WebSocket
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect to WebSocket: %v", err)
+	}
+	defer ws.Close()
+
+	messages := make(chan JobProgress)
+	go func() {
+		for {
+			var message JobProgress
+			err := ws.ReadJSON(&message)
+			if err != nil {
+				close(messages)
+				return
+			}
+			messages <- message
+		}
+	}()
+
+	// Send a progress event as it arrives from the event bus
+	progress, err := events.NewProgress(40, "halfway there", map[string]interface{}{"step": "render"})
+	assert.NoError(t, err)
+	event := events.NewJobEvent(events.KindProgress, "progress-job-id", "random_text")
+	event.Progress = progress
+	server.HandleJobEvent(event)
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "job_progress", msg.Type)
+		assert.Equal(t, "progress-job-id", msg.JobID)
+		assert.Equal(t, 40, msg.Progress.Percent)
+		assert.Equal(t, "halfway there", msg.Progress.Message)
+		assert.Equal(t, "render", msg.Progress.Metadata["step"])
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for progress update")
+	}
+}
+
+func TestWebSocketServerCancelMessage(t *testing.T) {
+	// Create a new WebSocket server that records cancellations
+	server := NewServer()
+	go server.Start()
+	defer server.Stop()
+
+	cancelled := make(chan string, 1)
+	server.HandleCancel(func(ctx context.Context, jobID string) error {
+		if jobID == "finished-job-id" {
+			return fmt.Errorf("job has already finished")
+		}
+		cancelled <- jobID
+		return nil
+	})
+
+	// Create a test HTTP server
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		server.HandleConnection(c.Writer, c.Request, c.Query("job_id"))
+	})
+
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+	t.Run("cancel running job", func(t *testing.T) {
+		ws, _, err := websocket.DefaultDialer.Dial(wsURL+"?job_id=cancel-job-id", nil)
+		if err != nil {
+			t.Fatalf("Failed to connect to WebSocket: %v", err)
+		}
+		defer ws.Close()
+
+		assert.NoError(t, ws.WriteJSON(ClientMessage{Type: "cancel"}))
+
+		select {
+		case jobID := <-cancelled:
+			assert.Equal(t, "cancel-job-id", jobID)
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for cancellation")
+		}
+	})
+
+	t.Run("cancel finished job", func(t *testing.T) {
+		ws, _, err := websocket.DefaultDialer.Dial(wsURL+"?job_id=finished-job-id", nil)
+		if err != nil {
+			t.Fatalf("Failed to connect to WebSocket: %v", err)
+		}
+		defer ws.Close()
+
+		assert.NoError(t, ws.WriteJSON(ClientMessage{Type: "cancel"}))
+
+		ws.SetReadDeadline(time.Now().Add(time.Second))
+		var msg ErrorMessage
+		assert.NoError(t, ws.ReadJSON(&msg))
+		assert.Equal(t, "error", msg.Type)
+		assert.Equal(t, "finished-job-id", msg.JobID)
+		assert.Contains(t, msg.Error, "already finished")
+	})
+}
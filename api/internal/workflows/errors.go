@@ -0,0 +1,34 @@
+package workflows
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// Error definitions
+var (
+	ErrWorkflowNotFound = errors.New("workflow not found")
+	ErrInvalidWorkflow  = errors.New("invalid workflow")
+)
+
+// ValidationError is returned when a workflow request is invalid. It lists
+// every invalid field and matches ErrInvalidWorkflow.
+type ValidationError struct {
+	Errors []models.FieldError
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fieldErr.Field+": "+fieldErr.Message)
+	}
+	return "invalid workflow: " + strings.Join(messages, "; ")
+}
+
+// Unwrap makes errors.Is match ErrInvalidWorkflow
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidWorkflow
+}
@@ -0,0 +1,104 @@
+package workflows
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormRepository implements Repository using GORM
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRepository creates a new GORM repository
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{db: db}
+}
+
+// Create creates a new workflow run and its steps
+func (r *GormRepository) Create(ctx context.Context, run *models.WorkflowRun) error {
+	result := r.db.WithContext(ctx).Create(run)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create workflow run: %w", result.Error)
+	}
+	return nil
+}
+
+// GetByID retrieves a workflow run and its steps by ID
+func (r *GormRepository) GetByID(ctx context.Context, id string) (*models.WorkflowRun, error) {
+	var run models.WorkflowRun
+	result := r.db.WithContext(ctx).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		First(&run, "id = ?", id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get workflow run: %w", result.Error)
+	}
+	return &run, nil
+}
+
+// GetByJobID retrieves the workflow run one of whose steps enqueued a job
+func (r *GormRepository) GetByJobID(ctx context.Context, jobID string) (*models.WorkflowRun, error) {
+	var step models.WorkflowStep
+	result := r.db.WithContext(ctx).First(&step, "job_id = ?", jobID)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: no step enqueued job %s", ErrWorkflowNotFound, jobID)
+		}
+		return nil, fmt.Errorf("failed to get workflow step: %w", result.Error)
+	}
+	return r.GetByID(ctx, step.RunID)
+}
+
+// ListRunning lists the IDs of the running workflow runs, oldest first
+func (r *GormRepository) ListRunning(ctx context.Context) ([]string, error) {
+	var ids []string
+	result := r.db.WithContext(ctx).
+		Model(&models.WorkflowRun{}).
+		Where("status = ?", models.WorkflowStatusRunning).
+		Order("created_at ASC").
+		Pluck("id", &ids)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list workflow runs: %w", result.Error)
+	}
+	return ids, nil
+}
+
+// Modify loads a workflow run and its steps with the run's row locked for
+// update and saves them in the same transaction if modify returns true, so
+// concurrent modifications of a run are applied one after the other
+func (r *GormRepository) Modify(ctx context.Context, id string, modify func(run *models.WorkflowRun) bool) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var run models.WorkflowRun
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&run, "id = ?", id)
+		if result.Error != nil {
+			if result.Error == gorm.ErrRecordNotFound {
+				return fmt.Errorf("%w: %s", ErrWorkflowNotFound, id)
+			}
+			return fmt.Errorf("failed to get workflow run: %w", result.Error)
+		}
+		if err := tx.Order("position").Find(&run.Steps, "run_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to get workflow steps: %w", err)
+		}
+
+		if !modify(&run) {
+			return nil
+		}
+
+		if err := tx.Omit("Steps").Save(&run).Error; err != nil {
+			return fmt.Errorf("failed to update workflow run: %w", err)
+		}
+		for _, step := range run.Steps {
+			if err := tx.Save(step).Error; err != nil {
+				return fmt.Errorf("failed to update workflow step: %w", err)
+			}
+		}
+		return nil
+	})
+}
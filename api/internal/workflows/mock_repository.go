@@ -0,0 +1,116 @@
+package workflows
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// MockRepository is an in-memory implementation of the Repository interface
+type MockRepository struct {
+	runs map[string]*models.WorkflowRun
+	mu   sync.RWMutex
+	// modifyMu serializes modifications like the row lock of GormRepository
+	modifyMu sync.Mutex
+}
+
+// NewMockRepository creates a new mock repository
+func NewMockRepository() *MockRepository {
+	return &MockRepository{
+		runs: make(map[string]*models.WorkflowRun),
+	}
+}
+
+// Create stores a workflow run in memory
+func (r *MockRepository) Create(ctx context.Context, run *models.WorkflowRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.runs[run.ID]; ok {
+		return fmt.Errorf("workflow run already exists: %s", run.ID)
+	}
+
+	r.runs[run.ID] = copyRun(run)
+	return nil
+}
+
+// GetByID retrieves a workflow run by ID from memory
+func (r *MockRepository) GetByID(ctx context.Context, id string) (*models.WorkflowRun, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	run, ok := r.runs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, id)
+	}
+	return copyRun(run), nil
+}
+
+// GetByJobID retrieves the workflow run one of whose steps enqueued a job from memory
+func (r *MockRepository) GetByJobID(ctx context.Context, jobID string) (*models.WorkflowRun, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, run := range r.runs {
+		if run.StepByJobID(jobID) != nil {
+			return copyRun(run), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no step enqueued job %s", ErrWorkflowNotFound, jobID)
+}
+
+// ListRunning lists the IDs of the running workflow runs in memory, oldest first
+func (r *MockRepository) ListRunning(ctx context.Context) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var running []*models.WorkflowRun
+	for _, run := range r.runs {
+		if run.Status == models.WorkflowStatusRunning {
+			running = append(running, run)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool {
+		return running[i].CreatedAt.Before(running[j].CreatedAt)
+	})
+
+	ids := make([]string, len(running))
+	for i, run := range running {
+		ids[i] = run.ID
+	}
+	return ids, nil
+}
+
+// Modify passes a copy of a workflow run in memory to modify and stores it if
+// modify returns true. Modifications are applied one at a time.
+func (r *MockRepository) Modify(ctx context.Context, id string, modify func(run *models.WorkflowRun) bool) error {
+	r.modifyMu.Lock()
+	defer r.modifyMu.Unlock()
+
+	run, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !modify(run) {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs[id] = copyRun(run)
+	return nil
+}
+
+// copyRun copies a workflow run and its steps
+func copyRun(run *models.WorkflowRun) *models.WorkflowRun {
+	copied := *run
+	copied.Steps = make([]*models.WorkflowStep, len(run.Steps))
+	for i, step := range run.Steps {
+		stepCopy := *step
+		copied.Steps[i] = &stepCopy
+	}
+	return &copied
+}
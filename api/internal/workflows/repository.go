@@ -0,0 +1,27 @@
+package workflows
+
+import (
+	"context"
+
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+)
+
+// Repository defines the interface for workflow run storage
+type Repository interface {
+	// Create creates a new workflow run and its steps
+	Create(ctx context.Context, run *models.WorkflowRun) error
+
+	// GetByID retrieves a workflow run and its steps by ID
+	GetByID(ctx context.Context, id string) (*models.WorkflowRun, error)
+
+	// GetByJobID retrieves the workflow run one of whose steps enqueued a job
+	GetByJobID(ctx context.Context, jobID string) (*models.WorkflowRun, error)
+
+	// ListRunning lists the IDs of the running workflow runs, oldest first
+	ListRunning(ctx context.Context) ([]string, error)
+
+	// Modify loads a workflow run and its steps, locked against concurrent
+	// modification, and passes it to modify. The run and its steps are saved
+	// if modify returns true.
+	Modify(ctx context.Context, id string, modify func(run *models.WorkflowRun) bool) error
+}
@@ -0,0 +1,479 @@
+// Package workflows runs workflows: sets of named steps, each a job of a
+// registered type, that depend on each other. A step's job is enqueued once
+// every step it depends on has completed, so steps can fan out from and fan
+// in to other steps. Runs are stored in Postgres and advanced from the job
+// events published by the worker, and periodically reconciled with the job
+// history in case an event was missed.
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/internal/jobs"
+	"github.com/dustinleblanc/go-bespin-api/internal/jobtypes"
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+)
+
+// MaxSteps is the maximum number of steps in a workflow
+const MaxSteps = 100
+
+// WorkflowService defines the interface for workflow operations
+type WorkflowService interface {
+	CreateWorkflow(ctx context.Context, req *models.WorkflowRequest) (*models.WorkflowRun, error)
+	GetWorkflow(ctx context.Context, id string) (*models.WorkflowRun, error)
+	HandleJobEvent(ctx context.Context, event *events.JobEvent) error
+}
+
+// Ensure Service implements WorkflowService
+var _ WorkflowService = (*Service)(nil)
+
+// Enqueuer enqueues the jobs of workflow steps
+type Enqueuer interface {
+	// AddJob adds a job to the queue
+	AddJob(ctx context.Context, job *models.Job) (string, error)
+}
+
+// JobTypes validates step data and picks the default queue of step jobs
+type JobTypes interface {
+	// Validate checks data against the schema of a job type
+	Validate(name models.JobType, data []byte) error
+	// DefaultQueue returns the queue live workers advertise for a job type
+	DefaultQueue(ctx context.Context, name models.JobType) (string, error)
+}
+
+// JobHistory reads the recorded status of jobs
+type JobHistory interface {
+	// GetJob gets a job record by ID
+	GetJob(ctx context.Context, id string) (*models.JobRecord, error)
+}
+
+// Service starts workflow runs and advances them as their jobs finish
+type Service struct {
+	repo     Repository
+	enqueuer Enqueuer
+	jobTypes JobTypes
+	history  JobHistory
+	logger   *log.Logger
+}
+
+// NewService creates a new workflow service. Step jobs are enqueued with
+// enqueuer after their data is validated by jobTypes, and history is read for
+// jobs that finished before their step was saved.
+func NewService(repo Repository, enqueuer Enqueuer, jobTypes JobTypes, history JobHistory) *Service {
+	return &Service{
+		repo:     repo,
+		enqueuer: enqueuer,
+		jobTypes: jobTypes,
+		history:  history,
+		logger:   log.New(log.Writer(), "[WorkflowService] ", log.LstdFlags),
+	}
+}
+
+// CreateWorkflow validates and stores a workflow run and enqueues the jobs of
+// the steps without dependencies
+func (s *Service) CreateWorkflow(ctx context.Context, req *models.WorkflowRequest) (*models.WorkflowRun, error) {
+	if err := s.validate(ctx, req); err != nil {
+		return nil, err
+	}
+
+	run, err := models.NewWorkflowRun(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workflow run: %w", err)
+	}
+	if err := s.repo.Create(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to create workflow run: %w", err)
+	}
+
+	err = s.repo.Modify(ctx, run.ID, func(stored *models.WorkflowRun) bool {
+		s.advance(ctx, stored)
+		run = stored
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Printf("Started workflow run %s (%s) with %d steps", run.ID, run.Name, len(run.Steps))
+	return run, nil
+}
+
+// GetWorkflow gets a workflow run by ID
+func (s *Service) GetWorkflow(ctx context.Context, id string) (*models.WorkflowRun, error) {
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	run, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow run: %w", err)
+	}
+	return run, nil
+}
+
+// HandleJobEvent applies a job event to the step that enqueued the job, if
+// any, and enqueues the steps that became ready when a step finished. Events
+// of the same run are applied one at a time, even across API instances.
+func (s *Service) HandleJobEvent(ctx context.Context, event *events.JobEvent) error {
+	switch event.Kind {
+	case events.KindProcessing, events.KindRetrying, events.KindCompleted, events.KindFailed, events.KindCancelled:
+	default:
+		return nil
+	}
+
+	run, err := s.repo.GetByJobID(ctx, event.JobID)
+	if err != nil {
+		if errors.Is(err, ErrWorkflowNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get workflow run: %w", err)
+	}
+
+	return s.repo.Modify(ctx, run.ID, func(run *models.WorkflowRun) bool {
+		step := run.StepByJobID(event.JobID)
+		if step == nil || !applyEvent(step, event) {
+			return false
+		}
+		run.UpdatedAt = time.Now()
+		if step.Status.IsFinal() {
+			s.advance(ctx, run)
+		}
+		return true
+	})
+}
+
+// Reconcile checks every running workflow run against the job history. It
+// applies the outcome of step jobs whose final event was missed and advances
+// their runs, since HandleJobEvent only sees the events delivered to this
+// process.
+func (s *Service) Reconcile(ctx context.Context) error {
+	ids, err := s.repo.ListRunning(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		err := s.repo.Modify(ctx, id, func(run *models.WorkflowRun) bool {
+			if run.Status != models.WorkflowStatusRunning {
+				return false
+			}
+			var active []*models.WorkflowStep
+			for _, step := range run.Steps {
+				if step.JobID != "" && !step.Status.IsFinal() {
+					active = append(active, step)
+				}
+			}
+			caughtUp := s.catchUp(ctx, active)
+			return s.advance(ctx, run) || caughtUp
+		})
+		if err != nil {
+			s.logger.Printf("Failed to reconcile workflow run %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// applyEvent updates a step from an event of its job. It returns false if
+// the step has already finished.
+func applyEvent(step *models.WorkflowStep, event *events.JobEvent) bool {
+	if step.Status.IsFinal() {
+		return false
+	}
+
+	now := event.Timestamp
+	switch event.Kind {
+	case events.KindProcessing:
+		step.Status = models.WorkflowStepStatusProcessing
+		if step.StartedAt == nil {
+			step.StartedAt = &now
+		}
+	case events.KindRetrying:
+		step.Status = models.WorkflowStepStatusRetrying
+		step.Error = event.Error
+	case events.KindCompleted:
+		step.Status = models.WorkflowStepStatusCompleted
+		step.Result = models.JSON(event.Result)
+		step.Error = ""
+		step.CompletedAt = &now
+	case events.KindFailed:
+		step.Status = models.WorkflowStepStatusFailed
+		step.Error = event.Error
+		step.CompletedAt = &now
+	case events.KindCancelled:
+		step.Status = models.WorkflowStepStatusCancelled
+		step.CompletedAt = &now
+	}
+	return true
+}
+
+// advance enqueues the jobs of the steps whose dependencies have completed,
+// skips the steps whose dependencies did not, and finishes the run once
+// every step has finished. Jobs that finished before their step was saved
+// are caught up from the job history. It returns true if the run changed.
+func (s *Service) advance(ctx context.Context, run *models.WorkflowRun) bool {
+	changed := false
+	for {
+		enqueued, stepsChanged := s.enqueueReady(ctx, run)
+		finished := finish(run)
+		changed = changed || stepsChanged || finished
+
+		if !s.catchUp(ctx, enqueued) {
+			if changed {
+				run.UpdatedAt = time.Now()
+			}
+			return changed
+		}
+	}
+}
+
+// enqueueReady enqueues the jobs of every waiting step whose dependencies have
+// completed and returns those steps, and whether any step changed. Steps with
+// a dependency that did not complete are skipped, which may in turn skip the
+// steps depending on them.
+func (s *Service) enqueueReady(ctx context.Context, run *models.WorkflowRun) ([]*models.WorkflowStep, bool) {
+	var enqueued []*models.WorkflowStep
+	stepsChanged := false
+	for changed := true; changed; {
+		changed = false
+		for _, step := range run.Steps {
+			if step.Status != models.WorkflowStepStatusWaiting {
+				continue
+			}
+
+			ready, skip := true, false
+			for _, name := range step.Dependencies() {
+				switch dep := run.Step(name); dep.Status {
+				case models.WorkflowStepStatusCompleted:
+				case models.WorkflowStepStatusFailed, models.WorkflowStepStatusCancelled, models.WorkflowStepStatusSkipped:
+					skip = true
+				default:
+					ready = false
+				}
+			}
+
+			now := time.Now()
+			switch {
+			case skip:
+				step.Status = models.WorkflowStepStatusSkipped
+				step.CompletedAt = &now
+				changed = true
+			case ready:
+				jobID, err := s.enqueueStep(ctx, run, step)
+				if err != nil {
+					s.logger.Printf("Failed to enqueue step %s of workflow run %s: %v", step.Name, run.ID, err)
+					step.Status = models.WorkflowStepStatusFailed
+					step.Error = err.Error()
+					step.CompletedAt = &now
+					changed = true
+					continue
+				}
+				step.JobID = jobID
+				step.Status = models.WorkflowStepStatusPending
+				enqueued = append(enqueued, step)
+			}
+		}
+		stepsChanged = stepsChanged || changed
+	}
+	return enqueued, stepsChanged || len(enqueued) > 0
+}
+
+// enqueueStep enqueues the job of a step. The job is deduplicated by run and
+// step, so a step handled twice is only enqueued once.
+func (s *Service) enqueueStep(ctx context.Context, run *models.WorkflowRun, step *models.WorkflowStep) (string, error) {
+	job := &models.Job{Type: step.JobType, Data: json.RawMessage(step.Data)}
+	if len(step.Options) > 0 {
+		var options models.JobOptions
+		if err := json.Unmarshal(step.Options, &options); err != nil {
+			return "", fmt.Errorf("failed to read step options: %w", err)
+		}
+		if errs := options.Apply(job); len(errs) > 0 {
+			return "", fmt.Errorf("invalid step options: %s", errs[0].Message)
+		}
+	}
+	job.IdempotencyKey = "workflow:" + run.ID + ":" + step.Name
+
+	if job.Queue == "" {
+		queueName, err := s.jobTypes.DefaultQueue(ctx, job.Type)
+		if err != nil {
+			s.logger.Printf("Failed to look up the default queue of %s jobs: %v", job.Type, err)
+		}
+		job.Queue = queueName
+	}
+
+	return s.enqueuer.AddJob(ctx, job)
+}
+
+// catchUp applies the status recorded in the job history to steps whose job
+// may have finished before the step was saved. It returns true if a step
+// finished.
+func (s *Service) catchUp(ctx context.Context, steps []*models.WorkflowStep) bool {
+	finished := false
+	for _, step := range steps {
+		record, err := s.history.GetJob(ctx, step.JobID)
+		if err != nil {
+			if !errors.Is(err, jobs.ErrJobNotFound) {
+				s.logger.Printf("Failed to get job %s of step %s: %v", step.JobID, step.Name, err)
+			}
+			continue
+		}
+		if !record.Status.IsFinal() {
+			continue
+		}
+
+		step.Status = models.WorkflowStepStatus(record.Status)
+		step.Result = record.Result
+		step.Error = record.Error
+		step.CompletedAt = record.CompletedAt
+		finished = true
+	}
+	return finished
+}
+
+// finish sets the status of a run whose steps have all finished. It returns
+// true if it finished the run.
+func finish(run *models.WorkflowRun) bool {
+	if run.Status != models.WorkflowStatusRunning {
+		return false
+	}
+
+	status := models.WorkflowStatusCompleted
+	for _, step := range run.Steps {
+		if !step.Status.IsFinal() {
+			return false
+		}
+		if step.Status != models.WorkflowStepStatusCompleted {
+			status = models.WorkflowStatusFailed
+		}
+	}
+
+	now := time.Now()
+	run.Status = status
+	run.CompletedAt = &now
+	return true
+}
+
+// validate checks that a workflow request has uniquely named steps of
+// registered job types with valid data and options, and that their
+// dependencies exist and do not form a cycle
+func (s *Service) validate(ctx context.Context, req *models.WorkflowRequest) error {
+	var errs []models.FieldError
+	invalid := func(field, message string) {
+		errs = append(errs, models.FieldError{Field: field, Message: message})
+	}
+
+	switch {
+	case len(req.Steps) == 0:
+		invalid("steps", "must contain at least one step")
+	case len(req.Steps) > MaxSteps:
+		invalid("steps", fmt.Sprintf("must not contain more than %d steps", MaxSteps))
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+
+	names := make(map[string]int)
+	for i, step := range req.Steps {
+		field := fmt.Sprintf("steps[%d]", i)
+		switch _, duplicate := names[step.Name]; {
+		case step.Name == "":
+			invalid(field+".name", "is required")
+		case duplicate:
+			invalid(field+".name", "must be unique")
+		default:
+			names[step.Name] = i
+		}
+
+		if step.Type == "" {
+			invalid(field+".type", "is required")
+		} else {
+			data := step.Data
+			if len(data) == 0 {
+				data = json.RawMessage("{}")
+			}
+			if err := s.jobTypes.Validate(step.Type, data); err != nil {
+				var validationErr *jobtypes.ValidationError
+				switch {
+				case errors.Is(err, jobtypes.ErrUnknownJobType):
+					invalid(field+".type", "is not a registered job type")
+				case errors.As(err, &validationErr):
+					for _, fieldErr := range validationErr.Errors {
+						invalid(field+"."+fieldErr.Field, fieldErr.Message)
+					}
+				default:
+					return fmt.Errorf("failed to validate step %s: %w", step.Name, err)
+				}
+			}
+		}
+
+		options := step.Options
+		job := &models.Job{}
+		for _, fieldErr := range options.Apply(job) {
+			invalid(field+"."+fieldErr.Field, fieldErr.Message)
+		}
+		if job.ProcessAt != nil || job.ProcessIn != 0 {
+			invalid(field+".options", "workflow steps cannot be scheduled")
+		}
+		if job.IdempotencyKey != "" || job.UniqueFor != 0 {
+			invalid(field+".options", "workflow steps cannot be deduplicated")
+		}
+	}
+
+	for i, step := range req.Steps {
+		for _, dep := range step.DependsOn {
+			field := fmt.Sprintf("steps[%d].depends_on", i)
+			switch _, ok := names[dep]; {
+			case dep == step.Name:
+				invalid(field, "a step cannot depend on itself")
+			case !ok:
+				invalid(field, fmt.Sprintf("unknown step %q", dep))
+			}
+		}
+	}
+
+	if len(errs) == 0 && hasCycle(req.Steps) {
+		invalid("steps", "dependencies must not form a cycle")
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// hasCycle checks whether the dependencies between steps form a cycle by
+// repeatedly removing the steps whose dependencies have all been removed
+func hasCycle(steps []models.WorkflowStepRequest) bool {
+	remaining := make(map[string]int, len(steps))
+	dependents := make(map[string][]string)
+	for _, step := range steps {
+		remaining[step.Name] = len(step.DependsOn)
+		for _, dep := range step.DependsOn {
+			dependents[dep] = append(dependents[dep], step.Name)
+		}
+	}
+
+	var ready []string
+	for name, count := range remaining {
+		if count == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	removed := 0
+	for len(ready) > 0 {
+		name := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		removed++
+		for _, dependent := range dependents[name] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	return removed < len(steps)
+}
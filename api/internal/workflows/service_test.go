@@ -0,0 +1,306 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-api/internal/jobs"
+	"github.com/dustinleblanc/go-bespin-api/internal/jobtypes"
+	"github.com/dustinleblanc/go-bespin-api/pkg/models"
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEnqueuer enqueues jobs by handing out an ID per step
+type fakeEnqueuer struct {
+	jobs map[string]*models.Job
+	err  error
+	mu   sync.Mutex
+}
+
+func (e *fakeEnqueuer) AddJob(ctx context.Context, job *models.Job) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return "", e.err
+	}
+	if e.jobs == nil {
+		e.jobs = make(map[string]*models.Job)
+	}
+	id := "job-" + job.IdempotencyKey
+	e.jobs[id] = job
+	return id, nil
+}
+
+// slowRepository widens the window between loading and saving a run, so
+// modifications that are not serialized would overwrite each other
+type slowRepository struct {
+	*MockRepository
+}
+
+func (r slowRepository) Modify(ctx context.Context, id string, modify func(run *models.WorkflowRun) bool) error {
+	return r.MockRepository.Modify(ctx, id, func(run *models.WorkflowRun) bool {
+		time.Sleep(10 * time.Millisecond)
+		return modify(run)
+	})
+}
+
+func newTestService(t *testing.T) (*Service, *fakeEnqueuer, *jobs.Service) {
+	registry, err := jobtypes.NewContractRegistry(nil)
+	require.NoError(t, err)
+	enqueuer := &fakeEnqueuer{}
+	history := jobs.NewService(jobs.NewMockRepository())
+	return NewService(NewMockRepository(), enqueuer, registry, history), enqueuer, history
+}
+
+// diamond is a workflow fanning out from fetch to two steps and back in to notify
+func diamond() *models.WorkflowRequest {
+	return &models.WorkflowRequest{
+		Name: "diamond",
+		Steps: []models.WorkflowStepRequest{
+			{Name: "fetch", Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":10}`)},
+			{Name: "left", Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":20}`), DependsOn: []string{"fetch"}},
+			{Name: "right", Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":30}`), DependsOn: []string{"fetch"}, Options: models.JobOptions{Queue: "low"}},
+			{Name: "notify", Type: models.JobTypeProcessWebhook, Data: json.RawMessage(`{"receipt_id":"r-1"}`), DependsOn: []string{"left", "right"}},
+		},
+	}
+}
+
+func TestCreateWorkflowValidation(t *testing.T) {
+	service, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		req        *models.WorkflowRequest
+		wantFields []string
+	}{
+		{name: "no steps", req: &models.WorkflowRequest{}, wantFields: []string{"steps"}},
+		{
+			name: "invalid steps",
+			req: &models.WorkflowRequest{Steps: []models.WorkflowStepRequest{
+				{Name: "a", Type: "resize_image"},
+				{Name: "a", Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":0}`)},
+				{Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":1}`), Options: models.JobOptions{ProcessIn: "5m"}},
+				{Name: "d", Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":1}`), DependsOn: []string{"d", "missing"}},
+			}},
+			wantFields: []string{
+				"steps[0].type",
+				"steps[1].name", "steps[1].data.length",
+				"steps[2].name", "steps[2].options",
+				"steps[3].depends_on", "steps[3].depends_on",
+			},
+		},
+		{
+			name: "cycle",
+			req: &models.WorkflowRequest{Steps: []models.WorkflowStepRequest{
+				{Name: "a", Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":1}`), DependsOn: []string{"c"}},
+				{Name: "b", Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":1}`), DependsOn: []string{"a"}},
+				{Name: "c", Type: models.JobTypeRandomText, Data: json.RawMessage(`{"length":1}`), DependsOn: []string{"b"}},
+			}},
+			wantFields: []string{"steps"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateWorkflow(ctx, tt.req)
+			assert.ErrorIs(t, err, ErrInvalidWorkflow)
+
+			var validationErr *ValidationError
+			require.True(t, errors.As(err, &validationErr))
+			var fields []string
+			for _, fieldErr := range validationErr.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			assert.ElementsMatch(t, tt.wantFields, fields)
+		})
+	}
+}
+
+func TestWorkflowRun(t *testing.T) {
+	ctx := context.Background()
+
+	complete := func(t *testing.T, service *Service, jobID string) {
+		event := events.NewJobEvent(events.KindCompleted, jobID, "")
+		event.Result = json.RawMessage(`{"ok":true}`)
+		require.NoError(t, service.HandleJobEvent(ctx, event))
+	}
+	statuses := func(t *testing.T, service *Service, id string) map[string]models.WorkflowStepStatus {
+		run, err := service.GetWorkflow(ctx, id)
+		require.NoError(t, err)
+		result := make(map[string]models.WorkflowStepStatus)
+		for _, step := range run.Steps {
+			result[step.Name] = step.Status
+		}
+		return result
+	}
+
+	t.Run("fan out and fan in", func(t *testing.T) {
+		service, enqueuer, _ := newTestService(t)
+
+		run, err := service.CreateWorkflow(ctx, diamond())
+		require.NoError(t, err)
+		assert.Equal(t, models.WorkflowStatusRunning, run.Status)
+		assert.Len(t, enqueuer.jobs, 1)
+		fetch := run.Step("fetch")
+		assert.Equal(t, models.WorkflowStepStatusPending, fetch.Status)
+		assert.Equal(t, "workflow:"+run.ID+":fetch", enqueuer.jobs[fetch.JobID].IdempotencyKey)
+
+		// Processing events update the step without enqueueing anything
+		require.NoError(t, service.HandleJobEvent(ctx, events.NewJobEvent(events.KindProcessing, fetch.JobID, "")))
+		assert.Equal(t, models.WorkflowStepStatusProcessing, statuses(t, service, run.ID)["fetch"])
+
+		// Completing fetch fans out to left and right
+		complete(t, service, fetch.JobID)
+		run, err = service.GetWorkflow(ctx, run.ID)
+		require.NoError(t, err)
+		assert.Len(t, enqueuer.jobs, 3)
+		assert.JSONEq(t, `{"ok":true}`, string(run.Step("fetch").Result))
+		assert.Equal(t, "low", enqueuer.jobs[run.Step("right").JobID].Queue)
+		assert.Equal(t, models.WorkflowStepStatusWaiting, run.Step("notify").Status)
+
+		// notify waits for both
+		complete(t, service, run.Step("left").JobID)
+		assert.Equal(t, models.WorkflowStepStatusWaiting, statuses(t, service, run.ID)["notify"])
+		complete(t, service, run.Step("right").JobID)
+
+		run, err = service.GetWorkflow(ctx, run.ID)
+		require.NoError(t, err)
+		require.NotEmpty(t, run.Step("notify").JobID)
+		assert.Equal(t, models.JobTypeProcessWebhook, enqueuer.jobs[run.Step("notify").JobID].Type)
+
+		complete(t, service, run.Step("notify").JobID)
+		run, err = service.GetWorkflow(ctx, run.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.WorkflowStatusCompleted, run.Status)
+		assert.NotNil(t, run.CompletedAt)
+	})
+
+	t.Run("failed step skips its dependents", func(t *testing.T) {
+		service, _, _ := newTestService(t)
+
+		run, err := service.CreateWorkflow(ctx, diamond())
+		require.NoError(t, err)
+		complete(t, service, run.Step("fetch").JobID)
+
+		run, err = service.GetWorkflow(ctx, run.ID)
+		require.NoError(t, err)
+		failed := events.NewJobEvent(events.KindFailed, run.Step("left").JobID, "")
+		failed.Error = "boom"
+		require.NoError(t, service.HandleJobEvent(ctx, failed))
+
+		// The run keeps going until right finishes
+		run, err = service.GetWorkflow(ctx, run.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.WorkflowStatusRunning, run.Status)
+		assert.Equal(t, models.WorkflowStepStatusSkipped, run.Step("notify").Status)
+		assert.Equal(t, "boom", run.Step("left").Error)
+
+		complete(t, service, run.Step("right").JobID)
+		run, err = service.GetWorkflow(ctx, run.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.WorkflowStatusFailed, run.Status)
+		assert.Equal(t, models.WorkflowStepStatusCompleted, run.Step("right").Status)
+	})
+
+	t.Run("job finished before its step was saved", func(t *testing.T) {
+		service, _, history := newTestService(t)
+
+		// The job history already holds the completed job of the first step
+		run, err := models.NewWorkflowRun(&models.WorkflowRequest{})
+		require.NoError(t, err)
+		require.NoError(t, history.RecordJob(ctx, &models.JobRecord{
+			ID:     "job-workflow:" + run.ID + ":only",
+			Status: models.JobStatusCompleted,
+			Result: models.JSON(`{"ok":true}`),
+		}))
+		run.Steps = []*models.WorkflowStep{{ID: "step-1", RunID: run.ID, Name: "only", JobType: models.JobTypeRandomText, Data: models.JSON(`{"length":1}`), Status: models.WorkflowStepStatusWaiting}}
+		require.NoError(t, service.repo.Create(ctx, run))
+		require.NoError(t, service.repo.Modify(ctx, run.ID, func(run *models.WorkflowRun) bool {
+			return service.advance(ctx, run)
+		}))
+
+		run, err = service.GetWorkflow(ctx, run.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.WorkflowStatusCompleted, run.Status)
+		assert.JSONEq(t, `{"ok":true}`, string(run.Step("only").Result))
+	})
+
+	t.Run("concurrent events of a run are all applied", func(t *testing.T) {
+		service, enqueuer, _ := newTestService(t)
+		service.repo = slowRepository{service.repo.(*MockRepository)}
+
+		run, err := service.CreateWorkflow(ctx, diamond())
+		require.NoError(t, err)
+		complete(t, service, run.Step("fetch").JobID)
+		run, err = service.GetWorkflow(ctx, run.ID)
+		require.NoError(t, err)
+
+		// left and right finish at the same time; neither update may be lost
+		var wg sync.WaitGroup
+		for _, name := range []string{"left", "right"} {
+			wg.Add(1)
+			go func(jobID string) {
+				defer wg.Done()
+				event := events.NewJobEvent(events.KindCompleted, jobID, "")
+				assert.NoError(t, service.HandleJobEvent(ctx, event))
+			}(run.Step(name).JobID)
+		}
+		wg.Wait()
+
+		run, err = service.GetWorkflow(ctx, run.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.WorkflowStepStatusCompleted, run.Step("left").Status)
+		assert.Equal(t, models.WorkflowStepStatusCompleted, run.Step("right").Status)
+		assert.Equal(t, models.WorkflowStepStatusPending, run.Step("notify").Status)
+		assert.Len(t, enqueuer.jobs, 4)
+	})
+
+	t.Run("missed events are reconciled from the job history", func(t *testing.T) {
+		service, _, history := newTestService(t)
+
+		run, err := service.CreateWorkflow(ctx, diamond())
+		require.NoError(t, err)
+
+		// Nothing to reconcile while the job is still pending
+		require.NoError(t, service.Reconcile(ctx))
+		assert.Equal(t, models.WorkflowStepStatusPending, statuses(t, service, run.ID)["fetch"])
+
+		// Only the job history sees the job complete
+		fetchID := run.Step("fetch").JobID
+		require.NoError(t, history.RecordJob(ctx, &models.JobRecord{ID: fetchID, Status: models.JobStatusPending}))
+		completed := events.NewJobEvent(events.KindCompleted, fetchID, "")
+		completed.Result = json.RawMessage(`{"ok":true}`)
+		require.NoError(t, history.HandleJobEvent(ctx, completed))
+
+		require.NoError(t, service.Reconcile(ctx))
+		run, err = service.GetWorkflow(ctx, run.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.WorkflowStepStatusCompleted, run.Step("fetch").Status)
+		assert.JSONEq(t, `{"ok":true}`, string(run.Step("fetch").Result))
+		assert.Equal(t, models.WorkflowStepStatusPending, run.Step("left").Status)
+		assert.Equal(t, models.WorkflowStepStatusPending, run.Step("right").Status)
+	})
+
+	t.Run("events of other jobs are ignored", func(t *testing.T) {
+		service, _, _ := newTestService(t)
+		assert.NoError(t, service.HandleJobEvent(ctx, events.NewJobEvent(events.KindCompleted, "unrelated", "")))
+	})
+
+	t.Run("enqueue failure fails the step", func(t *testing.T) {
+		service, enqueuer, _ := newTestService(t)
+		enqueuer.err = errors.New("redis unavailable")
+
+		run, err := service.CreateWorkflow(ctx, diamond())
+		require.NoError(t, err)
+		assert.Equal(t, models.WorkflowStatusFailed, run.Status)
+		assert.Equal(t, models.WorkflowStepStatusFailed, run.Step("fetch").Status)
+		assert.Equal(t, models.WorkflowStepStatusSkipped, run.Step("notify").Status)
+	})
+}
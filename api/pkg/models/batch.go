@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BatchStatus represents the status of a batch
+type BatchStatus string
+
+const (
+	// BatchStatusSubmitting indicates the jobs of the batch are being enqueued
+	BatchStatusSubmitting BatchStatus = "submitting"
+	// BatchStatusRunning indicates some jobs of the batch have not finished
+	BatchStatusRunning BatchStatus = "running"
+	// BatchStatusCompleted indicates every job of the batch has finished,
+	// whether it completed, failed or was cancelled
+	BatchStatusCompleted BatchStatus = "completed"
+)
+
+// Batch represents a group of jobs submitted together. Its counts are read
+// from the job history rather than stored with the batch.
+type Batch struct {
+	ID     string      `json:"id" gorm:"primaryKey"`
+	Name   string      `json:"name,omitempty" gorm:"index"`
+	Status BatchStatus `json:"status" gorm:"index"`
+	Total  int         `json:"total"`
+	Counts BatchCounts `json:"counts" gorm:"-"`
+	// JobIDs are the IDs of the jobs of the batch, in the order they were submitted
+	JobIDs []string `json:"job_ids,omitempty" gorm:"-"`
+	// Callback is the JobRequest of the job enqueued once every job of the
+	// batch has finished
+	Callback      JSON       `json:"callback,omitempty" gorm:"type:jsonb"`
+	CallbackJobID string     `json:"callback_job_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+}
+
+// TableName overrides the table name used by GORM
+func (Batch) TableName() string {
+	return "batches"
+}
+
+// NewBatch creates a new running batch of total jobs
+func NewBatch(name string, total int) *Batch {
+	now := time.Now()
+	return &Batch{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Status:    BatchStatusRunning,
+		Total:     total,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// BatchCounts counts the jobs of a batch by status. Scheduled jobs count as
+// pending and retrying jobs as processing.
+type BatchCounts struct {
+	Pending    int `json:"pending"`
+	Processing int `json:"processing"`
+	Completed  int `json:"completed"`
+	Failed     int `json:"failed"`
+	Cancelled  int `json:"cancelled"`
+}
+
+// NewBatchCounts aggregates job counts by status into batch counts
+func NewBatchCounts(counts map[JobStatus]int) BatchCounts {
+	return BatchCounts{
+		Pending:    counts[JobStatusPending] + counts[JobStatusScheduled],
+		Processing: counts[JobStatusProcessing] + counts[JobStatusRetrying],
+		Completed:  counts[JobStatusCompleted],
+		Failed:     counts[JobStatusFailed],
+		Cancelled:  counts[JobStatusCancelled],
+	}
+}
+
+// Finished returns how many jobs of the batch have finished
+func (c BatchCounts) Finished() int {
+	return c.Completed + c.Failed + c.Cancelled
+}
+
+// BatchRequest represents the request to submit a batch of jobs. Callback is
+// enqueued once every job has finished; string values of its data may
+// reference the batch with templates such as {{ parent.result.failed }}.
+type BatchRequest struct {
+	Name     string       `json:"name"`
+	Jobs     []JobRequest `json:"jobs"`
+	Callback *JobRequest  `json:"callback"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DeadLetter represents a task asynq archived after it ran out of retries
+type DeadLetter struct {
+	ID        string          `json:"id"`
+	Queue     string          `json:"queue"`
+	Type      JobType         `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	LastError string          `json:"last_error"`
+	Retried   int             `json:"retried"`
+	MaxRetry  int             `json:"max_retry"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+// DeadLetterPage represents a page of dead letters
+type DeadLetterPage struct {
+	DeadLetters []*DeadLetter `json:"dead_letters"`
+	Total       int           `json:"total"`
+	Page        int           `json:"page"`
+	PageSize    int           `json:"page_size"`
+}
+
+// ReplayResult represents the outcome of replaying one dead letter
+type ReplayResult struct {
+	ID    string `json:"id"`
+	JobID string `json:"job_id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
@@ -1,7 +1,12 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
 )
 
 // JobType represents the type of job
@@ -9,17 +14,27 @@ type JobType string
 
 const (
 	// JobTypeRandomText represents a random text generation job
-	JobTypeRandomText JobType = "random_text"
+	JobTypeRandomText JobType = tasks.TypeRandomText
 	// JobTypeProcessWebhook represents a webhook processing job
-	JobTypeProcessWebhook JobType = "process_webhook"
+	JobTypeProcessWebhook JobType = tasks.TypeProcessWebhook
 )
 
+// JobTypes returns every job type the API can enqueue
+func JobTypes() []JobType {
+	return []JobType{
+		JobTypeRandomText,
+		JobTypeProcessWebhook,
+	}
+}
+
 // JobStatus represents the status of a job
 type JobStatus string
 
 const (
 	// JobStatusPending indicates the job is pending execution
 	JobStatusPending JobStatus = "pending"
+	// JobStatusScheduled indicates the job is waiting for its scheduled time
+	JobStatusScheduled JobStatus = "scheduled"
 	// JobStatusProcessing indicates the job is being processed
 	JobStatusProcessing JobStatus = "processing"
 	// JobStatusCompleted indicates the job has completed successfully
@@ -28,34 +43,202 @@ const (
 	JobStatusFailed JobStatus = "failed"
 	// JobStatusRetrying indicates the job is being retried
 	JobStatusRetrying JobStatus = "retrying"
+	// JobStatusCancelled indicates the job was cancelled
+	JobStatusCancelled JobStatus = "cancelled"
 )
 
 // Job represents a job to be processed
 type Job struct {
 	Type JobType     `json:"type"`
 	Data interface{} `json:"data"`
+	// Queue is the priority queue to enqueue the job on; empty means the default queue
+	Queue string `json:"queue,omitempty"`
+	// ProcessAt schedules the job to run at a specific time
+	ProcessAt *time.Time `json:"process_at,omitempty"`
+	// ProcessIn schedules the job to run after a delay. It is not encoded,
+	// since a time.Duration would be encoded as nanoseconds; requests give it
+	// as a duration string in JobOptions.
+	ProcessIn time.Duration `json:"-"`
+	// MaxRetries overrides the number of times a failed job is retried
+	MaxRetries *int `json:"max_retries,omitempty"`
+	// Timeout overrides how long a single attempt may run. Like ProcessIn it
+	// is not encoded.
+	Timeout time.Duration `json:"-"`
+	// Deadline is the time after which the job must not run anymore
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Backoff overrides the delay between retries
+	Backoff *tasks.Backoff `json:"backoff,omitempty"`
+	// IdempotencyKey makes enqueueing the same job twice return the first job's ID
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// UniqueFor deduplicates jobs with the same type, queue and payload for
+	// this long; with an IdempotencyKey it sets how long the key is
+	// remembered. Like ProcessIn it is not encoded.
+	UniqueFor time.Duration `json:"-"`
+	// OnSuccess is enqueued by the worker when the job completes. String
+	// values of its data may reference the job with templates such as
+	// {{ parent.result.text }}.
+	OnSuccess *Job `json:"on_success,omitempty"`
+	// OnFailure is enqueued by the worker when the job fails for good
+	OnFailure *Job `json:"on_failure,omitempty"`
+	// BatchID is the batch the job was submitted in, if any
+	BatchID string `json:"batch_id,omitempty"`
+}
+
+// RetryPolicy returns the retry options set on the job, or nil if the job
+// uses the worker's defaults for its type
+func (j *Job) RetryPolicy() *tasks.RetryPolicy {
+	policy := &tasks.RetryPolicy{
+		MaxRetries: j.MaxRetries,
+		Timeout:    j.Timeout,
+		Deadline:   j.Deadline,
+		Backoff:    j.Backoff,
+	}
+	if policy.IsZero() {
+		return nil
+	}
+	return policy
+}
+
+// Chain returns the follow-up jobs of the job, or nil if it has none.
+// Follow-up jobs keep their type, data, queue and retry options.
+func (j *Job) Chain() (*tasks.Chain, error) {
+	if j.OnSuccess == nil && j.OnFailure == nil {
+		return nil, nil
+	}
+
+	chain := &tasks.Chain{}
+	var err error
+	if chain.OnSuccess, err = j.OnSuccess.followUp(); err != nil {
+		return nil, err
+	}
+	if chain.OnFailure, err = j.OnFailure.followUp(); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// followUp converts the job to a follow-up job of the contract
+func (j *Job) followUp() (*tasks.FollowUp, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	payload, err := json.Marshal(j.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode follow-up job data: %w", err)
+	}
+
+	chain, err := j.Chain()
+	if err != nil {
+		return nil, err
+	}
+	followUp := &tasks.FollowUp{
+		Type:        string(j.Type),
+		Payload:     payload,
+		Queue:       j.Queue,
+		RetryPolicy: j.RetryPolicy(),
+	}
+	if chain != nil {
+		followUp.OnSuccess = chain.OnSuccess
+		followUp.OnFailure = chain.OnFailure
+	}
+	return followUp, nil
+}
+
+// JobLink identifies a job related to another job
+type JobLink struct {
+	ID     string    `json:"id"`
+	Type   JobType   `json:"type"`
+	Status JobStatus `json:"status"`
 }
 
 // JobResult represents the result of a job
 type JobResult struct {
-	ID          string     `json:"id"`
-	Status      JobStatus  `json:"status"`
-	Result      string     `json:"result,omitempty"`
+	ID          string       `json:"id"`
+	Status      JobStatus    `json:"status"`
+	Queue       string       `json:"queue,omitempty"`
+	ParentID    string       `json:"parent_id,omitempty"`
+	Children    []JobLink    `json:"children,omitempty"`
+	Result      interface{}  `json:"result,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Progress    *JobProgress `json:"progress,omitempty"`
+	Attempts    int          `json:"attempts"`
+	NextRetryAt *time.Time   `json:"next_retry_at,omitempty"`
+	ScheduledAt *time.Time   `json:"scheduled_at,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+}
+
+// IsFinal returns true if no further status changes are expected for the job
+func (s JobStatus) IsFinal() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed || s == JobStatusCancelled
+}
+
+// JobRecord represents a job persisted in the jobs table. It outlives the task
+// in Redis so job history can be audited after the result retention expires.
+type JobRecord struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	Type        JobType    `json:"type" gorm:"index"`
+	Status      JobStatus  `json:"status" gorm:"index"`
+	Queue       string     `json:"queue" gorm:"index"`
+	ParentID    string     `json:"parent_id,omitempty" gorm:"index"`
+	BatchID     string     `json:"batch_id,omitempty" gorm:"index"`
+	Payload     JSON       `json:"payload,omitempty" gorm:"type:jsonb"`
+	Result      JSON       `json:"result,omitempty" gorm:"type:jsonb"`
 	Error       string     `json:"error,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
+	Progress    JSON       `json:"progress,omitempty" gorm:"type:jsonb"`
+	Attempts    int        `json:"attempts"`
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
 
-// RandomTextJobData represents the data for a random text generation job
-type RandomTextJobData struct {
-	Length int `json:"length"`
+// TableName overrides the table name used by GORM
+func (JobRecord) TableName() string {
+	return "jobs"
 }
 
-// WebhookJobData represents the data for a webhook processing job
-type WebhookJobData struct {
-	ReceiptID string `json:"receipt_id"`
+// ToResult converts the record to a job result
+func (r *JobRecord) ToResult() *JobResult {
+	result := &JobResult{
+		ID:          r.ID,
+		Status:      r.Status,
+		Queue:       r.Queue,
+		ParentID:    r.ParentID,
+		Error:       r.Error,
+		Attempts:    r.Attempts,
+		NextRetryAt: r.NextRetryAt,
+		ScheduledAt: r.ScheduledAt,
+		CreatedAt:   r.CreatedAt,
+		CompletedAt: r.CompletedAt,
+	}
+
+	if len(r.Result) > 0 {
+		result.Result = json.RawMessage(r.Result)
+	}
+
+	if len(r.Progress) > 0 {
+		var progress JobProgress
+		if err := json.Unmarshal(r.Progress, &progress); err == nil {
+			result.Progress = &progress
+		}
+	}
+
+	return result
 }
 
+// JobProgress represents the latest progress reported by a running job
+type JobProgress = events.Progress
+
+// RandomTextJobData represents the data for a random text generation job
+type RandomTextJobData = tasks.RandomTextPayload
+
+// WebhookJobData represents the data for a webhook processing job
+type WebhookJobData = tasks.WebhookPayload
+
 // JobResponse represents the response when creating a job
 type JobResponse struct {
 	JobID string `json:"jobId"`
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+)
+
+// JobRequest represents a request to submit a job of any registered type.
+// OnSuccess and OnFailure are follow-up jobs enqueued when the job completes
+// or fails for good. Batches also store their callback job as a JobRequest,
+// since Job does not encode its durations.
+type JobRequest struct {
+	Type      JobType         `json:"type"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Options   JobOptions      `json:"options"`
+	OnSuccess *JobRequest     `json:"on_success,omitempty"`
+	OnFailure *JobRequest     `json:"on_failure,omitempty"`
+}
+
+// JobOptions represents the optional settings of a submitted job. Durations
+// are Go duration strings such as 30s or 5m and times are RFC 3339 timestamps.
+type JobOptions struct {
+	Queue          string          `json:"queue,omitempty"`
+	ProcessAt      *time.Time      `json:"process_at,omitempty"`
+	ProcessIn      string          `json:"process_in,omitempty"`
+	MaxRetries     *int            `json:"max_retries,omitempty"`
+	Timeout        string          `json:"timeout,omitempty"`
+	Deadline       *time.Time      `json:"deadline,omitempty"`
+	Backoff        *BackoffOptions `json:"backoff,omitempty"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty"`
+	UniqueFor      string          `json:"unique_for,omitempty"`
+}
+
+// BackoffOptions represents the delay between retries of a submitted job
+type BackoffOptions struct {
+	Strategy string `json:"strategy"`
+	Delay    string `json:"delay"`
+	MaxDelay string `json:"max_delay,omitempty"`
+}
+
+// NewJobRequest returns the request that submits job along with its
+// follow-up jobs, with its durations as duration strings
+func NewJobRequest(job *Job) (*JobRequest, error) {
+	if job == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(job.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job data: %w", err)
+	}
+	req := &JobRequest{
+		Type: job.Type,
+		Data: data,
+		Options: JobOptions{
+			Queue:          job.Queue,
+			ProcessAt:      job.ProcessAt,
+			ProcessIn:      formatDuration(job.ProcessIn),
+			MaxRetries:     job.MaxRetries,
+			Timeout:        formatDuration(job.Timeout),
+			Deadline:       job.Deadline,
+			IdempotencyKey: job.IdempotencyKey,
+			UniqueFor:      formatDuration(job.UniqueFor),
+		},
+	}
+	if job.Backoff != nil {
+		req.Options.Backoff = &BackoffOptions{
+			Strategy: string(job.Backoff.Strategy),
+			Delay:    job.Backoff.Delay.String(),
+			MaxDelay: formatDuration(job.Backoff.MaxDelay),
+		}
+	}
+
+	if req.OnSuccess, err = NewJobRequest(job.OnSuccess); err != nil {
+		return nil, err
+	}
+	if req.OnFailure, err = NewJobRequest(job.OnFailure); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// formatDuration formats a duration as a duration string, or returns an
+// empty string for zero
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// Job builds the job the request submits along with its follow-up jobs and
+// returns an error for every option that is invalid. Unlike the handlers,
+// it does not validate the data against the schema of the job type.
+func (r *JobRequest) Job() (*Job, []FieldError) {
+	job := &Job{Type: r.Type, Data: r.Data}
+	errs := r.Options.Apply(job)
+
+	followUps := []struct {
+		field string
+		req   *JobRequest
+		job   **Job
+	}{
+		{"on_success.", r.OnSuccess, &job.OnSuccess},
+		{"on_failure.", r.OnFailure, &job.OnFailure},
+	}
+	for _, next := range followUps {
+		if next.req == nil {
+			continue
+		}
+		followUp, followUpErrs := next.req.Job()
+		for _, err := range followUpErrs {
+			errs = append(errs, FieldError{Field: next.field + err.Field, Message: err.Message})
+		}
+		*next.job = followUp
+	}
+	return job, errs
+}
+
+// FieldError describes why a field of a request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Apply copies the options onto job and returns an error for every option
+// that is invalid. Fields are named after their path in the request body.
+func (o *JobOptions) Apply(job *Job) []FieldError {
+	var errs []FieldError
+	invalid := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: "options." + field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if o.Queue != "" && !tasks.IsKnownQueue(o.Queue) {
+		invalid("queue", "must be one of %v", tasks.Queues())
+	}
+	job.Queue = o.Queue
+
+	if o.ProcessAt != nil && o.ProcessIn != "" {
+		invalid("process_in", "cannot be combined with process_at")
+	}
+	job.ProcessAt = o.ProcessAt
+	if o.ProcessIn != "" {
+		d, err := time.ParseDuration(o.ProcessIn)
+		if err != nil || d < 0 {
+			invalid("process_in", "must be a non-negative duration such as 30s or 5m")
+		}
+		job.ProcessIn = d
+	}
+
+	if o.MaxRetries != nil && *o.MaxRetries < 0 {
+		invalid("max_retries", "must be a non-negative integer")
+	}
+	job.MaxRetries = o.MaxRetries
+
+	if o.Timeout != "" {
+		d, err := time.ParseDuration(o.Timeout)
+		if err != nil || d <= 0 {
+			invalid("timeout", "must be a positive duration such as 30s or 5m")
+		}
+		job.Timeout = d
+	}
+	job.Deadline = o.Deadline
+
+	if o.Backoff != nil {
+		backoff := &tasks.Backoff{Strategy: tasks.BackoffStrategy(o.Backoff.Strategy)}
+		delay, delayErr := time.ParseDuration(o.Backoff.Delay)
+		if delayErr != nil {
+			invalid("backoff.delay", "must be a duration such as 10s")
+		}
+		backoff.Delay = delay
+		if o.Backoff.MaxDelay != "" {
+			maxDelay, err := time.ParseDuration(o.Backoff.MaxDelay)
+			if err != nil {
+				invalid("backoff.max_delay", "must be a duration such as 10m")
+			}
+			backoff.MaxDelay = maxDelay
+		}
+		if delayErr == nil {
+			if err := backoff.Validate(); err != nil {
+				invalid("backoff", "%v", err)
+			}
+		}
+		job.Backoff = backoff
+	}
+
+	job.IdempotencyKey = o.IdempotencyKey
+	if o.UniqueFor != "" {
+		d, err := time.ParseDuration(o.UniqueFor)
+		if err != nil || d <= 0 {
+			invalid("unique_for", "must be a positive duration such as 10m")
+		}
+		job.UniqueFor = d
+	}
+
+	return errs
+}
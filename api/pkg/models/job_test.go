@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestJobTypesMatchContract fails when the API drifts from the job contract
+func TestJobTypesMatchContract(t *testing.T) {
+	apiTypes := make(map[string]bool)
+	for _, jobType := range JobTypes() {
+		apiTypes[string(jobType)] = true
+		assert.True(t, tasks.IsKnownType(string(jobType)), "job type %q is not in the contract", jobType)
+	}
+
+	for _, taskType := range tasks.Types() {
+		assert.True(t, apiTypes[taskType], "contract task type %q has no API job type", taskType)
+	}
+}
+
+func TestJobEncodingOmitsDurations(t *testing.T) {
+	data, err := json.Marshal(&Job{Type: JobTypeRandomText, ProcessIn: 5 * time.Minute, Timeout: 5 * time.Minute, UniqueFor: 5 * time.Minute})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "process_in")
+	assert.NotContains(t, string(data), "timeout")
+	assert.NotContains(t, string(data), "unique_for")
+	assert.NotContains(t, string(data), "300000000000")
+}
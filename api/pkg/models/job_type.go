@@ -0,0 +1,20 @@
+package models
+
+import "encoding/json"
+
+// JobTypeStatus describes a job type and the live workers that can run it
+type JobTypeStatus struct {
+	Name        JobType         `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	// DefaultQueue is the queue advertised by the workers for the job type
+	DefaultQueue string `json:"default_queue,omitempty"`
+	// Versions lists the contract versions of the live workers' handlers
+	Versions []string `json:"versions"`
+	// Workers is the number of live workers with a handler for the job type
+	Workers int `json:"workers"`
+	// Available is false when no live worker can run the job type
+	Available bool `json:"available"`
+	// Accepted is false when workers handle the job type but the API cannot submit it
+	Accepted bool `json:"accepted"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// QueueStats represents a snapshot of a queue's size and throughput
+type QueueStats struct {
+	Queue          string    `json:"queue"`
+	Size           int       `json:"size"`
+	Pending        int       `json:"pending"`
+	Active         int       `json:"active"`
+	Scheduled      int       `json:"scheduled"`
+	Retry          int       `json:"retry"`
+	Archived       int       `json:"archived"`
+	Completed      int       `json:"completed"`
+	LatencyMs      int64     `json:"latency_ms"`
+	Processed      int       `json:"processed"`
+	Failed         int       `json:"failed"`
+	ProcessedTotal int       `json:"processed_total"`
+	FailedTotal    int       `json:"failed_total"`
+	MemoryUsage    int64     `json:"memory_usage_bytes"`
+	Paused         bool      `json:"paused"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// DrainResult represents the number of waiting jobs removed from a queue
+type DrainResult struct {
+	Queue     string `json:"queue"`
+	Pending   int    `json:"pending"`
+	Scheduled int    `json:"scheduled"`
+	Retry     int    `json:"retry"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schedule represents a recurring job enqueued on a cron schedule
+type Schedule struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"uniqueIndex"`
+	Cron      string    `json:"cron"`
+	JobType   JobType   `json:"job_type" gorm:"index"`
+	Payload   JSON      `json:"payload" gorm:"type:jsonb"`
+	Queue     string    `json:"queue"`
+	Enabled   bool      `json:"enabled" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ScheduleRequest represents the request to create or update a schedule
+type ScheduleRequest struct {
+	Name    string          `json:"name"`
+	Cron    string          `json:"cron"`
+	JobType JobType         `json:"job_type"`
+	Payload json.RawMessage `json:"payload"`
+	Queue   string          `json:"queue"`
+	Enabled *bool           `json:"enabled"`
+}
+
+// NewSchedule creates a new enabled schedule
+func NewSchedule(name, cron string, jobType JobType, payload []byte, queue string) *Schedule {
+	return &Schedule{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Cron:      cron,
+		JobType:   jobType,
+		Payload:   payload,
+		Queue:     queue,
+		Enabled:   true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
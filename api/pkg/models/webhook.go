@@ -2,38 +2,87 @@ package models
 
 import (
 	"encoding/json"
+	"net/http"
 	"time"
 
+	"github.com/dustinleblanc/go-bespin-contract/webhooks"
 	"github.com/google/uuid"
 )
 
-// WebhookStatus represents the status of a webhook receipt
-type WebhookStatus string
+// WebhookStatus represents the status of a webhook receipt, which the API and
+// the worker share through the contract
+type WebhookStatus = webhooks.Status
 
 const (
 	// WebhookStatusPending indicates the webhook is pending processing
-	WebhookStatusPending WebhookStatus = "pending"
+	WebhookStatusPending = webhooks.StatusPending
 	// WebhookStatusProcessing indicates the webhook is being processed
-	WebhookStatusProcessing WebhookStatus = "processing"
+	WebhookStatusProcessing = webhooks.StatusProcessing
 	// WebhookStatusCompleted indicates the webhook has been processed successfully
-	WebhookStatusCompleted WebhookStatus = "completed"
+	WebhookStatusCompleted = webhooks.StatusCompleted
 	// WebhookStatusFailed indicates the webhook processing failed
-	WebhookStatusFailed WebhookStatus = "failed"
+	WebhookStatusFailed = webhooks.StatusFailed
+	// WebhookStatusIgnored indicates the worker has no handler for the webhook
+	WebhookStatusIgnored = webhooks.StatusIgnored
 )
 
 // WebhookReceipt represents a webhook receipt
 type WebhookReceipt struct {
-	ID        string        `json:"id" gorm:"primaryKey"`
-	Source    string        `json:"source" gorm:"index"`
-	Event     string        `json:"event" gorm:"index"`
-	Payload   []byte        `json:"payload"`
-	Signature string        `json:"signature"`
+	ID     string `json:"id" gorm:"primaryKey"`
+	Source string `json:"source" gorm:"index;uniqueIndex:idx_webhook_receipts_delivery,where:delivery_id <> '';uniqueIndex:idx_webhook_receipts_digest,where:payload_digest <> ''"`
+	// DeliveryID is the ID the provider gave the delivery, which stays the
+	// same when it is redelivered. It is unique per source when set.
+	DeliveryID string `json:"delivery_id,omitempty" gorm:"uniqueIndex:idx_webhook_receipts_delivery,where:delivery_id <> ''"`
+	// PayloadDigest is the SHA-256 digest of the payload of deliveries whose
+	// signature does not cover both a timestamp and the delivery ID. It is
+	// unique per source when set, so such deliveries cannot be replayed.
+	PayloadDigest string `json:"-" gorm:"uniqueIndex:idx_webhook_receipts_digest,where:payload_digest <> ''"`
+	Event         string `json:"event" gorm:"index"`
+	Payload       []byte `json:"payload"`
+	Signature     string `json:"signature"`
+	// SecretID is the ID of the source's secret the signature matched
+	SecretID string `json:"secret_id,omitempty"`
+	// Headers are the request headers of the delivery, without credentials
+	Headers   JSON          `json:"-" gorm:"type:jsonb"`
 	Status    WebhookStatus `json:"status" gorm:"index"`
 	Error     string        `json:"error,omitempty"`
 	CreatedAt time.Time     `json:"created_at"`
 	UpdatedAt time.Time     `json:"updated_at"`
 }
 
+// WebhookStatusChange records a status a webhook receipt moved to, so the
+// processing history of a receipt can be inspected
+type WebhookStatusChange struct {
+	ID        uint          `json:"-" gorm:"primaryKey"`
+	ReceiptID string        `json:"-" gorm:"index"`
+	Status    WebhookStatus `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// TableName overrides the table name used by GORM
+func (WebhookReceipt) TableName() string {
+	return webhooks.ReceiptsTable
+}
+
+// TableName overrides the table name used by GORM
+func (WebhookStatusChange) TableName() string {
+	return webhooks.StatusChangesTable
+}
+
+// WebhookReceiptDetail represents a webhook receipt with its headers, its
+// payload decoded and its processing history
+type WebhookReceiptDetail struct {
+	*WebhookReceipt
+	Headers http.Header `json:"headers"`
+	// Payload is the payload as JSON, or as a string if it is not JSON
+	Payload interface{}            `json:"payload"`
+	History []*WebhookStatusChange `json:"history"`
+}
+
+// redactedHeaders are the request headers that are not stored with receipts
+var redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
 // WebhookRequest represents the request to create a webhook receipt
 type WebhookRequest struct {
 	Source    string                 `json:"source" binding:"required"`
@@ -65,6 +114,35 @@ func NewWebhookReceipt(source, event string, payload []byte, signature string) *
 	}
 }
 
+// SetHeaders stores the request headers of the delivery, leaving out the
+// headers carrying credentials
+func (r *WebhookReceipt) SetHeaders(header http.Header) {
+	stored := header.Clone()
+	for _, name := range redactedHeaders {
+		stored.Del(name)
+	}
+	data, _ := json.Marshal(stored)
+	r.Headers = JSON(data)
+}
+
+// HeaderMap returns the stored request headers of the delivery
+func (r *WebhookReceipt) HeaderMap() http.Header {
+	header := http.Header{}
+	if len(r.Headers) > 0 {
+		_ = json.Unmarshal(r.Headers, &header)
+	}
+	return header
+}
+
+// DecodedPayload returns the payload as raw JSON, or as a string if it is not
+// valid JSON
+func (r *WebhookReceipt) DecodedPayload() interface{} {
+	if json.Valid(r.Payload) {
+		return json.RawMessage(r.Payload)
+	}
+	return string(r.Payload)
+}
+
 // SetStatus sets the status of the webhook receipt
 func (r *WebhookReceipt) SetStatus(status WebhookStatus, err error) {
 	r.Status = status
@@ -76,9 +154,20 @@ func (r *WebhookReceipt) SetStatus(status WebhookStatus, err error) {
 	r.UpdatedAt = time.Now()
 }
 
+// StatusChange returns the history entry of the receipt's current status
+func (r *WebhookReceipt) StatusChange() *WebhookStatusChange {
+	return &WebhookStatusChange{
+		ReceiptID: r.ID,
+		Status:    r.Status,
+		Error:     r.Error,
+		CreatedAt: r.UpdatedAt,
+	}
+}
+
 // IsComplete returns true if the webhook receipt has been processed
+// successfully or ignored, so it must not be processed again
 func (r *WebhookReceipt) IsComplete() bool {
-	return r.Status == WebhookStatusCompleted || r.Status == WebhookStatusFailed
+	return r.Status.IsComplete()
 }
 
 // IsPending returns true if the webhook receipt is pending processing
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSecret is a secret of a webhook source. The primary secret is the
+// one the provider signs with; secondary secrets are accepted as well until
+// they expire, so a secret can be rotated without dropping deliveries.
+type WebhookSecret struct {
+	ID        string     `json:"id"`
+	Value     string     `json:"-"`
+	Primary   bool       `json:"primary"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// IsActive checks if the secret has not expired at now
+func (s *WebhookSecret) IsActive(now time.Time) bool {
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
+
+// WebhookSecrets are the secrets of a webhook source, stored as JSON
+type WebhookSecrets []*WebhookSecret
+
+// storedWebhookSecret is the stored form of a secret, which unlike the API
+// form includes its value
+type storedWebhookSecret struct {
+	ID        string     `json:"id"`
+	Value     string     `json:"value"`
+	Primary   bool       `json:"primary"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Add adds a secondary secret, or the primary one if there are no secrets yet
+func (s *WebhookSecrets) Add(value string, expiresAt *time.Time) *WebhookSecret {
+	secret := &WebhookSecret{
+		ID:        uuid.New().String(),
+		Value:     value,
+		Primary:   len(*s) == 0,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	if secret.Primary {
+		secret.ExpiresAt = nil
+	}
+	*s = append(*s, secret)
+	return secret
+}
+
+// Get gets a secret by ID
+func (s WebhookSecrets) Get(id string) (*WebhookSecret, bool) {
+	for _, secret := range s {
+		if secret.ID == id {
+			return secret, true
+		}
+	}
+	return nil, false
+}
+
+// Primary returns the primary secret
+func (s WebhookSecrets) Primary() (*WebhookSecret, bool) {
+	for _, secret := range s {
+		if secret.Primary {
+			return secret, true
+		}
+	}
+	return nil, false
+}
+
+// Promote makes a secret the primary one. The previous primary secret
+// becomes a secondary one that expires at previousExpiresAt, or never if nil.
+func (s WebhookSecrets) Promote(id string, previousExpiresAt *time.Time) bool {
+	promoted, ok := s.Get(id)
+	if !ok {
+		return false
+	}
+	if promoted.Primary {
+		return true
+	}
+	if previous, ok := s.Primary(); ok {
+		previous.Primary = false
+		previous.ExpiresAt = previousExpiresAt
+	}
+	promoted.Primary = true
+	promoted.ExpiresAt = nil
+	return true
+}
+
+// Remove removes a secret
+func (s *WebhookSecrets) Remove(id string) bool {
+	for i, secret := range *s {
+		if secret.ID == id {
+			*s = append((*s)[:i], (*s)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements the driver.Valuer interface
+func (s WebhookSecrets) Value() (driver.Value, error) {
+	stored := make([]storedWebhookSecret, len(s))
+	for i, secret := range s {
+		stored[i] = storedWebhookSecret(*secret)
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements the sql.Scanner interface. Secrets stored as a plain list
+// of values are read as a primary secret followed by secondary ones.
+func (s *WebhookSecrets) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into WebhookSecrets", value)
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("failed to decode webhook secrets: %w", err)
+	}
+
+	secrets := make(WebhookSecrets, 0, len(items))
+	for i, item := range items {
+		var value string
+		if err := json.Unmarshal(item, &value); err == nil {
+			secrets = append(secrets, &WebhookSecret{ID: fmt.Sprintf("legacy-%d", i+1), Value: value, Primary: i == 0})
+			continue
+		}
+
+		var stored storedWebhookSecret
+		if err := json.Unmarshal(item, &stored); err != nil {
+			return fmt.Errorf("failed to decode webhook secret: %w", err)
+		}
+		secret := WebhookSecret(stored)
+		secrets = append(secrets, &secret)
+	}
+	*s = secrets
+	return nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSecretsStorage(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	var secrets WebhookSecrets
+	secrets.Add("primary-secret", &expiresAt)
+	secrets.Add("secondary-secret", &expiresAt)
+	assert.Nil(t, secrets[0].ExpiresAt, "primary secrets do not expire")
+
+	// Values are stored but never encoded for the API
+	value, err := secrets.Value()
+	require.NoError(t, err)
+	assert.Contains(t, value, "secondary-secret")
+	data, err := json.Marshal(secrets)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "primary-secret")
+	assert.NotContains(t, string(data), "secondary-secret")
+
+	var scanned WebhookSecrets
+	require.NoError(t, scanned.Scan([]byte(value.(string))))
+	require.Len(t, scanned, 2)
+	assert.Equal(t, secrets[1].ID, scanned[1].ID)
+	assert.Equal(t, "secondary-secret", scanned[1].Value)
+	assert.True(t, scanned[1].ExpiresAt.Equal(expiresAt))
+}
+
+func TestWebhookSecretsScanLegacy(t *testing.T) {
+	var secrets WebhookSecrets
+	require.NoError(t, secrets.Scan(`["old-secret","new-secret"]`))
+	require.Len(t, secrets, 2)
+
+	primary, ok := secrets.Primary()
+	require.True(t, ok)
+	assert.Equal(t, "old-secret", primary.Value)
+	assert.False(t, secrets[1].Primary)
+	assert.NotEqual(t, secrets[0].ID, secrets[1].ID)
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookVerifierType names the signature scheme a webhook source's
+// deliveries are verified with
+type WebhookVerifierType string
+
+const (
+	// WebhookVerifierHMAC verifies a hex HMAC-SHA256 of the body in X-Signature
+	WebhookVerifierHMAC WebhookVerifierType = "hmac"
+	// WebhookVerifierGitHub verifies GitHub's X-Hub-Signature-256 header
+	WebhookVerifierGitHub WebhookVerifierType = "github"
+	// WebhookVerifierStripe verifies Stripe's timestamped Stripe-Signature header
+	WebhookVerifierStripe WebhookVerifierType = "stripe"
+	// WebhookVerifierSendGrid verifies SendGrid's ECDSA signed Event Webhook
+	WebhookVerifierSendGrid WebhookVerifierType = "sendgrid"
+)
+
+// WebhookSource represents a source webhooks are accepted from at
+// POST /api/webhooks/:name. The values of its secrets are never returned by
+// the API.
+type WebhookSource struct {
+	ID           string              `json:"id" gorm:"primaryKey"`
+	Name         string              `json:"name" gorm:"uniqueIndex"`
+	VerifierType WebhookVerifierType `json:"verifier_type"`
+	// Secrets verify the signatures of deliveries, any active one may match.
+	// For SendGrid they are verification keys.
+	Secrets WebhookSecrets `json:"secrets" gorm:"type:jsonb"`
+	// EventHeader is the header naming the event of a delivery, overriding
+	// the way the verifier type reads it
+	EventHeader string `json:"event_header,omitempty"`
+	Enabled     bool   `json:"enabled" gorm:"index"`
+	// AllowedIPs are the IP addresses and CIDR ranges deliveries are accepted
+	// from; deliveries are accepted from anywhere if empty
+	AllowedIPs JSON      `json:"allowed_ips" gorm:"type:jsonb"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName overrides the table name used by GORM
+func (WebhookSource) TableName() string {
+	return "webhook_sources"
+}
+
+// NewWebhookSource creates a new enabled webhook source
+func NewWebhookSource(name string, verifierType WebhookVerifierType, secrets []string) *WebhookSource {
+	now := time.Now()
+	source := &WebhookSource{
+		ID:           uuid.New().String(),
+		Name:         name,
+		VerifierType: verifierType,
+		Enabled:      true,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	source.SetSecrets(secrets)
+	source.SetAllowedIPs(nil)
+	return source
+}
+
+// SetSecrets replaces the secrets of the source. The first secret becomes
+// the primary one and the others secondary ones that do not expire.
+func (s *WebhookSource) SetSecrets(values []string) {
+	s.Secrets = make(WebhookSecrets, 0, len(values))
+	for _, value := range values {
+		s.Secrets.Add(value, nil)
+	}
+}
+
+// AllowedIPList returns the IP addresses and CIDR ranges of the source
+func (s *WebhookSource) AllowedIPList() []string {
+	return stringList(s.AllowedIPs)
+}
+
+// SetAllowedIPs replaces the IP addresses and CIDR ranges of the source
+func (s *WebhookSource) SetAllowedIPs(ips []string) {
+	s.AllowedIPs = newStringList(ips)
+}
+
+// WebhookSourceRequest represents the request to create or update a webhook
+// source. Fields left out of an update are not changed.
+type WebhookSourceRequest struct {
+	Name         string              `json:"name"`
+	VerifierType WebhookVerifierType `json:"verifier_type"`
+	Secrets      []string            `json:"secrets"`
+	EventHeader  *string             `json:"event_header"`
+	Enabled      *bool               `json:"enabled"`
+	AllowedIPs   []string            `json:"allowed_ips"`
+}
+
+// WebhookSecretRequest represents the request to add a secret to a webhook
+// source or to promote one to the primary secret. ExpirePreviousAfter is a Go
+// duration after which the previous primary secret expires; it is kept until
+// retired if empty.
+type WebhookSecretRequest struct {
+	Value               string     `json:"value"`
+	Primary             bool       `json:"primary"`
+	ExpiresAt           *time.Time `json:"expires_at"`
+	ExpirePreviousAfter string     `json:"expire_previous_after"`
+}
+
+// stringList decodes a JSON array of strings
+func stringList(data JSON) []string {
+	var values []string
+	if len(data) > 0 {
+		_ = json.Unmarshal(data, &values)
+	}
+	return values
+}
+
+// newStringList encodes strings as a JSON array, empty rather than null
+func newStringList(values []string) JSON {
+	if values == nil {
+		values = []string{}
+	}
+	data, _ := json.Marshal(values)
+	return JSON(data)
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkflowStatus represents the status of a workflow run
+type WorkflowStatus string
+
+const (
+	// WorkflowStatusRunning indicates some steps of the run have not finished
+	WorkflowStatusRunning WorkflowStatus = "running"
+	// WorkflowStatusCompleted indicates every step of the run completed
+	WorkflowStatusCompleted WorkflowStatus = "completed"
+	// WorkflowStatusFailed indicates every step finished and at least one did not complete
+	WorkflowStatusFailed WorkflowStatus = "failed"
+)
+
+// WorkflowStepStatus represents the status of a workflow step. Steps whose
+// job has been enqueued share the status of the job.
+type WorkflowStepStatus string
+
+const (
+	// WorkflowStepStatusWaiting indicates the step is waiting for its dependencies
+	WorkflowStepStatusWaiting WorkflowStepStatus = "waiting"
+	// WorkflowStepStatusPending indicates the step's job is queued
+	WorkflowStepStatusPending WorkflowStepStatus = WorkflowStepStatus(JobStatusPending)
+	// WorkflowStepStatusProcessing indicates the step's job is being processed
+	WorkflowStepStatusProcessing WorkflowStepStatus = WorkflowStepStatus(JobStatusProcessing)
+	// WorkflowStepStatusRetrying indicates the step's job is being retried
+	WorkflowStepStatusRetrying WorkflowStepStatus = WorkflowStepStatus(JobStatusRetrying)
+	// WorkflowStepStatusCompleted indicates the step's job completed
+	WorkflowStepStatusCompleted WorkflowStepStatus = WorkflowStepStatus(JobStatusCompleted)
+	// WorkflowStepStatusFailed indicates the step's job failed or could not be enqueued
+	WorkflowStepStatusFailed WorkflowStepStatus = WorkflowStepStatus(JobStatusFailed)
+	// WorkflowStepStatusCancelled indicates the step's job was cancelled
+	WorkflowStepStatusCancelled WorkflowStepStatus = WorkflowStepStatus(JobStatusCancelled)
+	// WorkflowStepStatusSkipped indicates a dependency of the step did not complete
+	WorkflowStepStatusSkipped WorkflowStepStatus = "skipped"
+)
+
+// IsFinal returns true if no further status changes are expected for the step
+func (s WorkflowStepStatus) IsFinal() bool {
+	switch s {
+	case WorkflowStepStatusCompleted, WorkflowStepStatusFailed, WorkflowStepStatusCancelled, WorkflowStepStatusSkipped:
+		return true
+	}
+	return false
+}
+
+// WorkflowRun represents a run of a workflow: a set of named steps, each a
+// job that is enqueued once the steps it depends on have completed
+type WorkflowRun struct {
+	ID          string          `json:"id" gorm:"primaryKey"`
+	Name        string          `json:"name,omitempty" gorm:"index"`
+	Status      WorkflowStatus  `json:"status" gorm:"index"`
+	Steps       []*WorkflowStep `json:"steps" gorm:"foreignKey:RunID;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time       `json:"created_at" gorm:"index"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// TableName overrides the table name used by GORM
+func (WorkflowRun) TableName() string {
+	return "workflow_runs"
+}
+
+// Step gets a step of the run by name
+func (r *WorkflowRun) Step(name string) *WorkflowStep {
+	for _, step := range r.Steps {
+		if step.Name == name {
+			return step
+		}
+	}
+	return nil
+}
+
+// StepByJobID gets the step of the run that enqueued a job
+func (r *WorkflowRun) StepByJobID(jobID string) *WorkflowStep {
+	for _, step := range r.Steps {
+		if step.JobID != "" && step.JobID == jobID {
+			return step
+		}
+	}
+	return nil
+}
+
+// WorkflowStep represents a step of a workflow run
+type WorkflowStep struct {
+	ID          string             `json:"-" gorm:"primaryKey"`
+	RunID       string             `json:"-" gorm:"index"`
+	Position    int                `json:"-"`
+	Name        string             `json:"name"`
+	JobType     JobType            `json:"job_type"`
+	Data        JSON               `json:"data" gorm:"type:jsonb"`
+	Options     JSON               `json:"options,omitempty" gorm:"type:jsonb"`
+	DependsOn   JSON               `json:"depends_on" gorm:"type:jsonb"`
+	JobID       string             `json:"job_id,omitempty" gorm:"index"`
+	Status      WorkflowStepStatus `json:"status"`
+	Result      JSON               `json:"result,omitempty" gorm:"type:jsonb"`
+	Error       string             `json:"error,omitempty"`
+	StartedAt   *time.Time         `json:"started_at,omitempty"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+}
+
+// TableName overrides the table name used by GORM
+func (WorkflowStep) TableName() string {
+	return "workflow_steps"
+}
+
+// Dependencies returns the names of the steps the step depends on
+func (s *WorkflowStep) Dependencies() []string {
+	var names []string
+	if len(s.DependsOn) > 0 {
+		_ = json.Unmarshal(s.DependsOn, &names)
+	}
+	return names
+}
+
+// WorkflowRequest represents the request to start a workflow run
+type WorkflowRequest struct {
+	Name  string                `json:"name"`
+	Steps []WorkflowStepRequest `json:"steps"`
+}
+
+// WorkflowStepRequest represents a step of a workflow run to start
+type WorkflowStepRequest struct {
+	Name      string          `json:"name"`
+	Type      JobType         `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	Options   JobOptions      `json:"options"`
+	DependsOn []string        `json:"depends_on"`
+}
+
+// NewWorkflowRun creates a new running workflow run whose steps all wait for
+// their dependencies
+func NewWorkflowRun(req *WorkflowRequest) (*WorkflowRun, error) {
+	now := time.Now()
+	run := &WorkflowRun{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Status:    WorkflowStatusRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	for i, stepReq := range req.Steps {
+		data := stepReq.Data
+		if len(data) == 0 {
+			data = json.RawMessage("{}")
+		}
+		options, err := json.Marshal(stepReq.Options)
+		if err != nil {
+			return nil, err
+		}
+		dependsOn := stepReq.DependsOn
+		if dependsOn == nil {
+			dependsOn = []string{}
+		}
+		deps, err := json.Marshal(dependsOn)
+		if err != nil {
+			return nil, err
+		}
+
+		run.Steps = append(run.Steps, &WorkflowStep{
+			ID:        uuid.New().String(),
+			RunID:     run.ID,
+			Position:  i,
+			Name:      stepReq.Name,
+			JobType:   stepReq.Type,
+			Data:      JSON(data),
+			Options:   JSON(options),
+			DependsOn: JSON(deps),
+			Status:    WorkflowStepStatusWaiting,
+		})
+	}
+	return run, nil
+}
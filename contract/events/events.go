@@ -0,0 +1,168 @@
+// Package events defines the job lifecycle events the worker publishes and
+// the API consumes. Events are JSON encoded and published on a Redis pub/sub
+// channel so that every API instance can forward them to its WebSocket clients.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Channel is the Redis pub/sub channel job events are published on
+const Channel = "bespin:job-events"
+
+// progressKeyPrefix is the prefix of the Redis keys job progress is stored under
+const progressKeyPrefix = "bespin:job-progress:"
+
+// cancelKeyPrefix is the prefix of the Redis keys marking cancelled jobs
+const cancelKeyPrefix = "bespin:job-cancelled:"
+
+// retryPolicyKeyPrefix is the prefix of the Redis keys job retry policies are stored under
+const retryPolicyKeyPrefix = "bespin:job-retry-policy:"
+
+// chainKeyPrefix is the prefix of the Redis keys the follow-up jobs of a job are stored under
+const chainKeyPrefix = "bespin:job-chain:"
+
+// ProgressKey returns the Redis key the latest progress of a job is stored under
+func ProgressKey(jobID string) string {
+	return progressKeyPrefix + jobID
+}
+
+// CancelKey returns the Redis key marking a job as cancelled. The API sets it
+// when a job is cancelled and the worker checks it before running a task.
+func CancelKey(jobID string) string {
+	return cancelKeyPrefix + jobID
+}
+
+// RetryPolicyKey returns the Redis key the retry policy of a job is stored
+// under. The API sets it when a job is enqueued with its own retry options.
+func RetryPolicyKey(jobID string) string {
+	return retryPolicyKeyPrefix + jobID
+}
+
+// ChainKey returns the Redis key the follow-up jobs of a job are stored under.
+// It is set when a job is enqueued with follow-up jobs, which the worker
+// enqueues once the job has finished.
+func ChainKey(jobID string) string {
+	return chainKeyPrefix + jobID
+}
+
+// CancelledError is the error the worker stops the tasks of cancelled jobs
+// with. Asynq archives these tasks, but they are not dead letters.
+const CancelledError = "job cancelled"
+
+// IsCancelledError checks whether the last error of a task is CancelledError
+func IsCancelledError(lastErr string) bool {
+	return strings.HasPrefix(lastErr, CancelledError)
+}
+
+// Kind represents the kind of a job event
+type Kind string
+
+const (
+	// KindPending is published when a job has been enqueued
+	KindPending Kind = "pending"
+	// KindScheduled is published when a job has been enqueued to run later
+	KindScheduled Kind = "scheduled"
+	// KindProcessing is published when a worker starts processing a job
+	KindProcessing Kind = "processing"
+	// KindProgress is published when a running job reports progress
+	KindProgress Kind = "progress"
+	// KindCompleted is published when a job has completed successfully
+	KindCompleted Kind = "completed"
+	// KindRetrying is published when a job failed and will be retried
+	KindRetrying Kind = "retrying"
+	// KindFailed is published when a job has failed for good
+	KindFailed Kind = "failed"
+	// KindCancelled is published when a job has been cancelled
+	KindCancelled Kind = "cancelled"
+)
+
+// Progress represents the progress reported by a running job
+type Progress struct {
+	Percent   int                    `json:"percent"`
+	Message   string                 `json:"message,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// NewProgress creates a new progress update. Percent must be between 0 and 100.
+func NewProgress(percent int, message string, metadata map[string]interface{}) (*Progress, error) {
+	if percent < 0 || percent > 100 {
+		return nil, fmt.Errorf("percent must be between 0 and 100")
+	}
+	return &Progress{
+		Percent:   percent,
+		Message:   message,
+		Metadata:  metadata,
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+// EncodeProgress serializes a progress update for storage
+func EncodeProgress(p *Progress) ([]byte, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize job progress: %w", err)
+	}
+	return data, nil
+}
+
+// DecodeProgress deserializes a stored progress update
+func DecodeProgress(data []byte) (*Progress, error) {
+	var p Progress
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to deserialize job progress: %w", err)
+	}
+	return &p, nil
+}
+
+// JobEvent represents a change in a job's lifecycle. Processing events carry
+// the attempt they start and retrying events the time of the next attempt.
+// Pending events of follow-up jobs carry the queue and the parent job's ID.
+type JobEvent struct {
+	Kind        Kind            `json:"kind"`
+	JobID       string          `json:"job_id"`
+	TaskType    string          `json:"task_type,omitempty"`
+	Queue       string          `json:"queue,omitempty"`
+	ParentID    string          `json:"parent_id,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Progress    *Progress       `json:"progress,omitempty"`
+	Attempt     int             `json:"attempt,omitempty"`
+	NextRetryAt *time.Time      `json:"next_retry_at,omitempty"`
+	Timestamp   time.Time       `json:"timestamp"`
+}
+
+// NewJobEvent creates a new job event timestamped with the current time
+func NewJobEvent(kind Kind, jobID, taskType string) *JobEvent {
+	return &JobEvent{
+		Kind:      kind,
+		JobID:     jobID,
+		TaskType:  taskType,
+		Timestamp: time.Now(),
+	}
+}
+
+// Encode serializes a job event for publishing
+func Encode(e *JobEvent) ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize job event: %w", err)
+	}
+	return data, nil
+}
+
+// Decode deserializes a published job event
+func Decode(data []byte) (*JobEvent, error) {
+	var e JobEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to deserialize job event: %w", err)
+	}
+	if e.JobID == "" {
+		return nil, fmt.Errorf("failed to deserialize job event: job_id is required")
+	}
+	return &e, nil
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	event := NewJobEvent(KindCompleted, "job-1", "random_text")
+	event.Result = json.RawMessage(`{"text":"cloud"}`)
+
+	data, err := Encode(event)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if decoded.Kind != KindCompleted || decoded.JobID != "job-1" || decoded.TaskType != "random_text" {
+		t.Errorf("unexpected event: %+v", decoded)
+	}
+	if string(decoded.Result) != `{"text":"cloud"}` {
+		t.Errorf("unexpected result: %s", decoded.Result)
+	}
+}
+
+func TestDecodeRequiresJobID(t *testing.T) {
+	if _, err := Decode([]byte(`{"kind":"pending"}`)); err == nil {
+		t.Error("expected error for event without job_id")
+	}
+}
+
+func TestNewProgress(t *testing.T) {
+	p, err := NewProgress(50, "halfway", map[string]interface{}{"step": 2})
+	if err != nil {
+		t.Fatalf("NewProgress returned error: %v", err)
+	}
+
+	data, err := EncodeProgress(p)
+	if err != nil {
+		t.Fatalf("EncodeProgress returned error: %v", err)
+	}
+
+	decoded, err := DecodeProgress(data)
+	if err != nil {
+		t.Fatalf("DecodeProgress returned error: %v", err)
+	}
+	if decoded.Percent != 50 || decoded.Message != "halfway" || decoded.Metadata["step"] != float64(2) {
+		t.Errorf("unexpected progress: %+v", decoded)
+	}
+
+	if _, err := NewProgress(101, "", nil); err == nil {
+		t.Error("expected error for percent above 100")
+	}
+	if _, err := NewProgress(-1, "", nil); err == nil {
+		t.Error("expected error for negative percent")
+	}
+}
+
+func TestIsCancelledError(t *testing.T) {
+	if !IsCancelledError(CancelledError + ": skip retry for the task") {
+		t.Error("expected the wrapped cancelled error to be recognized")
+	}
+	if IsCancelledError("handler failed: job cancelled") {
+		t.Error("expected other errors not to be recognized")
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/dustinleblanc/go-bespin-contract
+
+go 1.21
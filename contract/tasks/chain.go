@@ -0,0 +1,285 @@
+package tasks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxChainDepth is the maximum number of follow-up jobs chained after a job
+const MaxChainDepth = 10
+
+// Follow-up triggers
+const (
+	// TriggerOnSuccess runs a follow-up job when its parent completes
+	TriggerOnSuccess = "on_success"
+	// TriggerOnFailure runs a follow-up job when its parent fails for good
+	TriggerOnFailure = "on_failure"
+)
+
+// FollowUp describes a job the worker enqueues when its parent job finishes.
+// String values of the payload may reference the parent job with templates
+// such as {{ parent.result.text }}, which are rendered when the follow-up is
+// enqueued.
+type FollowUp struct {
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Queue       string          `json:"queue,omitempty"`
+	RetryPolicy *RetryPolicy    `json:"retry_policy,omitempty"`
+	OnSuccess   *FollowUp       `json:"on_success,omitempty"`
+	OnFailure   *FollowUp       `json:"on_failure,omitempty"`
+}
+
+// Chain holds the follow-up jobs of a job
+type Chain struct {
+	OnSuccess *FollowUp `json:"on_success,omitempty"`
+	OnFailure *FollowUp `json:"on_failure,omitempty"`
+}
+
+// IsZero returns true if the chain has no follow-up jobs
+func (c *Chain) IsZero() bool {
+	return c == nil || (c.OnSuccess == nil && c.OnFailure == nil)
+}
+
+// Validate checks that every follow-up job in the chain can be enqueued
+func (c *Chain) Validate() error {
+	return c.validate(1)
+}
+
+func (c *Chain) validate(depth int) error {
+	if c.IsZero() {
+		return nil
+	}
+	if depth > MaxChainDepth {
+		return fmt.Errorf("follow-up jobs may not be nested more than %d levels deep", MaxChainDepth)
+	}
+
+	for trigger, followUp := range map[string]*FollowUp{TriggerOnSuccess: c.OnSuccess, TriggerOnFailure: c.OnFailure} {
+		if followUp == nil {
+			continue
+		}
+		if err := followUp.validate(depth); err != nil {
+			return fmt.Errorf("%s: %w", trigger, err)
+		}
+	}
+	return nil
+}
+
+func (f *FollowUp) validate(depth int) error {
+	if !IsKnownType(f.Type) {
+		return fmt.Errorf("unknown task type: %s", f.Type)
+	}
+	if f.Queue != "" && !IsKnownQueue(f.Queue) {
+		return fmt.Errorf("unknown queue: %s", f.Queue)
+	}
+	if f.RetryPolicy != nil {
+		if err := f.RetryPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateTemplates(f.Payload); err != nil {
+		return err
+	}
+	return f.Chain().validate(depth + 1)
+}
+
+// Chain returns the follow-up jobs of the follow-up job itself
+func (f *FollowUp) Chain() *Chain {
+	return &Chain{OnSuccess: f.OnSuccess, OnFailure: f.OnFailure}
+}
+
+// EncodeChain serializes a chain for storage
+func EncodeChain(c *Chain) ([]byte, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize job chain: %w", err)
+	}
+	return data, nil
+}
+
+// DecodeChain deserializes a stored chain
+func DecodeChain(data []byte) (*Chain, error) {
+	var c Chain
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to deserialize job chain: %w", err)
+	}
+	return &c, nil
+}
+
+// Parent describes the finished job a follow-up payload can reference
+type Parent struct {
+	ID     string
+	Type   string
+	Result json.RawMessage
+	Error  string
+}
+
+// templatePattern matches a {{ parent.<field> }} template
+var templatePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.]+)\s*\}\}`)
+
+// HasTemplates checks if a payload references its parent job
+func HasTemplates(payload json.RawMessage) bool {
+	return templatePattern.Match(payload)
+}
+
+// RenderPayload replaces the templates in the string values of payload with
+// values of the parent job. A string that is a single template takes the
+// referenced value as is, so {{ parent.result }} can insert an object;
+// templates inside longer strings are replaced with the value's text.
+// References to result fields the parent did not produce render as null.
+func RenderPayload(payload json.RawMessage, parent *Parent) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return json.RawMessage("{}"), nil
+	}
+	if !HasTemplates(payload) {
+		return payload, nil
+	}
+
+	var result interface{}
+	if len(parent.Result) > 0 {
+		if err := decodeJSON(parent.Result, &result); err != nil {
+			return nil, fmt.Errorf("failed to read parent result: %w", err)
+		}
+	}
+	values := map[string]interface{}{
+		"id":     parent.ID,
+		"type":   parent.Type,
+		"error":  parent.Error,
+		"result": result,
+	}
+
+	var value interface{}
+	if err := decodeJSON(payload, &value); err != nil {
+		return nil, fmt.Errorf("failed to read follow-up payload: %w", err)
+	}
+
+	rendered, err := render(value, values)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize follow-up payload: %w", err)
+	}
+	return data, nil
+}
+
+// decodeJSON decodes data keeping numbers exact
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// render replaces the templates in every string of value
+func render(value interface{}, parent map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			rendered, err := render(item, parent)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = rendered
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			rendered, err := render(item, parent)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rendered
+		}
+		return v, nil
+	case string:
+		return renderString(v, parent)
+	default:
+		return v, nil
+	}
+}
+
+// renderString replaces the templates in a string
+func renderString(s string, parent map[string]interface{}) (interface{}, error) {
+	matches := templatePattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	// A lone template keeps the type of the referenced value
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return lookup(s[matches[0][2]:matches[0][3]], parent)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		value, err := lookup(s[m[2]:m[3]], parent)
+		if err != nil {
+			return nil, err
+		}
+		switch v := value.(type) {
+		case nil:
+		case string:
+			b.WriteString(v)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render %s: %w", s[m[2]:m[3]], err)
+			}
+			b.Write(data)
+		}
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// lookup resolves a parent reference such as parent.result.items.0.name
+func lookup(ref string, parent map[string]interface{}) (interface{}, error) {
+	path := strings.Split(ref, ".")
+	if len(path) < 2 || path[0] != "parent" {
+		return nil, fmt.Errorf("invalid template reference %q: must start with parent", ref)
+	}
+
+	value, ok := parent[path[1]]
+	if !ok {
+		return nil, fmt.Errorf("invalid template reference %q: parent has no field %s", ref, path[1])
+	}
+	if len(path) > 2 && path[1] != "result" {
+		return nil, fmt.Errorf("invalid template reference %q: only parent.result has fields", ref)
+	}
+
+	for _, key := range path[2:] {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, nil
+			}
+			value = v[i]
+		default:
+			return nil, nil
+		}
+	}
+	return value, nil
+}
+
+// validateTemplates checks that every template in payload references a
+// field of the parent job
+func validateTemplates(payload json.RawMessage) error {
+	empty := map[string]interface{}{"id": "", "type": "", "error": "", "result": nil}
+	for _, m := range templatePattern.FindAllSubmatch(payload, -1) {
+		if _, err := lookup(string(m[1]), empty); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package tasks
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestChainValidate(t *testing.T) {
+	valid := &Chain{
+		OnSuccess: &FollowUp{
+			Type:    TypeRandomText,
+			Payload: json.RawMessage(`{"length":"{{ parent.result.length }}"}`),
+			Queue:   QueueLow,
+			OnFailure: &FollowUp{
+				Type:    TypeRandomText,
+				Payload: json.RawMessage(`{"length":1}`),
+			},
+		},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected valid chain, got %v", err)
+	}
+
+	invalid := map[string]*Chain{
+		"unknown type":     {OnSuccess: &FollowUp{Type: "notify"}},
+		"unknown queue":    {OnFailure: &FollowUp{Type: TypeRandomText, Queue: "urgent"}},
+		"invalid template": {OnSuccess: &FollowUp{Type: TypeRandomText, Payload: json.RawMessage(`{"length":"{{ job.id }}"}`)}},
+		"invalid nested":   {OnSuccess: &FollowUp{Type: TypeRandomText, OnSuccess: &FollowUp{Type: "notify"}}},
+	}
+	for name, chain := range invalid {
+		if err := chain.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	// Chains deeper than MaxChainDepth are rejected
+	deep := &FollowUp{Type: TypeRandomText}
+	for i := 0; i < MaxChainDepth; i++ {
+		deep = &FollowUp{Type: TypeRandomText, OnSuccess: deep}
+	}
+	if err := (&Chain{OnSuccess: deep}).Validate(); err == nil {
+		t.Error("expected error for chain deeper than MaxChainDepth")
+	}
+}
+
+func TestEncodeDecodeChain(t *testing.T) {
+	data, err := EncodeChain(&Chain{OnFailure: &FollowUp{Type: TypeProcessWebhook, Payload: json.RawMessage(`{"receipt_id":"r-1"}`)}})
+	if err != nil {
+		t.Fatalf("EncodeChain returned error: %v", err)
+	}
+
+	chain, err := DecodeChain(data)
+	if err != nil {
+		t.Fatalf("DecodeChain returned error: %v", err)
+	}
+	if chain.OnSuccess != nil || chain.OnFailure == nil || chain.OnFailure.Type != TypeProcessWebhook {
+		t.Errorf("unexpected chain: %+v", chain)
+	}
+}
+
+func TestRenderPayload(t *testing.T) {
+	parent := &Parent{
+		ID:     "job-1",
+		Type:   TypeRandomText,
+		Result: json.RawMessage(`{"text":"cloud city","length":10,"tags":["a","b"]}`),
+		Error:  "",
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty payload", payload: ``, want: `{}`},
+		{name: "no templates", payload: `{"length":5}`, want: `{"length":5}`},
+		{name: "typed value", payload: `{"length":"{{ parent.result.length }}"}`, want: `{"length":10}`},
+		{name: "whole result", payload: `{"input":"{{parent.result}}"}`, want: `{"input":{"length":10,"tags":["a","b"],"text":"cloud city"}}`},
+		{name: "interpolated", payload: `{"message":"Job {{ parent.id }} wrote {{ parent.result.text }}"}`, want: `{"message":"Job job-1 wrote cloud city"}`},
+		{name: "array index", payload: `{"tag":"{{ parent.result.tags.1 }}"}`, want: `{"tag":"b"}`},
+		{name: "nested values", payload: `{"items":[{"id":"{{ parent.id }}"}]}`, want: `{"items":[{"id":"job-1"}]}`},
+		{name: "missing field", payload: `{"value":"{{ parent.result.missing }}"}`, want: `{"value":null}`},
+		{name: "unknown parent field", payload: `{"value":"{{ parent.owner }}"}`, wantErr: true},
+		{name: "not a parent reference", payload: `{"value":"{{ env.HOME }}"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderPayload(json.RawMessage(tt.payload), parent)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderPayload returned error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package tasks
+
+// Queue names. The worker processes every queue, giving each a share of its
+// capacity proportional to the queue's weight.
+const (
+	// QueueCritical is for latency sensitive jobs
+	QueueCritical = "critical"
+	// QueueDefault is used when no queue is requested
+	QueueDefault = "default"
+	// QueueLow is for background jobs that can wait
+	QueueLow = "low"
+)
+
+// QueueWeights returns every queue defined by the contract with its priority weight
+func QueueWeights() map[string]int {
+	return map[string]int{
+		QueueCritical: 6, // processed 60% of the time
+		QueueDefault:  3, // processed 30% of the time
+		QueueLow:      1, // processed 10% of the time
+	}
+}
+
+// Queues returns every queue name defined by the contract, highest priority first
+func Queues() []string {
+	return []string{
+		QueueCritical,
+		QueueDefault,
+		QueueLow,
+	}
+}
+
+// IsKnownQueue checks if a queue is defined by the contract
+func IsKnownQueue(queue string) bool {
+	_, ok := QueueWeights()[queue]
+	return ok
+}
@@ -0,0 +1,136 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// BackoffStrategy determines how the delay between retries grows
+type BackoffStrategy string
+
+const (
+	// BackoffFixed waits the same delay before every retry
+	BackoffFixed BackoffStrategy = "fixed"
+	// BackoffExponential doubles the delay after every retry and adds jitter
+	// so retries of jobs that failed together do not all run at once
+	BackoffExponential BackoffStrategy = "exponential"
+)
+
+// Backoff describes the delay between retries of a failed job
+type Backoff struct {
+	Strategy BackoffStrategy `json:"strategy"`
+	// Delay is the delay before the first retry
+	Delay time.Duration `json:"delay"`
+	// MaxDelay caps exponential delays; zero means no cap
+	MaxDelay time.Duration `json:"max_delay,omitempty"`
+}
+
+// Validate checks that the backoff is usable
+func (b *Backoff) Validate() error {
+	switch b.Strategy {
+	case BackoffFixed, BackoffExponential:
+	default:
+		return fmt.Errorf("unknown backoff strategy: %q", b.Strategy)
+	}
+	if b.Delay <= 0 {
+		return fmt.Errorf("backoff delay must be positive")
+	}
+	if b.MaxDelay < 0 {
+		return fmt.Errorf("backoff max delay must not be negative")
+	}
+	return nil
+}
+
+// RetryDelay returns the delay before the next attempt of a job that has
+// already been retried the given number of times
+func (b *Backoff) RetryDelay(retried int) time.Duration {
+	if b.Strategy == BackoffFixed {
+		return b.Delay
+	}
+
+	delay := b.Delay
+	for i := 0; i < retried; i++ {
+		if b.MaxDelay > 0 && delay >= b.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+
+	// Keep at least half of the delay and randomize the rest
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// RetryPolicy controls how a job is retried and how long it may run. Unset
+// fields fall back to the defaults the worker registers for the job type.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a failed job is retried
+	MaxRetries *int `json:"max_retries,omitempty"`
+	// Timeout limits how long a single attempt may run
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Deadline is the time after which the job must not run anymore
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Backoff determines the delay between retries
+	Backoff *Backoff `json:"backoff,omitempty"`
+}
+
+// IsZero returns true if the policy does not set anything
+func (p *RetryPolicy) IsZero() bool {
+	return p.MaxRetries == nil && p.Timeout == 0 && p.Deadline == nil && p.Backoff == nil
+}
+
+// Validate checks that the policy is usable
+func (p *RetryPolicy) Validate() error {
+	if p.MaxRetries != nil && *p.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if p.Backoff != nil {
+		if err := p.Backoff.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithDefaults returns a copy of the policy with unset fields taken from defaults
+func (p RetryPolicy) WithDefaults(defaults RetryPolicy) RetryPolicy {
+	if p.MaxRetries == nil {
+		p.MaxRetries = defaults.MaxRetries
+	}
+	if p.Timeout == 0 {
+		p.Timeout = defaults.Timeout
+	}
+	if p.Deadline == nil {
+		p.Deadline = defaults.Deadline
+	}
+	if p.Backoff == nil {
+		p.Backoff = defaults.Backoff
+	}
+	return p
+}
+
+// EncodeRetryPolicy serializes a retry policy so the worker can read it
+func EncodeRetryPolicy(p *RetryPolicy) ([]byte, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize retry policy: %w", err)
+	}
+	return data, nil
+}
+
+// DecodeRetryPolicy deserializes a retry policy
+func DecodeRetryPolicy(data []byte) (*RetryPolicy, error) {
+	var p RetryPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to deserialize retry policy: %w", err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,75 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFixedBackoff(t *testing.T) {
+	b := &Backoff{Strategy: BackoffFixed, Delay: 10 * time.Second}
+	for retried := 0; retried < 5; retried++ {
+		if d := b.RetryDelay(retried); d != 10*time.Second {
+			t.Errorf("retry %d: expected 10s, got %s", retried, d)
+		}
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := &Backoff{Strategy: BackoffExponential, Delay: time.Second, MaxDelay: 10 * time.Second}
+
+	cases := []struct {
+		retried int
+		max     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 8 * time.Second},
+		{4, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, c := range cases {
+		d := b.RetryDelay(c.retried)
+		if d < c.max/2 || d > c.max {
+			t.Errorf("retry %d: expected delay between %s and %s, got %s", c.retried, c.max/2, c.max, d)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	three, five := 3, 5
+	defaults := RetryPolicy{
+		MaxRetries: &three,
+		Timeout:    time.Minute,
+		Backoff:    &Backoff{Strategy: BackoffFixed, Delay: time.Second},
+	}
+
+	p := RetryPolicy{MaxRetries: &five}
+	merged := p.WithDefaults(defaults)
+	if *merged.MaxRetries != 5 || merged.Timeout != time.Minute || merged.Backoff != defaults.Backoff {
+		t.Errorf("unexpected merged policy: %+v", merged)
+	}
+
+	invalid := []RetryPolicy{
+		{Timeout: -time.Second},
+		{Backoff: &Backoff{Strategy: "linear", Delay: time.Second}},
+		{Backoff: &Backoff{Strategy: BackoffFixed}},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("expected error for policy %+v", p)
+		}
+	}
+
+	data, err := EncodeRetryPolicy(&merged)
+	if err != nil {
+		t.Fatalf("EncodeRetryPolicy returned error: %v", err)
+	}
+	decoded, err := DecodeRetryPolicy(data)
+	if err != nil {
+		t.Fatalf("DecodeRetryPolicy returned error: %v", err)
+	}
+	if *decoded.MaxRetries != 5 || decoded.Backoff.Delay != time.Second {
+		t.Errorf("unexpected decoded policy: %+v", decoded)
+	}
+}
@@ -0,0 +1,25 @@
+package tasks
+
+import (
+	"embed"
+	"fmt"
+)
+
+// schemas holds a JSON Schema for the payload of every task type, named
+// after the task type
+//
+//go:embed schemas/*.json
+var schemas embed.FS
+
+// Schema returns the JSON Schema describing the payload of a task type
+func Schema(taskType string) ([]byte, error) {
+	if !IsKnownType(taskType) {
+		return nil, fmt.Errorf("unknown task type: %s", taskType)
+	}
+
+	data, err := schemas.ReadFile("schemas/" + taskType + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no schema for task type %s: %w", taskType, err)
+	}
+	return data, nil
+}
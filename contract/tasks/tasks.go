@@ -0,0 +1,142 @@
+// Package tasks defines the job contract shared by the Bespin API and worker.
+// The API enqueues tasks using the type names and payloads declared here and
+// the worker registers handlers for exactly the same set, so the two sides can
+// never disagree about what a job looks like on the wire.
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the version of the job contract. Bump the major version when a
+// task type is renamed or a payload changes incompatibly.
+const Version = "1.11.0"
+
+// Task types
+const (
+	// TypeRandomText generates random text
+	TypeRandomText = "random_text"
+	// TypeProcessWebhook processes a stored webhook receipt
+	TypeProcessWebhook = "process_webhook"
+)
+
+// Types returns every task type defined by the contract
+func Types() []string {
+	return []string{
+		TypeRandomText,
+		TypeProcessWebhook,
+	}
+}
+
+// IsKnownType checks if a task type is defined by the contract
+func IsKnownType(taskType string) bool {
+	for _, t := range Types() {
+		if t == taskType {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is implemented by every task payload in the contract
+type Payload interface {
+	// TaskType returns the task type the payload belongs to
+	TaskType() string
+}
+
+// RandomTextPayload represents the payload for a random text task
+type RandomTextPayload struct {
+	Length int `json:"length"`
+}
+
+// TaskType implements Payload
+func (RandomTextPayload) TaskType() string {
+	return TypeRandomText
+}
+
+// WebhookPayload represents the payload for a webhook processing task
+type WebhookPayload struct {
+	ReceiptID string `json:"receipt_id"`
+	Source    string `json:"source,omitempty"`
+	Event     string `json:"event,omitempty"`
+}
+
+// TaskType implements Payload
+func (WebhookPayload) TaskType() string {
+	return TypeProcessWebhook
+}
+
+// RandomTextResult represents the result of a random text task
+type RandomTextResult struct {
+	Text   string `json:"text"`
+	Length int    `json:"length"`
+}
+
+// WebhookResult represents the result of a webhook processing task
+type WebhookResult struct {
+	ReceiptID string `json:"receipt_id"`
+	Source    string `json:"source,omitempty"`
+	Event     string `json:"event,omitempty"`
+	Status    string `json:"status"`
+}
+
+// Encode serializes a payload for the given task type. It fails if the task
+// type is not part of the contract or if the payload belongs to another type.
+func Encode(taskType string, payload interface{}) ([]byte, error) {
+	if !IsKnownType(taskType) {
+		return nil, fmt.Errorf("unknown task type: %s", taskType)
+	}
+
+	if p, ok := payload.(Payload); ok && p.TaskType() != taskType {
+		return nil, fmt.Errorf("payload for %s cannot be used for task type %s", p.TaskType(), taskType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize %s payload: %w", taskType, err)
+	}
+	return data, nil
+}
+
+// Decode deserializes a payload for the given task type
+func Decode(taskType string, data []byte) (Payload, error) {
+	switch taskType {
+	case TypeRandomText:
+		return DecodeRandomText(data)
+	case TypeProcessWebhook:
+		return DecodeWebhook(data)
+	default:
+		return nil, fmt.Errorf("unknown task type: %s", taskType)
+	}
+}
+
+// DecodeRandomText deserializes a random text payload
+func DecodeRandomText(data []byte) (*RandomTextPayload, error) {
+	var p RandomTextPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to deserialize random text payload: %w", err)
+	}
+	return &p, nil
+}
+
+// DecodeWebhook deserializes a webhook payload
+func DecodeWebhook(data []byte) (*WebhookPayload, error) {
+	var p WebhookPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to deserialize webhook payload: %w", err)
+	}
+	if p.ReceiptID == "" {
+		return nil, fmt.Errorf("failed to deserialize webhook payload: receipt_id is required")
+	}
+	return &p, nil
+}
+
+// EncodeResult serializes a task result so it can be stored alongside the task
+func EncodeResult(result interface{}) ([]byte, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize task result: %w", err)
+	}
+	return data, nil
+}
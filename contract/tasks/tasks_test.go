@@ -0,0 +1,116 @@
+package tasks
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	data, err := Encode(TypeRandomText, RandomTextPayload{Length: 42})
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	payload, err := DecodeRandomText(data)
+	if err != nil {
+		t.Fatalf("DecodeRandomText returned error: %v", err)
+	}
+	if payload.Length != 42 {
+		t.Errorf("expected length 42, got %d", payload.Length)
+	}
+
+	data, err = Encode(TypeProcessWebhook, &WebhookPayload{ReceiptID: "receipt-1", Source: "github", Event: "push"})
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	webhook, err := DecodeWebhook(data)
+	if err != nil {
+		t.Fatalf("DecodeWebhook returned error: %v", err)
+	}
+	if webhook.ReceiptID != "receipt-1" || webhook.Source != "github" || webhook.Event != "push" {
+		t.Errorf("unexpected webhook payload: %+v", webhook)
+	}
+}
+
+func TestEncodeRejectsMismatches(t *testing.T) {
+	if _, err := Encode("random-text", RandomTextPayload{Length: 1}); err == nil {
+		t.Error("expected error for unknown task type")
+	}
+
+	if _, err := Encode(TypeProcessWebhook, RandomTextPayload{Length: 1}); err == nil {
+		t.Error("expected error for payload of another task type")
+	}
+}
+
+func TestDecodeWebhookRequiresReceiptID(t *testing.T) {
+	if _, err := DecodeWebhook([]byte(`{"webhook_id":"legacy"}`)); err == nil {
+		t.Error("expected error for payload without receipt_id")
+	}
+}
+
+func TestEncodeResult(t *testing.T) {
+	data, err := EncodeResult(RandomTextResult{Text: "cloud data", Length: 2})
+	if err != nil {
+		t.Fatalf("EncodeResult returned error: %v", err)
+	}
+	if string(data) != `{"text":"cloud data","length":2}` {
+		t.Errorf("unexpected encoded result: %s", data)
+	}
+}
+
+func TestQueues(t *testing.T) {
+	weights := QueueWeights()
+	if len(weights) != len(Queues()) {
+		t.Fatalf("expected a weight for every queue, got %v", weights)
+	}
+	for _, q := range Queues() {
+		if !IsKnownQueue(q) {
+			t.Errorf("queue %q is not known", q)
+		}
+	}
+	if IsKnownQueue("urgent") {
+		t.Error("expected unknown queue to be rejected")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	p, err := Decode(TypeRandomText, []byte(`{"length":5}`))
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if p.TaskType() != TypeRandomText || p.(*RandomTextPayload).Length != 5 {
+		t.Errorf("unexpected payload: %+v", p)
+	}
+
+	if _, err := Decode(TypeProcessWebhook, []byte(`{}`)); err == nil {
+		t.Error("expected error for invalid webhook payload")
+	}
+	if _, err := Decode("unknown", []byte(`{}`)); err == nil {
+		t.Error("expected error for unknown task type")
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	for _, taskType := range Types() {
+		data, err := Schema(taskType)
+		if err != nil {
+			t.Fatalf("Schema(%s) returned error: %v", taskType, err)
+		}
+
+		var schema struct {
+			Title string `json:"title"`
+			Type  string `json:"type"`
+		}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			t.Fatalf("schema for %s is not valid JSON: %v", taskType, err)
+		}
+		if schema.Title != taskType || schema.Type != "object" {
+			t.Errorf("unexpected schema for %s: %+v", taskType, schema)
+		}
+	}
+
+	if _, err := Schema("unknown"); err == nil {
+		t.Error("expected error for unknown task type")
+	}
+}
@@ -0,0 +1,35 @@
+// Package webhooks defines the webhook receipts shared by the API and the
+// worker. The API stores a receipt for every delivery and owns the tables
+// below; the worker reads receipts and records the statuses it moves them to.
+package webhooks
+
+// ReceiptsTable is the PostgreSQL table webhook receipts are stored in
+const ReceiptsTable = "webhook_receipts"
+
+// StatusChangesTable is the PostgreSQL table every status a webhook receipt
+// moved to is recorded in
+const StatusChangesTable = "webhook_status_changes"
+
+// Status represents the status of a webhook receipt
+type Status string
+
+const (
+	// StatusPending indicates the receipt is waiting to be processed
+	StatusPending Status = "pending"
+	// StatusProcessing indicates the worker is processing the receipt
+	StatusProcessing Status = "processing"
+	// StatusCompleted indicates the receipt has been processed successfully
+	StatusCompleted Status = "completed"
+	// StatusFailed indicates the handler of the receipt returned an error. The
+	// receipt is processed again when its job is retried.
+	StatusFailed Status = "failed"
+	// StatusIgnored indicates the worker has no handler for the receipt's
+	// source and event
+	StatusIgnored Status = "ignored"
+)
+
+// IsComplete returns true if a receipt with the status has been handled for
+// good and must not be processed again. Failed receipts are not complete.
+func (s Status) IsComplete() bool {
+	return s == StatusCompleted || s == StatusIgnored
+}
@@ -0,0 +1,22 @@
+package webhooks
+
+import "testing"
+
+func TestStatusIsComplete(t *testing.T) {
+	tests := []struct {
+		status Status
+		want   bool
+	}{
+		{StatusPending, false},
+		{StatusProcessing, false},
+		{StatusCompleted, true},
+		{StatusFailed, false},
+		{StatusIgnored, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.IsComplete(); got != tt.want {
+			t.Errorf("%s.IsComplete() = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
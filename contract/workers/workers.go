@@ -0,0 +1,66 @@
+// Package workers defines how running workers advertise the task handlers
+// they have registered. Every worker stores a Registration in Redis under its
+// own key and refreshes it on a heartbeat, so the key expires shortly after the
+// worker stops and the API can tell which task types have a live worker.
+package workers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// keyPrefix is the prefix of the Redis keys worker registrations are stored under
+const keyPrefix = "bespin:workers:"
+
+// KeyPattern matches the Redis keys of every worker registration
+const KeyPattern = keyPrefix + "*"
+
+// TTL is how long a registration is kept after the worker last refreshed it
+const TTL = 30 * time.Second
+
+// HeartbeatInterval is how often workers refresh their registration
+const HeartbeatInterval = 10 * time.Second
+
+// Key returns the Redis key the registration of a worker is stored under
+func Key(workerID string) string {
+	return keyPrefix + workerID
+}
+
+// Handler describes a task handler registered by a worker
+type Handler struct {
+	Type         string          `json:"type"`
+	Version      string          `json:"version"`
+	Schema       json.RawMessage `json:"schema,omitempty"`
+	DefaultQueue string          `json:"default_queue"`
+}
+
+// Registration describes a running worker and the handlers it registered
+type Registration struct {
+	WorkerID    string    `json:"worker_id"`
+	Hostname    string    `json:"hostname"`
+	Handlers    []Handler `json:"handlers"`
+	StartedAt   time.Time `json:"started_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+}
+
+// Encode serializes a registration for storage in Redis
+func Encode(registration *Registration) ([]byte, error) {
+	data, err := json.Marshal(registration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize worker registration: %w", err)
+	}
+	return data, nil
+}
+
+// Decode deserializes a registration read from Redis
+func Decode(data []byte) (*Registration, error) {
+	var registration Registration
+	if err := json.Unmarshal(data, &registration); err != nil {
+		return nil, fmt.Errorf("failed to deserialize worker registration: %w", err)
+	}
+	if registration.WorkerID == "" {
+		return nil, fmt.Errorf("failed to deserialize worker registration: worker_id is required")
+	}
+	return &registration, nil
+}
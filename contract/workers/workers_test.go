@@ -0,0 +1,47 @@
+package workers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	registration := &Registration{
+		WorkerID: "host-1:42",
+		Hostname: "host-1",
+		Handlers: []Handler{
+			{Type: "random_text", Version: "1.10.0", Schema: json.RawMessage(`{"type":"object"}`), DefaultQueue: "default"},
+		},
+		StartedAt:   time.Now().UTC().Truncate(time.Second),
+		HeartbeatAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	data, err := Encode(registration)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if decoded.WorkerID != registration.WorkerID || len(decoded.Handlers) != 1 || !decoded.StartedAt.Equal(registration.StartedAt) {
+		t.Errorf("unexpected registration: %+v", decoded)
+	}
+	if h := decoded.Handlers[0]; h.Type != "random_text" || h.DefaultQueue != "default" || string(h.Schema) != `{"type":"object"}` {
+		t.Errorf("unexpected handler: %+v", h)
+	}
+}
+
+func TestDecodeRequiresWorkerID(t *testing.T) {
+	if _, err := Decode([]byte(`{"handlers":[]}`)); err == nil {
+		t.Error("expected error for registration without worker_id")
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("host-1:42") != "bespin:workers:host-1:42" {
+		t.Errorf("unexpected key: %s", Key("host-1:42"))
+	}
+}
@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"sort"
 	"syscall"
+	"time"
 
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-contract/workers"
+	"github.com/dustinleblanc/go-bespin-worker/internal/database"
+	"github.com/dustinleblanc/go-bespin-worker/internal/eventbus"
 	"github.com/dustinleblanc/go-bespin-worker/internal/jobs"
-	"github.com/dustinleblanc/go-bespin-worker/pkg/tasks"
+	"github.com/dustinleblanc/go-bespin-worker/internal/webhooks"
 	"github.com/hibiken/asynq"
 )
 
+// defaultResultRetention is how long job progress is kept when JOB_RESULT_RETENTION is not set
+const defaultResultRetention = 24 * time.Hour
+
 func main() {
 	// Get Redis address from environment variable
 	redisAddr := os.Getenv("REDIS_ADDR")
@@ -30,22 +40,67 @@ func main() {
 		asynq.Config{
 			// Specify how many concurrent workers to use
 			Concurrency: 10,
-			// Optionally specify multiple queues with different priorities
-			Queues: map[string]int{
-				"critical": 6, // processed 60% of the time
-				"default":  3, // processed 30% of the time
-				"low":      1, // processed 10% of the time
-			},
+			// Process every contract queue according to its priority weight
+			Queues: tasks.QueueWeights(),
+			// Use the backoff chosen by the retry middleware
+			RetryDelayFunc: jobs.RetryDelay,
 		},
 	)
 
-	// Create a new processor
-	processor := jobs.NewProcessor()
+	// Progress is kept for as long as job results
+	resultRetention := defaultResultRetention
+	if retention := os.Getenv("JOB_RESULT_RETENTION"); retention != "" {
+		d, err := time.ParseDuration(retention)
+		if err != nil {
+			log.Fatalf("Invalid JOB_RESULT_RETENTION: %v", err)
+		}
+		resultRetention = d
+	}
+
+	// Create the job event publisher
+	publisher, err := eventbus.NewRedisPublisher(redisAddr, resultRetention)
+	if err != nil {
+		log.Fatalf("Failed to create job event publisher: %v", err)
+	}
+	defer publisher.Close()
+
+	// Create the client follow-up jobs are enqueued with
+	client := asynq.NewClient(redisOpt)
+	defer client.Close()
+
+	// Connect to the database holding the webhook receipts
+	db, err := database.NewConnection()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// Create a new processor, routing webhook receipts to the registered handlers
+	webhookProcessor := webhooks.NewProcessor(webhooks.NewGormRepository(db), webhookHandlers())
+	processor := jobs.NewProcessor(webhookProcessor)
 
 	// Configure the mux server to handle different task types
-	mux := asynq.NewServeMux()
-	mux.HandleFunc(tasks.TypeRandomText, processor.HandleRandomTextTask)
-	mux.HandleFunc(tasks.TypeWebhook, processor.HandleWebhookTask)
+	mux := newServeMux(processor)
+	mux.Use(
+		jobs.ChainMiddleware(publisher, client, publisher, resultRetention),
+		jobs.EventMiddleware(publisher, publisher, publisher),
+		jobs.RetryMiddleware(publisher, retryPolicies()),
+	)
+
+	// Advertise the registered handlers until the worker stops
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	registration, err := newRegistration(fmt.Sprintf("%s:%d", hostname, os.Getpid()), hostname, processor)
+	if err != nil {
+		log.Fatalf("Failed to build worker registration: %v", err)
+	}
+	advertiseCtx, stopAdvertising := context.WithCancel(context.Background())
+	advertised := make(chan struct{})
+	go func() {
+		defer close(advertised)
+		advertise(advertiseCtx, publisher, registration)
+	}()
 
 	// Handle shutdown gracefully
 	sigChan := make(chan os.Signal, 1)
@@ -58,10 +113,123 @@ func main() {
 	}()
 
 	// Start the server
-	log.Printf("Starting worker server at %s", redisAddr)
+	log.Printf("Starting worker server at %s (job contract v%s)", redisAddr, tasks.Version)
 	if err := srv.Run(mux); err != nil {
 		log.Fatalf("Failed to run server: %v", err)
 	}
 
+	stopAdvertising()
+	<-advertised
 	fmt.Println("Worker server stopped")
 }
+
+// handlers maps every contract task type to the processor method that handles it
+func handlers(processor *jobs.Processor) map[string]asynq.HandlerFunc {
+	return map[string]asynq.HandlerFunc{
+		tasks.TypeRandomText:     processor.HandleRandomTextTask,
+		tasks.TypeProcessWebhook: processor.HandleWebhookTask,
+	}
+}
+
+// defaultQueues maps every contract task type to the queue clients should
+// enqueue it on when they do not pick one
+func defaultQueues() map[string]string {
+	return map[string]string{
+		tasks.TypeRandomText:     tasks.QueueDefault,
+		tasks.TypeProcessWebhook: tasks.QueueCritical,
+	}
+}
+
+// newRegistration describes the handlers this worker serves, with the
+// contract schema and default queue of each task type
+func newRegistration(workerID, hostname string, processor *jobs.Processor) (*workers.Registration, error) {
+	queues := defaultQueues()
+
+	var taskTypes []string
+	for taskType := range handlers(processor) {
+		taskTypes = append(taskTypes, taskType)
+	}
+	sort.Strings(taskTypes)
+
+	registration := &workers.Registration{
+		WorkerID:  workerID,
+		Hostname:  hostname,
+		StartedAt: time.Now(),
+	}
+	for _, taskType := range taskTypes {
+		schema, err := tasks.Schema(taskType)
+		if err != nil {
+			return nil, err
+		}
+		registration.Handlers = append(registration.Handlers, workers.Handler{
+			Type:         taskType,
+			Version:      tasks.Version,
+			Schema:       schema,
+			DefaultQueue: queues[taskType],
+		})
+	}
+	return registration, nil
+}
+
+// advertise stores the worker's registration and refreshes it every
+// heartbeat until ctx is cancelled, then withdraws it
+func advertise(ctx context.Context, advertiser eventbus.Advertiser, registration *workers.Registration) {
+	ticker := time.NewTicker(workers.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		registration.HeartbeatAt = time.Now()
+		if err := advertiser.Advertise(ctx, registration); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to advertise worker %s: %v", registration.WorkerID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			withdrawCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := advertiser.Withdraw(withdrawCtx, registration.WorkerID); err != nil {
+				log.Printf("Failed to withdraw worker %s: %v", registration.WorkerID, err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// retryPolicies returns the default retry policy of every contract task type.
+// Jobs enqueued with their own retry options override these.
+func retryPolicies() jobs.RetryPolicies {
+	randomTextRetries := 3
+	webhookRetries := 10
+
+	return jobs.RetryPolicies{
+		tasks.TypeRandomText: {
+			MaxRetries: &randomTextRetries,
+			Timeout:    time.Minute,
+			Backoff:    &tasks.Backoff{Strategy: tasks.BackoffFixed, Delay: 10 * time.Second},
+		},
+		tasks.TypeProcessWebhook: {
+			MaxRetries: &webhookRetries,
+			Timeout:    30 * time.Second,
+			Backoff:    &tasks.Backoff{Strategy: tasks.BackoffExponential, Delay: 5 * time.Second, MaxDelay: 10 * time.Minute},
+		},
+	}
+}
+
+// webhookHandlers returns the registry of webhook handlers by source and event.
+// Add integrations here with patterns such as "github/push", "github/*" or
+// "stripe/invoice.*"; receipts without a handler are logged by the default one.
+func webhookHandlers() *webhooks.Registry {
+	registry := webhooks.NewRegistry()
+	registry.SetDefault(webhooks.LogHandler())
+	return registry
+}
+
+// newServeMux creates a mux with a handler registered for each task type
+func newServeMux(processor *jobs.Processor) *asynq.ServeMux {
+	mux := asynq.NewServeMux()
+	for taskType, handler := range handlers(processor) {
+		mux.HandleFunc(taskType, handler)
+	}
+	return mux
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-worker/internal/jobs"
+)
+
+// TestHandlersMatchContract fails when the worker drifts from the job contract
+func TestHandlersMatchContract(t *testing.T) {
+	registered := handlers(jobs.NewProcessor(nil))
+
+	for _, taskType := range tasks.Types() {
+		if _, ok := registered[taskType]; !ok {
+			t.Errorf("no handler registered for contract task type %q", taskType)
+		}
+	}
+
+	for taskType := range registered {
+		if !tasks.IsKnownType(taskType) {
+			t.Errorf("handler registered for task type %q which is not in the contract", taskType)
+		}
+	}
+}
+
+// TestRetryPoliciesAreValid fails when a default retry policy is unusable
+func TestRetryPoliciesAreValid(t *testing.T) {
+	policies := retryPolicies()
+
+	for _, taskType := range tasks.Types() {
+		policy, ok := policies[taskType]
+		if !ok {
+			t.Errorf("no default retry policy for contract task type %q", taskType)
+			continue
+		}
+		if err := policy.Validate(); err != nil {
+			t.Errorf("invalid default retry policy for task type %q: %v", taskType, err)
+		}
+	}
+}
+
+// TestRegistrationAdvertisesEveryHandler fails when a handler is advertised
+// without a schema or with a queue the worker does not consume
+func TestRegistrationAdvertisesEveryHandler(t *testing.T) {
+	registration, err := newRegistration("host:1", "host", jobs.NewProcessor(nil))
+	if err != nil {
+		t.Fatalf("newRegistration returned error: %v", err)
+	}
+
+	if len(registration.Handlers) != len(tasks.Types()) {
+		t.Fatalf("expected %d advertised handlers, got %d", len(tasks.Types()), len(registration.Handlers))
+	}
+	for _, handler := range registration.Handlers {
+		if len(handler.Schema) == 0 {
+			t.Errorf("handler %q is advertised without a schema", handler.Type)
+		}
+		if !tasks.IsKnownQueue(handler.DefaultQueue) {
+			t.Errorf("handler %q is advertised with unknown default queue %q", handler.Type, handler.DefaultQueue)
+		}
+		if handler.Version != tasks.Version {
+			t.Errorf("handler %q is advertised with version %q", handler.Type, handler.Version)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+)
+
+// MockPublisher is an in-memory implementation of Publisher, ProgressStore,
+// CancellationChecker, RetryPolicyStore and ChainStore
+type MockPublisher struct {
+	events        []*events.JobEvent
+	progress      map[string]*events.Progress
+	cancelled     map[string]bool
+	retryPolicies map[string]*tasks.RetryPolicy
+	chains        map[string]*tasks.Chain
+	mu            sync.RWMutex
+
+	// Err is returned by every method when set
+	Err error
+}
+
+// NewMockPublisher creates a new mock publisher
+func NewMockPublisher() *MockPublisher {
+	return &MockPublisher{
+		progress:      make(map[string]*events.Progress),
+		cancelled:     make(map[string]bool),
+		retryPolicies: make(map[string]*tasks.RetryPolicy),
+		chains:        make(map[string]*tasks.Chain),
+	}
+}
+
+// Publish records a job event in memory
+func (m *MockPublisher) Publish(ctx context.Context, event *events.JobEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+	m.events = append(m.events, event)
+	return nil
+}
+
+// Events returns the published job events in order
+func (m *MockPublisher) Events() []*events.JobEvent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]*events.JobEvent(nil), m.events...)
+}
+
+// SaveProgress stores the latest progress of a job in memory
+func (m *MockPublisher) SaveProgress(ctx context.Context, jobID string, progress *events.Progress) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+	m.progress[jobID] = progress
+	return nil
+}
+
+// Progress returns the latest stored progress of a job, or nil if it has none
+func (m *MockPublisher) Progress(jobID string) *events.Progress {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.progress[jobID]
+}
+
+// Cancel marks a job as cancelled
+func (m *MockPublisher) Cancel(jobID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cancelled[jobID] = true
+}
+
+// IsCancelled checks whether a job has been marked as cancelled
+func (m *MockPublisher) IsCancelled(ctx context.Context, jobID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.Err != nil {
+		return false, m.Err
+	}
+	return m.cancelled[jobID], nil
+}
+
+// GetRetryPolicy gets the retry policy of a job from memory, or nil if it has none
+func (m *MockPublisher) GetRetryPolicy(ctx context.Context, jobID string) (*tasks.RetryPolicy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return m.retryPolicies[jobID], nil
+}
+
+// SaveRetryPolicy stores the retry policy of a job in memory
+func (m *MockPublisher) SaveRetryPolicy(ctx context.Context, jobID string, policy *tasks.RetryPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+	m.retryPolicies[jobID] = policy
+	return nil
+}
+
+// GetChain gets the follow-up jobs of a job from memory, or nil if it has none
+func (m *MockPublisher) GetChain(ctx context.Context, jobID string) (*tasks.Chain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return m.chains[jobID], nil
+}
+
+// SaveChain stores the follow-up jobs of a job in memory
+func (m *MockPublisher) SaveChain(ctx context.Context, jobID string, chain *tasks.Chain) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+	m.chains[jobID] = chain
+	return nil
+}
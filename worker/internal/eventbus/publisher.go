@@ -0,0 +1,203 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-contract/workers"
+	"github.com/go-redis/redis/v8"
+)
+
+// Publisher publishes job events
+type Publisher interface {
+	// Publish publishes a job event
+	Publish(ctx context.Context, event *events.JobEvent) error
+}
+
+// ProgressStore stores the latest progress of running jobs
+type ProgressStore interface {
+	// SaveProgress stores the latest progress of a job
+	SaveProgress(ctx context.Context, jobID string, progress *events.Progress) error
+}
+
+// CancellationChecker checks whether a job has been cancelled
+type CancellationChecker interface {
+	// IsCancelled checks whether a job has been cancelled
+	IsCancelled(ctx context.Context, jobID string) (bool, error)
+}
+
+// RetryPolicyStore reads the retry policies jobs were enqueued with
+type RetryPolicyStore interface {
+	// GetRetryPolicy gets the retry policy of a job, or nil if it has none
+	GetRetryPolicy(ctx context.Context, jobID string) (*tasks.RetryPolicy, error)
+}
+
+// ChainStore reads the follow-up jobs of finished jobs and stores those of
+// the follow-up jobs the worker enqueues
+type ChainStore interface {
+	// GetChain gets the follow-up jobs of a job, or nil if it has none
+	GetChain(ctx context.Context, jobID string) (*tasks.Chain, error)
+	// SaveChain stores the follow-up jobs of a job
+	SaveChain(ctx context.Context, jobID string, chain *tasks.Chain) error
+	// SaveRetryPolicy stores the retry policy of a job
+	SaveRetryPolicy(ctx context.Context, jobID string, policy *tasks.RetryPolicy) error
+}
+
+// Advertiser advertises the handlers of a running worker
+type Advertiser interface {
+	// Advertise stores the registration of a worker until it expires
+	Advertise(ctx context.Context, registration *workers.Registration) error
+	// Withdraw removes the registration of a worker
+	Withdraw(ctx context.Context, workerID string) error
+}
+
+// RedisPublisher implements Publisher using Redis pub/sub, and ProgressStore,
+// CancellationChecker, RetryPolicyStore, ChainStore and Advertiser using plain
+// Redis keys
+type RedisPublisher struct {
+	client      *redis.Client
+	progressTTL time.Duration
+}
+
+// NewRedisPublisher creates a new RedisPublisher. Stored progress expires
+// after progressTTL, which should match the job result retention.
+func NewRedisPublisher(redisAddr string, progressTTL time.Duration) (*RedisPublisher, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr: redisAddr,
+	})
+
+	// Test the connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &RedisPublisher{
+		client:      client,
+		progressTTL: progressTTL,
+	}, nil
+}
+
+// Publish publishes a job event on the job events channel
+func (p *RedisPublisher) Publish(ctx context.Context, event *events.JobEvent) error {
+	data, err := events.Encode(event)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.Publish(ctx, events.Channel, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish job event: %w", err)
+	}
+	return nil
+}
+
+// SaveProgress stores the latest progress of a job
+func (p *RedisPublisher) SaveProgress(ctx context.Context, jobID string, progress *events.Progress) error {
+	data, err := events.EncodeProgress(progress)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.Set(ctx, events.ProgressKey(jobID), data, p.progressTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save job progress: %w", err)
+	}
+	return nil
+}
+
+// IsCancelled checks whether the API has marked a job as cancelled
+func (p *RedisPublisher) IsCancelled(ctx context.Context, jobID string) (bool, error) {
+	err := p.client.Get(ctx, events.CancelKey(jobID)).Err()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check job cancellation: %w", err)
+	}
+	return true, nil
+}
+
+// GetRetryPolicy gets the retry policy the API stored for a job, if any
+func (p *RedisPublisher) GetRetryPolicy(ctx context.Context, jobID string) (*tasks.RetryPolicy, error) {
+	data, err := p.client.Get(ctx, events.RetryPolicyKey(jobID)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get job retry policy: %w", err)
+	}
+	return tasks.DecodeRetryPolicy(data)
+}
+
+// SaveRetryPolicy stores the retry policy of a job the worker enqueued
+func (p *RedisPublisher) SaveRetryPolicy(ctx context.Context, jobID string, policy *tasks.RetryPolicy) error {
+	data, err := tasks.EncodeRetryPolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.Set(ctx, events.RetryPolicyKey(jobID), data, p.progressTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save job retry policy: %w", err)
+	}
+	return nil
+}
+
+// GetChain gets the follow-up jobs stored for a job, if any
+func (p *RedisPublisher) GetChain(ctx context.Context, jobID string) (*tasks.Chain, error) {
+	data, err := p.client.Get(ctx, events.ChainKey(jobID)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get job chain: %w", err)
+	}
+	return tasks.DecodeChain(data)
+}
+
+// SaveChain stores the follow-up jobs of a job the worker enqueued
+func (p *RedisPublisher) SaveChain(ctx context.Context, jobID string, chain *tasks.Chain) error {
+	data, err := tasks.EncodeChain(chain)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.Set(ctx, events.ChainKey(jobID), data, p.progressTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save job chain: %w", err)
+	}
+	return nil
+}
+
+// Advertise stores the registration of a worker. It expires after
+// workers.TTL unless it is advertised again.
+func (p *RedisPublisher) Advertise(ctx context.Context, registration *workers.Registration) error {
+	data, err := workers.Encode(registration)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.Set(ctx, workers.Key(registration.WorkerID), data, workers.TTL).Err(); err != nil {
+		return fmt.Errorf("failed to advertise worker: %w", err)
+	}
+	return nil
+}
+
+// Withdraw removes the registration of a worker
+func (p *RedisPublisher) Withdraw(ctx context.Context, workerID string) error {
+	if err := p.client.Del(ctx, workers.Key(workerID)).Err(); err != nil {
+		return fmt.Errorf("failed to withdraw worker: %w", err)
+	}
+	return nil
+}
+
+// Close closes the publisher
+func (p *RedisPublisher) Close() error {
+	if err := p.client.Close(); err != nil {
+		return fmt.Errorf("failed to close Redis client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-worker/internal/eventbus"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+// Enqueuer enqueues tasks
+type Enqueuer interface {
+	// EnqueueContext enqueues a task
+	EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+}
+
+// ChainMiddleware enqueues the follow-up jobs of every job once it has
+// finished: the on_success job when the job completes and the on_failure job
+// when it fails for good. Follow-up payloads are rendered with the parent's
+// ID, type, result and error, and follow-up jobs keep their results for
+// retention. It must run outside EventMiddleware so it can read the result
+// the handler recorded.
+func ChainMiddleware(store eventbus.ChainStore, enqueuer Enqueuer, publisher eventbus.Publisher, retention time.Duration) asynq.MiddlewareFunc {
+	logger := log.New(log.Writer(), "[JobChain] ", log.LstdFlags)
+
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			recorder := &resultRecorder{}
+			err := next.ProcessTask(context.WithValue(ctx, resultKey{}, recorder), t)
+
+			trigger := tasks.TriggerOnSuccess
+			if err != nil {
+				if errors.Is(err, ErrJobCancelled) || failureKind(ctx, err) != events.KindFailed {
+					return err
+				}
+				trigger = tasks.TriggerOnFailure
+			}
+
+			// Use a fresh context so follow-up jobs are enqueued even if the task timed out
+			chainCtx := context.Background()
+			jobID, _ := getTaskID(ctx)
+			chain, chainErr := store.GetChain(chainCtx, jobID)
+			if chainErr != nil {
+				logger.Printf("Failed to get follow-up jobs of job %s: %v", jobID, chainErr)
+				return err
+			}
+			if chain.IsZero() {
+				return err
+			}
+
+			followUp := chain.OnSuccess
+			if trigger == tasks.TriggerOnFailure {
+				followUp = chain.OnFailure
+			}
+			if followUp == nil {
+				return err
+			}
+
+			parent := &tasks.Parent{ID: jobID, Type: t.Type(), Result: recorder.data}
+			if err != nil {
+				parent.Error = err.Error()
+			}
+			childID, enqueueErr := enqueueFollowUp(chainCtx, store, enqueuer, publisher, retention, parent, trigger, followUp)
+			if enqueueErr != nil {
+				logger.Printf("Failed to enqueue %s job of job %s: %v", trigger, jobID, enqueueErr)
+			} else {
+				logger.Printf("Enqueued %s job %s of job %s", trigger, childID, jobID)
+			}
+			return err
+		})
+	}
+}
+
+// enqueueFollowUp enqueues a follow-up job of parent. The follow-up job's ID
+// is derived from the parent's ID and the trigger, so a follow-up job is
+// enqueued only once even if its parent's completion is handled twice. A
+// follow-up job whose rendered payload does not match the schema of its type
+// is not enqueued but reported as failed.
+func enqueueFollowUp(ctx context.Context, store eventbus.ChainStore, enqueuer Enqueuer, publisher eventbus.Publisher, retention time.Duration, parent *tasks.Parent, trigger string, followUp *tasks.FollowUp) (string, error) {
+	queueName := followUp.Queue
+	if queueName == "" {
+		queueName = tasks.QueueDefault
+	}
+	childID := followUpID(parent.ID, trigger)
+
+	payload, err := tasks.RenderPayload(followUp.Payload, parent)
+	if err == nil {
+		err = validatePayload(followUp.Type, payload)
+	}
+	if err != nil {
+		event := events.NewJobEvent(events.KindFailed, childID, followUp.Type)
+		event.Queue = queueName
+		event.ParentID = parent.ID
+		event.Error = err.Error()
+		if publishErr := publisher.Publish(ctx, event); publishErr != nil {
+			log.Printf("Failed to publish failed event for job %s: %v", childID, publishErr)
+		}
+		return "", err
+	}
+
+	opts := []asynq.Option{asynq.TaskID(childID), asynq.Queue(queueName), asynq.Retention(retention)}
+	if policy := followUp.RetryPolicy; policy != nil {
+		if policy.MaxRetries != nil {
+			opts = append(opts, asynq.MaxRetry(*policy.MaxRetries))
+		}
+		if policy.Timeout > 0 {
+			opts = append(opts, asynq.Timeout(policy.Timeout))
+		}
+		if policy.Deadline != nil {
+			opts = append(opts, asynq.Deadline(*policy.Deadline))
+		}
+		if err := store.SaveRetryPolicy(ctx, childID, policy); err != nil {
+			return "", err
+		}
+	}
+
+	// Store the follow-up jobs of the follow-up job before a worker can pick it up
+	if chain := followUp.Chain(); !chain.IsZero() {
+		if err := store.SaveChain(ctx, childID, chain); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := enqueuer.EnqueueContext(ctx, asynq.NewTask(followUp.Type, payload), opts...); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			return childID, nil
+		}
+		return "", fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	event := events.NewJobEvent(events.KindPending, childID, followUp.Type)
+	event.Queue = queueName
+	event.ParentID = parent.ID
+	if err := publisher.Publish(ctx, event); err != nil {
+		log.Printf("Failed to publish pending event for job %s: %v", childID, err)
+	}
+	return childID, nil
+}
+
+// followUpID returns the ID of the follow-up job a parent job enqueues for trigger
+func followUpID(parentID, trigger string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(events.ChainKey(parentID)+":"+trigger)).String()
+}
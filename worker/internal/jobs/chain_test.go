@@ -0,0 +1,197 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-worker/internal/eventbus"
+	"github.com/hibiken/asynq"
+)
+
+func TestChainMiddleware(t *testing.T) {
+	onSuccess := &tasks.FollowUp{
+		Type:    tasks.TypeRandomText,
+		Payload: json.RawMessage(`{"length": "{{ parent.result.length }}"}`),
+		Queue:   tasks.QueueLow,
+	}
+	onFailure := &tasks.FollowUp{
+		Type:    tasks.TypeProcessWebhook,
+		Payload: json.RawMessage(`{"receipt_id": "{{ parent.id }}", "event": "{{ parent.error }}"}`),
+	}
+	result := []byte(`{"text": "abc", "length": 3}`)
+
+	tests := []struct {
+		name      string
+		chain     *tasks.Chain
+		err       error
+		retried   int
+		wantID    string
+		wantQueue string
+		wantData  string
+	}{
+		{
+			name:      "completed job enqueues on_success job",
+			chain:     &tasks.Chain{OnSuccess: onSuccess, OnFailure: onFailure},
+			wantID:    followUpID("job-1", tasks.TriggerOnSuccess),
+			wantQueue: tasks.QueueLow,
+			wantData:  `{"length":3}`,
+		},
+		{
+			name:      "job failed for good enqueues on_failure job",
+			chain:     &tasks.Chain{OnSuccess: onSuccess, OnFailure: onFailure},
+			err:       fmt.Errorf("boom: %w", asynq.SkipRetry),
+			wantID:    followUpID("job-1", tasks.TriggerOnFailure),
+			wantQueue: tasks.QueueDefault,
+			wantData:  `{"event":"boom: skip retry for the task","receipt_id":"job-1"}`,
+		},
+		{
+			name:    "job that will be retried enqueues nothing",
+			chain:   &tasks.Chain{OnSuccess: onSuccess, OnFailure: onFailure},
+			err:     errors.New("boom"),
+			retried: 1,
+		},
+		{
+			name:  "cancelled job enqueues nothing",
+			chain: &tasks.Chain{OnSuccess: onSuccess, OnFailure: onFailure},
+			err:   fmt.Errorf("%w: %w", ErrJobCancelled, asynq.SkipRetry),
+		},
+		{
+			name:  "completed job without on_success job enqueues nothing",
+			chain: &tasks.Chain{OnFailure: onFailure},
+		},
+		{
+			name: "job without chain enqueues nothing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTask(t, "job-1", tt.retried, 3)
+			store := eventbus.NewMockPublisher()
+			if tt.chain != nil {
+				_ = store.SaveChain(context.Background(), "job-1", tt.chain)
+			}
+			enqueuer := NewMockEnqueuer()
+
+			handler := ChainMiddleware(store, enqueuer, store, time.Hour)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+				recordResult(ctx, result)
+				return tt.err
+			}))
+
+			for i := 0; i < 2; i++ {
+				// Handling a completion twice enqueues the follow-up job once
+				if err := handler.ProcessTask(context.Background(), asynq.NewTask(tasks.TypeRandomText, nil)); err != tt.err {
+					t.Fatalf("expected handler error %v, got %v", tt.err, err)
+				}
+			}
+
+			if tt.wantID == "" {
+				if enqueuer.Len() != 0 || len(store.Events()) != 0 {
+					t.Fatalf("expected no follow-up job, got %d tasks and %d events", enqueuer.Len(), len(store.Events()))
+				}
+				return
+			}
+
+			if enqueuer.Len() != 1 {
+				t.Fatalf("expected 1 follow-up job, got %d", enqueuer.Len())
+			}
+			task, queue, ok := enqueuer.Task(tt.wantID)
+			if !ok {
+				t.Fatalf("expected follow-up job %s to be enqueued", tt.wantID)
+			}
+			if queue != tt.wantQueue {
+				t.Errorf("expected queue %s, got %s", tt.wantQueue, queue)
+			}
+			if string(task.Payload()) != tt.wantData {
+				t.Errorf("expected payload %s, got %s", tt.wantData, task.Payload())
+			}
+
+			published := store.Events()
+			if len(published) == 0 {
+				t.Fatal("expected pending event")
+			}
+			if event := published[0]; event.Kind != events.KindPending || event.JobID != tt.wantID || event.ParentID != "job-1" || event.Queue != tt.wantQueue {
+				t.Errorf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+func TestEnqueueFollowUp(t *testing.T) {
+	ctx := context.Background()
+	parent := &tasks.Parent{ID: "job-1", Type: tasks.TypeRandomText, Result: json.RawMessage(`{"text": "abc", "length": 3}`)}
+	childID := followUpID("job-1", tasks.TriggerOnSuccess)
+
+	t.Run("stores the policy and chain of the follow-up job", func(t *testing.T) {
+		store := eventbus.NewMockPublisher()
+		enqueuer := NewMockEnqueuer()
+		maxRetries := 2
+		next := &tasks.FollowUp{Type: tasks.TypeRandomText, Payload: json.RawMessage(`{"length": 1}`)}
+		followUp := &tasks.FollowUp{
+			Type:        tasks.TypeRandomText,
+			Payload:     json.RawMessage(`{"length": 5}`),
+			RetryPolicy: &tasks.RetryPolicy{MaxRetries: &maxRetries},
+			OnSuccess:   next,
+		}
+
+		id, err := enqueueFollowUp(ctx, store, enqueuer, store, time.Hour, parent, tasks.TriggerOnSuccess, followUp)
+		if err != nil {
+			t.Fatalf("enqueueFollowUp returned error: %v", err)
+		}
+		if id != childID {
+			t.Errorf("expected ID %s, got %s", childID, id)
+		}
+		if policy, _ := store.GetRetryPolicy(ctx, childID); policy == nil || *policy.MaxRetries != 2 {
+			t.Errorf("expected retry policy to be stored, got %+v", policy)
+		}
+		if chain, _ := store.GetChain(ctx, childID); chain == nil || chain.OnSuccess != next {
+			t.Errorf("expected chain to be stored, got %+v", chain)
+		}
+	})
+
+	invalid := []struct {
+		name     string
+		followUp *tasks.FollowUp
+	}{
+		{
+			name:     "rendered payload does not match the schema",
+			followUp: &tasks.FollowUp{Type: tasks.TypeRandomText, Payload: json.RawMessage(`{"length": "{{ parent.result.text }}"}`)},
+		},
+		{
+			name:     "rendered payload misses a required field",
+			followUp: &tasks.FollowUp{Type: tasks.TypeProcessWebhook, Payload: json.RawMessage(`{"source": "{{ parent.type }}"}`)},
+		},
+		{
+			name:     "unknown task type",
+			followUp: &tasks.FollowUp{Type: "unknown", Payload: json.RawMessage(`{}`)},
+		},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			store := eventbus.NewMockPublisher()
+			enqueuer := NewMockEnqueuer()
+
+			_, err := enqueueFollowUp(ctx, store, enqueuer, store, time.Hour, parent, tasks.TriggerOnSuccess, tt.followUp)
+			if !errors.Is(err, ErrInvalidJobData) {
+				t.Fatalf("expected ErrInvalidJobData, got %v", err)
+			}
+			if enqueuer.Len() != 0 {
+				t.Errorf("expected follow-up job not to be enqueued")
+			}
+
+			published := store.Events()
+			if len(published) != 1 {
+				t.Fatalf("expected 1 event, got %d", len(published))
+			}
+			if event := published[0]; event.Kind != events.KindFailed || event.JobID != childID || event.ParentID != "job-1" || event.Error == "" {
+				t.Errorf("expected failed event for the follow-up job, got %+v", event)
+			}
+		})
+	}
+}
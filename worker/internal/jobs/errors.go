@@ -2,6 +2,8 @@ package jobs
 
 import (
 	"errors"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
 )
 
 // Error definitions
@@ -9,4 +11,5 @@ var (
 	ErrInvalidJobData = errors.New("invalid job data")
 	ErrJobNotFound    = errors.New("job not found")
 	ErrJobFailed      = errors.New("job failed")
+	ErrJobCancelled   = errors.New(events.CancelledError)
 )
@@ -0,0 +1,123 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-worker/internal/eventbus"
+	"github.com/hibiken/asynq"
+)
+
+// Task metadata accessors. asynq only sets the metadata on the contexts of
+// the tasks it processes, so tests replace them.
+var (
+	getTaskID     = asynq.GetTaskID
+	getRetryCount = asynq.GetRetryCount
+	getMaxRetry   = asynq.GetMaxRetry
+)
+
+// resultKey is the context key for the result recorder
+type resultKey struct{}
+
+// resultRecorder holds the result written by a handler so it can be included
+// in the completed event
+type resultRecorder struct {
+	data []byte
+}
+
+// recordResult stores a handler result in the recorder carried by ctx, if any
+func recordResult(ctx context.Context, data []byte) {
+	if r, ok := ctx.Value(resultKey{}).(*resultRecorder); ok {
+		r.data = data
+	}
+}
+
+// EventMiddleware publishes processing, completed, retrying and failed events
+// around every task handler and makes a progress reporter available to the
+// handler through its context. Tasks of cancelled jobs are archived without
+// running; the API has already published the cancelled event for them.
+func EventMiddleware(publisher eventbus.Publisher, store eventbus.ProgressStore, cancellations eventbus.CancellationChecker) asynq.MiddlewareFunc {
+	logger := log.New(log.Writer(), "[JobEvents] ", log.LstdFlags)
+
+	publish := func(ctx context.Context, event *events.JobEvent) {
+		if err := publisher.Publish(ctx, event); err != nil {
+			logger.Printf("Failed to publish %s event for job %s: %v", event.Kind, event.JobID, err)
+		}
+	}
+
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			jobID, _ := getTaskID(ctx)
+
+			// Skip jobs cancelled while pending or before a retry
+			cancelled, err := cancellations.IsCancelled(ctx, jobID)
+			if err != nil {
+				logger.Printf("Failed to check cancellation of job %s: %v", jobID, err)
+			}
+			if cancelled {
+				logger.Printf("Skipping cancelled job %s", jobID)
+				return fmt.Errorf("%w: %w", ErrJobCancelled, asynq.SkipRetry)
+			}
+
+			processing := events.NewJobEvent(events.KindProcessing, jobID, t.Type())
+			retried, _ := getRetryCount(ctx)
+			processing.Attempt = retried + 1
+			publish(ctx, processing)
+
+			// Share the recorder of an outer middleware so it can read the result too
+			recorder, ok := ctx.Value(resultKey{}).(*resultRecorder)
+			if !ok {
+				recorder = &resultRecorder{}
+			}
+			handlerCtx := context.WithValue(ctx, resultKey{}, recorder)
+			handlerCtx = withProgressReporter(handlerCtx, &progressReporter{
+				jobID:     jobID,
+				taskType:  t.Type(),
+				store:     store,
+				publisher: publisher,
+			})
+			err = next.ProcessTask(handlerCtx, t)
+
+			// Use a fresh context so events are published even if the task timed out
+			pubCtx := context.Background()
+			if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+				if cancelled, _ := cancellations.IsCancelled(pubCtx, jobID); cancelled {
+					logger.Printf("Job %s was cancelled while running", jobID)
+					return fmt.Errorf("%w: %w", ErrJobCancelled, asynq.SkipRetry)
+				}
+			}
+			if err != nil {
+				event := events.NewJobEvent(failureKind(ctx, err), jobID, t.Type())
+				event.Error = err.Error()
+				if delay, ok := nextRetryDelay(err); ok && event.Kind == events.KindRetrying {
+					nextRetryAt := event.Timestamp.Add(delay)
+					event.NextRetryAt = &nextRetryAt
+				}
+				publish(pubCtx, event)
+				return err
+			}
+
+			event := events.NewJobEvent(events.KindCompleted, jobID, t.Type())
+			event.Result = recorder.data
+			publish(pubCtx, event)
+			return nil
+		})
+	}
+}
+
+// failureKind determines whether a failed task will be retried
+func failureKind(ctx context.Context, err error) events.Kind {
+	if errors.Is(err, asynq.SkipRetry) {
+		return events.KindFailed
+	}
+
+	retried, _ := getRetryCount(ctx)
+	maxRetry, _ := getMaxRetry(ctx)
+	if retried >= maxRetry {
+		return events.KindFailed
+	}
+	return events.KindRetrying
+}
@@ -0,0 +1,173 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-worker/internal/eventbus"
+	"github.com/hibiken/asynq"
+)
+
+func TestEventMiddleware(t *testing.T) {
+	boom := errors.New("boom")
+
+	tests := []struct {
+		name      string
+		err       error
+		retried   int
+		wantKinds []events.Kind
+	}{
+		{
+			name:      "completed job",
+			wantKinds: []events.Kind{events.KindProcessing, events.KindCompleted},
+		},
+		{
+			name:      "failed job with retries left",
+			err:       boom,
+			retried:   1,
+			wantKinds: []events.Kind{events.KindProcessing, events.KindRetrying},
+		},
+		{
+			name:      "failed job without retries left",
+			err:       boom,
+			retried:   3,
+			wantKinds: []events.Kind{events.KindProcessing, events.KindFailed},
+		},
+		{
+			name:      "failed job that must not be retried",
+			err:       fmt.Errorf("%w: %w", boom, asynq.SkipRetry),
+			wantKinds: []events.Kind{events.KindProcessing, events.KindFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTask(t, "job-1", tt.retried, 3)
+			store := eventbus.NewMockPublisher()
+
+			handler := EventMiddleware(store, store, store)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+				recordResult(ctx, []byte(`{"text":"abc"}`))
+				return tt.err
+			}))
+			if err := handler.ProcessTask(context.Background(), asynq.NewTask(tasks.TypeRandomText, nil)); err != tt.err {
+				t.Fatalf("expected handler error %v, got %v", tt.err, err)
+			}
+
+			published := store.Events()
+			if len(published) != len(tt.wantKinds) {
+				t.Fatalf("expected %d events, got %d", len(tt.wantKinds), len(published))
+			}
+			for i, kind := range tt.wantKinds {
+				if published[i].Kind != kind || published[i].JobID != "job-1" || published[i].TaskType != tasks.TypeRandomText {
+					t.Errorf("expected %s event for job-1, got %+v", kind, published[i])
+				}
+			}
+			if attempt := published[0].Attempt; attempt != tt.retried+1 {
+				t.Errorf("expected attempt %d, got %d", tt.retried+1, attempt)
+			}
+
+			last := published[len(published)-1]
+			if tt.err == nil {
+				if string(last.Result) != `{"text":"abc"}` {
+					t.Errorf("expected result on completed event, got %s", last.Result)
+				}
+			} else if last.Error != tt.err.Error() {
+				t.Errorf("expected error %q on %s event, got %q", tt.err, last.Kind, last.Error)
+			}
+		})
+	}
+}
+
+func TestEventMiddlewareRetryDelay(t *testing.T) {
+	withTask(t, "job-1", 0, 3)
+	store := eventbus.NewMockPublisher()
+
+	handler := EventMiddleware(store, store, store)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		return &retryDelayError{err: errors.New("boom"), delay: time.Minute}
+	}))
+	_ = handler.ProcessTask(context.Background(), asynq.NewTask(tasks.TypeRandomText, nil))
+
+	published := store.Events()
+	retrying := published[len(published)-1]
+	if retrying.Kind != events.KindRetrying || retrying.NextRetryAt == nil {
+		t.Fatalf("expected retrying event with next retry time, got %+v", retrying)
+	}
+	if delay := retrying.NextRetryAt.Sub(retrying.Timestamp); delay != time.Minute {
+		t.Errorf("expected next retry in 1m, got %s", delay)
+	}
+}
+
+func TestEventMiddlewareCancellation(t *testing.T) {
+	t.Run("job cancelled before it runs is skipped", func(t *testing.T) {
+		withTask(t, "job-1", 0, 3)
+		store := eventbus.NewMockPublisher()
+		store.Cancel("job-1")
+
+		ran := false
+		handler := EventMiddleware(store, store, store)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			ran = true
+			return nil
+		}))
+		err := handler.ProcessTask(context.Background(), asynq.NewTask(tasks.TypeRandomText, nil))
+
+		if !errors.Is(err, ErrJobCancelled) || !errors.Is(err, asynq.SkipRetry) {
+			t.Fatalf("expected cancelled error that skips retries, got %v", err)
+		}
+		if ran {
+			t.Error("expected handler of cancelled job not to run")
+		}
+		if len(store.Events()) != 0 {
+			t.Errorf("expected no events, got %d", len(store.Events()))
+		}
+	})
+
+	t.Run("job cancelled while running stops cooperatively", func(t *testing.T) {
+		withTask(t, "job-1", 0, 3)
+		store := eventbus.NewMockPublisher()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		handler := EventMiddleware(store, store, store)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			// The API marks the job cancelled and asynq cancels its context
+			store.Cancel("job-1")
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+		err := handler.ProcessTask(ctx, asynq.NewTask(tasks.TypeRandomText, nil))
+
+		if !errors.Is(err, ErrJobCancelled) || !errors.Is(err, asynq.SkipRetry) {
+			t.Fatalf("expected cancelled error that skips retries, got %v", err)
+		}
+		published := store.Events()
+		if len(published) != 1 || published[0].Kind != events.KindProcessing {
+			t.Errorf("expected only the processing event, got %+v", published)
+		}
+	})
+
+	t.Run("context cancelled without cancelling the job fails it", func(t *testing.T) {
+		withTask(t, "job-1", 0, 3)
+		store := eventbus.NewMockPublisher()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		handler := EventMiddleware(store, store, store)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			cancel()
+			return ctx.Err()
+		}))
+		err := handler.ProcessTask(ctx, asynq.NewTask(tasks.TypeRandomText, nil))
+
+		if errors.Is(err, ErrJobCancelled) {
+			t.Fatalf("expected job that was not cancelled to fail normally, got %v", err)
+		}
+		published := store.Events()
+		if last := published[len(published)-1]; last.Kind != events.KindRetrying {
+			t.Errorf("expected retrying event, got %+v", last)
+		}
+	})
+}
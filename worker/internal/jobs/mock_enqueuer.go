@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/hibiken/asynq"
+)
+
+// MockEnqueuer is an in-memory implementation of the Enqueuer interface. Like
+// asynq, it rejects tasks whose ID is already taken.
+type MockEnqueuer struct {
+	enqueued map[string]*asynq.Task
+	queues   map[string]string
+	mu       sync.RWMutex
+}
+
+// NewMockEnqueuer creates a new mock enqueuer
+func NewMockEnqueuer() *MockEnqueuer {
+	return &MockEnqueuer{
+		enqueued: make(map[string]*asynq.Task),
+		queues:   make(map[string]string),
+	}
+}
+
+// EnqueueContext stores a task in memory under the ID given by its options
+func (m *MockEnqueuer) EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	info := &asynq.TaskInfo{Type: task.Type(), Payload: task.Payload(), Queue: tasks.QueueDefault}
+	for _, opt := range opts {
+		switch opt.Type() {
+		case asynq.TaskIDOpt:
+			info.ID = opt.Value().(string)
+		case asynq.QueueOpt:
+			info.Queue = opt.Value().(string)
+		}
+	}
+	if info.ID == "" {
+		return nil, fmt.Errorf("mock enqueuer requires a task ID")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.enqueued[info.ID]; ok {
+		return nil, asynq.ErrTaskIDConflict
+	}
+	m.enqueued[info.ID] = task
+	m.queues[info.ID] = info.Queue
+	return info, nil
+}
+
+// Task returns an enqueued task and its queue by ID
+func (m *MockEnqueuer) Task(id string) (*asynq.Task, string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	task, ok := m.enqueued[id]
+	return task, m.queues[id], ok
+}
+
+// Len returns the number of enqueued tasks
+func (m *MockEnqueuer) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.enqueued)
+}
@@ -2,31 +2,42 @@ package jobs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"strings"
 	"time"
 
-	"github.com/dustinleblanc/go-bespin-worker/pkg/tasks"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-worker/internal/webhooks"
+	"github.com/dustinleblanc/go-bespin-worker/pkg/models"
 	"github.com/hibiken/asynq"
 )
 
+// WebhookProcessor processes stored webhook receipts
+type WebhookProcessor interface {
+	// Process processes a webhook receipt by ID and returns it with its new status
+	Process(ctx context.Context, receiptID string) (*models.WebhookReceipt, error)
+}
+
 // Processor handles job processing
 type Processor struct {
-	logger *log.Logger
+	webhooks WebhookProcessor
+	logger   *log.Logger
 }
 
-// NewProcessor creates a new job processor
-func NewProcessor() *Processor {
+// NewProcessor creates a new job processor. Webhook jobs are processed with webhookProcessor.
+func NewProcessor(webhookProcessor WebhookProcessor) *Processor {
 	return &Processor{
-		logger: log.New(log.Writer(), "[JobProcessor] ", log.LstdFlags),
+		webhooks: webhookProcessor,
+		logger:   log.New(log.Writer(), "[JobProcessor] ", log.LstdFlags),
 	}
 }
 
 // HandleRandomTextTask processes a random text job
 func (p *Processor) HandleRandomTextTask(ctx context.Context, t *asynq.Task) error {
-	payload, err := tasks.DeserializeRandomText(t.Payload())
+	payload, err := tasks.DecodeRandomText(t.Payload())
 	if err != nil {
 		return fmt.Errorf("failed to deserialize random text payload: %w", err)
 	}
@@ -34,40 +45,90 @@ func (p *Processor) HandleRandomTextTask(ctx context.Context, t *asynq.Task) err
 	p.logger.Printf("Processing random text job with length: %d", payload.Length)
 
 	// Generate random text
-	result := p.generateRandomText(payload.Length)
-
-	// In a real application, you might want to store the result somewhere
-	// or send it back through a channel/webhook
-	p.logger.Printf("Generated random text: %s", result)
+	text, err := p.generateRandomText(ctx, payload.Length)
+	if err != nil {
+		return err
+	}
+	p.logger.Printf("Generated random text: %s", text)
 
-	return nil
+	// Store the result so the API can return it
+	return p.writeResult(ctx, t, tasks.RandomTextResult{
+		Text:   text,
+		Length: payload.Length,
+	})
 }
 
-// HandleWebhookTask processes a webhook job
+// HandleWebhookTask processes a webhook job by processing its stored receipt.
+// Jobs whose receipt does not exist are not retried.
 func (p *Processor) HandleWebhookTask(ctx context.Context, t *asynq.Task) error {
-	payload, err := tasks.DeserializeWebhook(t.Payload())
+	payload, err := tasks.DecodeWebhook(t.Payload())
 	if err != nil {
 		return fmt.Errorf("failed to deserialize webhook payload: %w", err)
 	}
 
-	p.logger.Printf("Processing webhook job: ID=%s, Source=%s, Event=%s",
-		payload.WebhookID, payload.Source, payload.Event)
+	p.logger.Printf("Processing webhook job: ReceiptID=%s, Source=%s, Event=%s",
+		payload.ReceiptID, payload.Source, payload.Event)
 
-	// Here you would typically:
-	// 1. Fetch the webhook data from the database
-	// 2. Process it according to the source and event type
-	// 3. Update the webhook status in the database
-	// 4. Send any necessary notifications
+	receipt, err := p.webhooks.Process(ctx, payload.ReceiptID)
+	if err != nil {
+		if errors.Is(err, webhooks.ErrReceiptNotFound) {
+			return fmt.Errorf("%w: %v", asynq.SkipRetry, err)
+		}
+		return err
+	}
 
+	return p.writeResult(ctx, t, tasks.WebhookResult{
+		ReceiptID: receipt.ID,
+		Source:    receipt.Source,
+		Event:     receipt.Event,
+		Status:    string(receipt.Status),
+	})
+}
+
+// writeResult stores a task result alongside the task in Redis. The result is
+// kept for as long as the retention the task was enqueued with.
+func (p *Processor) writeResult(ctx context.Context, t *asynq.Task, result interface{}) error {
+	data, err := tasks.EncodeResult(result)
+	if err != nil {
+		return err
+	}
+	recordResult(ctx, data)
+
+	// Tasks created outside of a server (e.g. in tests) have no result writer
+	w := t.ResultWriter()
+	if w == nil {
+		return nil
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write task result: %w", err)
+	}
 	return nil
 }
 
-// generateRandomText generates a random text of the specified length
-func (p *Processor) generateRandomText(length int) string {
+// generateRandomText generates a random text of the specified length. It stops
+// early if ctx is cancelled.
+func (p *Processor) generateRandomText(ctx context.Context, length int) (string, error) {
 	p.logger.Printf("Generating random text of length: %d", length)
 
-	// Simulate processing time
-	time.Sleep(2 * time.Second)
+	// Simulate processing time, reporting progress along the way
+	progress := ProgressFromContext(ctx)
+	const steps = 4
+	for step := 1; step <= steps; step++ {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("random text generation stopped: %w", ctx.Err())
+		case <-time.After(2 * time.Second / steps):
+		}
+
+		percent := step * 100 / steps
+		if err := progress.Report(ctx, percent, fmt.Sprintf("Generating text (%d%%)", percent), map[string]interface{}{
+			"step":  step,
+			"steps": steps,
+		}); err != nil {
+			p.logger.Printf("Failed to report progress: %v", err)
+		}
+	}
 
 	words := []string{
 		"cloud", "computing", "platform", "service", "data",
@@ -84,5 +145,5 @@ func (p *Processor) generateRandomText(length int) string {
 		result.WriteString(" ")
 	}
 
-	return strings.TrimSpace(result.String())
+	return strings.TrimSpace(result.String()), nil
 }
@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-worker/internal/eventbus"
+)
+
+// ProgressReporter reports the progress of the job being processed
+type ProgressReporter interface {
+	// Report stores the job's progress and streams it to subscribers.
+	// Percent must be between 0 and 100.
+	Report(ctx context.Context, percent int, message string, metadata map[string]interface{}) error
+}
+
+// progressKey is the context key for the progress reporter
+type progressKey struct{}
+
+// ProgressFromContext returns the progress reporter for the job being
+// processed. Outside of a handler it returns a reporter that does nothing.
+func ProgressFromContext(ctx context.Context) ProgressReporter {
+	if r, ok := ctx.Value(progressKey{}).(ProgressReporter); ok {
+		return r
+	}
+	return noopProgressReporter{}
+}
+
+// withProgressReporter returns a context carrying a progress reporter
+func withProgressReporter(ctx context.Context, r ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, r)
+}
+
+// progressReporter stores progress and publishes progress events for one job
+type progressReporter struct {
+	jobID     string
+	taskType  string
+	store     eventbus.ProgressStore
+	publisher eventbus.Publisher
+}
+
+// Report implements ProgressReporter
+func (r *progressReporter) Report(ctx context.Context, percent int, message string, metadata map[string]interface{}) error {
+	progress, err := events.NewProgress(percent, message, metadata)
+	if err != nil {
+		return fmt.Errorf("invalid progress: %w", err)
+	}
+
+	if err := r.store.SaveProgress(ctx, r.jobID, progress); err != nil {
+		return err
+	}
+
+	event := events.NewJobEvent(events.KindProgress, r.jobID, r.taskType)
+	event.Progress = progress
+	return r.publisher.Publish(ctx, event)
+}
+
+// noopProgressReporter is used when no job is being processed
+type noopProgressReporter struct{}
+
+// Report implements ProgressReporter
+func (noopProgressReporter) Report(ctx context.Context, percent int, message string, metadata map[string]interface{}) error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dustinleblanc/go-bespin-contract/events"
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-worker/internal/eventbus"
+	"github.com/hibiken/asynq"
+)
+
+// withTask makes the task metadata accessors report a task with the given ID
+// and retry counts until the test finishes
+func withTask(t *testing.T, id string, retried, maxRetry int) {
+	t.Helper()
+	taskID, retryCount, maxRetryCount := getTaskID, getRetryCount, getMaxRetry
+	getTaskID = func(context.Context) (string, bool) { return id, true }
+	getRetryCount = func(context.Context) (int, bool) { return retried, true }
+	getMaxRetry = func(context.Context) (int, bool) { return maxRetry, true }
+	t.Cleanup(func() {
+		getTaskID, getRetryCount, getMaxRetry = taskID, retryCount, maxRetryCount
+	})
+}
+
+func TestProgressReporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		percent int
+		wantErr bool
+	}{
+		{name: "progress is stored and published", percent: 40},
+		{name: "complete progress is stored and published", percent: 100},
+		{name: "negative percent is rejected", percent: -1, wantErr: true},
+		{name: "percent above 100 is rejected", percent: 101, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTask(t, "job-1", 0, 3)
+			store := eventbus.NewMockPublisher()
+
+			var reportErr error
+			handler := EventMiddleware(store, store, store)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+				reportErr = ProgressFromContext(ctx).Report(ctx, tt.percent, "halfway", map[string]interface{}{"rows": 4})
+				return nil
+			}))
+			if err := handler.ProcessTask(context.Background(), asynq.NewTask(tasks.TypeRandomText, nil)); err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+
+			var progressEvents []*events.JobEvent
+			for _, event := range store.Events() {
+				if event.Kind == events.KindProgress {
+					progressEvents = append(progressEvents, event)
+				}
+			}
+
+			if tt.wantErr {
+				if reportErr == nil {
+					t.Fatal("expected Report to return an error")
+				}
+				if store.Progress("job-1") != nil || len(progressEvents) != 0 {
+					t.Errorf("expected invalid progress not to be stored or published")
+				}
+				return
+			}
+
+			if reportErr != nil {
+				t.Fatalf("Report returned error: %v", reportErr)
+			}
+			progress := store.Progress("job-1")
+			if progress == nil || progress.Percent != tt.percent || progress.Message != "halfway" || progress.Metadata["rows"] != 4 {
+				t.Fatalf("unexpected stored progress %+v", progress)
+			}
+			if len(progressEvents) != 1 {
+				t.Fatalf("expected 1 progress event, got %d", len(progressEvents))
+			}
+			if event := progressEvents[0]; event.JobID != "job-1" || event.TaskType != tasks.TypeRandomText || event.Progress != progress {
+				t.Errorf("unexpected progress event %+v", event)
+			}
+		})
+	}
+}
+
+func TestProgressFromContextOutsideHandler(t *testing.T) {
+	if err := ProgressFromContext(context.Background()).Report(context.Background(), 50, "", nil); err != nil {
+		t.Errorf("expected reporter outside a handler to do nothing, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-worker/internal/eventbus"
+	"github.com/hibiken/asynq"
+)
+
+// RetryPolicies holds the default retry policy of each task type
+type RetryPolicies map[string]tasks.RetryPolicy
+
+// retryDelayError carries the delay before the next attempt of a failed task
+// from RetryMiddleware to RetryDelay, since asynq only passes the error along
+type retryDelayError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryDelayError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryDelayError) Unwrap() error {
+	return e.err
+}
+
+// nextRetryDelay returns the delay RetryMiddleware chose for a failed task, if any
+func nextRetryDelay(err error) (time.Duration, bool) {
+	var delayErr *retryDelayError
+	if errors.As(err, &delayErr) {
+		return delayErr.delay, true
+	}
+	return 0, false
+}
+
+// RetryDelay implements asynq.RetryDelayFunc using the delay chosen by
+// RetryMiddleware, falling back to asynq's default exponential delay
+func RetryDelay(n int, err error, t *asynq.Task) time.Duration {
+	if delay, ok := nextRetryDelay(err); ok {
+		return delay
+	}
+	return asynq.DefaultRetryDelayFunc(n, err, t)
+}
+
+// RetryMiddleware applies the retry policy of each job. The policy the job was
+// enqueued with takes precedence over the default policy of its task type.
+// Timeouts and deadlines limit the handler's context, jobs that have used up
+// their retries are failed for good, and the backoff picks the retry delay.
+func RetryMiddleware(store eventbus.RetryPolicyStore, defaults RetryPolicies) asynq.MiddlewareFunc {
+	logger := log.New(log.Writer(), "[Retry] ", log.LstdFlags)
+
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			jobID, _ := getTaskID(ctx)
+
+			policy := defaults[t.Type()]
+			jobPolicy, err := store.GetRetryPolicy(ctx, jobID)
+			if err != nil {
+				logger.Printf("Failed to get retry policy of job %s: %v", jobID, err)
+			}
+			if jobPolicy != nil {
+				policy = jobPolicy.WithDefaults(policy)
+			}
+
+			handlerCtx := ctx
+			if policy.Deadline != nil {
+				if time.Now().After(*policy.Deadline) {
+					return fmt.Errorf("job deadline %s has passed: %w", policy.Deadline.Format(time.RFC3339), asynq.SkipRetry)
+				}
+				var cancel context.CancelFunc
+				handlerCtx, cancel = context.WithDeadline(handlerCtx, *policy.Deadline)
+				defer cancel()
+			}
+			if policy.Timeout > 0 {
+				var cancel context.CancelFunc
+				handlerCtx, cancel = context.WithTimeout(handlerCtx, policy.Timeout)
+				defer cancel()
+			}
+
+			err = next.ProcessTask(handlerCtx, t)
+			if err == nil || errors.Is(err, asynq.SkipRetry) {
+				return err
+			}
+
+			retried, _ := getRetryCount(ctx)
+			if policy.MaxRetries != nil && retried >= *policy.MaxRetries {
+				return fmt.Errorf("%w (retries exhausted: %w)", err, asynq.SkipRetry)
+			}
+
+			if policy.Backoff != nil {
+				return &retryDelayError{err: err, delay: policy.Backoff.RetryDelay(retried)}
+			}
+			return err
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/dustinleblanc/go-bespin-worker/internal/eventbus"
+	"github.com/hibiken/asynq"
+)
+
+func TestRetryMiddleware(t *testing.T) {
+	boom := errors.New("boom")
+	one, two := 1, 2
+	fixed := func(delay time.Duration) *tasks.Backoff {
+		return &tasks.Backoff{Strategy: tasks.BackoffFixed, Delay: delay}
+	}
+	passed := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name      string
+		defaults  RetryPolicies
+		policy    *tasks.RetryPolicy
+		err       error
+		retried   int
+		wantRun   bool
+		wantSkip  bool
+		wantDelay time.Duration
+	}{
+		{
+			name:    "completed job is returned as is",
+			wantRun: true,
+		},
+		{
+			name:    "failed job without policy uses asynq's retries",
+			err:     boom,
+			retried: 5,
+			wantRun: true,
+		},
+		{
+			name:     "failed job with retries left is retried",
+			defaults: RetryPolicies{tasks.TypeRandomText: {MaxRetries: &two}},
+			err:      boom,
+			retried:  1,
+			wantRun:  true,
+		},
+		{
+			name:     "failed job that used up the default retries is failed",
+			defaults: RetryPolicies{tasks.TypeRandomText: {MaxRetries: &two}},
+			err:      boom,
+			retried:  2,
+			wantRun:  true,
+			wantSkip: true,
+		},
+		{
+			name:     "job policy takes precedence over the default policy",
+			defaults: RetryPolicies{tasks.TypeRandomText: {MaxRetries: &two}},
+			policy:   &tasks.RetryPolicy{MaxRetries: &one},
+			err:      boom,
+			retried:  1,
+			wantRun:  true,
+			wantSkip: true,
+		},
+		{
+			name:      "unset fields of the job policy fall back to the default policy",
+			defaults:  RetryPolicies{tasks.TypeRandomText: {MaxRetries: &two, Backoff: fixed(time.Second)}},
+			policy:    &tasks.RetryPolicy{Timeout: time.Minute},
+			err:       boom,
+			wantRun:   true,
+			wantDelay: time.Second,
+		},
+		{
+			name:      "backoff picks the retry delay",
+			defaults:  RetryPolicies{tasks.TypeRandomText: {Backoff: fixed(time.Second)}},
+			policy:    &tasks.RetryPolicy{Backoff: fixed(time.Minute)},
+			err:       boom,
+			wantRun:   true,
+			wantDelay: time.Minute,
+		},
+		{
+			name:     "job past its deadline does not run",
+			policy:   &tasks.RetryPolicy{Deadline: &passed},
+			wantSkip: true,
+		},
+		{
+			name:     "failed job that must not be retried is returned as is",
+			policy:   &tasks.RetryPolicy{Backoff: fixed(time.Minute)},
+			err:      asynq.SkipRetry,
+			wantRun:  true,
+			wantSkip: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTask(t, "job-1", tt.retried, 25)
+			store := eventbus.NewMockPublisher()
+			if tt.policy != nil {
+				_ = store.SaveRetryPolicy(context.Background(), "job-1", tt.policy)
+			}
+
+			ran := false
+			handler := RetryMiddleware(store, tt.defaults)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+				ran = true
+				return tt.err
+			}))
+			task := asynq.NewTask(tasks.TypeRandomText, nil)
+			err := handler.ProcessTask(context.Background(), task)
+
+			if ran != tt.wantRun {
+				t.Errorf("expected handler to run: %v, ran: %v", tt.wantRun, ran)
+			}
+			if tt.err == nil && !tt.wantSkip {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("expected error wrapping %v, got %v", tt.err, err)
+			}
+			if skip := errors.Is(err, asynq.SkipRetry); skip != tt.wantSkip {
+				t.Errorf("expected retries to be skipped: %v, got error %v", tt.wantSkip, err)
+			}
+
+			delay, ok := nextRetryDelay(err)
+			if tt.wantDelay == 0 {
+				if ok {
+					t.Errorf("expected no retry delay, got %s", delay)
+				}
+				// asynq's default delay is randomized, so only check that one is used
+				if got := RetryDelay(1, err, task); got <= 0 {
+					t.Errorf("expected asynq's default retry delay, got %s", got)
+				}
+				return
+			}
+			if !ok || delay != tt.wantDelay {
+				t.Errorf("expected retry delay %s, got %s", tt.wantDelay, delay)
+			}
+			if got := RetryDelay(1, err, task); got != tt.wantDelay {
+				t.Errorf("expected RetryDelay to return %s, got %s", tt.wantDelay, got)
+			}
+		})
+	}
+}
+
+func TestRetryMiddlewareLimitsRunTime(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		policy *tasks.RetryPolicy
+		within time.Duration
+	}{
+		{name: "timeout limits an attempt", policy: &tasks.RetryPolicy{Timeout: time.Minute}, within: time.Minute},
+		{name: "deadline limits an attempt", policy: &tasks.RetryPolicy{Deadline: &future}, within: time.Hour},
+		{name: "earlier of timeout and deadline wins", policy: &tasks.RetryPolicy{Timeout: time.Minute, Deadline: &future}, within: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTask(t, "job-1", 0, 25)
+			store := eventbus.NewMockPublisher()
+			_ = store.SaveRetryPolicy(context.Background(), "job-1", tt.policy)
+
+			var deadline time.Time
+			var hasDeadline bool
+			handler := RetryMiddleware(store, nil)(asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+				deadline, hasDeadline = ctx.Deadline()
+				return nil
+			}))
+			if err := handler.ProcessTask(context.Background(), asynq.NewTask(tasks.TypeRandomText, nil)); err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+
+			if !hasDeadline {
+				t.Fatal("expected handler context to have a deadline")
+			}
+			if remaining := time.Until(deadline); remaining > tt.within || remaining < tt.within-time.Second {
+				t.Errorf("expected deadline in about %s, got %s", tt.within, remaining)
+			}
+		})
+	}
+}
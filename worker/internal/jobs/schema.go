@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/dustinleblanc/go-bespin-contract/tasks"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemas caches the compiled payload schemas of task types by task type
+var schemas sync.Map
+
+// validatePayload validates a task payload against the JSON Schema of its
+// task type
+func validatePayload(taskType string, payload []byte) error {
+	schema, err := compiledSchema(taskType)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJobData, err)
+	}
+	if err := schema.Validate(value); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJobData, err)
+	}
+	return nil
+}
+
+// compiledSchema returns the compiled payload schema of a task type
+func compiledSchema(taskType string) (*jsonschema.Schema, error) {
+	if schema, ok := schemas.Load(taskType); ok {
+		return schema.(*jsonschema.Schema), nil
+	}
+
+	data, err := tasks.Schema(taskType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJobData, err)
+	}
+	schema, err := jsonschema.CompileString(taskType+".json", string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema for task type %s: %w", taskType, err)
+	}
+	schemas.Store(taskType, schema)
+	return schema, nil
+}
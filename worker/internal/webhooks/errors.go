@@ -0,0 +1,12 @@
+package webhooks
+
+import (
+	"errors"
+)
+
+// Error definitions
+var (
+	ErrReceiptNotFound = errors.New("webhook receipt not found")
+	ErrIgnored         = errors.New("webhook ignored")
+	ErrInvalidPattern  = errors.New("invalid webhook handler pattern")
+)
@@ -0,0 +1,50 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dustinleblanc/go-bespin-worker/pkg/models"
+	"gorm.io/gorm"
+)
+
+// GormRepository implements Repository using GORM
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRepository creates a new GORM repository
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{db: db}
+}
+
+// GetByID retrieves a webhook receipt by ID
+func (r *GormRepository) GetByID(ctx context.Context, id string) (*models.WebhookReceipt, error) {
+	var receipt models.WebhookReceipt
+	result := r.db.WithContext(ctx).First(&receipt, "id = ?", id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrReceiptNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get webhook receipt: %w", result.Error)
+	}
+	return &receipt, nil
+}
+
+// Update updates the status and error of a webhook receipt and records them
+// in the receipt's history
+func (r *GormRepository) Update(ctx context.Context, receipt *models.WebhookReceipt) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(receipt).
+			Select("status", "error", "updated_at").
+			Updates(receipt)
+		if result.Error != nil {
+			return result.Error
+		}
+		return tx.Create(receipt.StatusChange()).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update webhook receipt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dustinleblanc/go-bespin-worker/pkg/models"
+)
+
+// MockRepository is an in-memory implementation of the Repository interface
+type MockRepository struct {
+	receipts map[string]*models.WebhookReceipt
+	history  map[string][]models.WebhookStatusChange
+	mu       sync.RWMutex
+}
+
+// NewMockRepository creates a new mock repository holding receipts
+func NewMockRepository(receipts ...*models.WebhookReceipt) *MockRepository {
+	r := &MockRepository{
+		receipts: make(map[string]*models.WebhookReceipt),
+		history:  make(map[string][]models.WebhookStatusChange),
+	}
+	for _, receipt := range receipts {
+		copied := *receipt
+		r.receipts[receipt.ID] = &copied
+	}
+	return r
+}
+
+// GetByID retrieves a webhook receipt by ID from memory
+func (r *MockRepository) GetByID(ctx context.Context, id string) (*models.WebhookReceipt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	receipt, ok := r.receipts[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReceiptNotFound, id)
+	}
+	copied := *receipt
+	return &copied, nil
+}
+
+// Update updates a webhook receipt in memory and records its status in its
+// history
+func (r *MockRepository) Update(ctx context.Context, receipt *models.WebhookReceipt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.receipts[receipt.ID]; !ok {
+		return fmt.Errorf("%w: %s", ErrReceiptNotFound, receipt.ID)
+	}
+	copied := *receipt
+	r.receipts[receipt.ID] = &copied
+	r.history[receipt.ID] = append(r.history[receipt.ID], *receipt.StatusChange())
+	return nil
+}
+
+// History returns the statuses recorded for a webhook receipt, oldest first
+func (r *MockRepository) History(id string) []models.WebhookStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]models.WebhookStatus, 0, len(r.history[id]))
+	for _, change := range r.history[id] {
+		statuses = append(statuses, change.Status)
+	}
+	return statuses
+}
@@ -0,0 +1,103 @@
+// Package webhooks processes the webhook receipts stored by the API. A
+// receipt is loaded by ID, marked as processing, routed to the handler
+// registered for its source and event and marked as completed, ignored or
+// failed with the handler's error.
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/dustinleblanc/go-bespin-worker/pkg/models"
+)
+
+// WebhookHandler handles a webhook receipt
+type WebhookHandler interface {
+	// Handle processes a receipt. Returning an error fails the receipt's
+	// processing attempt, except for ErrIgnored, which marks it as ignored.
+	Handle(ctx context.Context, receipt *models.WebhookReceipt) error
+}
+
+// HandlerFunc is an adapter to use ordinary functions as handlers
+type HandlerFunc func(ctx context.Context, receipt *models.WebhookReceipt) error
+
+// Handle calls f(ctx, receipt)
+func (f HandlerFunc) Handle(ctx context.Context, receipt *models.WebhookReceipt) error {
+	return f(ctx, receipt)
+}
+
+// LogHandler returns a handler that only logs the receipts it handles
+func LogHandler() WebhookHandler {
+	logger := log.New(log.Writer(), "[WebhookHandler] ", log.LstdFlags)
+	return HandlerFunc(func(ctx context.Context, receipt *models.WebhookReceipt) error {
+		logger.Printf("Received %s webhook %s from %s (%d bytes)", receipt.Event, receipt.ID, receipt.Source, len(receipt.Payload))
+		return nil
+	})
+}
+
+// Processor moves webhook receipts through their statuses while a handler
+// processes them
+type Processor struct {
+	repo    Repository
+	handler WebhookHandler
+	logger  *log.Logger
+}
+
+// NewProcessor creates a new webhook processor that loads and updates
+// receipts with repo and processes them with handler
+func NewProcessor(repo Repository, handler WebhookHandler) *Processor {
+	return &Processor{
+		repo:    repo,
+		handler: handler,
+		logger:  log.New(log.Writer(), "[WebhookProcessor] ", log.LstdFlags),
+	}
+}
+
+// Process loads a receipt, marks it as processing and runs the handler. The
+// receipt is marked as completed if the handler succeeds, as ignored if no
+// handler applies and as failed with the handler's error otherwise; a failed
+// receipt is processed again when its job is retried. Receipts that already
+// completed or were ignored are not processed again, so redelivered jobs are
+// harmless.
+func (p *Processor) Process(ctx context.Context, receiptID string) (*models.WebhookReceipt, error) {
+	receipt, err := p.repo.GetByID(ctx, receiptID)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.IsComplete() {
+		p.logger.Printf("Webhook receipt %s has already been processed", receipt.ID)
+		return receipt, nil
+	}
+
+	receipt.SetStatus(models.WebhookStatusProcessing, nil)
+	if err := p.repo.Update(ctx, receipt); err != nil {
+		return nil, err
+	}
+
+	handleErr := p.handler.Handle(ctx, receipt)
+	switch {
+	case errors.Is(handleErr, ErrIgnored):
+		p.logger.Printf("Ignored %s webhook receipt %s from %s: %v", receipt.Event, receipt.ID, receipt.Source, handleErr)
+		receipt.SetStatus(models.WebhookStatusIgnored, nil)
+		handleErr = nil
+	case handleErr != nil:
+		receipt.SetStatus(models.WebhookStatusFailed, handleErr)
+	default:
+		receipt.SetStatus(models.WebhookStatusCompleted, nil)
+	}
+
+	// Record the outcome even if the job timed out or was cancelled
+	if err := p.repo.Update(context.WithoutCancel(ctx), receipt); err != nil {
+		return nil, err
+	}
+	if handleErr != nil {
+		return receipt, fmt.Errorf("failed to handle webhook receipt %s: %w", receipt.ID, handleErr)
+	}
+
+	if receipt.Status == models.WebhookStatusCompleted {
+		p.logger.Printf("Processed %s webhook receipt %s from %s", receipt.Event, receipt.ID, receipt.Source)
+	}
+	return receipt, nil
+}
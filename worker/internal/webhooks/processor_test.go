@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dustinleblanc/go-bespin-worker/pkg/models"
+)
+
+func TestProcess(t *testing.T) {
+	ctx := context.Background()
+	receipt := &models.WebhookReceipt{ID: "r-1", Source: "github", Event: "push", Status: models.WebhookStatusPending}
+
+	t.Run("completed", func(t *testing.T) {
+		repo := NewMockRepository(receipt)
+		var seen models.WebhookStatus
+		processor := NewProcessor(repo, HandlerFunc(func(ctx context.Context, r *models.WebhookReceipt) error {
+			stored, _ := repo.GetByID(ctx, r.ID)
+			seen = stored.Status
+			return nil
+		}))
+
+		processed, err := processor.Process(ctx, "r-1")
+		if err != nil {
+			t.Fatalf("Process returned error: %v", err)
+		}
+		if seen != models.WebhookStatusProcessing {
+			t.Errorf("expected receipt to be processing while handled, got %s", seen)
+		}
+		stored, _ := repo.GetByID(ctx, "r-1")
+		if processed.Status != models.WebhookStatusCompleted || stored.Status != models.WebhookStatusCompleted {
+			t.Errorf("expected completed receipt, got %s", stored.Status)
+		}
+
+		// Completed receipts are not handled again
+		seen = ""
+		if _, err := processor.Process(ctx, "r-1"); err != nil || seen != "" {
+			t.Errorf("expected completed receipt to be skipped, got %v", err)
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		repo := NewMockRepository(receipt)
+		processor := NewProcessor(repo, HandlerFunc(func(ctx context.Context, r *models.WebhookReceipt) error {
+			return errors.New("downstream unavailable")
+		}))
+
+		if _, err := processor.Process(ctx, "r-1"); err == nil {
+			t.Fatal("expected error")
+		}
+		stored, _ := repo.GetByID(ctx, "r-1")
+		if stored.Status != models.WebhookStatusFailed || stored.Error != "downstream unavailable" {
+			t.Errorf("expected failed receipt with error, got %s %q", stored.Status, stored.Error)
+		}
+
+		// Every processing attempt is recorded in the receipt's history
+		if _, err := processor.Process(ctx, "r-1"); err == nil {
+			t.Fatal("expected error")
+		}
+		want := []models.WebhookStatus{models.WebhookStatusProcessing, models.WebhookStatusFailed, models.WebhookStatusProcessing, models.WebhookStatusFailed}
+		if history := repo.History("r-1"); !reflect.DeepEqual(history, want) {
+			t.Errorf("expected history %v, got %v", want, history)
+		}
+	})
+
+	t.Run("ignored", func(t *testing.T) {
+		repo := NewMockRepository(receipt)
+		processor := NewProcessor(repo, NewRegistry())
+
+		processed, err := processor.Process(ctx, "r-1")
+		if err != nil {
+			t.Fatalf("Process returned error: %v", err)
+		}
+		if processed.Status != models.WebhookStatusIgnored {
+			t.Errorf("expected ignored receipt, got %s", processed.Status)
+		}
+	})
+
+	t.Run("missing receipt", func(t *testing.T) {
+		processor := NewProcessor(NewMockRepository(), LogHandler())
+		if _, err := processor.Process(ctx, "missing"); !errors.Is(err, ErrReceiptNotFound) {
+			t.Errorf("expected ErrReceiptNotFound, got %v", err)
+		}
+	})
+}
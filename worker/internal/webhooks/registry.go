@@ -0,0 +1,125 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/dustinleblanc/go-bespin-worker/pkg/models"
+)
+
+// route is a handler registered for a source and event pattern
+type route struct {
+	pattern string
+	source  string
+	event   string
+	handler WebhookHandler
+}
+
+// Registry routes webhook receipts to the handler registered for their source
+// and event. It implements WebhookHandler, so it can be handed to a Processor.
+type Registry struct {
+	mu       sync.RWMutex
+	routes   []route
+	fallback WebhookHandler
+}
+
+// NewRegistry creates a new registry without handlers
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register registers a handler for a "<source>/<event>" pattern. Either part
+// may use the wildcards of path.Match, e.g. github/* for every GitHub event,
+// stripe/invoice.* for every Stripe invoice event or */ping for ping events of
+// every source. A pattern without a slash matches every event of a source.
+func (r *Registry) Register(pattern string, handler WebhookHandler) error {
+	source, event, found := strings.Cut(pattern, "/")
+	if !found {
+		event = "*"
+	}
+	if source == "" || event == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidPattern, pattern)
+	}
+	for _, part := range []string{source, event} {
+		if _, err := path.Match(part, ""); err != nil {
+			return fmt.Errorf("%w: %q: %v", ErrInvalidPattern, pattern, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, route{pattern: pattern, source: source, event: event, handler: handler})
+	return nil
+}
+
+// SetDefault sets the handler of receipts no registered pattern matches.
+// Without a default handler such receipts are ignored.
+func (r *Registry) SetDefault(handler WebhookHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = handler
+}
+
+// Lookup finds the handler of a source and event and the pattern it was
+// registered with. When several patterns match, an exact source beats a
+// wildcard one and then the pattern with the most literal characters wins;
+// ties go to the pattern registered first. The default handler is returned
+// with an empty pattern.
+func (r *Registry) Lookup(source, event string) (WebhookHandler, string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best *route
+	bestScore := -1
+	for i := range r.routes {
+		rt := &r.routes[i]
+		if !match(rt.source, source) || !match(rt.event, event) {
+			continue
+		}
+		if score := specificity(rt); score > bestScore {
+			best, bestScore = rt, score
+		}
+	}
+
+	if best != nil {
+		return best.handler, best.pattern, true
+	}
+	if r.fallback != nil {
+		return r.fallback, "", true
+	}
+	return nil, "", false
+}
+
+// Handle passes a receipt to the handler of its source and event. Receipts
+// without a handler are ignored.
+func (r *Registry) Handle(ctx context.Context, receipt *models.WebhookReceipt) error {
+	handler, _, ok := r.Lookup(receipt.Source, receipt.Event)
+	if !ok {
+		return fmt.Errorf("%w: no handler for %s/%s", ErrIgnored, receipt.Source, receipt.Event)
+	}
+	return handler.Handle(ctx, receipt)
+}
+
+// match checks if name matches a pattern part
+func match(pattern, name string) bool {
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// specificity ranks how specific a route is. Exact sources rank above every
+// wildcard source, then literal characters count.
+func specificity(rt *route) int {
+	score := literals(rt.source) + literals(rt.event)
+	if literals(rt.source) == len(rt.source) {
+		score += 1 << 16
+	}
+	return score
+}
+
+// literals counts the characters of a pattern part that are not wildcards
+func literals(pattern string) int {
+	return len(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
+}
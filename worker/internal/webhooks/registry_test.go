@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dustinleblanc/go-bespin-worker/pkg/models"
+)
+
+// named returns a handler that records its name when it handles a receipt
+func named(name string, handled *string) WebhookHandler {
+	return HandlerFunc(func(ctx context.Context, receipt *models.WebhookReceipt) error {
+		*handled = name
+		return nil
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	var handled string
+
+	registry := NewRegistry()
+	for _, pattern := range []string{"github/*", "github/push", "stripe/invoice.*", "stripe/invoice.paid", "*/ping", "sendgrid"} {
+		if err := registry.Register(pattern, named(pattern, &handled)); err != nil {
+			t.Fatalf("Register(%q) returned error: %v", pattern, err)
+		}
+	}
+
+	tests := []struct {
+		source, event string
+		want          string
+	}{
+		{"github", "push", "github/push"},
+		{"github", "pull_request", "github/*"},
+		{"github", "ping", "github/*"},
+		{"stripe", "invoice.paid", "stripe/invoice.paid"},
+		{"stripe", "invoice.payment_failed", "stripe/invoice.*"},
+		{"test", "ping", "*/ping"},
+		{"sendgrid", "bounce", "sendgrid"},
+	}
+	for _, tt := range tests {
+		handled = ""
+		if err := registry.Handle(ctx, &models.WebhookReceipt{Source: tt.source, Event: tt.event}); err != nil {
+			t.Errorf("%s/%s: Handle returned error: %v", tt.source, tt.event, err)
+		}
+		if handled != tt.want {
+			t.Errorf("%s/%s: expected %s to handle the receipt, got %q", tt.source, tt.event, tt.want, handled)
+		}
+	}
+
+	// Unmatched receipts are ignored until a default handler is set
+	err := registry.Handle(ctx, &models.WebhookReceipt{Source: "stripe", Event: "charge.refunded"})
+	if !errors.Is(err, ErrIgnored) {
+		t.Errorf("expected ErrIgnored, got %v", err)
+	}
+	registry.SetDefault(named("default", &handled))
+	if err := registry.Handle(ctx, &models.WebhookReceipt{Source: "stripe", Event: "charge.refunded"}); err != nil || handled != "default" {
+		t.Errorf("expected default handler, got %q (%v)", handled, err)
+	}
+
+	for _, pattern := range []string{"", "/push", "github/", "github/[push"} {
+		if err := registry.Register(pattern, LogHandler()); !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("Register(%q): expected ErrInvalidPattern, got %v", pattern, err)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package webhooks
+
+import (
+	"context"
+
+	"github.com/dustinleblanc/go-bespin-worker/pkg/models"
+)
+
+// Repository defines the interface for webhook receipt storage
+type Repository interface {
+	// GetByID retrieves a webhook receipt by ID
+	GetByID(ctx context.Context, id string) (*models.WebhookReceipt, error)
+
+	// Update updates the status of a webhook receipt and records it in the
+	// receipt's history
+	Update(ctx context.Context, receipt *models.WebhookReceipt) error
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"github.com/dustinleblanc/go-bespin-contract/webhooks"
+)
+
+// WebhookStatus represents the status of a webhook receipt, which the API and
+// the worker share through the contract
+type WebhookStatus = webhooks.Status
+
+const (
+	// WebhookStatusPending indicates the webhook is pending processing
+	WebhookStatusPending = webhooks.StatusPending
+	// WebhookStatusProcessing indicates the webhook is being processed
+	WebhookStatusProcessing = webhooks.StatusProcessing
+	// WebhookStatusCompleted indicates the webhook has been processed successfully
+	WebhookStatusCompleted = webhooks.StatusCompleted
+	// WebhookStatusFailed indicates the webhook processing failed
+	WebhookStatusFailed = webhooks.StatusFailed
+	// WebhookStatusIgnored indicates the worker has no handler for the webhook
+	WebhookStatusIgnored = webhooks.StatusIgnored
+)
+
+// WebhookReceipt represents a webhook receipt stored by the API. The API owns
+// the receipts table; the worker only reads receipts and updates their status.
+type WebhookReceipt struct {
+	ID        string        `json:"id" gorm:"primaryKey"`
+	Source    string        `json:"source"`
+	Event     string        `json:"event"`
+	Payload   []byte        `json:"payload"`
+	Signature string        `json:"signature"`
+	Status    WebhookStatus `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// WebhookStatusChange records a status a webhook receipt moved to. The API
+// owns the status changes table; the worker adds to it when it updates the
+// status of a receipt.
+type WebhookStatusChange struct {
+	ID        uint          `json:"-" gorm:"primaryKey"`
+	ReceiptID string        `json:"-"`
+	Status    WebhookStatus `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// TableName overrides the table name used by GORM
+func (WebhookReceipt) TableName() string {
+	return webhooks.ReceiptsTable
+}
+
+// TableName overrides the table name used by GORM
+func (WebhookStatusChange) TableName() string {
+	return webhooks.StatusChangesTable
+}
+
+// StatusChange returns the history entry of the receipt's current status
+func (r *WebhookReceipt) StatusChange() *WebhookStatusChange {
+	return &WebhookStatusChange{
+		ReceiptID: r.ID,
+		Status:    r.Status,
+		Error:     r.Error,
+		CreatedAt: r.UpdatedAt,
+	}
+}
+
+// SetStatus sets the status of the webhook receipt
+func (r *WebhookReceipt) SetStatus(status WebhookStatus, err error) {
+	r.Status = status
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		r.Error = ""
+	}
+	r.UpdatedAt = time.Now()
+}
+
+// IsComplete returns true if the webhook receipt has been processed
+// successfully or ignored, so it must not be processed again
+func (r *WebhookReceipt) IsComplete() bool {
+	return r.Status.IsComplete()
+}